@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"stripe-go-spike/internal/audit"
+	"stripe-go-spike/internal/db"
+)
+
+const usage = `usage: audit <command> [flags]
+
+commands:
+  export   write audit events for a date range as CSV or NDJSON
+  archive  move events past their retention to compressed NDJSON files
+  import   re-insert events from archive files
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "export":
+		err = runExport(os.Args[2:])
+	case "archive":
+		err = runArchive(os.Args[2:])
+	case "import":
+		err = runImport(os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatalf("%s: %v", os.Args[1], err)
+	}
+}
+
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", string(audit.FormatCSV), "output format: csv or ndjson")
+	from := fs.String("from", "", "inclusive start (RFC3339 or YYYY-MM-DD)")
+	to := fs.String("to", "", "exclusive end (RFC3339 or YYYY-MM-DD)")
+	columns := fs.String("columns", "", "comma-separated columns; payload fields as payload.<path>")
+	out := fs.String("out", "", "output file (default stdout)")
+	subsystem := fs.String("subsystem", "", "only events from this subsystem")
+	eventType := fs.String("event-type", "", "only events of this type")
+	_ = fs.Parse(args)
+
+	fromTime, err := audit.ParseExportTime(*from)
+	if err != nil {
+		return err
+	}
+	toTime, err := audit.ParseExportTime(*to)
+	if err != nil {
+		return err
+	}
+
+	// Connect based on env (TURSO_DATABASE_URL for Turso, otherwise local SQLite)
+	database, err := db.NewConnection()
+	if err != nil {
+		return fmt.Errorf("db connect: %w", err)
+	}
+	defer database.Close()
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	n, err := audit.NewService(db.New(database)).Export(context.Background(), w, audit.ExportOptions{
+		Format:  audit.ExportFormat(*format),
+		From:    fromTime,
+		To:      toTime,
+		Columns: audit.ParseExportColumns(*columns),
+		Filter:  audit.Filter{Subsystem: *subsystem, EventType: *eventType},
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("exported %d audit events", n)
+	return nil
+}
+
+func runArchive(args []string) error {
+	fs := flag.NewFlagSet("archive", flag.ExitOnError)
+	policy := fs.String("policy", os.Getenv("AUDIT_RETENTION"), "retention rules, e.g. webhook.received=30d,transaction.*=7y")
+	dir := fs.String("dir", "_data/audit-archive", "directory for archive files")
+	_ = fs.Parse(args)
+
+	if strings.TrimSpace(*policy) == "" {
+		return fmt.Errorf("no retention policy: pass -policy or set AUDIT_RETENTION")
+	}
+	p, err := audit.ParseRetentionPolicy(*policy)
+	if err != nil {
+		return err
+	}
+
+	database, err := db.NewConnection()
+	if err != nil {
+		return fmt.Errorf("db connect: %w", err)
+	}
+	defer database.Close()
+
+	archiver := &audit.Archiver{Queries: db.New(database), Policy: p, Dir: *dir}
+	result, err := archiver.Run(context.Background())
+	if err != nil {
+		return err
+	}
+	if result.File == "" {
+		log.Printf("no expired audit events")
+		return nil
+	}
+	log.Printf("archived %d audit events to %s (%d deleted)", result.Archived, result.File, result.Deleted)
+	return nil
+}
+
+func runImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	_ = fs.Parse(args)
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: audit import <archive.ndjson.gz>...")
+	}
+
+	database, err := db.NewConnection()
+	if err != nil {
+		return fmt.Errorf("db connect: %w", err)
+	}
+	defer database.Close()
+	queries := db.New(database)
+
+	for _, name := range fs.Args() {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		n, err := audit.ImportArchive(context.Background(), queries, f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		log.Printf("imported %d audit events from %s", n, name)
+	}
+	return nil
+}
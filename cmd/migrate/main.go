@@ -1,23 +1,186 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"os"
+	"strconv"
 
 	"stripe-go-spike/internal/db"
 )
 
+const usage = `usage: migrate <command> [N]
+
+commands:
+  up [N]       apply all pending migrations, or only the next N
+  down [N]     roll back the last N applied migrations (default 1)
+  redo         roll back the last applied migration and apply it again
+  status       list migrations and whether they are applied or modified
+  dry-run [N]  print the migrations up would apply without running them
+  version      print the last applied migration
+  history      list every recorded up and down run
+`
+
 func main() {
-	// Connect based on env (TURSO_DATABASE_URL for Turso, otherwise local SQLite)
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	n, err := parseCount(os.Args[2:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n\n%s", err, usage)
+		os.Exit(2)
+	}
+
+	// Connect based on env (DATABASE_URL for Postgres, TURSO_DATABASE_URL for Turso,
+	// otherwise local SQLite)
 	database, err := db.NewConnection()
 	if err != nil {
 		log.Fatalf("db connect: %v", err)
 	}
 	defer database.Close()
 
-	mfs := os.DirFS("db/migrations")
-	if err := db.RunMigrations(database, mfs, ""); err != nil {
-		log.Fatalf("migrations: %v", err)
+	ctx := context.Background()
+	m := db.NewEmbeddedMigrator(database)
+
+	// Changes take the same lock as servers started with RUN_MIGRATION=true
+	lockOpts, err := db.LockOptionsFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	locked := func(run func(ctx context.Context) error) error {
+		return m.WithLock(ctx, lockOpts, run)
+	}
+
+	switch os.Args[1] {
+	case "up":
+		err = locked(func(ctx context.Context) error { return runUp(ctx, m, n) })
+	case "down":
+		err = locked(func(ctx context.Context) error { return runDown(ctx, m, n) })
+	case "redo":
+		err = locked(func(ctx context.Context) error { return runRedo(ctx, m) })
+	case "status":
+		err = runStatus(ctx, m)
+	case "dry-run":
+		err = runDryRun(ctx, m, n)
+	case "version":
+		err = runVersion(ctx, m)
+	case "history":
+		err = runHistory(ctx, m)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatalf("%s: %v", os.Args[1], err)
+	}
+}
+
+// parseCount reads the optional step count; 0 means all.
+func parseCount(args []string) (int, error) {
+	if len(args) == 0 {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid count %q", args[0])
+	}
+	return n, nil
+}
+
+func runUp(ctx context.Context, m *db.Migrator, n int) error {
+	applied, err := m.Up(ctx, n)
+	for _, name := range applied {
+		log.Printf("applied %s", name)
+	}
+	if err != nil {
+		return err
+	}
+	if len(applied) == 0 {
+		log.Println("no pending migrations")
+	}
+	return nil
+}
+
+func runDown(ctx context.Context, m *db.Migrator, n int) error {
+	rolledBack, err := m.Down(ctx, n)
+	for _, name := range rolledBack {
+		log.Printf("rolled back %s", name)
+	}
+	return err
+}
+
+func runRedo(ctx context.Context, m *db.Migrator) error {
+	name, err := m.Redo(ctx)
+	if err != nil {
+		return err
+	}
+	log.Printf("redid %s", name)
+	return nil
+}
+
+func runStatus(ctx context.Context, m *db.Migrator) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	for _, st := range statuses {
+		state := "pending"
+		switch {
+		case st.Missing:
+			state = "applied " + st.AppliedAt + " (file missing)"
+		case st.Modified:
+			state = "applied " + st.AppliedAt + " (MODIFIED)"
+		case st.Applied:
+			state = "applied " + st.AppliedAt
+		}
+		if !st.Reversible && !st.Missing {
+			state += " (irreversible)"
+		}
+		fmt.Printf("%-40s %s\n", st.Name, state)
+	}
+	return nil
+}
+
+func runDryRun(ctx context.Context, m *db.Migrator, n int) error {
+	pending, err := m.Pending(ctx, n)
+	if err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		fmt.Println("-- no pending migrations")
+	}
+	for _, mig := range pending {
+		fmt.Printf("-- %s (sha256 %s)\n%s\n", mig.Name, mig.Checksum, mig.SQL)
+	}
+	return nil
+}
+
+func runVersion(ctx context.Context, m *db.Migrator) error {
+	version, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+	if version == "" {
+		version = "none"
+	}
+	fmt.Println(version)
+	return nil
+}
+
+func runHistory(ctx context.Context, m *db.Migrator) error {
+	runs, err := m.History(ctx)
+	if err != nil {
+		return err
+	}
+	for _, run := range runs {
+		line := fmt.Sprintf("%s  %-4s  %s", run.RanAt, run.Direction, run.Name)
+		if run.Error != "" {
+			line += "  FAILED: " + run.Error
+		}
+		fmt.Println(line)
 	}
-	log.Println("migrations applied successfully")
+	return nil
 }
@@ -0,0 +1,112 @@
+// Command reconcile compares transactions with Stripe for a time window and prints
+// the mismatches, optionally repairing drifted statuses.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"stripe-go-spike/internal/audit"
+	"stripe-go-spike/internal/db"
+	"stripe-go-spike/internal/payments"
+	"stripe-go-spike/internal/reconcile"
+)
+
+func main() {
+	from := flag.String("from", "", "inclusive start (RFC3339 or YYYY-MM-DD; default: -since before -to)")
+	to := flag.String("to", "", "exclusive end (RFC3339 or YYYY-MM-DD; default: now)")
+	since := flag.Duration("since", 24*time.Hour, "window length when -from is not set")
+	repair := flag.Bool("repair", false, "repair drifted transactions and write audit events for each fix")
+	format := flag.String("format", "text", "report format: text or json")
+	flag.Parse()
+
+	if err := run(*from, *to, *since, *repair, *format); err != nil {
+		log.Fatalf("reconcile: %v", err)
+	}
+}
+
+func run(from, to string, since time.Duration, repair bool, format string) error {
+	if format != "text" && format != "json" {
+		return fmt.Errorf("unknown format %q", format)
+	}
+	secretKey := os.Getenv("STRIPE_SECRET_KEY")
+	if secretKey == "" {
+		return fmt.Errorf("STRIPE_SECRET_KEY is not set")
+	}
+
+	window := payments.Window{To: time.Now()}
+	var err error
+	if to != "" {
+		if window.To, err = audit.ParseExportTime(to); err != nil {
+			return err
+		}
+	}
+	window.From = window.To.Add(-since)
+	if from != "" {
+		if window.From, err = audit.ParseExportTime(from); err != nil {
+			return err
+		}
+	}
+
+	database, err := db.NewConnection()
+	if err != nil {
+		return fmt.Errorf("db connect: %w", err)
+	}
+	defer database.Close()
+	queries := db.New(database)
+
+	reconciler := &reconcile.Reconciler{
+		Gateway: payments.NewStripeGateway(secretKey),
+		Store:   db.NewStore(database, queries),
+		Audit:   audit.NewService(queries),
+		Repair:  repair,
+	}
+	report, err := reconciler.Run(context.Background(), window)
+	if err != nil {
+		return err
+	}
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	return writeText(os.Stdout, report)
+}
+
+func writeText(w io.Writer, report reconcile.Report) error {
+	fmt.Fprintf(w, "%s to %s: %d sessions, %d payment intents, %d refunds, %d transactions\n",
+		report.From.Format(time.RFC3339), report.To.Format(time.RFC3339),
+		report.Sessions, report.PaymentIntents, report.Refunds, report.Transactions)
+	if len(report.Mismatches) == 0 {
+		fmt.Fprintln(w, "no mismatches")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tTRANSACTION\tSESSION\tPAYMENT INTENT\tLOCAL\tSTRIPE\tREPAIR\tDETAIL")
+	for _, m := range report.Mismatches {
+		repair := "-"
+		switch {
+		case m.Repaired:
+			repair = "repaired"
+		case m.RepairError != "":
+			repair = "failed: " + m.RepairError
+		case m.Repairable:
+			repair = "repairable"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", m.Kind, m.TransactionID, m.SessionID,
+			m.PaymentIntentID, m.LocalStatus, m.StripeStatus, repair, m.Detail)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "%d mismatches, %d repaired\n", len(report.Mismatches), report.Repaired())
+	return nil
+}
@@ -1,17 +1,31 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"embed"
+	"errors"
+	"fmt"
 	"log"
 	"net"
+	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 
 	"stripe-go-spike/internal/api"
+	"stripe-go-spike/internal/audit"
 	dbpkg "stripe-go-spike/internal/db"
+	"stripe-go-spike/internal/outbound"
+	"stripe-go-spike/internal/outbox"
 	"stripe-go-spike/internal/payments"
+	"stripe-go-spike/internal/reconcile"
+	"stripe-go-spike/internal/scheduler"
 )
 
 //go:embed frontend
@@ -40,20 +54,67 @@ func main() {
 	queries := dbpkg.New(database)
 
 	// Initialize the payments service
-	payService := payments.NewService(payments.Config{
-		SecretKey:      os.Getenv("STRIPE_SECRET_KEY"),
-		PublishableKey: os.Getenv("STRIPE_PUBLISHABLE_KEY"),
-		WebhookSecret:  os.Getenv("STRIPE_WEBHOOK_SECRET"),
-	})
+	payConfig, err := paymentsConfig()
+	if err != nil {
+		log.Fatalf("invalid payments settings: %v", err)
+	}
+	payService := payments.NewService(payConfig)
+
+	// Initialize the audit service (buffered unless AUDIT_ASYNC=false)
+	auditService, err := newAuditService(queries)
+	if err != nil {
+		log.Fatalf("failed to initialize audit service: %v", err)
+	}
 
 	// Create and run the Gin server
-	router := api.NewRouter(payService, database, queries, frontendAssets)
+	router := api.NewRouter(payService, database, queries, auditService, frontendAssets)
+
+	srv := &http.Server{
+		Addr:    getServerAddr(),
+		Handler: router,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Background jobs
+	jobs := scheduler.New()
+	if err := scheduleAuditRetention(jobs, queries, auditService); err != nil {
+		log.Fatalf("invalid audit retention settings: %v", err)
+	}
+	if err := scheduleReconciliation(jobs, database, queries, auditService); err != nil {
+		log.Fatalf("invalid reconciliation settings: %v", err)
+	}
+	if err := schedulePendingSweep(jobs, database, queries, auditService, payService); err != nil {
+		log.Fatalf("invalid pending sweep settings: %v", err)
+	}
+	if err := scheduleOutboundWebhooks(jobs, database, queries, auditService); err != nil {
+		log.Fatalf("invalid outbound webhook settings: %v", err)
+	}
+	if err := scheduleOutboxRelay(jobs, database, queries, auditService); err != nil {
+		log.Fatalf("invalid outbox settings: %v", err)
+	}
+	jobs.Start(ctx)
+
+	go func() {
+		log.Printf("Starting server on %s", srv.Addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("failed to run server: %v", err)
+		}
+	}()
 
-	addr := getServerAddr()
+	<-ctx.Done()
+	log.Printf("Shutting down server")
 
-	log.Printf("Starting server on %s", addr)
-	if err := router.Run(addr); err != nil {
-		log.Fatalf("failed to run server: %v", err)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("server shutdown: %v", err)
+	}
+	jobs.Stop()
+	// Flush buffered audit events before the database is closed
+	if err := auditService.Close(shutdownCtx); err != nil {
+		log.Printf("audit flush: %v", err)
 	}
 }
 
@@ -103,8 +164,350 @@ func runMigrations() error {
 	}
 	defer database.Close()
 
-	mfs := os.DirFS("db/migrations")
-	return dbpkg.RunMigrations(database, mfs, "")
+	// Replicas starting together take turns; with MIGRATION_LOCK_WAIT=0 the ones
+	// that lose skip migrating when the schema is already current and otherwise
+	// exit rather than serve before the lock holder has created it
+	opts, err := dbpkg.LockOptionsFromEnv()
+	if err != nil {
+		return err
+	}
+	m := dbpkg.NewEmbeddedMigrator(database)
+	err = m.WithLock(context.Background(), opts, func(ctx context.Context) error {
+		_, err := m.Up(ctx, 0)
+		return err
+	})
+	if errors.Is(err, dbpkg.ErrLockHeld) && opts.Wait == 0 {
+		pending, pendingErr := m.Pending(context.Background(), 0)
+		if pendingErr != nil {
+			return pendingErr
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("%w and %d migrations are pending", err, len(pending))
+		}
+		log.Printf("another instance is running migrations and the schema is current, skipping")
+		return nil
+	}
+	return err
+}
+
+// paymentsConfig reads the Stripe keys and checkout settings from the environment
+// and validates them.
+func paymentsConfig() (payments.Config, error) {
+	cfg := payments.Config{
+		SecretKey:            os.Getenv("STRIPE_SECRET_KEY"),
+		PublishableKey:       os.Getenv("STRIPE_PUBLISHABLE_KEY"),
+		WebhookSecret:        os.Getenv("STRIPE_WEBHOOK_SECRET"),
+		ConnectWebhookSecret: os.Getenv("STRIPE_CONNECT_WEBHOOK_SECRET"),
+		BaseURL:              os.Getenv("BASE_URL"),
+		SuccessURL:           os.Getenv("CHECKOUT_SUCCESS_URL"),
+		CancelURL:            os.Getenv("CHECKOUT_CANCEL_URL"),
+		Checkout: payments.CheckoutOptions{
+			PaymentMethodTypes:       splitList(os.Getenv("CHECKOUT_PAYMENT_METHOD_TYPES")),
+			Locale:                   os.Getenv("CHECKOUT_LOCALE"),
+			BillingAddressCollection: os.Getenv("CHECKOUT_BILLING_ADDRESS_COLLECTION"),
+			CustomText: payments.CustomText{
+				Submit:      os.Getenv("CHECKOUT_SUBMIT_MESSAGE"),
+				AfterSubmit: os.Getenv("CHECKOUT_AFTER_SUBMIT_MESSAGE"),
+			},
+		},
+		Overridable:       splitList(os.Getenv("CHECKOUT_OVERRIDABLE")),
+		ConnectFeeMode:    os.Getenv("CONNECT_FEE_MODE"),
+		ConnectReturnURL:  os.Getenv("CONNECT_RETURN_URL"),
+		ConnectRefreshURL: os.Getenv("CONNECT_REFRESH_URL"),
+	}
+	var err error
+	if v := os.Getenv("CHECKOUT_SESSION_TTL"); v != "" {
+		if cfg.SessionTTL, err = time.ParseDuration(v); err != nil {
+			return cfg, err
+		}
+	}
+	if v := os.Getenv("CHECKOUT_PHONE_NUMBER_COLLECTION"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.Checkout.PhoneNumberCollection = &enabled
+	}
+	if v := os.Getenv("STRIPE_AUTOMATIC_TAX"); v != "" {
+		if cfg.AutomaticTax, err = strconv.ParseBool(v); err != nil {
+			return cfg, err
+		}
+	}
+	return cfg, cfg.Validate()
+}
+
+// splitList splits a comma-separated setting, dropping empty items.
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// newAuditService builds the audit service from AUDIT_* environment variables.
+func newAuditService(queries *dbpkg.Queries) (*audit.Service, error) {
+	if v := os.Getenv("AUDIT_ASYNC"); v != "" {
+		async, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("AUDIT_ASYNC: %w", err)
+		}
+		if !async {
+			return audit.NewService(queries), nil
+		}
+	}
+
+	cfg := audit.DefaultWriterConfig()
+	var err error
+	if v := os.Getenv("AUDIT_BUFFER_SIZE"); v != "" {
+		if cfg.BufferSize, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("AUDIT_BUFFER_SIZE: %w", err)
+		}
+	}
+	if v := os.Getenv("AUDIT_BATCH_SIZE"); v != "" {
+		if cfg.BatchSize, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("AUDIT_BATCH_SIZE: %w", err)
+		}
+	}
+	if v := os.Getenv("AUDIT_FLUSH_INTERVAL"); v != "" {
+		if cfg.FlushInterval, err = time.ParseDuration(v); err != nil {
+			return nil, fmt.Errorf("AUDIT_FLUSH_INTERVAL: %w", err)
+		}
+	}
+	if v := os.Getenv("AUDIT_OVERFLOW_POLICY"); v != "" {
+		cfg.Overflow = audit.OverflowPolicy(v)
+	}
+	if v := os.Getenv("AUDIT_SPILL_PATH"); v != "" {
+		cfg.SpillPath = v
+	}
+	return audit.NewAsyncService(queries, cfg)
+}
+
+// scheduleAuditRetention registers the audit archival job when AUDIT_RETENTION is set.
+func scheduleAuditRetention(jobs *scheduler.Scheduler, queries *dbpkg.Queries, auditService *audit.Service) error {
+	spec := os.Getenv("AUDIT_RETENTION")
+	if spec == "" {
+		return nil
+	}
+	policy, err := audit.ParseRetentionPolicy(spec)
+	if err != nil {
+		return err
+	}
+	interval := 24 * time.Hour
+	if v := os.Getenv("AUDIT_RETENTION_INTERVAL"); v != "" {
+		if interval, err = time.ParseDuration(v); err != nil {
+			return err
+		}
+		if interval <= 0 {
+			return fmt.Errorf("AUDIT_RETENTION_INTERVAL must be positive, got %s", v)
+		}
+	}
+	dir := os.Getenv("AUDIT_ARCHIVE_DIR")
+	if dir == "" {
+		dir = "_data/audit-archive"
+	}
+
+	archiver := &audit.Archiver{Queries: queries, Policy: policy, Dir: dir}
+	return jobs.Every("audit-retention", interval, func(ctx context.Context) error {
+		result, err := archiver.Run(ctx)
+		if result.Archived > 0 || err != nil {
+			auditService.Log(ctx, audit.AuditArchived.New("Expired audit events archived", audit.ArchivePayload{
+				File:     result.File,
+				Archived: result.Archived,
+				Deleted:  result.Deleted,
+				Error:    errString(err),
+			}))
+		}
+		return err
+	})
+}
+
+// scheduleReconciliation registers the Stripe reconciliation job when
+// RECONCILE_INTERVAL is set. Each run covers RECONCILE_WINDOW (default 24h) up to
+// now and repairs drift only with RECONCILE_REPAIR=true.
+func scheduleReconciliation(jobs *scheduler.Scheduler, database *sql.DB, queries *dbpkg.Queries, auditService *audit.Service) error {
+	spec := os.Getenv("RECONCILE_INTERVAL")
+	if spec == "" {
+		return nil
+	}
+	interval, err := time.ParseDuration(spec)
+	if err != nil {
+		return err
+	}
+	if interval <= 0 {
+		return fmt.Errorf("RECONCILE_INTERVAL must be positive, got %s", spec)
+	}
+	window := 24 * time.Hour
+	if v := os.Getenv("RECONCILE_WINDOW"); v != "" {
+		if window, err = time.ParseDuration(v); err != nil {
+			return err
+		}
+		if window <= 0 {
+			return fmt.Errorf("RECONCILE_WINDOW must be positive, got %s", v)
+		}
+	}
+	repair := false
+	if v := os.Getenv("RECONCILE_REPAIR"); v != "" {
+		if repair, err = strconv.ParseBool(v); err != nil {
+			return err
+		}
+	}
+	secretKey := os.Getenv("STRIPE_SECRET_KEY")
+	if secretKey == "" {
+		log.Printf("RECONCILE_INTERVAL is set but STRIPE_SECRET_KEY is not; reconciliation disabled")
+		return nil
+	}
+
+	reconciler := &reconcile.Reconciler{
+		Gateway: payments.NewStripeGateway(secretKey),
+		Store:   dbpkg.NewStore(database, queries),
+		Audit:   auditService,
+		Repair:  repair,
+	}
+	return jobs.Every("reconcile", interval, func(ctx context.Context) error {
+		now := time.Now()
+		report, err := reconciler.Run(ctx, payments.Window{From: now.Add(-window), To: now})
+		if len(report.Mismatches) > 0 {
+			log.Printf("reconcile: %d mismatches, %d repaired", len(report.Mismatches), report.Repaired())
+		}
+		return err
+	})
+}
+
+// schedulePendingSweep registers the job that settles transactions left pending
+// longer than the checkout session TTL. It runs every PENDING_SWEEP_INTERVAL
+// (default 15m; 0 disables it). It doesn't run in mock mode: the mock sessions
+// are lost on restart, and the sweep would cancel their transactions as unknown.
+func schedulePendingSweep(jobs *scheduler.Scheduler, database *sql.DB, queries *dbpkg.Queries, auditService *audit.Service, payService *payments.Service) error {
+	interval := 15 * time.Minute
+	var err error
+	if v := os.Getenv("PENDING_SWEEP_INTERVAL"); v != "" {
+		if interval, err = time.ParseDuration(v); err != nil {
+			return err
+		}
+	}
+	if interval <= 0 {
+		return nil
+	}
+	if payService.MockMode() {
+		log.Printf("STRIPE_SECRET_KEY is not set; pending sweep disabled")
+		return nil
+	}
+
+	sweeper := &reconcile.Sweeper{
+		Gateway: payService.Gateway(),
+		Store:   dbpkg.NewStore(database, queries),
+		Audit:   auditService,
+		TTL:     payService.SessionTTL(),
+	}
+	return jobs.Every("pending-sweep", interval, func(ctx context.Context) error {
+		result, err := sweeper.Run(ctx)
+		if result.Completed+result.Cancelled+result.Failed > 0 {
+			log.Printf("pending sweep: %d checked, %d completed, %d cancelled, %d failed",
+				result.Checked, result.Completed, result.Cancelled, result.Failed)
+		}
+		return err
+	})
+}
+
+// scheduleOutboundWebhooks registers the job that sends queued outbound webhook
+// deliveries. It runs every OUTBOUND_WEBHOOK_INTERVAL (default 10s, 0 disables
+// it) and gives up on a delivery after OUTBOUND_WEBHOOK_MAX_ATTEMPTS (default 8).
+func scheduleOutboundWebhooks(jobs *scheduler.Scheduler, database *sql.DB, queries *dbpkg.Queries, auditService *audit.Service) error {
+	interval := 10 * time.Second
+	var err error
+	if v := os.Getenv("OUTBOUND_WEBHOOK_INTERVAL"); v != "" {
+		if interval, err = time.ParseDuration(v); err != nil {
+			return err
+		}
+	}
+	if interval <= 0 {
+		return nil
+	}
+	var maxAttempts int64
+	if v := os.Getenv("OUTBOUND_WEBHOOK_MAX_ATTEMPTS"); v != "" {
+		if maxAttempts, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return err
+		}
+	}
+
+	dispatcher := &outbound.Dispatcher{
+		Store:       dbpkg.NewStore(database, queries),
+		Audit:       auditService,
+		MaxAttempts: maxAttempts,
+	}
+	return jobs.Every("outbound-webhooks", interval, func(ctx context.Context) error {
+		result, err := dispatcher.Run(ctx)
+		if result.Retrying+result.Failed > 0 {
+			log.Printf("outbound webhooks: %d sent, %d succeeded, %d retrying, %d failed",
+				result.Attempted, result.Succeeded, result.Retrying, result.Failed)
+		}
+		return err
+	})
+}
+
+// scheduleOutboxRelay registers the job that publishes outbox messages to their
+// consumers: the outbound webhooks and, when OUTBOX_HTTP_URL is set, a POST
+// there. It runs every OUTBOX_RELAY_INTERVAL (default 5s, 0 disables it, and
+// with it the outbound webhooks), sets a message aside after OUTBOX_MAX_ATTEMPTS
+// (default 100) and deletes published messages after OUTBOX_RETENTION (default
+// 168h).
+func scheduleOutboxRelay(jobs *scheduler.Scheduler, database *sql.DB, queries *dbpkg.Queries, auditService *audit.Service) error {
+	interval := 5 * time.Second
+	var err error
+	if v := os.Getenv("OUTBOX_RELAY_INTERVAL"); v != "" {
+		if interval, err = time.ParseDuration(v); err != nil {
+			return err
+		}
+	}
+	if interval <= 0 {
+		log.Printf("outbox relay disabled; outbound webhooks won't be queued")
+		return nil
+	}
+	var maxAttempts int64
+	if v := os.Getenv("OUTBOX_MAX_ATTEMPTS"); v != "" {
+		if maxAttempts, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return err
+		}
+	}
+	var retention time.Duration
+	if v := os.Getenv("OUTBOX_RETENTION"); v != "" {
+		if retention, err = time.ParseDuration(v); err != nil {
+			return err
+		}
+	}
+
+	store := dbpkg.NewStore(database, queries)
+	mux := outbox.NewMux()
+	outbound.Subscribe(mux, store)
+	sink := outbox.Sink(mux)
+	if v := os.Getenv("OUTBOX_HTTP_URL"); v != "" {
+		sink = outbox.Fanout(mux, &outbox.HTTPSink{URL: v})
+	}
+
+	relay := &outbox.Relay{
+		Store:       store,
+		Sink:        sink,
+		Audit:       auditService,
+		MaxAttempts: maxAttempts,
+		Retention:   retention,
+	}
+	return jobs.Every("outbox-relay", interval, func(ctx context.Context) error {
+		result, err := relay.Run(ctx)
+		if result.Retrying+result.Dead > 0 {
+			log.Printf("outbox relay: %d published, %d retrying, %d dead", result.Published, result.Retrying, result.Dead)
+		}
+		return err
+	})
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
 }
 
 func getServerAddr() string {
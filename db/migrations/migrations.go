@@ -0,0 +1,11 @@
+// Package migrations embeds the SQL migration files so binaries can migrate a
+// database without the source tree. Each dialect has its own directory
+// (sqlite, postgres) with matching file names.
+package migrations
+
+import "embed"
+
+// FS holds the migration files under sqlite/ and postgres/.
+//
+//go:embed sqlite/*.sql postgres/*.sql
+var FS embed.FS
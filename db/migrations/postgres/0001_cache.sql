@@ -4,3 +4,6 @@ CREATE TABLE IF NOT EXISTS cache (
   key   TEXT PRIMARY KEY,
   value TEXT NOT NULL
 );
+
+-- +migrate Down
+DROP TABLE IF EXISTS cache;
@@ -0,0 +1,20 @@
+-- 0005_webhook_inbox.sql
+-- Inbox of verified Stripe webhook events and their processing outcome
+CREATE TABLE IF NOT EXISTS webhook_inbox (
+    id BIGSERIAL PRIMARY KEY,
+    stripe_event_id TEXT NOT NULL,           -- Stripe event ID (evt_...)
+    event_type TEXT NOT NULL,                -- e.g. 'checkout.session.completed'
+    session_id TEXT,                         -- checkout session referenced by the event
+    payment_intent_id TEXT,                  -- payment intent referenced by the event
+    status TEXT NOT NULL DEFAULT 'received', -- received, processed, failed
+    error TEXT,                              -- processing error (nullable)
+    received_at TEXT NOT NULL DEFAULT (to_char(now() AT TIME ZONE 'UTC', 'YYYY-MM-DD HH24:MI:SS')),
+    processed_at TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_inbox_stripe_event_id ON webhook_inbox(stripe_event_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_inbox_session_id ON webhook_inbox(session_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_inbox_payment_intent_id ON webhook_inbox(payment_intent_id);
+
+-- +migrate Down
+DROP TABLE IF EXISTS webhook_inbox;
@@ -0,0 +1,25 @@
+-- 0006_normalize_timestamps.sql
+-- Store every timestamp as UTC with millisecond precision (2006-01-02T15:04:05.000Z).
+-- Casting to timestamp (without time zone) reads both the old default format and
+-- the RFC3339 values handlers wrote; all of them were UTC.
+ALTER TABLE transactions ALTER COLUMN created_at SET DEFAULT (to_char(now() AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"'));
+ALTER TABLE transactions ALTER COLUMN updated_at SET DEFAULT (to_char(now() AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"'));
+UPDATE transactions SET
+    created_at = to_char(created_at::timestamp, 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"'),
+    updated_at = to_char(updated_at::timestamp, 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"'),
+    refund_date = to_char(refund_date::timestamp, 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"');
+
+ALTER TABLE audit_events ALTER COLUMN timestamp SET DEFAULT (to_char(now() AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"'));
+UPDATE audit_events SET timestamp = to_char(timestamp::timestamp, 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"');
+
+ALTER TABLE webhook_inbox ALTER COLUMN received_at SET DEFAULT (to_char(now() AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"'));
+UPDATE webhook_inbox SET
+    received_at = to_char(received_at::timestamp, 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"'),
+    processed_at = to_char(processed_at::timestamp, 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"');
+
+-- +migrate Down
+-- Values keep their millisecond format; only the defaults go back
+ALTER TABLE transactions ALTER COLUMN created_at SET DEFAULT (to_char(now() AT TIME ZONE 'UTC', 'YYYY-MM-DD HH24:MI:SS'));
+ALTER TABLE transactions ALTER COLUMN updated_at SET DEFAULT (to_char(now() AT TIME ZONE 'UTC', 'YYYY-MM-DD HH24:MI:SS'));
+ALTER TABLE audit_events ALTER COLUMN timestamp SET DEFAULT (to_char(now() AT TIME ZONE 'UTC', 'YYYY-MM-DD HH24:MI:SS'));
+ALTER TABLE webhook_inbox ALTER COLUMN received_at SET DEFAULT (to_char(now() AT TIME ZONE 'UTC', 'YYYY-MM-DD HH24:MI:SS'));
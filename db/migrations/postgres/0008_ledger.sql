@@ -0,0 +1,40 @@
+-- 0008_ledger.sql
+-- Double-entry ledger. Journal entries are append-only: corrections are new entries,
+-- never updates. Every entry's postings sum to zero (checked by internal/ledger).
+CREATE TABLE IF NOT EXISTS ledger_accounts (
+    id BIGSERIAL PRIMARY KEY,
+    code TEXT NOT NULL,                      -- e.g. 'stripe_balance', 'revenue', 'refunds'
+    type TEXT NOT NULL,                      -- asset, revenue, contra_revenue, expense
+    currency TEXT NOT NULL,                  -- ISO 4217 code; one account per code and currency
+    created_at TEXT NOT NULL DEFAULT (to_char(now() AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"')),
+    UNIQUE (code, currency)
+);
+
+CREATE TABLE IF NOT EXISTS journal_entries (
+    id BIGSERIAL PRIMARY KEY,
+    idempotency_key TEXT NOT NULL UNIQUE,    -- e.g. 'charge:<transaction id>', 'refund:<event id>'
+    kind TEXT NOT NULL,                      -- charge, refund, dispute, fee
+    transaction_id TEXT,                     -- transactions.id the entry belongs to
+    user_id TEXT,                            -- owner of the transaction
+    stripe_event_id TEXT,                    -- webhook event that caused the entry
+    description TEXT NOT NULL,
+    created_at TEXT NOT NULL DEFAULT (to_char(now() AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"'))
+);
+
+CREATE TABLE IF NOT EXISTS ledger_postings (
+    id BIGSERIAL PRIMARY KEY,
+    journal_entry_id BIGINT NOT NULL REFERENCES journal_entries(id),
+    account_id BIGINT NOT NULL REFERENCES ledger_accounts(id),
+    user_id TEXT,                            -- copied from the entry for per-user balances
+    amount BIGINT NOT NULL                   -- minor units; debits positive, credits negative
+);
+
+CREATE INDEX IF NOT EXISTS idx_journal_entries_transaction_id ON journal_entries(transaction_id);
+CREATE INDEX IF NOT EXISTS idx_ledger_postings_journal_entry_id ON ledger_postings(journal_entry_id);
+CREATE INDEX IF NOT EXISTS idx_ledger_postings_account_id ON ledger_postings(account_id);
+CREATE INDEX IF NOT EXISTS idx_ledger_postings_user_id ON ledger_postings(user_id);
+
+-- +migrate Down
+DROP TABLE IF EXISTS ledger_postings;
+DROP TABLE IF EXISTS journal_entries;
+DROP TABLE IF EXISTS ledger_accounts;
@@ -0,0 +1,52 @@
+-- 0013_outbound_webhooks.sql
+-- Outbound webhooks: our own downstream systems register endpoints and receive
+-- signed transaction events, with every delivery attempt kept.
+CREATE TABLE IF NOT EXISTS webhook_endpoints (
+    id BIGSERIAL PRIMARY KEY,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,                      -- HMAC-SHA256 signing key
+    event_types TEXT NOT NULL DEFAULT '',      -- comma-separated; empty for every type
+    description TEXT NOT NULL DEFAULT '',
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TEXT NOT NULL,
+    updated_at TEXT NOT NULL
+);
+
+-- One row per event and endpoint. event_key identifies the change (e.g. the
+-- transaction and its new status) so repeated Stripe events deliver once.
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    endpoint_id BIGINT NOT NULL REFERENCES webhook_endpoints(id),
+    event_id TEXT NOT NULL,
+    event_type TEXT NOT NULL,
+    event_key TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',    -- pending, succeeded, failed
+    attempts BIGINT NOT NULL DEFAULT 0,
+    next_attempt_at TEXT,                      -- null once succeeded or failed
+    last_status_code BIGINT,
+    last_error TEXT,
+    created_at TEXT NOT NULL,
+    updated_at TEXT NOT NULL,
+    UNIQUE (endpoint_id, event_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);
+
+CREATE TABLE IF NOT EXISTS webhook_delivery_attempts (
+    id BIGSERIAL PRIMARY KEY,
+    delivery_id BIGINT NOT NULL REFERENCES webhook_deliveries(id),
+    attempted_at TEXT NOT NULL,
+    status_code BIGINT,                        -- null when no response arrived
+    error TEXT,
+    duration_ms BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_delivery_attempts_delivery_id ON webhook_delivery_attempts(delivery_id);
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_webhook_delivery_attempts_delivery_id;
+DROP TABLE IF EXISTS webhook_delivery_attempts;
+DROP INDEX IF EXISTS idx_webhook_deliveries_due;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhook_endpoints;
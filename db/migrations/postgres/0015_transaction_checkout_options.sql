@@ -0,0 +1,7 @@
+-- 0015_transaction_checkout_options.sql
+-- The checkout option overrides a session was created with, as JSON ('' for
+-- none), so a session is only reused for a request with the same overrides
+ALTER TABLE transactions ADD COLUMN checkout_options TEXT NOT NULL DEFAULT '';
+
+-- +migrate Down
+ALTER TABLE transactions DROP COLUMN checkout_options;
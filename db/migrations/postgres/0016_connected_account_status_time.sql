@@ -0,0 +1,7 @@
+-- 0016_connected_account_status_time.sql
+-- When the account.updated event last applied to a connected account was
+-- created, so events Stripe delivers out of order can't roll its status back
+ALTER TABLE connected_accounts ADD COLUMN status_updated_at TEXT;
+
+-- +migrate Down
+ALTER TABLE connected_accounts DROP COLUMN status_updated_at;
@@ -0,0 +1,7 @@
+-- 0017_outbox_dead_letter.sql
+-- Set when a message failed its last attempt. A dead message is no longer
+-- retried and stops holding back the later messages of its key.
+ALTER TABLE outbox_messages ADD COLUMN dead_at TEXT;
+
+-- +migrate Down
+ALTER TABLE outbox_messages DROP COLUMN dead_at;
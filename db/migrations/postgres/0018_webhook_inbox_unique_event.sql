@@ -0,0 +1,12 @@
+-- 0018_webhook_inbox_unique_event.sql
+-- Stripe redelivers events; each is kept once, so a redelivery is recognised
+-- and skipped. Copies recorded before this migration are dropped, keeping the
+-- first.
+DELETE FROM webhook_inbox
+WHERE id NOT IN (SELECT MIN(id) FROM webhook_inbox GROUP BY stripe_event_id);
+DROP INDEX IF EXISTS idx_webhook_inbox_stripe_event_id;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_webhook_inbox_stripe_event_id ON webhook_inbox(stripe_event_id);
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_webhook_inbox_stripe_event_id;
+CREATE INDEX IF NOT EXISTS idx_webhook_inbox_stripe_event_id ON webhook_inbox(stripe_event_id);
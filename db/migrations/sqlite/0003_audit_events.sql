@@ -18,4 +18,6 @@ CREATE INDEX IF NOT EXISTS idx_audit_events_event_type ON audit_events(event_typ
 CREATE INDEX IF NOT EXISTS idx_audit_events_user_id ON audit_events(user_id);
 CREATE INDEX IF NOT EXISTS idx_audit_events_ref_id ON audit_events(ref_id);
 CREATE INDEX IF NOT EXISTS idx_audit_events_ref_id2 ON audit_events(ref_id2);
-CREATE INDEX IF NOT EXISTS idx_audit_events_subsystem_event_type ON audit_events(subsystem, event_type);
\ No newline at end of file
+CREATE INDEX IF NOT EXISTS idx_audit_events_subsystem_event_type ON audit_events(subsystem, event_type);
+-- +migrate Down
+DROP TABLE IF EXISTS audit_events;
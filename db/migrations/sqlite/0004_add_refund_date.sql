@@ -3,4 +3,7 @@
 ALTER TABLE transactions ADD COLUMN refund_date TEXT;
 
 -- Create index for refund date to support queries on refunded transactions
-CREATE INDEX IF NOT EXISTS idx_transactions_refund_date ON transactions(refund_date);
\ No newline at end of file
+CREATE INDEX IF NOT EXISTS idx_transactions_refund_date ON transactions(refund_date);
+-- +migrate Down
+DROP INDEX IF EXISTS idx_transactions_refund_date;
+ALTER TABLE transactions DROP COLUMN refund_date;
@@ -0,0 +1,141 @@
+-- 0006_normalize_timestamps.sql
+-- Store every timestamp as UTC with millisecond precision (2006-01-02T15:04:05.000Z).
+-- Handlers wrote RFC3339 and the column defaults wrote datetime('now'); both are
+-- rewritten here. SQLite can't change a column default in place, so the tables are
+-- rebuilt.
+CREATE TABLE transactions_new (
+    id TEXT PRIMARY KEY DEFAULT (lower(hex(randomblob(16)))),
+    user_id TEXT NOT NULL,
+    product_id TEXT NOT NULL,
+    product_name TEXT NOT NULL,
+    amount INTEGER NOT NULL, -- price in cents
+    stripe_session_id TEXT,
+    stripe_payment_intent_id TEXT,
+    status TEXT NOT NULL DEFAULT 'pending', -- pending, completed, failed, cancelled
+    created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+    updated_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+    refund_date TEXT
+);
+INSERT INTO transactions_new (id, user_id, product_id, product_name, amount, stripe_session_id, stripe_payment_intent_id, status, created_at, updated_at, refund_date)
+SELECT id, user_id, product_id, product_name, amount, stripe_session_id, stripe_payment_intent_id, status,
+    coalesce(strftime('%Y-%m-%dT%H:%M:%fZ', created_at), created_at),
+    coalesce(strftime('%Y-%m-%dT%H:%M:%fZ', updated_at), updated_at),
+    coalesce(strftime('%Y-%m-%dT%H:%M:%fZ', refund_date), refund_date)
+FROM transactions;
+DROP TABLE transactions;
+ALTER TABLE transactions_new RENAME TO transactions;
+CREATE INDEX IF NOT EXISTS idx_transactions_user_id ON transactions(user_id);
+CREATE INDEX IF NOT EXISTS idx_transactions_status ON transactions(status);
+CREATE INDEX IF NOT EXISTS idx_transactions_created_at ON transactions(created_at);
+CREATE INDEX IF NOT EXISTS idx_transactions_stripe_session_id ON transactions(stripe_session_id);
+CREATE INDEX IF NOT EXISTS idx_transactions_refund_date ON transactions(refund_date);
+
+CREATE TABLE audit_events_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    timestamp TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+    subsystem TEXT NOT NULL,          -- e.g., 'stripe', 'payment', 'user'
+    event_type TEXT NOT NULL,         -- e.g., 'webhook.received', 'transaction.created'
+    user_id TEXT,                     -- user identifier (nullable)
+    information TEXT,                 -- human-readable description
+    payload TEXT,                     -- JSON data (nullable)
+    ref_id TEXT,                      -- primary reference ID (e.g., payment_intent_id)
+    ref_id2 TEXT                      -- secondary reference ID (e.g., session_id)
+);
+INSERT INTO audit_events_new (id, timestamp, subsystem, event_type, user_id, information, payload, ref_id, ref_id2)
+SELECT id, coalesce(strftime('%Y-%m-%dT%H:%M:%fZ', timestamp), timestamp), subsystem, event_type, user_id, information, payload, ref_id, ref_id2
+FROM audit_events;
+DROP TABLE audit_events;
+ALTER TABLE audit_events_new RENAME TO audit_events;
+CREATE INDEX IF NOT EXISTS idx_audit_events_timestamp ON audit_events(timestamp);
+CREATE INDEX IF NOT EXISTS idx_audit_events_subsystem ON audit_events(subsystem);
+CREATE INDEX IF NOT EXISTS idx_audit_events_event_type ON audit_events(event_type);
+CREATE INDEX IF NOT EXISTS idx_audit_events_user_id ON audit_events(user_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_ref_id ON audit_events(ref_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_ref_id2 ON audit_events(ref_id2);
+CREATE INDEX IF NOT EXISTS idx_audit_events_subsystem_event_type ON audit_events(subsystem, event_type);
+
+CREATE TABLE webhook_inbox_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    stripe_event_id TEXT NOT NULL,           -- Stripe event ID (evt_...)
+    event_type TEXT NOT NULL,                -- e.g. 'checkout.session.completed'
+    session_id TEXT,                         -- checkout session referenced by the event
+    payment_intent_id TEXT,                  -- payment intent referenced by the event
+    status TEXT NOT NULL DEFAULT 'received', -- received, processed, failed
+    error TEXT,                              -- processing error (nullable)
+    received_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+    processed_at TEXT
+);
+INSERT INTO webhook_inbox_new (id, stripe_event_id, event_type, session_id, payment_intent_id, status, error, received_at, processed_at)
+SELECT id, stripe_event_id, event_type, session_id, payment_intent_id, status, error,
+    coalesce(strftime('%Y-%m-%dT%H:%M:%fZ', received_at), received_at),
+    coalesce(strftime('%Y-%m-%dT%H:%M:%fZ', processed_at), processed_at)
+FROM webhook_inbox;
+DROP TABLE webhook_inbox;
+ALTER TABLE webhook_inbox_new RENAME TO webhook_inbox;
+CREATE INDEX IF NOT EXISTS idx_webhook_inbox_stripe_event_id ON webhook_inbox(stripe_event_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_inbox_session_id ON webhook_inbox(session_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_inbox_payment_intent_id ON webhook_inbox(payment_intent_id);
+
+-- +migrate Down
+-- Values keep their millisecond format; only the defaults go back to datetime('now')
+CREATE TABLE transactions_old (
+    id TEXT PRIMARY KEY DEFAULT (lower(hex(randomblob(16)))),
+    user_id TEXT NOT NULL,
+    product_id TEXT NOT NULL,
+    product_name TEXT NOT NULL,
+    amount INTEGER NOT NULL,
+    stripe_session_id TEXT,
+    stripe_payment_intent_id TEXT,
+    status TEXT NOT NULL DEFAULT 'pending',
+    created_at TEXT NOT NULL DEFAULT (datetime('now')),
+    updated_at TEXT NOT NULL DEFAULT (datetime('now')),
+    refund_date TEXT
+);
+INSERT INTO transactions_old SELECT id, user_id, product_id, product_name, amount, stripe_session_id, stripe_payment_intent_id, status, created_at, updated_at, refund_date FROM transactions;
+DROP TABLE transactions;
+ALTER TABLE transactions_old RENAME TO transactions;
+CREATE INDEX IF NOT EXISTS idx_transactions_user_id ON transactions(user_id);
+CREATE INDEX IF NOT EXISTS idx_transactions_status ON transactions(status);
+CREATE INDEX IF NOT EXISTS idx_transactions_created_at ON transactions(created_at);
+CREATE INDEX IF NOT EXISTS idx_transactions_stripe_session_id ON transactions(stripe_session_id);
+CREATE INDEX IF NOT EXISTS idx_transactions_refund_date ON transactions(refund_date);
+
+CREATE TABLE audit_events_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    timestamp TEXT NOT NULL DEFAULT (datetime('now')),
+    subsystem TEXT NOT NULL,
+    event_type TEXT NOT NULL,
+    user_id TEXT,
+    information TEXT,
+    payload TEXT,
+    ref_id TEXT,
+    ref_id2 TEXT
+);
+INSERT INTO audit_events_old SELECT id, timestamp, subsystem, event_type, user_id, information, payload, ref_id, ref_id2 FROM audit_events;
+DROP TABLE audit_events;
+ALTER TABLE audit_events_old RENAME TO audit_events;
+CREATE INDEX IF NOT EXISTS idx_audit_events_timestamp ON audit_events(timestamp);
+CREATE INDEX IF NOT EXISTS idx_audit_events_subsystem ON audit_events(subsystem);
+CREATE INDEX IF NOT EXISTS idx_audit_events_event_type ON audit_events(event_type);
+CREATE INDEX IF NOT EXISTS idx_audit_events_user_id ON audit_events(user_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_ref_id ON audit_events(ref_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_ref_id2 ON audit_events(ref_id2);
+CREATE INDEX IF NOT EXISTS idx_audit_events_subsystem_event_type ON audit_events(subsystem, event_type);
+
+CREATE TABLE webhook_inbox_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    stripe_event_id TEXT NOT NULL,
+    event_type TEXT NOT NULL,
+    session_id TEXT,
+    payment_intent_id TEXT,
+    status TEXT NOT NULL DEFAULT 'received',
+    error TEXT,
+    received_at TEXT NOT NULL DEFAULT (datetime('now')),
+    processed_at TEXT
+);
+INSERT INTO webhook_inbox_old SELECT id, stripe_event_id, event_type, session_id, payment_intent_id, status, error, received_at, processed_at FROM webhook_inbox;
+DROP TABLE webhook_inbox;
+ALTER TABLE webhook_inbox_old RENAME TO webhook_inbox;
+CREATE INDEX IF NOT EXISTS idx_webhook_inbox_stripe_event_id ON webhook_inbox(stripe_event_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_inbox_session_id ON webhook_inbox(session_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_inbox_payment_intent_id ON webhook_inbox(payment_intent_id);
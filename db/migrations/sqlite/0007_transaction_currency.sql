@@ -0,0 +1,6 @@
+-- 0007_transaction_currency.sql
+-- ISO 4217 code (upper case) of transactions.amount; earlier rows were all USD
+ALTER TABLE transactions ADD COLUMN currency TEXT NOT NULL DEFAULT 'USD';
+
+-- +migrate Down
+ALTER TABLE transactions DROP COLUMN currency;
@@ -0,0 +1,7 @@
+-- 0009_transaction_expires_at.sql
+-- When the transaction's checkout session expires; null for rows created before
+-- sessions had an explicit expiry
+ALTER TABLE transactions ADD COLUMN expires_at TEXT;
+
+-- +migrate Down
+ALTER TABLE transactions DROP COLUMN expires_at;
@@ -0,0 +1,39 @@
+-- 0010_coupons.sql
+-- Local coupons, mirrored to Stripe coupons and promotion codes. A transaction
+-- that uses a coupon is its redemption.
+CREATE TABLE IF NOT EXISTS coupons (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    code TEXT NOT NULL UNIQUE,               -- upper case, entered by customers
+    percent_off INTEGER NOT NULL DEFAULT 0,  -- 1-100, or 0 for an amount-off coupon
+    amount_off INTEGER NOT NULL DEFAULT 0,   -- minor units of currency
+    currency TEXT,                           -- ISO 4217 code of amount_off
+    expires_at TEXT,                         -- last moment the code can be redeemed
+    max_redemptions INTEGER,                 -- across all users; null for no limit
+    per_user_limit INTEGER,                  -- per user; null for no limit
+    stripe_coupon_id TEXT,
+    stripe_promotion_code_id TEXT,
+    created_at TEXT NOT NULL,
+    updated_at TEXT NOT NULL,
+    archived_at TEXT                         -- archived coupons can't be redeemed
+);
+
+-- Products a coupon is restricted to; none means every product
+CREATE TABLE IF NOT EXISTS coupon_products (
+    coupon_id INTEGER NOT NULL REFERENCES coupons(id),
+    product_id TEXT NOT NULL,
+    PRIMARY KEY (coupon_id, product_id)
+);
+
+-- amount stays the charged amount; discount_amount is what the coupon took off.
+-- coupon_id has no foreign key so that SQLite can drop it again.
+ALTER TABLE transactions ADD COLUMN coupon_id INTEGER;
+ALTER TABLE transactions ADD COLUMN discount_amount INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_transactions_coupon_id ON transactions(coupon_id);
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_transactions_coupon_id;
+ALTER TABLE transactions DROP COLUMN discount_amount;
+ALTER TABLE transactions DROP COLUMN coupon_id;
+DROP TABLE IF EXISTS coupon_products;
+DROP TABLE IF EXISTS coupons;
@@ -0,0 +1,44 @@
+-- 0011_tax.sql
+-- Local tax rates for deployments without Stripe automatic tax, and the tax
+-- charged per line item and per transaction.
+CREATE TABLE IF NOT EXISTS tax_rates (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    country TEXT NOT NULL,                   -- ISO 3166-1 alpha-2, upper case
+    region TEXT NOT NULL DEFAULT '',         -- state or province code; '' for the whole country
+    tax_code TEXT NOT NULL DEFAULT '',       -- product tax code, e.g. 'txcd_99999999'; '' for any
+    name TEXT NOT NULL,                      -- shown to customers, e.g. 'VAT'
+    rate_bps INTEGER NOT NULL,               -- basis points: 1900 is 19%
+    stripe_tax_rate_id TEXT,
+    created_at TEXT NOT NULL,
+    archived_at TEXT                         -- archived rates no longer apply
+);
+
+CREATE INDEX IF NOT EXISTS idx_tax_rates_country ON tax_rates(country);
+
+-- What was charged for each product of a transaction. The line total is
+-- unit_amount * quantity - discount_amount + tax_amount.
+CREATE TABLE IF NOT EXISTS transaction_line_items (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    transaction_id TEXT NOT NULL REFERENCES transactions(id),
+    product_id TEXT NOT NULL,
+    description TEXT NOT NULL,
+    quantity INTEGER NOT NULL DEFAULT 1,
+    unit_amount INTEGER NOT NULL,            -- minor units, before discount and tax
+    currency TEXT NOT NULL,
+    discount_amount INTEGER NOT NULL DEFAULT 0,
+    tax_amount INTEGER NOT NULL DEFAULT 0,
+    tax_code TEXT NOT NULL DEFAULT '',
+    tax_rate_id INTEGER                      -- local rate applied; null with Stripe automatic tax
+);
+
+CREATE INDEX IF NOT EXISTS idx_transaction_line_items_transaction_id ON transaction_line_items(transaction_id);
+
+-- amount is the total charged, tax included; tax_amount is the tax in it
+ALTER TABLE transactions ADD COLUMN tax_amount INTEGER NOT NULL DEFAULT 0;
+
+-- +migrate Down
+ALTER TABLE transactions DROP COLUMN tax_amount;
+DROP INDEX IF EXISTS idx_transaction_line_items_transaction_id;
+DROP TABLE IF EXISTS transaction_line_items;
+DROP INDEX IF EXISTS idx_tax_rates_country;
+DROP TABLE IF EXISTS tax_rates;
@@ -0,0 +1,40 @@
+-- 0012_connect.sql
+-- Stripe Connect: partner vendors' connected accounts, the products they sell
+-- and the split of each transaction between the platform and the vendor.
+CREATE TABLE IF NOT EXISTS connected_accounts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    stripe_account_id TEXT NOT NULL UNIQUE,
+    name TEXT NOT NULL,
+    email TEXT NOT NULL DEFAULT '',
+    country TEXT NOT NULL,                          -- ISO 3166-1 alpha-2
+    fee_bps INTEGER NOT NULL DEFAULT 0,             -- platform's share in basis points
+    -- Kept in sync by account.updated webhooks; checkout needs charges_enabled
+    charges_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    payouts_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    details_submitted BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TEXT NOT NULL,
+    updated_at TEXT NOT NULL
+);
+
+-- Products sold by a vendor; other products are the platform's own
+CREATE TABLE IF NOT EXISTS product_accounts (
+    product_id TEXT PRIMARY KEY,
+    connected_account_id INTEGER NOT NULL REFERENCES connected_accounts(id)
+);
+
+-- Destination charges: the vendor receives transfer_amount and the platform
+-- keeps application_fee_amount (its fee plus any tax), which add up to amount.
+-- connected_account_id has no foreign key so that SQLite can drop it again.
+ALTER TABLE transactions ADD COLUMN connected_account_id INTEGER;
+ALTER TABLE transactions ADD COLUMN application_fee_amount INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE transactions ADD COLUMN transfer_amount INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_transactions_connected_account_id ON transactions(connected_account_id);
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_transactions_connected_account_id;
+ALTER TABLE transactions DROP COLUMN transfer_amount;
+ALTER TABLE transactions DROP COLUMN application_fee_amount;
+ALTER TABLE transactions DROP COLUMN connected_account_id;
+DROP TABLE IF EXISTS product_accounts;
+DROP TABLE IF EXISTS connected_accounts;
@@ -0,0 +1,23 @@
+-- 0014_outbox.sql
+-- Transactional outbox: side effects of a change are written in the same
+-- database transaction as the change, and a relay publishes them afterwards.
+CREATE TABLE IF NOT EXISTS outbox_messages (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    topic TEXT NOT NULL,                       -- e.g. transaction.completed
+    message_key TEXT NOT NULL,                 -- messages with the same key publish in order
+    dedupe_key TEXT NOT NULL UNIQUE,           -- identifies the change, so it is written once
+    payload TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TEXT NOT NULL,
+    published_at TEXT,                         -- null until every sink accepted it
+    last_error TEXT,
+    created_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_messages_due ON outbox_messages(published_at, next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_outbox_messages_key ON outbox_messages(message_key, id);
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_outbox_messages_key;
+DROP INDEX IF EXISTS idx_outbox_messages_due;
+DROP TABLE IF EXISTS outbox_messages;
@@ -1,4 +1,4 @@
--- name: CreateAuditEvent :exec
+-- name: CreateAuditEvent :one
 INSERT INTO audit_events (
     subsystem,
     event_type,
@@ -7,7 +7,8 @@ INSERT INTO audit_events (
     payload,
     ref_id,
     ref_id2
-) VALUES (?, ?, ?, ?, ?, ?, ?);
+) VALUES (?, ?, ?, ?, ?, ?, ?)
+RETURNING *;
 
 -- name: GetAuditEventsBySubsystem :many
 SELECT * FROM audit_events
@@ -54,4 +55,53 @@ LIMIT ? OFFSET ?;
 SELECT * FROM audit_events
 WHERE ref_id2 = ?
 ORDER BY timestamp DESC
-LIMIT ? OFFSET ?;
\ No newline at end of file
+LIMIT ? OFFSET ?;
+
+-- name: GetAuditEventsAfterID :many
+SELECT * FROM audit_events
+WHERE id > ?
+ORDER BY id ASC
+LIMIT ?;
+
+-- name: ListAuditEventsForExport :many
+-- Empty filters match every event; the others must all match.
+SELECT * FROM audit_events
+WHERE id > ?1 AND timestamp >= ?2 AND timestamp < ?3
+  AND (?4 = '' OR subsystem = ?4)
+  AND (?5 = '' OR event_type = ?5)
+  AND (?6 = '' OR user_id = ?6)
+  AND (?7 = '' OR ref_id = ?7)
+  AND (?8 = '' OR ref_id2 = ?8)
+ORDER BY id ASC
+LIMIT ?9;
+
+-- name: ImportAuditEvent :exec
+INSERT INTO audit_events (
+    id,
+    timestamp,
+    subsystem,
+    event_type,
+    user_id,
+    information,
+    payload,
+    ref_id,
+    ref_id2
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (id) DO NOTHING;
+
+-- name: ListAuditEventsByRefs :many
+SELECT * FROM audit_events
+WHERE ref_id IN (?1, ?2, ?3) OR ref_id2 IN (?1, ?2, ?3)
+ORDER BY id ASC
+LIMIT ?4;
+
+-- name: ListAuditEvents :many
+-- Empty filters match every event; the others must all match.
+SELECT * FROM audit_events
+WHERE (?1 = '' OR subsystem = ?1)
+  AND (?2 = '' OR event_type = ?2)
+  AND (?3 = '' OR user_id = ?3)
+  AND (?4 = '' OR ref_id = ?4)
+  AND (?5 = '' OR ref_id2 = ?5)
+ORDER BY timestamp DESC, id DESC
+LIMIT ?6 OFFSET ?7;
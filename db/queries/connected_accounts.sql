@@ -0,0 +1,52 @@
+-- name: CreateConnectedAccount :one
+INSERT INTO connected_accounts (stripe_account_id, name, email, country, fee_bps, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetConnectedAccount :one
+SELECT * FROM connected_accounts
+WHERE id = ?
+LIMIT 1;
+
+-- name: GetConnectedAccountByStripeID :one
+SELECT * FROM connected_accounts
+WHERE stripe_account_id = ?
+LIMIT 1;
+
+-- name: ListConnectedAccounts :many
+SELECT * FROM connected_accounts
+ORDER BY id
+LIMIT ? OFFSET ?;
+
+-- name: UpdateConnectedAccountFee :one
+UPDATE connected_accounts
+SET fee_bps = ?, updated_at = ?
+WHERE id = ?
+RETURNING *;
+
+-- name: UpdateConnectedAccountStatus :execrows
+-- Mirrors the capabilities Stripe reports in account.updated webhooks. An
+-- event created before the last one applied is stale and changes nothing.
+UPDATE connected_accounts
+SET charges_enabled = ?, payouts_enabled = ?, details_submitted = ?, status_updated_at = ?, updated_at = ?
+WHERE stripe_account_id = ? AND (status_updated_at IS NULL OR status_updated_at <= ?);
+
+-- name: SetProductAccount :exec
+INSERT INTO product_accounts (product_id, connected_account_id)
+VALUES (?, ?)
+ON CONFLICT (product_id) DO UPDATE SET connected_account_id = excluded.connected_account_id;
+
+-- name: DeleteProductAccount :exec
+DELETE FROM product_accounts
+WHERE product_id = ?;
+
+-- name: GetProductConnectedAccount :one
+-- The vendor selling a product; no rows for the platform's own products.
+SELECT connected_accounts.* FROM connected_accounts
+JOIN product_accounts ON product_accounts.connected_account_id = connected_accounts.id
+WHERE product_accounts.product_id = ?
+LIMIT 1;
+
+-- name: ListProductAccounts :many
+SELECT * FROM product_accounts
+ORDER BY product_id;
@@ -0,0 +1,65 @@
+-- name: CreateCoupon :one
+INSERT INTO coupons (code, percent_off, amount_off, currency, expires_at, max_redemptions, per_user_limit, stripe_coupon_id, stripe_promotion_code_id, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetCoupon :one
+SELECT * FROM coupons
+WHERE id = ?
+LIMIT 1;
+
+-- name: GetCouponByCode :one
+SELECT * FROM coupons
+WHERE code = ?
+LIMIT 1;
+
+-- name: GetCouponByStripePromotionCodeID :one
+SELECT * FROM coupons
+WHERE stripe_promotion_code_id = ?
+LIMIT 1;
+
+-- name: ListCoupons :many
+SELECT * FROM coupons
+ORDER BY id DESC
+LIMIT ? OFFSET ?;
+
+-- name: UpdateCoupon :one
+UPDATE coupons
+SET expires_at = ?, max_redemptions = ?, per_user_limit = ?, stripe_promotion_code_id = ?, updated_at = ?
+WHERE id = ?
+RETURNING *;
+
+-- name: ArchiveCoupon :exec
+UPDATE coupons
+SET archived_at = ?, updated_at = ?
+WHERE id = ?;
+
+-- name: LockCoupon :exec
+-- Locks the coupon's row (in SQLite, the database) until the transaction ends,
+-- so concurrent redemptions of one coupon are counted one at a time.
+UPDATE coupons
+SET updated_at = updated_at
+WHERE id = ?;
+
+-- name: AddCouponProduct :exec
+INSERT INTO coupon_products (coupon_id, product_id)
+VALUES (?, ?);
+
+-- name: DeleteCouponProducts :exec
+DELETE FROM coupon_products
+WHERE coupon_id = ?;
+
+-- name: ListCouponProducts :many
+SELECT product_id FROM coupon_products
+WHERE coupon_id = ?
+ORDER BY product_id;
+
+-- name: CountCouponRedemptions :one
+-- Transactions that used the coupon and were not cancelled or failed; pending
+-- ones hold their redemption until their session expires.
+SELECT COUNT(*) FROM transactions
+WHERE coupon_id = ? AND status IN ('pending', 'completed', 'refunded');
+
+-- name: CountCouponRedemptionsByUser :one
+SELECT COUNT(*) FROM transactions
+WHERE coupon_id = ? AND user_id = ? AND status IN ('pending', 'completed', 'refunded');
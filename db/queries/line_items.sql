@@ -0,0 +1,15 @@
+-- name: CreateTransactionLineItem :exec
+INSERT INTO transaction_line_items (transaction_id, product_id, description, quantity, unit_amount, currency, discount_amount, tax_amount, tax_code, tax_rate_id)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+
+-- name: ListTransactionLineItems :many
+SELECT * FROM transaction_line_items
+WHERE transaction_id = ?
+ORDER BY id;
+
+-- name: UpdateLineItemAdjustments :exec
+-- Copies a discount or tax reported by Stripe to the line item of a
+-- transaction. Transactions have a single line item.
+UPDATE transaction_line_items
+SET discount_amount = ?, tax_amount = ?
+WHERE transaction_id = ?;
@@ -0,0 +1,81 @@
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (url, secret, event_types, description, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetWebhookEndpoint :one
+SELECT * FROM webhook_endpoints
+WHERE id = ?
+LIMIT 1;
+
+-- name: ListWebhookEndpoints :many
+SELECT * FROM webhook_endpoints
+ORDER BY id
+LIMIT ? OFFSET ?;
+
+-- name: ListActiveWebhookEndpoints :many
+SELECT * FROM webhook_endpoints
+WHERE active
+ORDER BY id;
+
+-- name: UpdateWebhookEndpoint :one
+UPDATE webhook_endpoints
+SET url = ?, event_types = ?, description = ?, active = ?, updated_at = ?
+WHERE id = ?
+RETURNING *;
+
+-- name: CreateWebhookDelivery :exec
+-- Queues an event for an endpoint. An event key already queued for the endpoint
+-- is skipped.
+INSERT INTO webhook_deliveries (endpoint_id, event_id, event_type, event_key, payload, next_attempt_at, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (endpoint_id, event_key) DO NOTHING;
+
+-- name: GetWebhookDelivery :one
+SELECT * FROM webhook_deliveries
+WHERE id = ?
+LIMIT 1;
+
+-- name: ListWebhookDeliveriesByEndpoint :many
+SELECT * FROM webhook_deliveries
+WHERE endpoint_id = ?
+ORDER BY id DESC
+LIMIT ? OFFSET ?;
+
+-- name: ListDueWebhookDeliveries :many
+-- Pending deliveries whose next attempt is due, oldest first.
+SELECT * FROM webhook_deliveries
+WHERE status = 'pending' AND next_attempt_at <= ?
+ORDER BY next_attempt_at, id
+LIMIT ?;
+
+-- name: ClaimWebhookDelivery :one
+-- Leases a due delivery by moving its next attempt past the time a send can
+-- take. No rows when another worker has claimed it first.
+UPDATE webhook_deliveries
+SET next_attempt_at = ?, updated_at = ?
+WHERE id = ? AND status = 'pending' AND next_attempt_at = ?
+RETURNING *;
+
+-- name: RecordWebhookDeliveryResult :exec
+-- Records the outcome of an attempt: succeeded, failed for good, or pending
+-- with the time of the retry.
+UPDATE webhook_deliveries
+SET status = ?, attempts = attempts + 1, next_attempt_at = ?, last_status_code = ?, last_error = ?, updated_at = ?
+WHERE id = ?;
+
+-- name: ResetWebhookDelivery :one
+-- Queues a delivery again with a fresh set of retries.
+UPDATE webhook_deliveries
+SET status = 'pending', attempts = 0, next_attempt_at = ?, updated_at = ?
+WHERE id = ?
+RETURNING *;
+
+-- name: CreateWebhookDeliveryAttempt :exec
+INSERT INTO webhook_delivery_attempts (delivery_id, attempted_at, status_code, error, duration_ms)
+VALUES (?, ?, ?, ?, ?);
+
+-- name: ListWebhookDeliveryAttempts :many
+SELECT * FROM webhook_delivery_attempts
+WHERE delivery_id = ?
+ORDER BY id;
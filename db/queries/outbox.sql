@@ -0,0 +1,58 @@
+-- name: CreateOutboxMessage :exec
+-- Writes a message for a change. A change already written (same dedupe key) is
+-- skipped.
+INSERT INTO outbox_messages (topic, message_key, dedupe_key, payload, next_attempt_at, created_at)
+VALUES (?, ?, ?, ?, ?, ?)
+ON CONFLICT (dedupe_key) DO NOTHING;
+
+-- name: GetOutboxMessage :one
+SELECT * FROM outbox_messages
+WHERE id = ?
+LIMIT 1;
+
+-- name: ListDueOutboxMessages :many
+-- Unpublished messages that are due, oldest first. A message waits while an
+-- earlier one with the same key is unpublished, so each key publishes in order.
+-- Dead messages are neither due nor waited for.
+SELECT * FROM outbox_messages
+WHERE published_at IS NULL AND dead_at IS NULL AND next_attempt_at <= ?
+  AND NOT EXISTS (
+    SELECT 1 FROM outbox_messages earlier
+    WHERE earlier.message_key = outbox_messages.message_key
+      AND earlier.id < outbox_messages.id
+      AND earlier.published_at IS NULL
+      AND earlier.dead_at IS NULL
+  )
+ORDER BY id
+LIMIT ?;
+
+-- name: ClaimOutboxMessage :one
+-- Leases a due message by moving its next attempt past the time publishing can
+-- take. No rows when another relay has claimed it first.
+UPDATE outbox_messages
+SET next_attempt_at = ?
+WHERE id = ? AND published_at IS NULL AND next_attempt_at = ?
+RETURNING *;
+
+-- name: MarkOutboxMessagePublished :exec
+UPDATE outbox_messages
+SET published_at = ?, attempts = attempts + 1, last_error = NULL
+WHERE id = ?;
+
+-- name: RecordOutboxMessageFailure :exec
+-- Records a failed attempt with the time of the retry, or with dead_at when it
+-- was the last one.
+UPDATE outbox_messages
+SET attempts = attempts + 1, next_attempt_at = ?, last_error = ?, dead_at = ?
+WHERE id = ?;
+
+-- name: RequeueOutboxMessage :execrows
+-- Gives a dead message a fresh set of attempts. No rows unless it is dead.
+UPDATE outbox_messages
+SET dead_at = NULL, attempts = 0, next_attempt_at = ?
+WHERE id = ? AND dead_at IS NOT NULL;
+
+-- name: DeletePublishedOutboxMessages :execrows
+-- Deletes messages published before the given time and returns how many.
+DELETE FROM outbox_messages
+WHERE published_at IS NOT NULL AND published_at < ?;
@@ -0,0 +1,107 @@
+-- name: CreateAuditEvent :one
+INSERT INTO audit_events (
+    subsystem,
+    event_type,
+    user_id,
+    information,
+    payload,
+    ref_id,
+    ref_id2
+) VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING *;
+
+-- name: GetAuditEventsBySubsystem :many
+SELECT * FROM audit_events
+WHERE subsystem = $1
+ORDER BY timestamp DESC
+LIMIT $2 OFFSET $3;
+
+-- name: GetAuditEventsByEventType :many
+SELECT * FROM audit_events
+WHERE event_type = $1
+ORDER BY timestamp DESC
+LIMIT $2 OFFSET $3;
+
+-- name: GetAuditEventsByUser :many
+SELECT * FROM audit_events
+WHERE user_id = $1
+ORDER BY timestamp DESC
+LIMIT $2 OFFSET $3;
+
+-- name: GetAuditEventsInDateRange :many
+SELECT * FROM audit_events
+WHERE timestamp >= $1 AND timestamp <= $2
+ORDER BY timestamp DESC
+LIMIT $3 OFFSET $4;
+
+-- name: GetAllAuditEvents :many
+SELECT * FROM audit_events
+ORDER BY timestamp DESC
+LIMIT $1 OFFSET $2;
+
+-- name: GetAuditEventsBySubsystemAndType :many
+SELECT * FROM audit_events
+WHERE subsystem = $1 AND event_type = $2
+ORDER BY timestamp DESC
+LIMIT $3 OFFSET $4;
+
+-- name: GetAuditEventsByRefID :many
+SELECT * FROM audit_events
+WHERE ref_id = $1
+ORDER BY timestamp DESC
+LIMIT $2 OFFSET $3;
+
+-- name: GetAuditEventsByRefID2 :many
+SELECT * FROM audit_events
+WHERE ref_id2 = $1
+ORDER BY timestamp DESC
+LIMIT $2 OFFSET $3;
+
+-- name: GetAuditEventsAfterID :many
+SELECT * FROM audit_events
+WHERE id > $1
+ORDER BY id ASC
+LIMIT $2;
+
+-- name: ListAuditEventsForExport :many
+-- Empty filters match every event; the others must all match.
+SELECT * FROM audit_events
+WHERE id > $1 AND timestamp >= $2 AND timestamp < $3
+  AND ($4 = '' OR subsystem = $4)
+  AND ($5 = '' OR event_type = $5)
+  AND ($6 = '' OR user_id = $6)
+  AND ($7 = '' OR ref_id = $7)
+  AND ($8 = '' OR ref_id2 = $8)
+ORDER BY id ASC
+LIMIT $9;
+
+-- name: ImportAuditEvent :exec
+INSERT INTO audit_events (
+    id,
+    timestamp,
+    subsystem,
+    event_type,
+    user_id,
+    information,
+    payload,
+    ref_id,
+    ref_id2
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+ON CONFLICT (id) DO NOTHING;
+
+-- name: ListAuditEventsByRefs :many
+SELECT * FROM audit_events
+WHERE ref_id IN ($1, $2, $3) OR ref_id2 IN ($1, $2, $3)
+ORDER BY id ASC
+LIMIT $4;
+
+-- name: ListAuditEvents :many
+-- Empty filters match every event; the others must all match.
+SELECT * FROM audit_events
+WHERE ($1 = '' OR subsystem = $1)
+  AND ($2 = '' OR event_type = $2)
+  AND ($3 = '' OR user_id = $3)
+  AND ($4 = '' OR ref_id = $4)
+  AND ($5 = '' OR ref_id2 = $5)
+ORDER BY timestamp DESC, id DESC
+LIMIT $6 OFFSET $7;
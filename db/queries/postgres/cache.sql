@@ -0,0 +1,13 @@
+-- name: GetCacheValue :one
+SELECT value FROM cache WHERE key = $1 LIMIT 1;
+
+-- name: SetCacheValue :exec
+INSERT INTO cache (key, value)
+VALUES ($1, $2)
+ON CONFLICT(key) DO UPDATE SET value=excluded.value;
+
+-- name: DeleteCacheKey :exec
+DELETE FROM cache WHERE key = $1;
+
+-- name: ListCache :many
+SELECT key, value FROM cache ORDER BY key ASC;
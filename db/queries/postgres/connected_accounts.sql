@@ -0,0 +1,52 @@
+-- name: CreateConnectedAccount :one
+INSERT INTO connected_accounts (stripe_account_id, name, email, country, fee_bps, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING *;
+
+-- name: GetConnectedAccount :one
+SELECT * FROM connected_accounts
+WHERE id = $1
+LIMIT 1;
+
+-- name: GetConnectedAccountByStripeID :one
+SELECT * FROM connected_accounts
+WHERE stripe_account_id = $1
+LIMIT 1;
+
+-- name: ListConnectedAccounts :many
+SELECT * FROM connected_accounts
+ORDER BY id
+LIMIT $1 OFFSET $2;
+
+-- name: UpdateConnectedAccountFee :one
+UPDATE connected_accounts
+SET fee_bps = $1, updated_at = $2
+WHERE id = $3
+RETURNING *;
+
+-- name: UpdateConnectedAccountStatus :execrows
+-- Mirrors the capabilities Stripe reports in account.updated webhooks. An
+-- event created before the last one applied is stale and changes nothing.
+UPDATE connected_accounts
+SET charges_enabled = $1, payouts_enabled = $2, details_submitted = $3, status_updated_at = $4, updated_at = $5
+WHERE stripe_account_id = $6 AND (status_updated_at IS NULL OR status_updated_at <= $7);
+
+-- name: SetProductAccount :exec
+INSERT INTO product_accounts (product_id, connected_account_id)
+VALUES ($1, $2)
+ON CONFLICT (product_id) DO UPDATE SET connected_account_id = excluded.connected_account_id;
+
+-- name: DeleteProductAccount :exec
+DELETE FROM product_accounts
+WHERE product_id = $1;
+
+-- name: GetProductConnectedAccount :one
+-- The vendor selling a product; no rows for the platform's own products.
+SELECT connected_accounts.* FROM connected_accounts
+JOIN product_accounts ON product_accounts.connected_account_id = connected_accounts.id
+WHERE product_accounts.product_id = $1
+LIMIT 1;
+
+-- name: ListProductAccounts :many
+SELECT * FROM product_accounts
+ORDER BY product_id;
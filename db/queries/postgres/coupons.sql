@@ -0,0 +1,65 @@
+-- name: CreateCoupon :one
+INSERT INTO coupons (code, percent_off, amount_off, currency, expires_at, max_redemptions, per_user_limit, stripe_coupon_id, stripe_promotion_code_id, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+RETURNING *;
+
+-- name: GetCoupon :one
+SELECT * FROM coupons
+WHERE id = $1
+LIMIT 1;
+
+-- name: GetCouponByCode :one
+SELECT * FROM coupons
+WHERE code = $1
+LIMIT 1;
+
+-- name: GetCouponByStripePromotionCodeID :one
+SELECT * FROM coupons
+WHERE stripe_promotion_code_id = $1
+LIMIT 1;
+
+-- name: ListCoupons :many
+SELECT * FROM coupons
+ORDER BY id DESC
+LIMIT $1 OFFSET $2;
+
+-- name: UpdateCoupon :one
+UPDATE coupons
+SET expires_at = $1, max_redemptions = $2, per_user_limit = $3, stripe_promotion_code_id = $4, updated_at = $5
+WHERE id = $6
+RETURNING *;
+
+-- name: ArchiveCoupon :exec
+UPDATE coupons
+SET archived_at = $1, updated_at = $2
+WHERE id = $3;
+
+-- name: LockCoupon :exec
+-- Locks the coupon's row (in SQLite, the database) until the transaction ends,
+-- so concurrent redemptions of one coupon are counted one at a time.
+UPDATE coupons
+SET updated_at = updated_at
+WHERE id = $1;
+
+-- name: AddCouponProduct :exec
+INSERT INTO coupon_products (coupon_id, product_id)
+VALUES ($1, $2);
+
+-- name: DeleteCouponProducts :exec
+DELETE FROM coupon_products
+WHERE coupon_id = $1;
+
+-- name: ListCouponProducts :many
+SELECT product_id FROM coupon_products
+WHERE coupon_id = $1
+ORDER BY product_id;
+
+-- name: CountCouponRedemptions :one
+-- Transactions that used the coupon and were not cancelled or failed; pending
+-- ones hold their redemption until their session expires.
+SELECT COUNT(*) FROM transactions
+WHERE coupon_id = $1 AND status IN ('pending', 'completed', 'refunded');
+
+-- name: CountCouponRedemptionsByUser :one
+SELECT COUNT(*) FROM transactions
+WHERE coupon_id = $1 AND user_id = $2 AND status IN ('pending', 'completed', 'refunded');
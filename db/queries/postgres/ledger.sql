@@ -0,0 +1,36 @@
+-- name: EnsureLedgerAccount :one
+INSERT INTO ledger_accounts (code, type, currency)
+VALUES ($1, $2, $3)
+ON CONFLICT(code, currency) DO UPDATE SET type = excluded.type
+RETURNING *;
+
+-- name: CreateJournalEntry :one
+-- Returns no row if an entry with the same idempotency key exists.
+INSERT INTO journal_entries (idempotency_key, kind, transaction_id, user_id, stripe_event_id, description)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT(idempotency_key) DO NOTHING
+RETURNING *;
+
+-- name: CreateLedgerPosting :exec
+INSERT INTO ledger_postings (journal_entry_id, account_id, user_id, amount)
+VALUES ($1, $2, $3, $4);
+
+-- name: ListJournalEntriesByTransactionID :many
+SELECT * FROM journal_entries
+WHERE transaction_id = $1
+ORDER BY id ASC;
+
+-- name: ListLedgerAccountBalances :many
+SELECT a.code, a.type, a.currency, CAST(COALESCE(SUM(p.amount), 0) AS BIGINT) AS balance
+FROM ledger_accounts a
+LEFT JOIN ledger_postings p ON p.account_id = a.id
+GROUP BY a.id, a.code, a.type, a.currency
+ORDER BY a.code, a.currency;
+
+-- name: ListLedgerUserBalances :many
+SELECT a.code, a.type, a.currency, CAST(COALESCE(SUM(p.amount), 0) AS BIGINT) AS balance
+FROM ledger_postings p
+JOIN ledger_accounts a ON a.id = p.account_id
+WHERE p.user_id = $1
+GROUP BY a.id, a.code, a.type, a.currency
+ORDER BY a.code, a.currency;
@@ -0,0 +1,81 @@
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (url, secret, event_types, description, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: GetWebhookEndpoint :one
+SELECT * FROM webhook_endpoints
+WHERE id = $1
+LIMIT 1;
+
+-- name: ListWebhookEndpoints :many
+SELECT * FROM webhook_endpoints
+ORDER BY id
+LIMIT $1 OFFSET $2;
+
+-- name: ListActiveWebhookEndpoints :many
+SELECT * FROM webhook_endpoints
+WHERE active
+ORDER BY id;
+
+-- name: UpdateWebhookEndpoint :one
+UPDATE webhook_endpoints
+SET url = $1, event_types = $2, description = $3, active = $4, updated_at = $5
+WHERE id = $6
+RETURNING *;
+
+-- name: CreateWebhookDelivery :exec
+-- Queues an event for an endpoint. An event key already queued for the endpoint
+-- is skipped.
+INSERT INTO webhook_deliveries (endpoint_id, event_id, event_type, event_key, payload, next_attempt_at, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+ON CONFLICT (endpoint_id, event_key) DO NOTHING;
+
+-- name: GetWebhookDelivery :one
+SELECT * FROM webhook_deliveries
+WHERE id = $1
+LIMIT 1;
+
+-- name: ListWebhookDeliveriesByEndpoint :many
+SELECT * FROM webhook_deliveries
+WHERE endpoint_id = $1
+ORDER BY id DESC
+LIMIT $2 OFFSET $3;
+
+-- name: ListDueWebhookDeliveries :many
+-- Pending deliveries whose next attempt is due, oldest first.
+SELECT * FROM webhook_deliveries
+WHERE status = 'pending' AND next_attempt_at <= $1
+ORDER BY next_attempt_at, id
+LIMIT $2;
+
+-- name: ClaimWebhookDelivery :one
+-- Leases a due delivery by moving its next attempt past the time a send can
+-- take. No rows when another worker has claimed it first.
+UPDATE webhook_deliveries
+SET next_attempt_at = $1, updated_at = $2
+WHERE id = $3 AND status = 'pending' AND next_attempt_at = $4
+RETURNING *;
+
+-- name: RecordWebhookDeliveryResult :exec
+-- Records the outcome of an attempt: succeeded, failed for good, or pending
+-- with the time of the retry.
+UPDATE webhook_deliveries
+SET status = $1, attempts = attempts + 1, next_attempt_at = $2, last_status_code = $3, last_error = $4, updated_at = $5
+WHERE id = $6;
+
+-- name: ResetWebhookDelivery :one
+-- Queues a delivery again with a fresh set of retries.
+UPDATE webhook_deliveries
+SET status = 'pending', attempts = 0, next_attempt_at = $1, updated_at = $2
+WHERE id = $3
+RETURNING *;
+
+-- name: CreateWebhookDeliveryAttempt :exec
+INSERT INTO webhook_delivery_attempts (delivery_id, attempted_at, status_code, error, duration_ms)
+VALUES ($1, $2, $3, $4, $5);
+
+-- name: ListWebhookDeliveryAttempts :many
+SELECT * FROM webhook_delivery_attempts
+WHERE delivery_id = $1
+ORDER BY id;
@@ -0,0 +1,58 @@
+-- name: CreateOutboxMessage :exec
+-- Writes a message for a change. A change already written (same dedupe key) is
+-- skipped.
+INSERT INTO outbox_messages (topic, message_key, dedupe_key, payload, next_attempt_at, created_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (dedupe_key) DO NOTHING;
+
+-- name: GetOutboxMessage :one
+SELECT * FROM outbox_messages
+WHERE id = $1
+LIMIT 1;
+
+-- name: ListDueOutboxMessages :many
+-- Unpublished messages that are due, oldest first. A message waits while an
+-- earlier one with the same key is unpublished, so each key publishes in order.
+-- Dead messages are neither due nor waited for.
+SELECT * FROM outbox_messages
+WHERE published_at IS NULL AND dead_at IS NULL AND next_attempt_at <= $1
+  AND NOT EXISTS (
+    SELECT 1 FROM outbox_messages earlier
+    WHERE earlier.message_key = outbox_messages.message_key
+      AND earlier.id < outbox_messages.id
+      AND earlier.published_at IS NULL
+      AND earlier.dead_at IS NULL
+  )
+ORDER BY id
+LIMIT $2;
+
+-- name: ClaimOutboxMessage :one
+-- Leases a due message by moving its next attempt past the time publishing can
+-- take. No rows when another relay has claimed it first.
+UPDATE outbox_messages
+SET next_attempt_at = $1
+WHERE id = $2 AND published_at IS NULL AND next_attempt_at = $3
+RETURNING *;
+
+-- name: MarkOutboxMessagePublished :exec
+UPDATE outbox_messages
+SET published_at = $1, attempts = attempts + 1, last_error = NULL
+WHERE id = $2;
+
+-- name: RecordOutboxMessageFailure :exec
+-- Records a failed attempt with the time of the retry, or with dead_at when it
+-- was the last one.
+UPDATE outbox_messages
+SET attempts = attempts + 1, next_attempt_at = $1, last_error = $2, dead_at = $3
+WHERE id = $4;
+
+-- name: RequeueOutboxMessage :execrows
+-- Gives a dead message a fresh set of attempts. No rows unless it is dead.
+UPDATE outbox_messages
+SET dead_at = NULL, attempts = 0, next_attempt_at = $1
+WHERE id = $2 AND dead_at IS NOT NULL;
+
+-- name: DeletePublishedOutboxMessages :execrows
+-- Deletes messages published before the given time and returns how many.
+DELETE FROM outbox_messages
+WHERE published_at IS NOT NULL AND published_at < $1;
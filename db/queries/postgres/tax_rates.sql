@@ -0,0 +1,30 @@
+-- name: CreateTaxRate :one
+INSERT INTO tax_rates (country, region, tax_code, name, rate_bps, stripe_tax_rate_id, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING *;
+
+-- name: GetTaxRate :one
+SELECT * FROM tax_rates
+WHERE id = $1
+LIMIT 1;
+
+-- name: ListTaxRates :many
+SELECT * FROM tax_rates
+ORDER BY country, region, tax_code, id DESC
+LIMIT $1 OFFSET $2;
+
+-- name: ListActiveTaxRatesByCountry :many
+-- Rates that may apply in a country; the most specific match for the region
+-- and tax code wins.
+SELECT * FROM tax_rates
+WHERE country = $1 AND archived_at IS NULL
+ORDER BY id;
+
+-- name: CountActiveTaxRates :one
+SELECT COUNT(*) FROM tax_rates
+WHERE archived_at IS NULL;
+
+-- name: ArchiveTaxRate :exec
+UPDATE tax_rates
+SET archived_at = $1
+WHERE id = $2;
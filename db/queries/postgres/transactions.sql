@@ -0,0 +1,132 @@
+-- name: CreateTransaction :exec
+INSERT INTO transactions (id, user_id, product_id, product_name, amount, currency, stripe_session_id, stripe_payment_intent_id, status, created_at, updated_at, refund_date, expires_at, coupon_id, discount_amount, tax_amount, connected_account_id, application_fee_amount, transfer_amount, checkout_options)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20);
+
+-- name: GetTransaction :one
+SELECT id, user_id, product_id, product_name, amount, stripe_session_id, stripe_payment_intent_id, status, created_at, updated_at, refund_date, currency, expires_at, coupon_id, discount_amount, tax_amount, connected_account_id, application_fee_amount, transfer_amount, checkout_options
+FROM transactions
+WHERE id = $1
+LIMIT 1;
+
+-- name: GetTransactionByStripeSessionID :one
+SELECT id, user_id, product_id, product_name, amount, stripe_session_id, stripe_payment_intent_id, status, created_at, updated_at, refund_date, currency, expires_at, coupon_id, discount_amount, tax_amount, connected_account_id, application_fee_amount, transfer_amount, checkout_options
+FROM transactions
+WHERE stripe_session_id = $1
+LIMIT 1;
+
+-- name: GetTransactionByPaymentIntentID :one
+SELECT id, user_id, product_id, product_name, amount, stripe_session_id, stripe_payment_intent_id, status, created_at, updated_at, refund_date, currency, expires_at, coupon_id, discount_amount, tax_amount, connected_account_id, application_fee_amount, transfer_amount, checkout_options
+FROM transactions
+WHERE stripe_payment_intent_id = $1
+LIMIT 1;
+
+-- name: GetOpenTransactionForCart :one
+-- The newest pending transaction of the user for the same product, price,
+-- coupon (0 for none) and checkout option overrides whose checkout session has
+-- not expired yet.
+SELECT id, user_id, product_id, product_name, amount, stripe_session_id, stripe_payment_intent_id, status, created_at, updated_at, refund_date, currency, expires_at, coupon_id, discount_amount, tax_amount, connected_account_id, application_fee_amount, transfer_amount, checkout_options
+FROM transactions
+WHERE user_id = $1 AND product_id = $2 AND amount = $3 AND currency = $4
+  AND COALESCE(coupon_id, 0) = $5 AND checkout_options = $6
+  AND status = 'pending' AND stripe_session_id IS NOT NULL AND expires_at > $7
+ORDER BY created_at DESC
+LIMIT 1;
+
+-- name: ListTransactionsByUserID :many
+SELECT id, user_id, product_id, product_name, amount, stripe_session_id, stripe_payment_intent_id, status, created_at, updated_at, refund_date, currency, expires_at, coupon_id, discount_amount, tax_amount, connected_account_id, application_fee_amount, transfer_amount, checkout_options
+FROM transactions
+WHERE user_id = $1
+ORDER BY created_at DESC
+LIMIT $2 OFFSET $3;
+
+-- name: ListTransactionsCreatedBetween :many
+SELECT id, user_id, product_id, product_name, amount, stripe_session_id, stripe_payment_intent_id, status, created_at, updated_at, refund_date, currency, expires_at, coupon_id, discount_amount, tax_amount, connected_account_id, application_fee_amount, transfer_amount, checkout_options
+FROM transactions
+WHERE created_at >= $1 AND created_at < $2
+ORDER BY created_at ASC, id ASC
+LIMIT $3 OFFSET $4;
+
+-- name: ListPendingTransactionsCreatedBefore :many
+SELECT id, user_id, product_id, product_name, amount, stripe_session_id, stripe_payment_intent_id, status, created_at, updated_at, refund_date, currency, expires_at, coupon_id, discount_amount, tax_amount, connected_account_id, application_fee_amount, transfer_amount, checkout_options
+FROM transactions
+WHERE status = 'pending' AND created_at < $1
+ORDER BY created_at ASC, id ASC
+LIMIT $2 OFFSET $3;
+
+-- name: ListAllTransactions :many
+SELECT id, user_id, product_id, product_name, amount, stripe_session_id, stripe_payment_intent_id, status, created_at, updated_at, refund_date, currency, expires_at, coupon_id, discount_amount, tax_amount, connected_account_id, application_fee_amount, transfer_amount, checkout_options
+FROM transactions
+ORDER BY created_at DESC
+LIMIT $1 OFFSET $2;
+
+-- name: UpdateTransactionStatus :exec
+UPDATE transactions 
+SET status = $1, updated_at = $2
+WHERE id = $3;
+
+-- name: CancelPendingTransaction :execrows
+-- Cancels a transaction that is still pending and returns how many rows changed,
+-- so a webhook that settled it first wins.
+UPDATE transactions
+SET status = 'cancelled', updated_at = $1
+WHERE id = $2 AND status = 'pending';
+
+-- name: UpdateTransactionWithStripeData :execrows
+-- Moves a transaction's status forward (pending, then failed or cancelled, then
+-- completed, then refunded) and returns how many rows changed, so late or
+-- redelivered webhooks cannot move it back.
+UPDATE transactions
+SET stripe_payment_intent_id = $1, status = $2, updated_at = $3
+WHERE stripe_session_id = $4
+  AND (status = 'pending'
+    OR (status IN ('failed', 'cancelled') AND $2 IN ('failed', 'cancelled', 'completed', 'refunded'))
+    OR (status = 'completed' AND $2 IN ('completed', 'refunded'))
+    OR (status = 'refunded' AND $2 = 'refunded'));
+
+-- name: UpdateTransactionByPaymentIntentID :execrows
+-- Moves a transaction's status forward like UpdateTransactionWithStripeData.
+UPDATE transactions
+SET status = $1, updated_at = $2
+WHERE stripe_payment_intent_id = $3
+  AND (status = 'pending'
+    OR (status IN ('failed', 'cancelled') AND $1 IN ('failed', 'cancelled', 'completed', 'refunded'))
+    OR (status = 'completed' AND $1 IN ('completed', 'refunded'))
+    OR (status = 'refunded' AND $1 = 'refunded'));
+
+-- name: UpdateTransactionByPaymentIntentIDWithRefundDate :execrows
+-- Moves a transaction's status forward like UpdateTransactionWithStripeData.
+UPDATE transactions
+SET status = $1, updated_at = $2, refund_date = $3
+WHERE stripe_payment_intent_id = $4
+  AND (status = 'pending'
+    OR (status IN ('failed', 'cancelled') AND $1 IN ('failed', 'cancelled', 'completed', 'refunded'))
+    OR (status = 'completed' AND $1 IN ('completed', 'refunded'))
+    OR (status = 'refunded' AND $1 = 'refunded'));
+
+-- name: RepairTransaction :execrows
+-- Repairs a transaction still in the given status and returns how many rows
+-- changed, so concurrent repairs and webhooks apply a change once.
+UPDATE transactions
+SET status = $1, stripe_payment_intent_id = $2, refund_date = $3, updated_at = $4
+WHERE id = $5 AND status = $6;
+
+-- name: ApplyStripeDiscount :exec
+-- Records a promotion code entered on the Stripe Checkout page. Transactions
+-- created with a coupon keep theirs.
+UPDATE transactions
+SET amount = $1, discount_amount = $2, coupon_id = $3, updated_at = $4
+WHERE stripe_session_id = $5 AND coupon_id IS NULL AND discount_amount = 0;
+
+-- name: ApplyStripeTax :exec
+-- Records the tax Stripe automatic tax added to a session. Transactions taxed
+-- locally keep their tax.
+UPDATE transactions
+SET amount = $1, tax_amount = $2, updated_at = $3
+WHERE stripe_session_id = $4 AND tax_amount = 0;
+
+-- name: SetTransactionApplicationFee :exec
+-- Makes the platform's part of a vendor's transaction up to its amount, once
+-- Stripe has added tax the vendor's transfer doesn't include.
+UPDATE transactions
+SET application_fee_amount = $1, updated_at = $2
+WHERE id = $3;
@@ -0,0 +1,17 @@
+-- name: CreateWebhookInboxEntry :one
+-- Records a verified event. No rows when the event is already in the inbox,
+-- i.e. Stripe redelivered it.
+INSERT INTO webhook_inbox (stripe_event_id, event_type, session_id, payment_intent_id)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (stripe_event_id) DO NOTHING
+RETURNING *;
+
+-- name: UpdateWebhookInboxStatus :exec
+UPDATE webhook_inbox
+SET status = $1, error = $2, processed_at = $3
+WHERE id = $4;
+
+-- name: ListWebhookInboxByRefs :many
+SELECT * FROM webhook_inbox
+WHERE session_id = $1 OR payment_intent_id = $2
+ORDER BY id ASC;
@@ -1,28 +1,60 @@
 -- name: CreateTransaction :exec
-INSERT INTO transactions (id, user_id, product_id, product_name, amount, stripe_session_id, stripe_payment_intent_id, status, created_at, updated_at, refund_date)
-VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+INSERT INTO transactions (id, user_id, product_id, product_name, amount, currency, stripe_session_id, stripe_payment_intent_id, status, created_at, updated_at, refund_date, expires_at, coupon_id, discount_amount, tax_amount, connected_account_id, application_fee_amount, transfer_amount, checkout_options)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
 
 -- name: GetTransaction :one
-SELECT id, user_id, product_id, product_name, amount, stripe_session_id, stripe_payment_intent_id, status, created_at, updated_at, refund_date
+SELECT id, user_id, product_id, product_name, amount, stripe_session_id, stripe_payment_intent_id, status, created_at, updated_at, refund_date, currency, expires_at, coupon_id, discount_amount, tax_amount, connected_account_id, application_fee_amount, transfer_amount, checkout_options
 FROM transactions
 WHERE id = ?
 LIMIT 1;
 
 -- name: GetTransactionByStripeSessionID :one
-SELECT id, user_id, product_id, product_name, amount, stripe_session_id, stripe_payment_intent_id, status, created_at, updated_at, refund_date
+SELECT id, user_id, product_id, product_name, amount, stripe_session_id, stripe_payment_intent_id, status, created_at, updated_at, refund_date, currency, expires_at, coupon_id, discount_amount, tax_amount, connected_account_id, application_fee_amount, transfer_amount, checkout_options
 FROM transactions
 WHERE stripe_session_id = ?
 LIMIT 1;
 
+-- name: GetTransactionByPaymentIntentID :one
+SELECT id, user_id, product_id, product_name, amount, stripe_session_id, stripe_payment_intent_id, status, created_at, updated_at, refund_date, currency, expires_at, coupon_id, discount_amount, tax_amount, connected_account_id, application_fee_amount, transfer_amount, checkout_options
+FROM transactions
+WHERE stripe_payment_intent_id = ?
+LIMIT 1;
+
+-- name: GetOpenTransactionForCart :one
+-- The newest pending transaction of the user for the same product, price,
+-- coupon (0 for none) and checkout option overrides whose checkout session has
+-- not expired yet.
+SELECT id, user_id, product_id, product_name, amount, stripe_session_id, stripe_payment_intent_id, status, created_at, updated_at, refund_date, currency, expires_at, coupon_id, discount_amount, tax_amount, connected_account_id, application_fee_amount, transfer_amount, checkout_options
+FROM transactions
+WHERE user_id = ? AND product_id = ? AND amount = ? AND currency = ?
+  AND COALESCE(coupon_id, 0) = ? AND checkout_options = ?
+  AND status = 'pending' AND stripe_session_id IS NOT NULL AND expires_at > ?
+ORDER BY created_at DESC
+LIMIT 1;
+
 -- name: ListTransactionsByUserID :many
-SELECT id, user_id, product_id, product_name, amount, stripe_session_id, stripe_payment_intent_id, status, created_at, updated_at, refund_date
+SELECT id, user_id, product_id, product_name, amount, stripe_session_id, stripe_payment_intent_id, status, created_at, updated_at, refund_date, currency, expires_at, coupon_id, discount_amount, tax_amount, connected_account_id, application_fee_amount, transfer_amount, checkout_options
 FROM transactions
 WHERE user_id = ?
 ORDER BY created_at DESC
 LIMIT ? OFFSET ?;
 
+-- name: ListTransactionsCreatedBetween :many
+SELECT id, user_id, product_id, product_name, amount, stripe_session_id, stripe_payment_intent_id, status, created_at, updated_at, refund_date, currency, expires_at, coupon_id, discount_amount, tax_amount, connected_account_id, application_fee_amount, transfer_amount, checkout_options
+FROM transactions
+WHERE created_at >= ? AND created_at < ?
+ORDER BY created_at ASC, id ASC
+LIMIT ? OFFSET ?;
+
+-- name: ListPendingTransactionsCreatedBefore :many
+SELECT id, user_id, product_id, product_name, amount, stripe_session_id, stripe_payment_intent_id, status, created_at, updated_at, refund_date, currency, expires_at, coupon_id, discount_amount, tax_amount, connected_account_id, application_fee_amount, transfer_amount, checkout_options
+FROM transactions
+WHERE status = 'pending' AND created_at < ?
+ORDER BY created_at ASC, id ASC
+LIMIT ? OFFSET ?;
+
 -- name: ListAllTransactions :many
-SELECT id, user_id, product_id, product_name, amount, stripe_session_id, stripe_payment_intent_id, status, created_at, updated_at, refund_date
+SELECT id, user_id, product_id, product_name, amount, stripe_session_id, stripe_payment_intent_id, status, created_at, updated_at, refund_date, currency, expires_at, coupon_id, discount_amount, tax_amount, connected_account_id, application_fee_amount, transfer_amount, checkout_options
 FROM transactions
 ORDER BY created_at DESC
 LIMIT ? OFFSET ?;
@@ -32,17 +64,69 @@ UPDATE transactions
 SET status = ?, updated_at = ?
 WHERE id = ?;
 
--- name: UpdateTransactionWithStripeData :exec
-UPDATE transactions 
-SET stripe_payment_intent_id = ?, status = ?, updated_at = ?
-WHERE stripe_session_id = ?;
+-- name: CancelPendingTransaction :execrows
+-- Cancels a transaction that is still pending and returns how many rows changed,
+-- so a webhook that settled it first wins.
+UPDATE transactions
+SET status = 'cancelled', updated_at = ?
+WHERE id = ? AND status = 'pending';
 
--- name: UpdateTransactionByPaymentIntentID :exec
-UPDATE transactions 
-SET status = ?, updated_at = ?
-WHERE stripe_payment_intent_id = ?;
+-- name: UpdateTransactionWithStripeData :execrows
+-- Moves a transaction's status forward (pending, then failed or cancelled, then
+-- completed, then refunded) and returns how many rows changed, so late or
+-- redelivered webhooks cannot move it back.
+UPDATE transactions
+SET stripe_payment_intent_id = ?1, status = ?2, updated_at = ?3
+WHERE stripe_session_id = ?4
+  AND (status = 'pending'
+    OR (status IN ('failed', 'cancelled') AND ?2 IN ('failed', 'cancelled', 'completed', 'refunded'))
+    OR (status = 'completed' AND ?2 IN ('completed', 'refunded'))
+    OR (status = 'refunded' AND ?2 = 'refunded'));
 
--- name: UpdateTransactionByPaymentIntentIDWithRefundDate :exec
-UPDATE transactions 
-SET status = ?, updated_at = ?, refund_date = ?
-WHERE stripe_payment_intent_id = ?;
\ No newline at end of file
+-- name: UpdateTransactionByPaymentIntentID :execrows
+-- Moves a transaction's status forward like UpdateTransactionWithStripeData.
+UPDATE transactions
+SET status = ?1, updated_at = ?2
+WHERE stripe_payment_intent_id = ?3
+  AND (status = 'pending'
+    OR (status IN ('failed', 'cancelled') AND ?1 IN ('failed', 'cancelled', 'completed', 'refunded'))
+    OR (status = 'completed' AND ?1 IN ('completed', 'refunded'))
+    OR (status = 'refunded' AND ?1 = 'refunded'));
+
+-- name: UpdateTransactionByPaymentIntentIDWithRefundDate :execrows
+-- Moves a transaction's status forward like UpdateTransactionWithStripeData.
+UPDATE transactions
+SET status = ?1, updated_at = ?2, refund_date = ?3
+WHERE stripe_payment_intent_id = ?4
+  AND (status = 'pending'
+    OR (status IN ('failed', 'cancelled') AND ?1 IN ('failed', 'cancelled', 'completed', 'refunded'))
+    OR (status = 'completed' AND ?1 IN ('completed', 'refunded'))
+    OR (status = 'refunded' AND ?1 = 'refunded'));
+
+-- name: RepairTransaction :execrows
+-- Repairs a transaction still in the given status and returns how many rows
+-- changed, so concurrent repairs and webhooks apply a change once.
+UPDATE transactions
+SET status = ?, stripe_payment_intent_id = ?, refund_date = ?, updated_at = ?
+WHERE id = ? AND status = ?;
+
+-- name: ApplyStripeDiscount :exec
+-- Records a promotion code entered on the Stripe Checkout page. Transactions
+-- created with a coupon keep theirs.
+UPDATE transactions
+SET amount = ?, discount_amount = ?, coupon_id = ?, updated_at = ?
+WHERE stripe_session_id = ? AND coupon_id IS NULL AND discount_amount = 0;
+
+-- name: ApplyStripeTax :exec
+-- Records the tax Stripe automatic tax added to a session. Transactions taxed
+-- locally keep their tax.
+UPDATE transactions
+SET amount = ?, tax_amount = ?, updated_at = ?
+WHERE stripe_session_id = ? AND tax_amount = 0;
+
+-- name: SetTransactionApplicationFee :exec
+-- Makes the platform's part of a vendor's transaction up to its amount, once
+-- Stripe has added tax the vendor's transfer doesn't include.
+UPDATE transactions
+SET application_fee_amount = ?, updated_at = ?
+WHERE id = ?;
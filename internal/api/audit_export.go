@@ -0,0 +1,54 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"stripe-go-spike/internal/audit"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportAuditEvents streams audit events as CSV or NDJSON without buffering them.
+// Query parameters: format (csv|ndjson), from/to (RFC3339 or YYYY-MM-DD, to is
+// exclusive), columns (comma-separated, payload fields as payload.<path>) and the
+// same filters as GetAuditEvents.
+func (h *Handlers) ExportAuditEvents(c *gin.Context) {
+	from, err := audit.ParseExportTime(c.Query("from"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	to, err := audit.ParseExportTime(c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	opts := audit.ExportOptions{
+		Format:  audit.ExportFormat(c.DefaultQuery("format", string(audit.FormatCSV))),
+		From:    from,
+		To:      to,
+		Columns: audit.ParseExportColumns(c.Query("columns")),
+		Filter:  auditFilter(c),
+	}
+	if err := opts.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	contentType := "text/csv; charset=utf-8"
+	if opts.Format == audit.FormatNDJSON {
+		contentType = "application/x-ndjson"
+	}
+	filename := fmt.Sprintf("audit-events-%s.%s", time.Now().UTC().Format("20060102T150405Z"), opts.Format)
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+
+	// Headers are already sent, so a failure can only be logged and the stream cut short
+	if _, err := h.auditService.Export(c.Request.Context(), c.Writer, opts); err != nil {
+		log.Printf("audit export aborted: %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"stripe-go-spike/internal/audit"
+	"stripe-go-spike/internal/db"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// streamBackfillPage is the page size used when replaying events after Last-Event-ID
+	streamBackfillPage = 500
+	// streamHeartbeat keeps idle connections open through proxies
+	streamHeartbeat = 15 * time.Second
+)
+
+// StreamAuditEvents pushes newly logged audit events as Server-Sent Events.
+// It accepts the same filters as GetAuditEvents and resumes after the
+// Last-Event-ID header (or last_event_id query parameter) when present.
+func (h *Handlers) StreamAuditEvents(c *gin.Context) {
+	filter := auditFilter(c)
+
+	lastID := int64(0)
+	lastIDStr := c.GetHeader("Last-Event-ID")
+	if lastIDStr == "" {
+		lastIDStr = c.Query("last_event_id")
+	}
+	if lastIDStr != "" {
+		id, err := strconv.ParseInt(lastIDStr, 10, 64)
+		if err != nil || id < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Last-Event-ID"})
+			return
+		}
+		lastID = id
+	}
+
+	// Subscribe before replaying so nothing logged in between is missed
+	sub := h.auditService.Subscribe(filter)
+	defer sub.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	ctx := c.Request.Context()
+	if lastID > 0 {
+		for {
+			events, err := h.store.GetAuditEventsAfterID(ctx, db.GetAuditEventsAfterIDParams{
+				ID:    lastID,
+				Limit: streamBackfillPage,
+			})
+			if err != nil {
+				return
+			}
+			for _, event := range events {
+				lastID = event.ID
+				if !filter.Match(event) {
+					continue
+				}
+				if err := writeAuditSSE(c, event); err != nil {
+					return
+				}
+			}
+			if len(events) < streamBackfillPage {
+				break
+			}
+		}
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-sub.C:
+			if !ok {
+				// Dropped for falling behind; the client reconnects with Last-Event-ID
+				return
+			}
+			if event.ID <= lastID {
+				continue
+			}
+			lastID = event.ID
+			if err := writeAuditSSE(c, event); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": ping\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+// auditFilter reads the audit event filters shared by the list, stream and export
+// endpoints from the query string.
+func auditFilter(c *gin.Context) audit.Filter {
+	return audit.Filter{
+		Subsystem: c.Query("subsystem"),
+		EventType: c.Query("event_type"),
+		UserID:    c.Query("user_id"),
+		RefID:     c.Query("ref_id"),
+		RefID2:    c.Query("ref_id2"),
+	}
+}
+
+// writeAuditSSE writes one audit event in SSE wire format
+func writeAuditSSE(c *gin.Context, event db.AuditEvent) error {
+	payload, err := json.Marshal(toAuditEvent(event))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(c.Writer, "id: %d\nevent: audit\ndata: %s\n\n", event.ID, payload)
+	return err
+}
@@ -0,0 +1,180 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"stripe-go-spike/internal/audit"
+	"stripe-go-spike/internal/data"
+	"stripe-go-spike/internal/db"
+	"stripe-go-spike/internal/money"
+	"stripe-go-spike/internal/payments"
+	"stripe-go-spike/internal/reconcile"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CheckoutSessionStatusResponse is the outcome of a checkout session as far as
+// Stripe and the database know it.
+type CheckoutSessionStatusResponse struct {
+	SessionID string `json:"session_id"`
+	// Status is the transaction status: pending, completed, failed, cancelled or
+	// refunded
+	Status        string           `json:"status"`
+	SessionStatus string           `json:"session_status"` // open, complete or expired at Stripe
+	PaymentStatus string           `json:"payment_status"` // paid, unpaid or no_payment_required at Stripe
+	Transaction   data.Transaction `json:"transaction"`
+	// Reconciled is set when this request updated the transaction because the
+	// webhook had not arrived yet
+	Reconciled bool `json:"reconciled"`
+}
+
+// GetCheckoutSession looks the checkout session up at Stripe, for the success
+// redirect, and brings its transaction up to date if the webhook hasn't done so
+// yet. Transactions are only moved forward, so repeated calls and late webhooks
+// don't change the result.
+func (h *Handlers) GetCheckoutSession(c *gin.Context) {
+	ctx := c.Request.Context()
+	sessionID := c.Param("id")
+
+	txn, err := h.store.GetTransactionByStripeSessionID(ctx, sql.NullString{String: sessionID, Valid: true})
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Checkout session not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transaction"})
+		return
+	}
+
+	sess, err := h.service.Gateway().CheckoutSession(ctx, sessionID)
+	if errors.Is(err, payments.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	txn, reconciled, err := reconcile.SyncSession(ctx, h.store, h.auditService, txn, sess)
+	if err != nil {
+		log.Printf("failed to reconcile transaction %s with session %s: %v", txn.ID, sessionID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update transaction"})
+		return
+	}
+	c.JSON(http.StatusOK, CheckoutSessionStatusResponse{
+		SessionID:     sessionID,
+		Status:        txn.Status,
+		SessionStatus: sess.Status,
+		PaymentStatus: sess.PaymentStatus,
+		Transaction:   data.NewTransaction(txn),
+		Reconciled:    reconciled,
+	})
+}
+
+// openSession returns the checkout session of the user's pending transaction for
+// the same product, charge, coupon (0 for none) and checkout option overrides,
+// if it has not expired and Stripe still has it open.
+func (h *Handlers) openSession(ctx context.Context, userID, productID string, charge money.Amount, couponID int64, options payments.CheckoutOptions) (CheckoutSessionResponse, bool) {
+	txn, err := h.store.GetOpenTransactionForCart(ctx, db.GetOpenTransactionForCartParams{
+		UserID:          userID,
+		ProductID:       productID,
+		Amount:          charge.Minor,
+		Currency:        charge.Currency,
+		CouponID:        couponID,
+		CheckoutOptions: options.Key(),
+		ExpiresAt:       db.NullTimestamp{Timestamp: db.Now(), Valid: true},
+	})
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("failed to look up open checkout session for %s: %v", userID, err)
+		}
+		return CheckoutSessionResponse{}, false
+	}
+
+	sess, err := h.service.Gateway().CheckoutSession(ctx, txn.StripeSessionID.String)
+	if err != nil || sess.Status != "open" || sess.URL == "" {
+		return CheckoutSessionResponse{}, false
+	}
+	return CheckoutSessionResponse{
+		SessionID:     sess.ID,
+		URL:           sess.URL,
+		TransactionID: txn.ID,
+		ExpiresAt:     txn.ExpiresAt.Ptr(),
+		Reused:        true,
+		Amount:        charge,
+		Discount:      amountPtr(money.Amount{Minor: txn.DiscountAmount, Currency: txn.Currency}),
+	}, true
+}
+
+// errNotPending rolls back a cancellation that lost the race with a webhook.
+var errNotPending = errors.New("transaction is no longer pending")
+
+// ExpireCheckoutSession expires the open checkout session of a pending transaction
+// before its expiry time and cancels the transaction.
+func (h *Handlers) ExpireCheckoutSession(c *gin.Context) {
+	ctx := c.Request.Context()
+	sessionID := c.Param("id")
+
+	txn, err := h.store.GetTransactionByStripeSessionID(ctx, sql.NullString{String: sessionID, Valid: true})
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Checkout session not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transaction"})
+		return
+	}
+	if txn.Status != "pending" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Transaction is already " + txn.Status})
+		return
+	}
+
+	if _, err := h.service.Gateway().ExpireCheckoutSession(ctx, sessionID); err != nil {
+		if errors.Is(err, payments.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The checkout.session.expired webhook that follows finds the transaction
+	// already cancelled. A webhook that settled it meanwhile wins.
+	now := db.Now()
+	var logged db.AuditEvent
+	err = h.store.WithTx(ctx, func(q db.Querier) error {
+		n, err := q.CancelPendingTransaction(ctx, db.CancelPendingTransactionParams{
+			UpdatedAt: now,
+			ID:        txn.ID,
+		})
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return errNotPending
+		}
+		logged, err = h.auditService.LogTx(ctx, q, audit.TransactionCancelled.New(
+			"Transaction cancelled by expiring its checkout session",
+			audit.TransactionStatusPayload{SessionID: sessionID, PaymentIntentID: txn.StripePaymentIntentID.String},
+		).WithUser(txn.UserID).WithRefs(txn.StripePaymentIntentID.String, sessionID))
+		return err
+	})
+	if errors.Is(err, errNotPending) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Transaction is no longer pending"})
+		return
+	}
+	if err != nil {
+		log.Printf("failed to cancel transaction %s: %v", txn.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel transaction"})
+		return
+	}
+	h.auditService.Publish(logged)
+
+	txn.Status = "cancelled"
+	txn.UpdatedAt = now
+	c.JSON(http.StatusOK, data.NewTransaction(txn))
+}
@@ -0,0 +1,385 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"stripe-go-spike/internal/audit"
+	"stripe-go-spike/internal/connect"
+	"stripe-go-spike/internal/data"
+	"stripe-go-spike/internal/db"
+	"stripe-go-spike/internal/payments"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ConnectedAccountRequest creates a vendor's connected account. The vendor
+// completes it through an onboarding link.
+type ConnectedAccountRequest struct {
+	Name    string `json:"name" binding:"required"`
+	Email   string `json:"email"`
+	Country string `json:"country" binding:"required"`
+	FeeBps  int64  `json:"fee_bps"` // platform's share before tax: 1000 is 10%
+}
+
+// ConnectedAccountUpdateRequest changes the platform fee of a vendor. Checkouts
+// already started keep their split.
+type ConnectedAccountUpdateRequest struct {
+	FeeBps int64 `json:"fee_bps"`
+}
+
+// ProductAccountRequest assigns a product to a vendor.
+type ProductAccountRequest struct {
+	ConnectedAccountID int64 `json:"connected_account_id" binding:"required"`
+}
+
+type ConnectedAccountsResponse struct {
+	ConnectedAccounts []data.ConnectedAccount `json:"connected_accounts"`
+}
+
+// GetConnectedAccounts lists vendors' connected accounts (admin view)
+func (h *Handlers) GetConnectedAccounts(c *gin.Context) {
+	limit := int64(50)
+	offset := int64(0)
+
+	// Parse pagination parameters
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.ParseInt(limitStr, 10, 64); err == nil && l > 0 {
+			limit = l
+		}
+	}
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		if o, err := strconv.ParseInt(offsetStr, 10, 64); err == nil && o >= 0 {
+			offset = o
+		}
+	}
+
+	rows, err := h.store.ListConnectedAccounts(c.Request.Context(), db.ListConnectedAccountsParams{Limit: limit, Offset: offset})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch connected accounts"})
+		return
+	}
+	result := make([]data.ConnectedAccount, len(rows))
+	for i, row := range rows {
+		result[i] = toConnectedAccount(row)
+	}
+	c.JSON(http.StatusOK, ConnectedAccountsResponse{ConnectedAccounts: result})
+}
+
+// GetConnectedAccount returns one connected account with its onboarding status
+// (admin view)
+func (h *Handlers) GetConnectedAccount(c *gin.Context) {
+	account, ok := h.lookupConnectedAccount(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, toConnectedAccount(account))
+}
+
+// CreateConnectedAccount creates an Express account at Stripe for a vendor and
+// saves it. It can't take payments until the vendor has been through
+// onboarding (admin)
+func (h *Handlers) CreateConnectedAccount(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req ConnectedAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	spec := connect.AccountSpec{
+		Name:    req.Name,
+		Email:   req.Email,
+		Country: req.Country,
+		FeeBps:  req.FeeBps,
+	}.Normalize()
+	if err := spec.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	stripeAccountID, err := h.service.CreateConnectedAccount(ctx, payments.ConnectedAccountParams{
+		Name:    spec.Name,
+		Email:   spec.Email,
+		Country: spec.Country,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := db.Now()
+	var created db.ConnectedAccount
+	var logged db.AuditEvent
+	err = h.store.WithTx(ctx, func(q db.Querier) error {
+		var err error
+		created, err = q.CreateConnectedAccount(ctx, db.CreateConnectedAccountParams{
+			StripeAccountID: stripeAccountID,
+			Name:            spec.Name,
+			Email:           spec.Email,
+			Country:         spec.Country,
+			FeeBps:          spec.FeeBps,
+			CreatedAt:       now,
+			UpdatedAt:       now,
+		})
+		if err != nil {
+			return err
+		}
+		logged, err = h.auditService.LogTx(ctx, q, audit.ConnectedAccountCreated.New(
+			"Connected account created for "+created.Name, connectedAccountPayload(created),
+		).WithRefs(created.StripeAccountID, ""))
+		return err
+	})
+	if err != nil {
+		log.Printf("failed to create connected account %s: %v", stripeAccountID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create connected account"})
+		return
+	}
+	h.auditService.Publish(logged)
+	c.JSON(http.StatusCreated, toConnectedAccount(created))
+}
+
+// UpdateConnectedAccount changes a vendor's platform fee (admin)
+func (h *Handlers) UpdateConnectedAccount(c *gin.Context) {
+	ctx := c.Request.Context()
+	account, ok := h.lookupConnectedAccount(c)
+	if !ok {
+		return
+	}
+	var req ConnectedAccountUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := connect.ValidateFee(req.FeeBps); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var logged db.AuditEvent
+	err := h.store.WithTx(ctx, func(q db.Querier) error {
+		var err error
+		account, err = q.UpdateConnectedAccountFee(ctx, db.UpdateConnectedAccountFeeParams{
+			FeeBps:    req.FeeBps,
+			UpdatedAt: db.Now(),
+			ID:        account.ID,
+		})
+		if err != nil {
+			return err
+		}
+		logged, err = h.auditService.LogTx(ctx, q, audit.ConnectedAccountUpdated.New(
+			"Platform fee of "+account.Name+" changed", connectedAccountPayload(account),
+		).WithRefs(account.StripeAccountID, ""))
+		return err
+	})
+	if err != nil {
+		log.Printf("failed to update connected account %d: %v", account.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update connected account"})
+		return
+	}
+	h.auditService.Publish(logged)
+	c.JSON(http.StatusOK, toConnectedAccount(account))
+}
+
+// CreateOnboardingLink returns a single-use link to Stripe's onboarding for a
+// vendor. Links expire after a few minutes; Stripe sends vendors with an
+// expired one to the refresh URL to ask for another (admin)
+func (h *Handlers) CreateOnboardingLink(c *gin.Context) {
+	account, ok := h.lookupConnectedAccount(c)
+	if !ok {
+		return
+	}
+	link, err := h.service.CreateOnboardingLink(c.Request.Context(), account.StripeAccountID)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, link)
+}
+
+// AssignProductAccount has a vendor sell a product: its checkouts become
+// destination charges to the vendor's connected account (admin)
+func (h *Handlers) AssignProductAccount(c *gin.Context) {
+	ctx := c.Request.Context()
+	product := data.GetProductByID(c.Param("id"))
+	if product == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+	var req ProductAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	account, err := h.store.GetConnectedAccount(ctx, req.ConnectedAccountID)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid connected account ID"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch connected account"})
+		return
+	}
+
+	var logged db.AuditEvent
+	err = h.store.WithTx(ctx, func(q db.Querier) error {
+		err := q.SetProductAccount(ctx, db.SetProductAccountParams{ProductID: product.ID, ConnectedAccountID: account.ID})
+		if err != nil {
+			return err
+		}
+		logged, err = h.auditService.LogTx(ctx, q, audit.ProductAccountAssigned.New(
+			product.Name+" assigned to "+account.Name,
+			audit.ProductAccountPayload{
+				ProductID:          product.ID,
+				ConnectedAccountID: account.ID,
+				StripeAccountID:    account.StripeAccountID,
+			},
+		).WithRefs(account.StripeAccountID, ""))
+		return err
+	})
+	if err != nil {
+		log.Printf("failed to assign product %s to connected account %d: %v", product.ID, account.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign product"})
+		return
+	}
+	h.auditService.Publish(logged)
+	c.JSON(http.StatusOK, toConnectedAccount(account))
+}
+
+// RemoveProductAccount has the platform sell a product itself again (admin)
+func (h *Handlers) RemoveProductAccount(c *gin.Context) {
+	ctx := c.Request.Context()
+	product := data.GetProductByID(c.Param("id"))
+	if product == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	var logged db.AuditEvent
+	err := h.store.WithTx(ctx, func(q db.Querier) error {
+		if err := q.DeleteProductAccount(ctx, product.ID); err != nil {
+			return err
+		}
+		var err error
+		logged, err = h.auditService.LogTx(ctx, q, audit.ProductAccountRemoved.New(
+			product.Name+" is sold by the platform",
+			audit.ProductAccountPayload{ProductID: product.ID},
+		))
+		return err
+	})
+	if err != nil {
+		log.Printf("failed to remove connected account of product %s: %v", product.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove connected account"})
+		return
+	}
+	h.auditService.Publish(logged)
+	c.Status(http.StatusNoContent)
+}
+
+// applyAccountUpdate mirrors an account.updated event onto the connected
+// account, with its audit event and the inbox settlement in one unit of work.
+// Events for accounts that aren't ours, and events created before the last one
+// applied, are settled without a change.
+func (h *Handlers) applyAccountUpdate(ctx context.Context, event *payments.WebhookEvent, inboxID int64) {
+	status := event.Account
+	var logged db.AuditEvent
+	err := h.store.WithTx(ctx, func(q db.Querier) error {
+		account, err := q.GetConnectedAccountByStripeID(ctx, status.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return settleWebhook(ctx, q, inboxID, nil)
+		}
+		if err != nil {
+			return err
+		}
+		created := db.NullTimestamp{Timestamp: db.NewTimestamp(event.Created), Valid: true}
+		n, err := q.UpdateConnectedAccountStatus(ctx, db.UpdateConnectedAccountStatusParams{
+			ChargesEnabled:    status.ChargesEnabled,
+			PayoutsEnabled:    status.PayoutsEnabled,
+			DetailsSubmitted:  status.DetailsSubmitted,
+			StatusUpdatedAt:   created,
+			UpdatedAt:         db.Now(),
+			StripeAccountID:   status.ID,
+			StatusUpdatedAt_2: created,
+		})
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return settleWebhook(ctx, q, inboxID, nil)
+		}
+		account.ChargesEnabled = status.ChargesEnabled
+		account.PayoutsEnabled = status.PayoutsEnabled
+		account.DetailsSubmitted = status.DetailsSubmitted
+		logged, err = h.auditService.LogTx(ctx, q, audit.ConnectedAccountUpdated.New(
+			"Onboarding status of "+account.Name+" updated", connectedAccountPayload(account),
+		).WithRefs(account.StripeAccountID, ""))
+		if err != nil {
+			return err
+		}
+		return settleWebhook(ctx, q, inboxID, nil)
+	})
+	if err != nil {
+		log.Printf("failed to update connected account %s: %v", status.ID, err)
+		if err := settleWebhook(ctx, h.store, inboxID, err); err != nil {
+			log.Printf("failed to update webhook inbox entry %d: %v", inboxID, err)
+		}
+		return
+	}
+	if logged.ID != 0 {
+		h.auditService.Publish(logged)
+	}
+}
+
+// lookupConnectedAccount loads the connected account named by the :id
+// parameter, writing the error response if there is none.
+func (h *Handlers) lookupConnectedAccount(c *gin.Context) (db.ConnectedAccount, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid connected account ID"})
+		return db.ConnectedAccount{}, false
+	}
+	account, err := h.store.GetConnectedAccount(c.Request.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Connected account not found"})
+		return db.ConnectedAccount{}, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch connected account"})
+		return db.ConnectedAccount{}, false
+	}
+	return account, true
+}
+
+// toConnectedAccount converts a stored connected account into its API
+// representation
+func toConnectedAccount(account db.ConnectedAccount) data.ConnectedAccount {
+	return data.ConnectedAccount{
+		ID:               account.ID,
+		StripeAccountID:  account.StripeAccountID,
+		Name:             account.Name,
+		Email:            account.Email,
+		Country:          account.Country,
+		FeeBps:           account.FeeBps,
+		ChargesEnabled:   account.ChargesEnabled,
+		PayoutsEnabled:   account.PayoutsEnabled,
+		DetailsSubmitted: account.DetailsSubmitted,
+		CreatedAt:        account.CreatedAt,
+		UpdatedAt:        account.UpdatedAt,
+	}
+}
+
+func connectedAccountPayload(account db.ConnectedAccount) audit.ConnectedAccountPayload {
+	return audit.ConnectedAccountPayload{
+		ConnectedAccountID: account.ID,
+		StripeAccountID:    account.StripeAccountID,
+		Name:               account.Name,
+		Country:            account.Country,
+		FeeBps:             account.FeeBps,
+		ChargesEnabled:     account.ChargesEnabled,
+		PayoutsEnabled:     account.PayoutsEnabled,
+		DetailsSubmitted:   account.DetailsSubmitted,
+	}
+}
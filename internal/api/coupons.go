@@ -0,0 +1,414 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"stripe-go-spike/internal/audit"
+	"stripe-go-spike/internal/coupons"
+	"stripe-go-spike/internal/data"
+	"stripe-go-spike/internal/db"
+	"stripe-go-spike/internal/money"
+	"stripe-go-spike/internal/payments"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CouponRequest creates a coupon. Exactly one of PercentOff and AmountOff is set.
+type CouponRequest struct {
+	Code           string        `json:"code" binding:"required"`
+	PercentOff     int64         `json:"percent_off"`
+	AmountOff      *money.Amount `json:"amount_off"`
+	ExpiresAt      *time.Time    `json:"expires_at"`
+	MaxRedemptions int64         `json:"max_redemptions"` // zero for no limit
+	PerUserLimit   int64         `json:"per_user_limit"`  // zero for no limit
+	ProductIDs     []string      `json:"product_ids"`     // empty for every product
+}
+
+// CouponUpdateRequest replaces the expiry, limits and product restrictions of a
+// coupon. The code and discount can't change once customers may have seen them.
+type CouponUpdateRequest struct {
+	ExpiresAt      *time.Time `json:"expires_at"`
+	MaxRedemptions int64      `json:"max_redemptions"`
+	PerUserLimit   int64      `json:"per_user_limit"`
+	ProductIDs     []string   `json:"product_ids"`
+}
+
+type CouponsResponse struct {
+	Coupons []data.Coupon `json:"coupons"`
+}
+
+// GetCoupons lists coupons, newest first (admin view)
+func (h *Handlers) GetCoupons(c *gin.Context) {
+	limit := int64(50)
+	offset := int64(0)
+
+	// Parse pagination parameters
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.ParseInt(limitStr, 10, 64); err == nil && l > 0 {
+			limit = l
+		}
+	}
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		if o, err := strconv.ParseInt(offsetStr, 10, 64); err == nil && o >= 0 {
+			offset = o
+		}
+	}
+
+	ctx := c.Request.Context()
+	rows, err := h.store.ListCoupons(ctx, db.ListCouponsParams{Limit: limit, Offset: offset})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch coupons"})
+		return
+	}
+	result := make([]data.Coupon, len(rows))
+	for i, row := range rows {
+		if result[i], err = h.toCoupon(ctx, row); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch coupons"})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, CouponsResponse{Coupons: result})
+}
+
+// GetCoupon returns one coupon with its redemption count (admin view)
+func (h *Handlers) GetCoupon(c *gin.Context) {
+	coupon, ok := h.lookupCoupon(c)
+	if !ok {
+		return
+	}
+	h.writeCoupon(c, http.StatusOK, coupon)
+}
+
+// CreateCoupon saves a coupon and mirrors it to Stripe as a coupon with a
+// promotion code of the same code (admin)
+func (h *Handlers) CreateCoupon(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req CouponRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	spec := coupons.Spec{
+		Code:           coupons.NormalizeCode(req.Code),
+		PercentOff:     req.PercentOff,
+		MaxRedemptions: req.MaxRedemptions,
+		PerUserLimit:   req.PerUserLimit,
+		ProductIDs:     req.ProductIDs,
+	}
+	if req.AmountOff != nil {
+		spec.AmountOff = *req.AmountOff
+	}
+	if req.ExpiresAt != nil {
+		spec.ExpiresAt = *req.ExpiresAt
+	}
+	if err := spec.Validate(time.Now()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !validProducts(c, spec.ProductIDs) {
+		return
+	}
+	if _, err := h.store.GetCouponByCode(ctx, spec.Code); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Coupon " + spec.Code + " already exists"})
+		return
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch coupon"})
+		return
+	}
+
+	stripeCoupon, err := h.service.CreateCoupon(ctx, payments.CouponParams{
+		Code:           spec.Code,
+		PercentOff:     spec.PercentOff,
+		AmountOff:      spec.AmountOff,
+		ExpiresAt:      spec.ExpiresAt,
+		MaxRedemptions: spec.MaxRedemptions,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := db.Now()
+	var coupon db.Coupon
+	var logged db.AuditEvent
+	err = h.store.WithTx(ctx, func(q db.Querier) error {
+		var err error
+		coupon, err = q.CreateCoupon(ctx, db.CreateCouponParams{
+			Code:                  spec.Code,
+			PercentOff:            spec.PercentOff,
+			AmountOff:             spec.AmountOff.Minor,
+			Currency:              sql.NullString{String: spec.AmountOff.Currency, Valid: spec.AmountOff.Currency != ""},
+			ExpiresAt:             nullTime(spec.ExpiresAt),
+			MaxRedemptions:        sql.NullInt64{Int64: spec.MaxRedemptions, Valid: spec.MaxRedemptions > 0},
+			PerUserLimit:          sql.NullInt64{Int64: spec.PerUserLimit, Valid: spec.PerUserLimit > 0},
+			StripeCouponID:        sql.NullString{String: stripeCoupon.CouponID, Valid: true},
+			StripePromotionCodeID: sql.NullString{String: stripeCoupon.PromotionCodeID, Valid: true},
+			CreatedAt:             now,
+			UpdatedAt:             now,
+		})
+		if err != nil {
+			return err
+		}
+		if err := setCouponProducts(ctx, q, coupon.ID, spec.ProductIDs); err != nil {
+			return err
+		}
+		logged, err = h.auditService.LogTx(ctx, q, audit.CouponCreated.New(
+			"Coupon "+coupon.Code+" created", couponPayload(coupon),
+		).WithRefs(coupon.StripePromotionCodeID.String, coupon.StripeCouponID.String))
+		return err
+	})
+	if err != nil {
+		log.Printf("failed to create coupon %s: %v", spec.Code, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create coupon"})
+		return
+	}
+	h.auditService.Publish(logged)
+	h.writeCoupon(c, http.StatusCreated, coupon)
+}
+
+// UpdateCoupon replaces a coupon's expiry, limits and product restrictions. A
+// changed expiry or redemption limit replaces the Stripe promotion code, which
+// Stripe doesn't allow to be edited (admin)
+func (h *Handlers) UpdateCoupon(c *gin.Context) {
+	ctx := c.Request.Context()
+	coupon, ok := h.lookupCoupon(c)
+	if !ok {
+		return
+	}
+	var req CouponUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if coupon.ArchivedAt.Valid {
+		c.JSON(http.StatusConflict, gin.H{"error": "Coupon " + coupon.Code + " is archived"})
+		return
+	}
+	var expiresAt time.Time
+	if req.ExpiresAt != nil {
+		expiresAt = *req.ExpiresAt
+	}
+	if err := coupons.ValidateLimits(expiresAt, req.MaxRedemptions, req.PerUserLimit, time.Now()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !validProducts(c, req.ProductIDs) {
+		return
+	}
+
+	params := db.UpdateCouponParams{
+		ExpiresAt:             nullTime(expiresAt),
+		MaxRedemptions:        sql.NullInt64{Int64: req.MaxRedemptions, Valid: req.MaxRedemptions > 0},
+		PerUserLimit:          sql.NullInt64{Int64: req.PerUserLimit, Valid: req.PerUserLimit > 0},
+		StripePromotionCodeID: coupon.StripePromotionCodeID,
+		UpdatedAt:             db.Now(),
+		ID:                    coupon.ID,
+	}
+	sameExpiry := params.ExpiresAt.Valid == coupon.ExpiresAt.Valid &&
+		params.ExpiresAt.Timestamp.Equal(coupon.ExpiresAt.Timestamp.Time)
+	if !sameExpiry || params.MaxRedemptions != coupon.MaxRedemptions {
+		promotionCodeID, err := h.service.ReplacePromotionCode(ctx, payments.StripeCoupon{
+			CouponID:        coupon.StripeCouponID.String,
+			PromotionCodeID: coupon.StripePromotionCodeID.String,
+		}, payments.CouponParams{Code: coupon.Code, ExpiresAt: expiresAt, MaxRedemptions: req.MaxRedemptions})
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+			return
+		}
+		params.StripePromotionCodeID = sql.NullString{String: promotionCodeID, Valid: true}
+	}
+
+	var logged db.AuditEvent
+	err := h.store.WithTx(ctx, func(q db.Querier) error {
+		var err error
+		coupon, err = q.UpdateCoupon(ctx, params)
+		if err != nil {
+			return err
+		}
+		if err := setCouponProducts(ctx, q, coupon.ID, req.ProductIDs); err != nil {
+			return err
+		}
+		logged, err = h.auditService.LogTx(ctx, q, audit.CouponUpdated.New(
+			"Coupon "+coupon.Code+" updated", couponPayload(coupon),
+		).WithRefs(coupon.StripePromotionCodeID.String, coupon.StripeCouponID.String))
+		return err
+	})
+	if err != nil {
+		log.Printf("failed to update coupon %d: %v", coupon.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update coupon"})
+		return
+	}
+	h.auditService.Publish(logged)
+	h.writeCoupon(c, http.StatusOK, coupon)
+}
+
+// ArchiveCoupon stops a coupon from being redeemed and deactivates its Stripe
+// promotion code. Transactions that used it keep their discount (admin)
+func (h *Handlers) ArchiveCoupon(c *gin.Context) {
+	ctx := c.Request.Context()
+	coupon, ok := h.lookupCoupon(c)
+	if !ok {
+		return
+	}
+	if coupon.ArchivedAt.Valid {
+		h.writeCoupon(c, http.StatusOK, coupon)
+		return
+	}
+	if err := h.service.DeactivatePromotionCode(ctx, coupon.StripePromotionCodeID.String); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := db.Now()
+	var logged db.AuditEvent
+	err := h.store.WithTx(ctx, func(q db.Querier) error {
+		err := q.ArchiveCoupon(ctx, db.ArchiveCouponParams{
+			ArchivedAt: db.NullTimestamp{Timestamp: now, Valid: true},
+			UpdatedAt:  now,
+			ID:         coupon.ID,
+		})
+		if err != nil {
+			return err
+		}
+		logged, err = h.auditService.LogTx(ctx, q, audit.CouponArchived.New(
+			"Coupon "+coupon.Code+" archived", couponPayload(coupon),
+		).WithRefs(coupon.StripePromotionCodeID.String, coupon.StripeCouponID.String))
+		return err
+	})
+	if err != nil {
+		log.Printf("failed to archive coupon %d: %v", coupon.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to archive coupon"})
+		return
+	}
+	h.auditService.Publish(logged)
+
+	coupon.ArchivedAt = db.NullTimestamp{Timestamp: now, Valid: true}
+	coupon.UpdatedAt = now
+	h.writeCoupon(c, http.StatusOK, coupon)
+}
+
+// lookupCoupon loads the coupon named by the :id parameter, writing the error
+// response if there is none.
+func (h *Handlers) lookupCoupon(c *gin.Context) (db.Coupon, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid coupon ID"})
+		return db.Coupon{}, false
+	}
+	coupon, err := h.store.GetCoupon(c.Request.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Coupon not found"})
+		return db.Coupon{}, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch coupon"})
+		return db.Coupon{}, false
+	}
+	return coupon, true
+}
+
+func (h *Handlers) writeCoupon(c *gin.Context, status int, coupon db.Coupon) {
+	result, err := h.toCoupon(c.Request.Context(), coupon)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch coupon"})
+		return
+	}
+	c.JSON(status, result)
+}
+
+// toCoupon converts a stored coupon into its API representation, with its
+// product restrictions and redemption count
+func (h *Handlers) toCoupon(ctx context.Context, coupon db.Coupon) (data.Coupon, error) {
+	products, err := h.store.ListCouponProducts(ctx, coupon.ID)
+	if err != nil {
+		return data.Coupon{}, err
+	}
+	redemptions, err := h.store.CountCouponRedemptions(ctx, sql.NullInt64{Int64: coupon.ID, Valid: true})
+	if err != nil {
+		return data.Coupon{}, err
+	}
+	result := data.Coupon{
+		ID:          coupon.ID,
+		Code:        coupon.Code,
+		PercentOff:  coupon.PercentOff,
+		ExpiresAt:   coupon.ExpiresAt.Ptr(),
+		ProductIDs:  products,
+		Redemptions: redemptions,
+		CreatedAt:   coupon.CreatedAt,
+		UpdatedAt:   coupon.UpdatedAt,
+		ArchivedAt:  coupon.ArchivedAt.Ptr(),
+	}
+	if coupon.AmountOff > 0 {
+		result.AmountOff = &money.Amount{Minor: coupon.AmountOff, Currency: coupon.Currency.String}
+	}
+	if coupon.MaxRedemptions.Valid {
+		result.MaxRedemptions = &coupon.MaxRedemptions.Int64
+	}
+	if coupon.PerUserLimit.Valid {
+		result.PerUserLimit = &coupon.PerUserLimit.Int64
+	}
+	if coupon.StripeCouponID.Valid {
+		result.StripeCouponID = &coupon.StripeCouponID.String
+	}
+	if coupon.StripePromotionCodeID.Valid {
+		result.StripePromotionCodeID = &coupon.StripePromotionCodeID.String
+	}
+	return result, nil
+}
+
+// validProducts checks product restrictions, writing the error response for an
+// unknown product.
+func validProducts(c *gin.Context, productIDs []string) bool {
+	for _, id := range productIDs {
+		if data.GetProductByID(id) == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID " + id})
+			return false
+		}
+	}
+	return true
+}
+
+// setCouponProducts replaces the products a coupon is restricted to.
+func setCouponProducts(ctx context.Context, q db.Querier, couponID int64, productIDs []string) error {
+	if err := q.DeleteCouponProducts(ctx, couponID); err != nil {
+		return err
+	}
+	for _, id := range productIDs {
+		if err := q.AddCouponProduct(ctx, db.AddCouponProductParams{CouponID: couponID, ProductID: id}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func couponPayload(coupon db.Coupon) audit.CouponPayload {
+	payload := audit.CouponPayload{
+		CouponID:              coupon.ID,
+		Code:                  coupon.Code,
+		PercentOff:            coupon.PercentOff,
+		AmountOff:             coupon.AmountOff,
+		Currency:              coupon.Currency.String,
+		MaxRedemptions:        coupon.MaxRedemptions.Int64,
+		PerUserLimit:          coupon.PerUserLimit.Int64,
+		StripeCouponID:        coupon.StripeCouponID.String,
+		StripePromotionCodeID: coupon.StripePromotionCodeID.String,
+	}
+	if coupon.ExpiresAt.Valid {
+		payload.ExpiresAt = coupon.ExpiresAt.Timestamp.String()
+	}
+	return payload
+}
+
+// nullTime returns a NULL timestamp for the zero time.
+func nullTime(t time.Time) db.NullTimestamp {
+	if t.IsZero() {
+		return db.NullTimestamp{}
+	}
+	return db.NewNullTimestamp(t)
+}
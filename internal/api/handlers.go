@@ -1,14 +1,23 @@
 package api
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"io"
+	"log"
 	"net/http"
 	"strconv"
 	"stripe-go-spike/internal/audit"
+	"stripe-go-spike/internal/connect"
+	"stripe-go-spike/internal/coupons"
 	"stripe-go-spike/internal/data"
 	"stripe-go-spike/internal/db"
+	"stripe-go-spike/internal/ledger"
+	"stripe-go-spike/internal/money"
+	"stripe-go-spike/internal/outbound"
 	"stripe-go-spike/internal/payments"
+	"stripe-go-spike/internal/tax"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -18,18 +27,19 @@ import (
 // Handlers holds the dependencies for the API handlers.
 type Handlers struct {
 	service      *payments.Service
-	db           *sql.DB
-	queries      *db.Queries
+	store        db.Repository
 	auditService *audit.Service
+	dispatcher   *outbound.Dispatcher // redelivers outbound webhooks on request
 }
 
 // NewHandlers creates new handlers.
 func NewHandlers(service *payments.Service, database *sql.DB, queries *db.Queries, auditService *audit.Service) *Handlers {
+	store := db.NewStore(database, queries)
 	return &Handlers{
 		service:      service,
-		db:           database,
-		queries:      queries,
+		store:        store,
 		auditService: auditService,
+		dispatcher:   &outbound.Dispatcher{Store: store, Audit: auditService},
 	}
 }
 
@@ -43,15 +53,48 @@ func (h *Handlers) Health(c *gin.Context) {
 type CheckoutSessionRequest struct {
 	UserID    string `json:"user_id" binding:"required"`
 	ProductID string `json:"product_id" binding:"required"`
+	// Currency overrides the user's preferred currency; the product must be priced in it
+	Currency string `json:"currency"`
+	// Country is an optional ISO 3166-1 country hint used when no currency is given.
+	// With Region (ISO 3166-2 subdivision, e.g. CA) it also picks the local tax
+	// rate; there is no local tax without a country.
+	Country string `json:"country"`
+	Region  string `json:"region"`
+	// Options override the configured checkout options the server allows to be
+	// overridden
+	Options payments.CheckoutOptions `json:"options"`
+	// PromoCode applies a coupon. AllowPromotionCodes lets the customer enter one
+	// on the Checkout page instead; Stripe doesn't allow both.
+	PromoCode           string `json:"promo_code"`
+	AllowPromotionCodes bool   `json:"allow_promotion_codes"`
 }
 
 type CheckoutSessionResponse struct {
-	SessionID string `json:"session_id"`
-	URL       string `json:"url"`
+	SessionID     string        `json:"session_id"`
+	URL           string        `json:"url"`
+	TransactionID string        `json:"transaction_id"`
+	ExpiresAt     *db.Timestamp `json:"expires_at,omitempty"`
+	// Reused is set when the user's open session for the same cart is returned
+	// instead of a new one
+	Reused bool `json:"reused,omitempty"`
+	// Amount is the price charged, after Discount and with Tax. Stripe automatic
+	// tax is added at checkout, so it isn't included.
+	Amount   money.Amount  `json:"amount"`
+	Discount *money.Amount `json:"discount,omitempty"`
+	Tax      *money.Amount `json:"tax,omitempty"`
+}
+
+// ProductWithPrice is a product with the price resolved for the caller.
+type ProductWithPrice struct {
+	data.Product
+	Price money.Amount `json:"price"`
+	// ConnectedAccountID is the vendor selling the product; unset for the
+	// platform's own products
+	ConnectedAccountID *int64 `json:"connected_account_id,omitempty"`
 }
 
 type ProductsResponse struct {
-	Products []data.Product `json:"products"`
+	Products []ProductWithPrice `json:"products"`
 }
 
 type UsersResponse struct {
@@ -87,86 +130,319 @@ func (h *Handlers) CreateCheckoutSession(c *gin.Context) {
 		return
 	}
 
+	// Choose the currency from the request, the user's profile or the locale
+	price, source, err := product.SelectPrice(data.CurrencyHints{
+		Requested:      req.Currency,
+		User:           user,
+		Country:        req.Country,
+		AcceptLanguage: c.GetHeader("Accept-Language"),
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := h.service.CheckoutOptions(req.Options); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.PromoCode != "" && req.AllowPromotionCodes {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "promo_code and allow_promotion_codes can't be combined"})
+		return
+	}
+
+	// Products sold by a vendor are paid to the vendor's connected account
+	vendor, hasVendor, err := connect.Lookup(c.Request.Context(), h.store, product.ID)
+	if errors.Is(err, connect.ErrNotReady) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch connected account"})
+		return
+	}
+	if hasVendor && req.AllowPromotionCodes {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "allow_promotion_codes can't be used for a vendor's product"})
+		return
+	}
+
+	// Work out the discount before looking for an open session, which must be for
+	// the same coupon and charge
+	var redemption coupons.Redemption
+	charge := price
+	if req.PromoCode != "" {
+		redemption, err = coupons.Apply(c.Request.Context(), h.store, req.PromoCode, product.ID, price, time.Now())
+		if errors.Is(err, coupons.ErrNotFound) || errors.Is(err, coupons.ErrNotRedeemable) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch coupon"})
+			return
+		}
+		charge = redemption.Total
+	}
+
+	// Local tax goes on top of the discounted price. Stripe automatic tax is only
+	// known once the customer has entered their address. The local rate follows
+	// the requested country, so Checkout collects the billing address for the
+	// webhook to check it against.
+	var rate db.TaxRate
+	taxAmount := money.Amount{Currency: charge.Currency}
+	total := charge
+	collectAddress := false
+	if !h.service.AutomaticTax() {
+		var ok bool
+		rate, ok, err = tax.Lookup(c.Request.Context(), h.store, req.Country, req.Region, product.TaxCode)
+		if err == nil {
+			collectAddress, err = tax.InUse(c.Request.Context(), h.store)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tax rates"})
+			return
+		}
+		if ok {
+			taxAmount = tax.Compute(charge, rate.RateBps)
+			if total, err = charge.Add(taxAmount); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+	}
+
+	// The vendor's transfer leaves the platform its fee and the tax
+	split := connect.Split{Fee: money.Amount{Currency: total.Currency}, Transfer: money.Amount{Currency: total.Currency}}
+	if hasVendor {
+		if split, err = connect.ComputeSplit(total, taxAmount, vendor.FeeBps); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// Send the user back to an unexpired session for the same cart and options
+	// rather than opening another one. Sessions that take promotion codes on the Checkout
+	// page aren't recorded as such, so they are always new.
+	if !req.AllowPromotionCodes {
+		if resp, ok := h.openSession(c.Request.Context(), req.UserID, product.ID, total, redemption.Coupon.ID, req.Options); ok {
+			c.JSON(http.StatusOK, resp)
+			return
+		}
+	}
+
+	// The open session above already holds a redemption, so limits are checked
+	// only for new ones. This check spares a Stripe session for a used-up coupon;
+	// the one that counts is Claim's, with the transaction insert.
+	if req.PromoCode != "" {
+		err := coupons.CheckLimits(c.Request.Context(), h.store, redemption.Coupon, req.UserID)
+		if errors.Is(err, coupons.ErrNotRedeemable) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count coupon redemptions"})
+			return
+		}
+	}
+
 	// Create transaction record
 	transactionID := uuid.New().String()
-	now := time.Now().UTC().Format(time.RFC3339)
+	now := db.Now()
 
 	// Create Stripe checkout session
 	sess, err := h.service.CreateCheckoutSession(payments.CheckoutSessionParams{
-		Amount:        product.Price,
-		Currency:      "usd",
+		Amount:        price,
 		UserID:        req.UserID,
 		ProductID:     req.ProductID,
 		TransactionID: transactionID,
+		Options:       req.Options,
+
+		PromotionCodeID:     redemption.Coupon.StripePromotionCodeID.String,
+		Discount:            redemption.Discount,
+		AllowPromotionCodes: req.AllowPromotionCodes,
+
+		TaxCode:   product.TaxCode,
+		TaxRateID: rate.StripeTaxRateID.String,
+		Tax:       taxAmount,
+
+		CollectBillingAddress: collectAddress,
+
+		Destination:    vendor.StripeAccountID,
+		ApplicationFee: split.Fee,
+		Transfer:       split.Transfer,
 	})
 	if err != nil {
 		// Log checkout session creation failure
-		h.auditService.LogStripe(c.Request.Context(), "checkout_session.failed",
+		h.auditService.Log(c.Request.Context(), audit.CheckoutSessionFailed.New(
 			"Failed to create Stripe checkout session",
-			&req.UserID,
-			map[string]interface{}{
-				"transaction_id": transactionID,
-				"error":          err.Error(),
-			})
+			audit.CheckoutFailedPayload{TransactionID: transactionID, Error: err.Error()},
+		).WithUser(req.UserID))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Now log transaction creation with the payment intent and session IDs
-	var paymentIntentRef *string
-	if sess.PaymentIntentID != "" {
-		paymentIntentRef = &sess.PaymentIntentID
-	}
-	h.auditService.LogPaymentWithRefs(c.Request.Context(), "transaction.created",
-		"Transaction created for checkout session",
-		&req.UserID,
-		map[string]interface{}{
-			"transaction_id":    transactionID,
-			"user_id":           req.UserID,
-			"product_id":        req.ProductID,
-			"product_name":      product.Name,
-			"amount":            product.Price,
-			"currency":          "usd",
-			"session_id":        sess.ID,
-			"payment_intent_id": sess.PaymentIntentID,
-		},
-		paymentIntentRef, // payment intent ID as primary reference
-		&sess.ID,         // session ID as secondary reference
-	)
-
-	// Save transaction to database
+	// Save the transaction and its creation event together
 	var stripePaymentIntentID sql.NullString
 	if sess.PaymentIntentID != "" {
 		stripePaymentIntentID = sql.NullString{String: sess.PaymentIntentID, Valid: true}
 	}
+	var expiresAt db.NullTimestamp
+	if !sess.ExpiresAt.IsZero() {
+		expiresAt = db.NullTimestamp{Timestamp: db.NewTimestamp(sess.ExpiresAt), Valid: true}
+	}
+
+	var created db.AuditEvent
+	err = h.store.WithTx(c.Request.Context(), func(q db.Querier) error {
+		if req.PromoCode != "" {
+			if err := coupons.Claim(c.Request.Context(), q, redemption.Coupon, req.UserID); err != nil {
+				return err
+			}
+		}
+		err := q.CreateTransaction(c.Request.Context(), db.CreateTransactionParams{
+			ID:                    transactionID,
+			UserID:                req.UserID,
+			ProductID:             req.ProductID,
+			ProductName:           product.Name,
+			Amount:                total.Minor,
+			Currency:              total.Currency,
+			StripeSessionID:       sql.NullString{String: sess.ID, Valid: true},
+			StripePaymentIntentID: stripePaymentIntentID,
+			Status:                "pending",
+			CreatedAt:             now,
+			UpdatedAt:             now,
+			RefundDate:            db.NullTimestamp{}, // Initially null
+			ExpiresAt:             expiresAt,
+			CouponID:              sql.NullInt64{Int64: redemption.Coupon.ID, Valid: redemption.Coupon.ID != 0},
+			DiscountAmount:        redemption.Discount.Minor,
+			TaxAmount:             taxAmount.Minor,
+			ConnectedAccountID:    sql.NullInt64{Int64: vendor.ID, Valid: hasVendor},
+			ApplicationFeeAmount:  split.Fee.Minor,
+			TransferAmount:        split.Transfer.Minor,
+			CheckoutOptions:       req.Options.Key(),
+		})
+		if err != nil {
+			return err
+		}
+		err = q.CreateTransactionLineItem(c.Request.Context(), db.CreateTransactionLineItemParams{
+			TransactionID:  transactionID,
+			ProductID:      product.ID,
+			Description:    product.Name,
+			Quantity:       1,
+			UnitAmount:     price.Minor,
+			Currency:       price.Currency,
+			DiscountAmount: redemption.Discount.Minor,
+			TaxAmount:      taxAmount.Minor,
+			TaxCode:        product.TaxCode,
+			TaxRateID:      sql.NullInt64{Int64: rate.ID, Valid: rate.ID != 0},
+		})
+		if err != nil {
+			return err
+		}
+
+		// The payment intent ID is the primary reference and the session ID the
+		// secondary reference
+		created, err = h.auditService.LogTx(c.Request.Context(), q, audit.TransactionCreated.New(
+			"Transaction created for checkout session",
+			audit.TransactionCreatedPayload{
+				TransactionID:   transactionID,
+				UserID:          req.UserID,
+				ProductID:       req.ProductID,
+				ProductName:     product.Name,
+				Amount:          total.Minor,
+				Currency:        total.Currency,
+				CurrencySource:  string(source),
+				SessionID:       sess.ID,
+				PaymentIntentID: sess.PaymentIntentID,
+				CouponCode:      redemption.Coupon.Code,
+				DiscountAmount:  redemption.Discount.Minor,
+				TaxAmount:       taxAmount.Minor,
 
-	err = h.queries.CreateTransaction(c.Request.Context(), db.CreateTransactionParams{
-		ID:                    transactionID,
-		UserID:                req.UserID,
-		ProductID:             req.ProductID,
-		ProductName:           product.Name,
-		Amount:                product.Price,
-		StripeSessionID:       sql.NullString{String: sess.ID, Valid: true},
-		StripePaymentIntentID: stripePaymentIntentID,
-		Status:                "pending",
-		CreatedAt:             now,
-		UpdatedAt:             now,
-		RefundDate:            sql.NullString{}, // Initially null
+				ConnectedAccountID:   vendor.StripeAccountID,
+				ApplicationFeeAmount: split.Fee.Minor,
+				TransferAmount:       split.Transfer.Minor,
+			},
+		).WithUser(req.UserID).WithRefs(sess.PaymentIntentID, sess.ID))
+		return err
 	})
+	if errors.Is(err, coupons.ErrNotRedeemable) {
+		// Another checkout took the last redemption meanwhile
+		if _, err := h.service.Gateway().ExpireCheckoutSession(c.Request.Context(), sess.ID); err != nil {
+			log.Printf("failed to expire checkout session %s: %v", sess.ID, err)
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
+		log.Printf("failed to create transaction %s: %v", transactionID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create transaction"})
 		return
 	}
+	h.auditService.Publish(created)
 
 	c.JSON(http.StatusOK, CheckoutSessionResponse{
-		SessionID: sess.ID,
-		URL:       sess.URL,
+		SessionID:     sess.ID,
+		URL:           sess.URL,
+		TransactionID: transactionID,
+		ExpiresAt:     expiresAt.Ptr(),
+		Amount:        total,
+		Discount:      amountPtr(redemption.Discount),
+		Tax:           amountPtr(taxAmount),
 	})
 }
 
-// GetProducts returns the list of hardcoded products
+// amountPtr returns nil for no discount or tax, to leave it out of responses.
+func amountPtr(discount money.Amount) *money.Amount {
+	if !discount.IsPositive() {
+		return nil
+	}
+	return &discount
+}
+
+// GetProducts returns the list of hardcoded products, each with the price it
+// would be charged at. The currency hints are the same as for checkout and come
+// from the currency, user_id and country query parameters and Accept-Language.
 func (h *Handlers) GetProducts(c *gin.Context) {
-	c.JSON(http.StatusOK, ProductsResponse{Products: data.Products})
+	hints := data.CurrencyHints{
+		Requested:      c.Query("currency"),
+		User:           data.GetUserByID(c.Query("user_id")),
+		Country:        c.Query("country"),
+		AcceptLanguage: c.GetHeader("Accept-Language"),
+	}
+	if hints.Requested != "" {
+		if _, ok := money.LookupCurrency(hints.Requested); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown currency " + hints.Requested})
+			return
+		}
+	}
+
+	assigned, err := h.store.ListProductAccounts(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch product accounts"})
+		return
+	}
+	vendors := make(map[string]int64, len(assigned))
+	for _, a := range assigned {
+		vendors[a.ProductID] = a.ConnectedAccountID
+	}
+
+	products := make([]ProductWithPrice, 0, len(data.Products))
+	for _, product := range data.Products {
+		price, _, err := product.SelectPrice(hints)
+		if errors.Is(err, data.ErrCurrencyUnavailable) {
+			// List the product in the currency it would otherwise be sold in
+			fallback := hints
+			fallback.Requested = ""
+			price, _, err = product.SelectPrice(fallback)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		item := ProductWithPrice{Product: product, Price: price}
+		if id, ok := vendors[product.ID]; ok {
+			item.ConnectedAccountID = &id
+		}
+		products = append(products, item)
+	}
+	c.JSON(http.StatusOK, ProductsResponse{Products: products})
 }
 
 // GetUsers returns the list of hardcoded users
@@ -176,7 +452,7 @@ func (h *Handlers) GetUsers(c *gin.Context) {
 
 // GetUserTransactions returns transactions for a specific user
 func (h *Handlers) GetUserTransactions(c *gin.Context) {
-	userID := c.Param("user_id")
+	userID := c.Param("id")
 	if userID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id is required"})
 		return
@@ -204,7 +480,7 @@ func (h *Handlers) GetUserTransactions(c *gin.Context) {
 		}
 	}
 
-	txns, err := h.queries.ListTransactionsByUserID(c.Request.Context(), db.ListTransactionsByUserIDParams{
+	txns, err := h.store.ListTransactionsByUserID(c.Request.Context(), db.ListTransactionsByUserIDParams{
 		UserID: userID,
 		Limit:  limit,
 		Offset: offset,
@@ -216,37 +492,7 @@ func (h *Handlers) GetUserTransactions(c *gin.Context) {
 
 	transactions := make([]data.Transaction, len(txns))
 	for i, txn := range txns {
-		createdAt, _ := time.Parse(time.RFC3339, txn.CreatedAt)
-		updatedAt, _ := time.Parse(time.RFC3339, txn.UpdatedAt)
-		transactions[i] = data.Transaction{
-			ID:          txn.ID,
-			UserID:      txn.UserID,
-			ProductID:   txn.ProductID,
-			ProductName: txn.ProductName,
-			Amount:      txn.Amount,
-			StripeSessionID: func() *string {
-				if txn.StripeSessionID.Valid {
-					return &txn.StripeSessionID.String
-				}
-				return nil
-			}(),
-			StripePaymentIntentID: func() *string {
-				if txn.StripePaymentIntentID.Valid {
-					return &txn.StripePaymentIntentID.String
-				}
-				return nil
-			}(),
-			Status:    txn.Status,
-			CreatedAt: createdAt,
-			UpdatedAt: updatedAt,
-			RefundDate: func() *time.Time {
-				if txn.RefundDate.Valid {
-					refundDate, _ := time.Parse(time.RFC3339, txn.RefundDate.String)
-					return &refundDate
-				}
-				return nil
-			}(),
-		}
+		transactions[i] = data.NewTransaction(txn)
 	}
 
 	c.JSON(http.StatusOK, TransactionsResponse{Transactions: transactions})
@@ -269,7 +515,7 @@ func (h *Handlers) GetAllTransactions(c *gin.Context) {
 		}
 	}
 
-	txns, err := h.queries.ListAllTransactions(c.Request.Context(), db.ListAllTransactionsParams{
+	txns, err := h.store.ListAllTransactions(c.Request.Context(), db.ListAllTransactionsParams{
 		Limit:  limit,
 		Offset: offset,
 	})
@@ -280,37 +526,7 @@ func (h *Handlers) GetAllTransactions(c *gin.Context) {
 
 	transactions := make([]data.Transaction, len(txns))
 	for i, txn := range txns {
-		createdAt, _ := time.Parse(time.RFC3339, txn.CreatedAt)
-		updatedAt, _ := time.Parse(time.RFC3339, txn.UpdatedAt)
-		transactions[i] = data.Transaction{
-			ID:          txn.ID,
-			UserID:      txn.UserID,
-			ProductID:   txn.ProductID,
-			ProductName: txn.ProductName,
-			Amount:      txn.Amount,
-			StripeSessionID: func() *string {
-				if txn.StripeSessionID.Valid {
-					return &txn.StripeSessionID.String
-				}
-				return nil
-			}(),
-			StripePaymentIntentID: func() *string {
-				if txn.StripePaymentIntentID.Valid {
-					return &txn.StripePaymentIntentID.String
-				}
-				return nil
-			}(),
-			Status:    txn.Status,
-			CreatedAt: createdAt,
-			UpdatedAt: updatedAt,
-			RefundDate: func() *time.Time {
-				if txn.RefundDate.Valid {
-					refundDate, _ := time.Parse(time.RFC3339, txn.RefundDate.String)
-					return &refundDate
-				}
-				return nil
-			}(),
-		}
+		transactions[i] = data.NewTransaction(txn)
 	}
 
 	c.JSON(http.StatusOK, TransactionsResponse{Transactions: transactions})
@@ -318,15 +534,15 @@ func (h *Handlers) GetAllTransactions(c *gin.Context) {
 
 // Webhook receiver for Stripe events
 func (h *Handlers) Webhook(c *gin.Context) {
+	ctx := c.Request.Context()
+
 	// Read the request body
 	body, err := io.ReadAll(c.Request.Body)
 	if err != nil {
-		h.auditService.LogStripe(c.Request.Context(), "webhook.read_failed",
+		h.auditService.Log(ctx, audit.WebhookReadFailed.New(
 			"Failed to read webhook request body",
-			nil,
-			map[string]interface{}{
-				"error": err.Error(),
-			})
+			audit.ErrorPayload{Error: err.Error()},
+		))
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
 		return
 	}
@@ -335,322 +551,560 @@ func (h *Handlers) Webhook(c *gin.Context) {
 	signature := c.GetHeader("Stripe-Signature")
 
 	// Log webhook received (no reference IDs available yet)
-	h.auditService.LogStripe(c.Request.Context(), "webhook.received",
+	h.auditService.Log(ctx, audit.WebhookReceived.New(
 		"Stripe webhook event received",
-		nil,
-		map[string]interface{}{
-			"body_length":   len(body),
-			"has_signature": signature != "",
-			"signature":     signature,
-			"raw_body":      string(body),
-		})
+		audit.WebhookReceivedPayload{
+			BodyLength:   len(body),
+			HasSignature: signature != "",
+			Signature:    signature,
+			RawBody:      string(body),
+		},
+	))
 
 	// Process the webhook event
 	event, err := h.service.ProcessWebhook(body, signature)
 	if err != nil {
 		// Log webhook processing failure (no reference IDs available)
-		h.auditService.LogStripe(c.Request.Context(), "webhook.processing_failed",
+		h.auditService.Log(ctx, audit.WebhookProcessingFailed.New(
 			"Failed to process webhook event",
-			nil,
-			map[string]interface{}{
-				"error":     err.Error(),
-				"signature": signature,
-			})
+			audit.WebhookFailedPayload{Error: err.Error(), Signature: signature},
+		))
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Log successful webhook processing with reference IDs
-	var paymentIntentRef, sessionRef *string
-	if event.PaymentIntentID != "" {
-		paymentIntentRef = &event.PaymentIntentID
-	}
-	if event.SessionID != "" {
-		sessionRef = &event.SessionID
-	}
-	h.auditService.LogStripeWithRefs(c.Request.Context(), "webhook.processed",
+	// All events below use the payment intent ID as primary reference
+	// and the session ID as secondary reference
+	h.auditService.Log(ctx, audit.WebhookProcessed.New(
 		"Stripe webhook event processed successfully",
-		nil,
-		map[string]interface{}{
-			"event_type":        event.Type,
-			"session_id":        event.SessionID,
-			"payment_intent_id": event.PaymentIntentID,
-			"status":            event.Status,
+		audit.WebhookProcessedPayload{
+			EventType:       event.Type,
+			SessionID:       event.SessionID,
+			PaymentIntentID: event.PaymentIntentID,
+			Status:          event.Status,
 		},
-		paymentIntentRef, // payment intent ID as primary reference
-		sessionRef,       // session ID as secondary reference
-	)
+	).WithRefs(event.PaymentIntentID, event.SessionID))
+
+	// Payment intent events don't carry the charge's balance transaction, so the
+	// fee is fetched before the event is kept. Failing the webhook makes Stripe
+	// redeliver it rather than book the payment without its fee.
+	if event.Type == "payment_intent.succeeded" && event.PaymentIntentID != "" && !event.Fee.IsPositive() {
+		fee, err := h.service.Gateway().ChargeFee(ctx, event.PaymentIntentID)
+		if err != nil {
+			h.auditService.Log(ctx, audit.WebhookProcessingFailed.New(
+				"Failed to fetch the Stripe fee for a payment",
+				audit.WebhookFailedPayload{Error: err.Error(), Signature: signature},
+			).WithRefs(event.PaymentIntentID, ""))
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to fetch the Stripe fee"})
+			return
+		}
+		event.Fee = fee
+	}
+
+	// Keep the event in the inbox so it shows up on transaction timelines. An
+	// event already there is a redelivery and was handled the first time.
+	inboxID, duplicate := h.recordWebhook(ctx, event)
+	if duplicate {
+		c.Status(http.StatusOK)
+		return
+	}
 
 	// Handle different event types
+	var update *statusUpdate
 	switch event.Type {
 	case "checkout.session.completed":
 		if event.SessionID != "" {
-			// Log session completion with reference IDs
-			var paymentIntentRef *string
-			if event.PaymentIntentID != "" {
-				paymentIntentRef = &event.PaymentIntentID
-			}
-			h.auditService.LogStripeWithRefs(c.Request.Context(), "checkout_session.completed",
+			h.auditService.Log(ctx, audit.CheckoutSessionCompleted.New(
 				"Checkout session completed",
-				nil,
-				map[string]interface{}{
-					"session_id":        event.SessionID,
-					"payment_intent_id": event.PaymentIntentID,
-				},
-				paymentIntentRef, // payment intent ID as primary reference
-				&event.SessionID, // session ID as secondary reference
-			)
-
-			// Update transaction status in database
-			now := time.Now().UTC().Format(time.RFC3339)
-			var paymentIntentID sql.NullString
-			if event.PaymentIntentID != "" {
-				paymentIntentID = sql.NullString{String: event.PaymentIntentID, Valid: true}
-			}
+				audit.SessionRefPayload{SessionID: event.SessionID, PaymentIntentID: event.PaymentIntentID},
+			).WithRefs(event.PaymentIntentID, event.SessionID))
 
-			err = h.queries.UpdateTransactionWithStripeData(c.Request.Context(), db.UpdateTransactionWithStripeDataParams{
-				StripeSessionID:       sql.NullString{String: event.SessionID, Valid: true},
-				StripePaymentIntentID: paymentIntentID,
-				Status:                "completed",
-				UpdatedAt:             now,
-			})
-			if err != nil {
-				// Log database update failure with reference IDs
-				var paymentIntentRef *string
-				if event.PaymentIntentID != "" {
-					paymentIntentRef = &event.PaymentIntentID
-				}
-				h.auditService.LogPaymentWithRefs(c.Request.Context(), "transaction.update_failed",
-					"Failed to update transaction status",
-					nil,
-					map[string]interface{}{
-						"session_id":        event.SessionID,
-						"payment_intent_id": event.PaymentIntentID,
-						"error":             err.Error(),
-					},
-					paymentIntentRef, // payment intent ID as primary reference
-					&event.SessionID, // session ID as secondary reference
-				)
-				// Log error but don't fail the webhook
-				// In production, you might want to queue this for retry
-			} else {
-				// Log successful transaction update with reference IDs
-				var paymentIntentRef *string
-				if event.PaymentIntentID != "" {
-					paymentIntentRef = &event.PaymentIntentID
-				}
-				h.auditService.LogPaymentWithRefs(c.Request.Context(), "transaction.completed",
-					"Transaction marked as completed",
-					nil,
-					map[string]interface{}{
-						"session_id":        event.SessionID,
-						"payment_intent_id": event.PaymentIntentID,
-					},
-					paymentIntentRef, // payment intent ID as primary reference
-					&event.SessionID, // session ID as secondary reference
-				)
-			}
+			update = &statusUpdate{status: "completed", bySession: true, success: audit.TransactionCompleted,
+				successInfo: "Transaction marked as completed",
+				failureInfo: "Failed to update transaction status"}
 		}
 
 	case "payment_intent.succeeded":
 		// Payment completed successfully
 		if event.PaymentIntentID != "" {
-			now := time.Now().UTC().Format(time.RFC3339)
-			err = h.queries.UpdateTransactionByPaymentIntentID(c.Request.Context(), db.UpdateTransactionByPaymentIntentIDParams{
-				StripePaymentIntentID: sql.NullString{String: event.PaymentIntentID, Valid: true},
-				Status:                "completed",
-				UpdatedAt:             now,
-			})
-			if err != nil {
-				// Log database update failure with payment intent reference
-				h.auditService.LogPaymentWithRefs(c.Request.Context(), "transaction.update_failed",
-					"Failed to update transaction status for payment intent",
-					nil,
-					map[string]interface{}{
-						"payment_intent_id": event.PaymentIntentID,
-						"error":             err.Error(),
-					},
-					&event.PaymentIntentID, // payment intent ID as primary reference
-					nil,                    // no secondary reference
-				)
-			} else {
-				// Log successful transaction update with payment intent reference
-				h.auditService.LogPaymentWithRefs(c.Request.Context(), "transaction.completed",
-					"Transaction marked as completed via payment intent",
-					nil,
-					map[string]interface{}{
-						"payment_intent_id": event.PaymentIntentID,
-					},
-					&event.PaymentIntentID, // payment intent ID as primary reference
-					nil,                    // no secondary reference
-				)
-			}
+			update = &statusUpdate{status: "completed", success: audit.TransactionCompleted,
+				successInfo: "Transaction marked as completed via payment intent",
+				failureInfo: "Failed to update transaction status for payment intent"}
 		}
 
 	case "payment_intent.payment_failed":
 		// Mark transaction as failed
 		if event.PaymentIntentID != "" {
-			now := time.Now().UTC().Format(time.RFC3339)
-			err = h.queries.UpdateTransactionByPaymentIntentID(c.Request.Context(), db.UpdateTransactionByPaymentIntentIDParams{
-				StripePaymentIntentID: sql.NullString{String: event.PaymentIntentID, Valid: true},
-				Status:                "failed",
-				UpdatedAt:             now,
-			})
-			if err != nil {
-				// Log database update failure with payment intent reference
-				h.auditService.LogPaymentWithRefs(c.Request.Context(), "transaction.update_failed",
-					"Failed to update transaction status for failed payment",
-					nil,
-					map[string]interface{}{
-						"payment_intent_id": event.PaymentIntentID,
-						"error":             err.Error(),
-					},
-					&event.PaymentIntentID, // payment intent ID as primary reference
-					nil,                    // no secondary reference
-				)
-			} else {
-				// Log successful transaction update with payment intent reference
-				h.auditService.LogPaymentWithRefs(c.Request.Context(), "transaction.failed",
-					"Transaction marked as failed via payment intent",
-					nil,
-					map[string]interface{}{
-						"payment_intent_id": event.PaymentIntentID,
-					},
-					&event.PaymentIntentID, // payment intent ID as primary reference
-					nil,                    // no secondary reference
-				)
-			}
+			update = &statusUpdate{status: "failed", success: audit.TransactionFailed,
+				successInfo: "Transaction marked as failed via payment intent",
+				failureInfo: "Failed to update transaction status for failed payment"}
 		}
 
 	case "checkout.session.expired":
 		// Mark transaction as cancelled
 		if event.SessionID != "" {
-			now := time.Now().UTC().Format(time.RFC3339)
-			var paymentIntentID sql.NullString
-			if event.PaymentIntentID != "" {
-				paymentIntentID = sql.NullString{String: event.PaymentIntentID, Valid: true}
-			}
-
-			err = h.queries.UpdateTransactionWithStripeData(c.Request.Context(), db.UpdateTransactionWithStripeDataParams{
-				StripeSessionID:       sql.NullString{String: event.SessionID, Valid: true},
-				StripePaymentIntentID: paymentIntentID,
-				Status:                "cancelled",
-				UpdatedAt:             now,
-			})
-			if err != nil {
-				// Log database update failure with reference IDs
-				var paymentIntentRef *string
-				if event.PaymentIntentID != "" {
-					paymentIntentRef = &event.PaymentIntentID
-				}
-				h.auditService.LogPaymentWithRefs(c.Request.Context(), "transaction.update_failed",
-					"Failed to update transaction status for expired session",
-					nil,
-					map[string]interface{}{
-						"session_id":        event.SessionID,
-						"payment_intent_id": event.PaymentIntentID,
-						"error":             err.Error(),
-					},
-					paymentIntentRef, // payment intent ID as primary reference
-					&event.SessionID, // session ID as secondary reference
-				)
-			} else {
-				// Log successful transaction update with reference IDs
-				var paymentIntentRef *string
-				if event.PaymentIntentID != "" {
-					paymentIntentRef = &event.PaymentIntentID
-				}
-				h.auditService.LogPaymentWithRefs(c.Request.Context(), "transaction.cancelled",
-					"Transaction marked as cancelled due to expired session",
-					nil,
-					map[string]interface{}{
-						"session_id":        event.SessionID,
-						"payment_intent_id": event.PaymentIntentID,
-					},
-					paymentIntentRef, // payment intent ID as primary reference
-					&event.SessionID, // session ID as secondary reference
-				)
-			}
+			update = &statusUpdate{status: "cancelled", bySession: true, success: audit.TransactionCancelled,
+				successInfo: "Transaction marked as cancelled due to expired session",
+				failureInfo: "Failed to update transaction status for expired session"}
 		}
 
 	case "payment_intent.canceled":
 		// Mark transaction as cancelled via payment intent
 		if event.PaymentIntentID != "" {
-			now := time.Now().UTC().Format(time.RFC3339)
-			err = h.queries.UpdateTransactionByPaymentIntentID(c.Request.Context(), db.UpdateTransactionByPaymentIntentIDParams{
-				StripePaymentIntentID: sql.NullString{String: event.PaymentIntentID, Valid: true},
-				Status:                "cancelled",
-				UpdatedAt:             now,
-			})
-			if err != nil {
-				// Log database update failure with payment intent reference
-				h.auditService.LogPaymentWithRefs(c.Request.Context(), "transaction.update_failed",
-					"Failed to update transaction status for cancelled payment intent",
-					nil,
-					map[string]interface{}{
-						"payment_intent_id": event.PaymentIntentID,
-						"error":             err.Error(),
-					},
-					&event.PaymentIntentID, // payment intent ID as primary reference
-					nil,                    // no secondary reference
-				)
-			} else {
-				// Log successful transaction update with payment intent reference
-				h.auditService.LogPaymentWithRefs(c.Request.Context(), "transaction.cancelled",
-					"Transaction marked as cancelled via payment intent cancellation",
-					nil,
-					map[string]interface{}{
-						"payment_intent_id": event.PaymentIntentID,
-					},
-					&event.PaymentIntentID, // payment intent ID as primary reference
-					nil,                    // no secondary reference
-				)
-			}
+			update = &statusUpdate{status: "cancelled", success: audit.TransactionCancelled,
+				successInfo: "Transaction marked as cancelled via payment intent cancellation",
+				failureInfo: "Failed to update transaction status for cancelled payment intent"}
 		}
 
 	case "charge.dispute.created", "refund.created":
 		// Mark transaction as refunded
 		if event.PaymentIntentID != "" {
-			now := time.Now().UTC().Format(time.RFC3339)
-			err = h.queries.UpdateTransactionByPaymentIntentIDWithRefundDate(c.Request.Context(), db.UpdateTransactionByPaymentIntentIDWithRefundDateParams{
-				StripePaymentIntentID: sql.NullString{String: event.PaymentIntentID, Valid: true},
-				Status:                "refunded",
-				UpdatedAt:             now,
-				RefundDate:            sql.NullString{String: now, Valid: true},
-			})
+			update = &statusUpdate{status: "refunded", success: audit.TransactionRefunded,
+				successInfo: "Transaction marked as refunded",
+				failureInfo: "Failed to update transaction status for refund/dispute"}
+		}
+
+	case "account.updated":
+		// A vendor's onboarding progressed; not a transaction change
+		if event.Account != nil && event.Account.ID != "" {
+			h.applyAccountUpdate(ctx, event, inboxID)
+			c.Status(http.StatusOK)
+			return
+		}
+	}
+
+	if update != nil {
+		h.applyStatusUpdate(ctx, event, inboxID, *update)
+	} else if err := settleWebhook(ctx, h.store, inboxID, nil); err != nil {
+		log.Printf("failed to update webhook inbox entry %d: %v", inboxID, err)
+	}
+	c.Status(http.StatusOK)
+}
+
+// recordWebhook stores a verified webhook event in the inbox and returns its ID,
+// or 0 if it could not be stored. It reports a duplicate when the event is
+// already in the inbox.
+func (h *Handlers) recordWebhook(ctx context.Context, event *payments.WebhookEvent) (int64, bool) {
+	entry, err := h.store.CreateWebhookInboxEntry(ctx, db.CreateWebhookInboxEntryParams{
+		StripeEventID:   event.ID,
+		EventType:       event.Type,
+		SessionID:       sql.NullString{String: event.SessionID, Valid: event.SessionID != ""},
+		PaymentIntentID: sql.NullString{String: event.PaymentIntentID, Valid: event.PaymentIntentID != ""},
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, true
+	}
+	if err != nil {
+		log.Printf("failed to record webhook %s in inbox: %v", event.ID, err)
+		return 0, false
+	}
+	return entry.ID, false
+}
+
+// settleWebhook marks an inbox entry as processed, or failed when handling it
+// returned an error. Entries that could not be recorded (ID 0) are skipped.
+func settleWebhook(ctx context.Context, q db.Querier, inboxID int64, handleErr error) error {
+	if inboxID == 0 {
+		return nil
+	}
+	params := db.UpdateWebhookInboxStatusParams{
+		Status:      "processed",
+		ProcessedAt: db.NullTimestamp{Timestamp: db.Now(), Valid: true},
+		ID:          inboxID,
+	}
+	if handleErr != nil {
+		params.Status = "failed"
+		params.Error = sql.NullString{String: handleErr.Error(), Valid: true}
+	}
+	return q.UpdateWebhookInboxStatus(ctx, params)
+}
+
+// statusUpdate describes the transaction change a webhook event triggers.
+type statusUpdate struct {
+	status      string
+	bySession   bool // find the transaction by checkout session instead of payment intent
+	success     audit.EventDef[audit.TransactionStatusPayload]
+	successInfo string
+	failureInfo string
+}
+
+// apply updates the transaction owning the event's session or payment intent and
+// reports whether it changed. Refunds also record the refund date. A status only
+// moves forward, so a late or redelivered event for a settled transaction, or an
+// event for an unknown one, changes nothing.
+func (u statusUpdate) apply(ctx context.Context, q db.Querier, event *payments.WebhookEvent) (bool, error) {
+	now := db.Now()
+	var n int64
+	var err error
+	switch {
+	case u.bySession:
+		var paymentIntentID sql.NullString
+		if event.PaymentIntentID != "" {
+			paymentIntentID = sql.NullString{String: event.PaymentIntentID, Valid: true}
+		}
+		n, err = q.UpdateTransactionWithStripeData(ctx, db.UpdateTransactionWithStripeDataParams{
+			StripeSessionID:       sql.NullString{String: event.SessionID, Valid: true},
+			StripePaymentIntentID: paymentIntentID,
+			Status:                u.status,
+			UpdatedAt:             now,
+		})
+	case u.status == "refunded":
+		n, err = q.UpdateTransactionByPaymentIntentIDWithRefundDate(ctx, db.UpdateTransactionByPaymentIntentIDWithRefundDateParams{
+			StripePaymentIntentID: sql.NullString{String: event.PaymentIntentID, Valid: true},
+			Status:                u.status,
+			UpdatedAt:             now,
+			RefundDate:            db.NullTimestamp{Timestamp: now, Valid: true},
+		})
+	default:
+		n, err = q.UpdateTransactionByPaymentIntentID(ctx, db.UpdateTransactionByPaymentIntentIDParams{
+			StripePaymentIntentID: sql.NullString{String: event.PaymentIntentID, Valid: true},
+			Status:                u.status,
+			UpdatedAt:             now,
+		})
+	}
+	return n > 0, err
+}
+
+// applyStripeDiscount records a promotion code the customer entered on the
+// Checkout page: the transaction was created at the full price, so its amount
+// becomes what Stripe charged. Codes of unknown coupons are still recorded as a
+// discount. Stripe doesn't know a coupon's product restriction or per-user
+// limit, so a code that broke them is still recorded, since the customer has
+// paid the discounted price, and flagged with a coupon.violated event.
+func (h *Handlers) applyStripeDiscount(ctx context.Context, q db.Querier, event *payments.WebhookEvent, u statusUpdate) ([]db.AuditEvent, error) {
+	if !u.bySession || u.status != "completed" || !event.Discount.IsPositive() || event.Amount.Currency != event.Discount.Currency {
+		return nil, nil
+	}
+	txn, err := q.GetTransactionByStripeSessionID(ctx, sql.NullString{String: event.SessionID, Valid: true})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if txn.CouponID.Valid || txn.DiscountAmount != 0 {
+		// Created with a coupon, or recorded by an earlier delivery
+		return nil, nil
+	}
+
+	var couponID sql.NullInt64
+	var flagged []db.AuditEvent
+	if event.PromotionCodeID != "" {
+		coupon, err := q.GetCouponByStripePromotionCodeID(ctx, sql.NullString{String: event.PromotionCodeID, Valid: true})
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		if err == nil {
+			couponID = sql.NullInt64{Int64: coupon.ID, Valid: true}
+			err := coupons.CheckEntered(ctx, q, coupon, txn.ProductID, txn.UserID)
+			if err != nil && !errors.Is(err, coupons.ErrNotRedeemable) {
+				return nil, err
+			}
 			if err != nil {
-				// Log database update failure with payment intent reference
-				h.auditService.LogPaymentWithRefs(c.Request.Context(), "transaction.update_failed",
-					"Failed to update transaction status for refund/dispute",
-					nil,
-					map[string]interface{}{
-						"payment_intent_id": event.PaymentIntentID,
-						"event_type":        event.Type,
-						"error":             err.Error(),
-					},
-					&event.PaymentIntentID, // payment intent ID as primary reference
-					nil,                    // no secondary reference
-				)
-			} else {
-				// Log successful transaction update with payment intent reference
-				h.auditService.LogPaymentWithRefs(c.Request.Context(), "transaction.refunded",
-					"Transaction marked as refunded",
-					nil,
-					map[string]interface{}{
-						"payment_intent_id": event.PaymentIntentID,
-						"event_type":        event.Type,
+				logged, err := h.auditService.LogTx(ctx, q, audit.CouponViolated.New(
+					"Promotion code entered at Stripe broke its coupon's restrictions",
+					audit.CouponViolationPayload{
+						CouponID:      coupon.ID,
+						Code:          coupon.Code,
+						TransactionID: txn.ID,
+						SessionID:     event.SessionID,
+						Error:         err.Error(),
 					},
-					&event.PaymentIntentID, // payment intent ID as primary reference
-					nil,                    // no secondary reference
-				)
+				).WithUser(txn.UserID).WithRefs(event.PaymentIntentID, event.SessionID))
+				if err != nil {
+					return nil, err
+				}
+				flagged = append(flagged, logged)
 			}
 		}
 	}
+	err = q.ApplyStripeDiscount(ctx, db.ApplyStripeDiscountParams{
+		Amount:          event.Amount.Minor,
+		DiscountAmount:  event.Discount.Minor,
+		CouponID:        couponID,
+		UpdatedAt:       db.Now(),
+		StripeSessionID: sql.NullString{String: event.SessionID, Valid: true},
+	})
+	return flagged, err
+}
 
-	c.Status(http.StatusOK)
+// applyStripeTax records the tax Stripe automatic tax added to a completed
+// session: the transaction was created without it, so its amount becomes what
+// Stripe charged. Transactions taxed locally already have their tax.
+func applyStripeTax(ctx context.Context, q db.Querier, event *payments.WebhookEvent, u statusUpdate) error {
+	if !u.bySession || u.status != "completed" || !event.Tax.IsPositive() || event.Amount.Currency != event.Tax.Currency {
+		return nil
+	}
+	return q.ApplyStripeTax(ctx, db.ApplyStripeTaxParams{
+		Amount:          event.Amount.Minor,
+		TaxAmount:       event.Tax.Minor,
+		UpdatedAt:       db.Now(),
+		StripeSessionID: sql.NullString{String: event.SessionID, Valid: true},
+	})
 }
 
-// GetAuditEvents returns audit events with optional filtering
+// checkTaxRate compares the local tax rate a completed session was charged,
+// picked from the country the checkout request named, with the rate for the
+// billing address the customer entered. A different rate is flagged with a
+// tax_rate.mismatch event; the charge stands.
+func (h *Handlers) checkTaxRate(ctx context.Context, q db.Querier, event *payments.WebhookEvent, u statusUpdate) ([]db.AuditEvent, error) {
+	if !u.bySession || u.status != "completed" || event.BillingCountry == "" || h.service.AutomaticTax() {
+		return nil, nil
+	}
+	txn, err := q.GetTransactionByStripeSessionID(ctx, sql.NullString{String: event.SessionID, Valid: true})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	items, err := q.ListTransactionLineItems(ctx, txn.ID)
+	if err != nil || len(items) == 0 {
+		return nil, err
+	}
+	item := items[0]
+
+	var charged db.TaxRate
+	if item.TaxRateID.Valid {
+		if charged, err = q.GetTaxRate(ctx, item.TaxRateID.Int64); err != nil {
+			return nil, err
+		}
+	}
+	expected, _, err := tax.Lookup(ctx, q, event.BillingCountry, event.BillingRegion, item.TaxCode)
+	if err != nil {
+		return nil, err
+	}
+	if expected.RateBps == charged.RateBps {
+		return nil, nil
+	}
+	logged, err := h.auditService.LogTx(ctx, q, audit.TaxRateMismatch.New(
+		"Tax rate charged doesn't match the billing address",
+		audit.TaxMismatchPayload{
+			TransactionID:   txn.ID,
+			SessionID:       event.SessionID,
+			BillingCountry:  event.BillingCountry,
+			BillingRegion:   event.BillingRegion,
+			ChargedRateID:   charged.ID,
+			ChargedRateBps:  charged.RateBps,
+			ExpectedRateID:  expected.ID,
+			ExpectedRateBps: expected.RateBps,
+		},
+	).WithUser(txn.UserID).WithRefs(event.PaymentIntentID, event.SessionID))
+	if err != nil {
+		return nil, err
+	}
+	return []db.AuditEvent{logged}, nil
+}
+
+// syncApplicationFee gives the platform the tax Stripe added to a vendor's
+// transaction: the vendor's transfer was fixed at checkout, so the fee is the
+// rest of the amount charged.
+func syncApplicationFee(ctx context.Context, q db.Querier, event *payments.WebhookEvent, u statusUpdate) error {
+	if !u.bySession || u.status != "completed" || !event.Tax.IsPositive() {
+		return nil
+	}
+	txn, err := q.GetTransactionByStripeSessionID(ctx, sql.NullString{String: event.SessionID, Valid: true})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !txn.ConnectedAccountID.Valid || txn.ApplicationFeeAmount+txn.TransferAmount == txn.Amount {
+		return nil
+	}
+	return q.SetTransactionApplicationFee(ctx, db.SetTransactionApplicationFeeParams{
+		ApplicationFeeAmount: txn.Amount - txn.TransferAmount,
+		UpdatedAt:            db.Now(),
+		ID:                   txn.ID,
+	})
+}
+
+// syncLineItem copies a discount or tax applied at Stripe to the transaction's
+// line item.
+func syncLineItem(ctx context.Context, q db.Querier, event *payments.WebhookEvent, u statusUpdate) error {
+	if !u.bySession || u.status != "completed" || (!event.Discount.IsPositive() && !event.Tax.IsPositive()) {
+		return nil
+	}
+	txn, err := q.GetTransactionByStripeSessionID(ctx, sql.NullString{String: event.SessionID, Valid: true})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return q.UpdateLineItemAdjustments(ctx, db.UpdateLineItemAdjustmentsParams{
+		DiscountAmount: txn.DiscountAmount,
+		TaxAmount:      txn.TaxAmount,
+		TransactionID:  txn.ID,
+	})
+}
+
+// postLedger records the money movement behind a status update: the charge of a
+// completed transaction and its transfer to a vendor, refunds, disputes and the
+// fees an event carries. Completed payments carry the fee Webhook fetched for
+// payment_intent.succeeded; disputes list theirs in the payload. Entries already
+// posted (redelivered events, or the second of the completion events) are
+// skipped, as are events for unknown transactions.
+func postLedger(ctx context.Context, q db.Querier, event *payments.WebhookEvent, u statusUpdate) error {
+	txn, ok, err := findTransaction(ctx, q, event, u)
+	if err != nil || !ok {
+		return err
+	}
+
+	// Partial refunds and disputes carry their own amount; otherwise the whole
+	// transaction amount moved
+	amount := event.Amount
+	if !amount.IsPositive() || amount.Currency != txn.Currency {
+		amount = money.Amount{Minor: txn.Amount, Currency: txn.Currency}
+	}
+	objectID := event.ObjectID
+	if objectID == "" {
+		objectID = event.ID
+	}
+	var entries []ledger.Entry
+	switch {
+	case u.status == "completed":
+		entries = append(entries, ledger.Charge(txn, amount, event.ID))
+		if txn.TransferAmount > 0 {
+			transfer := money.Amount{Minor: txn.TransferAmount, Currency: txn.Currency}
+			entries = append(entries, ledger.Transfer(txn, transfer, event.ID))
+		}
+	case event.Type == "refund.created":
+		entries = append(entries, ledger.Refund(txn, amount, objectID, event.ID))
+	case event.Type == "charge.dispute.created":
+		entries = append(entries, ledger.Dispute(txn, amount, objectID, event.ID))
+	}
+	if event.Fee.IsPositive() {
+		entries = append(entries, ledger.Fee(txn, event.Fee, objectID, event.ID))
+	}
+	for _, entry := range entries {
+		if _, err := ledger.Post(ctx, q, entry); err != nil && !errors.Is(err, ledger.ErrDuplicate) {
+			return err
+		}
+	}
+	return nil
+}
+
+// queueStatusEvent writes the status change to the outbox, whose relay hands it
+// to the outbound webhook endpoints and the other consumers. A change is written
+// once, however often Stripe reports it: completions by both the session and
+// the payment intent, and redelivered events. Each refund or dispute is a change
+// of its own. It is only called when the guarded status update changed the
+// transaction, so an event that would move the status back sends nothing.
+func queueStatusEvent(ctx context.Context, q db.Querier, event *payments.WebhookEvent, u statusUpdate) error {
+	txn, ok, err := findTransaction(ctx, q, event, u)
+	if err != nil || !ok {
+		return err
+	}
+	objectID := event.ObjectID
+	if objectID == "" {
+		objectID = event.ID
+	}
+	return outbound.QueueTransactionEvent(ctx, q, txn, outbound.TransactionEventKey(txn.ID, u.status, objectID))
+}
+
+// findTransaction returns the transaction a status update applies to, if known.
+func findTransaction(ctx context.Context, q db.Querier, event *payments.WebhookEvent, u statusUpdate) (db.Transaction, bool, error) {
+	var txn db.Transaction
+	var err error
+	if u.bySession {
+		txn, err = q.GetTransactionByStripeSessionID(ctx, sql.NullString{String: event.SessionID, Valid: true})
+	} else {
+		txn, err = q.GetTransactionByPaymentIntentID(ctx, sql.NullString{String: event.PaymentIntentID, Valid: true})
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return txn, false, nil
+	}
+	return txn, err == nil, err
+}
+
+// applyStatusUpdate commits the status change, its ledger entries, its outbox
+// message, its audit event and the inbox settlement in one unit of work, so a
+// crash can't leave a changed transaction without its ledger entries, side
+// effects or audit trail. When the unit of work fails, the failure is audited
+// and the inbox entry marked failed after the rollback. Failures don't fail the
+// webhook; the inbox keeps them for a retry. An event that changes no
+// transaction only settles its inbox entry.
+func (h *Handlers) applyStatusUpdate(ctx context.Context, event *payments.WebhookEvent, inboxID int64, u statusUpdate) {
+	payload, sessionRef := transactionStatusPayload(event, u.bySession)
+
+	var changed bool
+	var logged db.AuditEvent
+	var flagged []db.AuditEvent
+	err := h.store.WithTx(ctx, func(q db.Querier) error {
+		var err error
+		if changed, err = u.apply(ctx, q, event); err != nil {
+			return err
+		}
+		if !changed {
+			// Nothing to record: the event is settled without side effects
+			return settleWebhook(ctx, q, inboxID, nil)
+		}
+		if flagged, err = h.applyStripeDiscount(ctx, q, event, u); err != nil {
+			return err
+		}
+		if err := applyStripeTax(ctx, q, event, u); err != nil {
+			return err
+		}
+		if err := syncApplicationFee(ctx, q, event, u); err != nil {
+			return err
+		}
+		if err := syncLineItem(ctx, q, event, u); err != nil {
+			return err
+		}
+		mismatch, err := h.checkTaxRate(ctx, q, event, u)
+		if err != nil {
+			return err
+		}
+		flagged = append(flagged, mismatch...)
+		if err := postLedger(ctx, q, event, u); err != nil {
+			return err
+		}
+		if err := queueStatusEvent(ctx, q, event, u); err != nil {
+			return err
+		}
+		logged, err = h.auditService.LogTx(ctx, q, u.success.New(u.successInfo, payload).WithRefs(event.PaymentIntentID, sessionRef))
+		if err != nil {
+			return err
+		}
+		return settleWebhook(ctx, q, inboxID, nil)
+	})
+	if err == nil {
+		if changed {
+			h.auditService.Publish(append(flagged, logged)...)
+		}
+		return
+	}
+
+	h.auditService.Log(ctx, audit.TransactionUpdateFailed.New(u.failureInfo, audit.TransactionUpdateFailedPayload{
+		SessionID:       payload.SessionID,
+		PaymentIntentID: payload.PaymentIntentID,
+		EventType:       payload.EventType,
+		Error:           err.Error(),
+	}).WithRefs(event.PaymentIntentID, sessionRef))
+	if err := settleWebhook(ctx, h.store, inboxID, err); err != nil {
+		log.Printf("failed to update webhook inbox entry %d: %v", inboxID, err)
+	}
+}
+
+// transactionStatusPayload builds the audit payload of a status update. Session-based
+// updates reference the session ID as well; refund/dispute updates also record the
+// Stripe event type.
+func transactionStatusPayload(event *payments.WebhookEvent, bySession bool) (audit.TransactionStatusPayload, string) {
+	payload := audit.TransactionStatusPayload{PaymentIntentID: event.PaymentIntentID}
+	sessionRef := ""
+	if bySession {
+		payload.SessionID = event.SessionID
+		sessionRef = event.SessionID
+	}
+	if event.Type == "charge.dispute.created" || event.Type == "refund.created" {
+		payload.EventType = event.Type
+	}
+	return payload, sessionRef
+}
+
+// GetAuditEvents returns audit events with optional filters, which must all match
 func (h *Handlers) GetAuditEvents(c *gin.Context) {
 	limit := int64(50)
 	offset := int64(0)
@@ -667,60 +1121,7 @@ func (h *Handlers) GetAuditEvents(c *gin.Context) {
 		}
 	}
 
-	subsystem := c.Query("subsystem")
-	eventType := c.Query("event_type")
-	userID := c.Query("user_id")
-	refID := c.Query("ref_id")
-	refID2 := c.Query("ref_id2")
-
-	var events []db.AuditEvent
-	var err error
-
-	// Query based on filters (prioritize reference ID queries)
-	if refID != "" {
-		events, err = h.queries.GetAuditEventsByRefID(c.Request.Context(), db.GetAuditEventsByRefIDParams{
-			RefID:  sql.NullString{String: refID, Valid: true},
-			Limit:  limit,
-			Offset: offset,
-		})
-	} else if refID2 != "" {
-		events, err = h.queries.GetAuditEventsByRefID2(c.Request.Context(), db.GetAuditEventsByRefID2Params{
-			RefId2: sql.NullString{String: refID2, Valid: true},
-			Limit:  limit,
-			Offset: offset,
-		})
-	} else if subsystem != "" && eventType != "" {
-		events, err = h.queries.GetAuditEventsBySubsystemAndType(c.Request.Context(), db.GetAuditEventsBySubsystemAndTypeParams{
-			Subsystem: subsystem,
-			EventType: eventType,
-			Limit:     limit,
-			Offset:    offset,
-		})
-	} else if subsystem != "" {
-		events, err = h.queries.GetAuditEventsBySubsystem(c.Request.Context(), db.GetAuditEventsBySubsystemParams{
-			Subsystem: subsystem,
-			Limit:     limit,
-			Offset:    offset,
-		})
-	} else if eventType != "" {
-		events, err = h.queries.GetAuditEventsByEventType(c.Request.Context(), db.GetAuditEventsByEventTypeParams{
-			EventType: eventType,
-			Limit:     limit,
-			Offset:    offset,
-		})
-	} else if userID != "" {
-		events, err = h.queries.GetAuditEventsByUser(c.Request.Context(), db.GetAuditEventsByUserParams{
-			UserID: sql.NullString{String: userID, Valid: true},
-			Limit:  limit,
-			Offset: offset,
-		})
-	} else {
-		events, err = h.queries.GetAllAuditEvents(c.Request.Context(), db.GetAllAuditEventsParams{
-			Limit:  limit,
-			Offset: offset,
-		})
-	}
-
+	events, err := h.store.ListAuditEvents(c.Request.Context(), auditFilter(c).ListParams(limit, offset))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch audit events"})
 		return
@@ -729,32 +1130,58 @@ func (h *Handlers) GetAuditEvents(c *gin.Context) {
 	// Convert to API response format
 	auditEvents := make([]data.AuditEvent, len(events))
 	for i, event := range events {
-		timestamp, _ := time.Parse("2006-01-02 15:04:05", event.Timestamp)
-		auditEvents[i] = data.AuditEvent{
-			ID:        event.ID,
-			Timestamp: timestamp,
-			Subsystem: event.Subsystem,
-			EventType: event.EventType,
-			UserID: func() *string {
-				if event.UserID.Valid {
-					return &event.UserID.String
-				}
-				return nil
-			}(),
-			Information: func() *string {
-				if event.Information.Valid {
-					return &event.Information.String
-				}
-				return nil
-			}(),
-			Payload: func() *string {
-				if event.Payload.Valid {
-					return &event.Payload.String
-				}
-				return nil
-			}(),
-		}
+		auditEvents[i] = toAuditEvent(event)
 	}
 
 	c.JSON(http.StatusOK, AuditEventsResponse{Events: auditEvents})
 }
+
+// toAuditEvent converts a stored audit row into its API representation
+func toAuditEvent(event db.AuditEvent) data.AuditEvent {
+	return data.AuditEvent{
+		ID:        event.ID,
+		Timestamp: event.Timestamp,
+		Subsystem: event.Subsystem,
+		EventType: event.EventType,
+		UserID: func() *string {
+			if event.UserID.Valid {
+				return &event.UserID.String
+			}
+			return nil
+		}(),
+		Information: func() *string {
+			if event.Information.Valid {
+				return &event.Information.String
+			}
+			return nil
+		}(),
+		Payload: func() *string {
+			if event.Payload.Valid {
+				return &event.Payload.String
+			}
+			return nil
+		}(),
+		RefID: func() *string {
+			if event.RefID.Valid {
+				return &event.RefID.String
+			}
+			return nil
+		}(),
+		RefID2: func() *string {
+			if event.RefId2.Valid {
+				return &event.RefId2.String
+			}
+			return nil
+		}(),
+	}
+}
+
+// GetAuditEventTypes lists the audit event catalog
+func (h *Handlers) GetAuditEventTypes(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"types": audit.Types()})
+}
+
+// GetAuditMetrics returns the audit writer counters (dropped, spilled and failed events)
+func (h *Handlers) GetAuditMetrics(c *gin.Context) {
+	c.JSON(http.StatusOK, h.auditService.Stats())
+}
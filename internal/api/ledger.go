@@ -0,0 +1,58 @@
+package api
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"stripe-go-spike/internal/data"
+	"stripe-go-spike/internal/ledger"
+	"stripe-go-spike/internal/money"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LedgerBalancesResponse lists account balances and the net revenue they add up
+// to, per currency.
+type LedgerBalancesResponse struct {
+	UserID     string           `json:"user_id,omitempty"`
+	Balances   []ledger.Balance `json:"balances"`
+	NetRevenue []money.Amount   `json:"net_revenue"`
+}
+
+// GetLedgerBalances returns the balance of every ledger account (admin view)
+func (h *Handlers) GetLedgerBalances(c *gin.Context) {
+	rows, err := h.store.ListLedgerAccountBalances(c.Request.Context())
+	if err != nil {
+		log.Printf("failed to list ledger balances: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch ledger balances"})
+		return
+	}
+	h.writeLedgerBalances(c, "", ledger.AccountBalances(rows))
+}
+
+// GetUserLedgerBalances returns the ledger balances of one user's transactions
+// (admin view)
+func (h *Handlers) GetUserLedgerBalances(c *gin.Context) {
+	userID := c.Param("id")
+	if data.GetUserByID(userID) == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	rows, err := h.store.ListLedgerUserBalances(c.Request.Context(), sql.NullString{String: userID, Valid: true})
+	if err != nil {
+		log.Printf("failed to list ledger balances for %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch ledger balances"})
+		return
+	}
+	h.writeLedgerBalances(c, userID, ledger.UserBalances(rows))
+}
+
+func (h *Handlers) writeLedgerBalances(c *gin.Context, userID string, balances []ledger.Balance) {
+	net, err := ledger.NetRevenue(balances)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, LedgerBalancesResponse{UserID: userID, Balances: balances, NetRevenue: net})
+}
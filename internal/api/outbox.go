@@ -0,0 +1,118 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"stripe-go-spike/internal/audit"
+	"stripe-go-spike/internal/data"
+	"stripe-go-spike/internal/db"
+	"stripe-go-spike/internal/outbox"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetOutboxMessage returns an outbox message with its payload and last error,
+// e.g. the one named by an outbox_message.dead audit event (admin)
+func (h *Handlers) GetOutboxMessage(c *gin.Context) {
+	message, ok := h.lookupOutboxMessage(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, toOutboxMessage(message))
+}
+
+// RequeueOutboxMessage gives a dead outbox message a fresh set of attempts,
+// due straight away, e.g. once the consumer that rejected it is fixed. Messages
+// that aren't dead are left alone (admin)
+func (h *Handlers) RequeueOutboxMessage(c *gin.Context) {
+	ctx := c.Request.Context()
+	message, ok := h.lookupOutboxMessage(c)
+	if !ok {
+		return
+	}
+
+	var requeued bool
+	var logged db.AuditEvent
+	err := h.store.WithTx(ctx, func(q db.Querier) error {
+		var err error
+		if requeued, err = outbox.Requeue(ctx, q, message.ID); err != nil || !requeued {
+			return err
+		}
+		// The event records the attempts and error the message died with
+		logged, err = h.auditService.LogTx(ctx, q, audit.OutboxMessageRequeued.New(
+			"Outbox message "+strconv.FormatInt(message.ID, 10)+" ("+message.Topic+") requeued",
+			audit.OutboxMessagePayload{
+				MessageID: message.ID,
+				Topic:     message.Topic,
+				Key:       message.MessageKey,
+				Attempts:  message.Attempts,
+				Error:     message.LastError.String,
+			},
+		).WithRefs(message.MessageKey, ""))
+		if err != nil {
+			return err
+		}
+		message, err = q.GetOutboxMessage(ctx, message.ID)
+		return err
+	})
+	if err != nil {
+		log.Printf("failed to requeue outbox message %d: %v", message.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to requeue outbox message"})
+		return
+	}
+	if !requeued {
+		c.JSON(http.StatusConflict, gin.H{"error": "Outbox message is not dead"})
+		return
+	}
+	h.auditService.Publish(logged)
+	c.JSON(http.StatusOK, toOutboxMessage(message))
+}
+
+// lookupOutboxMessage loads the message named by the :id route parameter,
+// writing the error response when there is none.
+func (h *Handlers) lookupOutboxMessage(c *gin.Context) (db.OutboxMessage, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid outbox message ID"})
+		return db.OutboxMessage{}, false
+	}
+	message, err := h.store.GetOutboxMessage(c.Request.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Outbox message not found"})
+		return db.OutboxMessage{}, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch outbox message"})
+		return db.OutboxMessage{}, false
+	}
+	return message, true
+}
+
+// toOutboxMessage converts a stored outbox message into its API representation
+func toOutboxMessage(message db.OutboxMessage) data.OutboxMessage {
+	status := "pending"
+	switch {
+	case message.PublishedAt.Valid:
+		status = "published"
+	case message.DeadAt.Valid:
+		status = "dead"
+	}
+	return data.OutboxMessage{
+		ID:            message.ID,
+		Topic:         message.Topic,
+		Key:           message.MessageKey,
+		Payload:       json.RawMessage(message.Payload),
+		Status:        status,
+		Attempts:      message.Attempts,
+		NextAttemptAt: message.NextAttemptAt,
+		LastError:     message.LastError.String,
+		CreatedAt:     message.CreatedAt,
+		PublishedAt:   message.PublishedAt.Ptr(),
+		DeadAt:        message.DeadAt.Ptr(),
+	}
+}
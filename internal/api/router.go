@@ -13,9 +13,8 @@ import (
 )
 
 // NewRouter creates a new Gin router.
-func NewRouter(service *payments.Service, database *sql.DB, queries *db.Queries, frontendAssets embed.FS) *gin.Engine {
+func NewRouter(service *payments.Service, database *sql.DB, queries *db.Queries, auditService *audit.Service, frontendAssets embed.FS) *gin.Engine {
 	r := gin.Default()
-	auditService := audit.NewService(queries)
 	h := NewHandlers(service, database, queries, auditService)
 
 	api := r.Group("/api")
@@ -23,11 +22,48 @@ func NewRouter(service *payments.Service, database *sql.DB, queries *db.Queries,
 		api.GET("/health", h.Health)
 		api.GET("/products", h.GetProducts)
 		api.GET("/users", h.GetUsers)
-		api.GET("/transactions/:user_id", h.GetUserTransactions)
+		// Gin allows one wildcard name per path segment, so the user's
+		// transactions and a single transaction's routes share :id
+		api.GET("/transactions/:id", h.GetUserTransactions)
 		api.GET("/transactions", h.GetAllTransactions)
+		api.GET("/transactions/:id/timeline", h.GetTransactionTimeline)
+		api.GET("/transactions/:id/receipt", h.GetTransactionReceipt)
 		api.POST("/checkout-session", h.CreateCheckoutSession)
+		api.GET("/checkout-session/:id", h.GetCheckoutSession)
+		api.POST("/checkout-session/:id/expire", h.ExpireCheckoutSession)
 		api.POST("/webhook", h.Webhook)
 		api.GET("/audit-events", h.GetAuditEvents)
+		api.GET("/audit-events/metrics", h.GetAuditMetrics)
+		api.GET("/audit-events/stream", h.StreamAuditEvents)
+		api.GET("/audit-events/export", h.ExportAuditEvents)
+		api.GET("/audit-events/types", h.GetAuditEventTypes)
+		api.GET("/ledger/balances", h.GetLedgerBalances)
+		api.GET("/ledger/balances/users/:id", h.GetUserLedgerBalances)
+		api.GET("/coupons", h.GetCoupons)
+		api.POST("/coupons", h.CreateCoupon)
+		api.GET("/coupons/:id", h.GetCoupon)
+		api.PUT("/coupons/:id", h.UpdateCoupon)
+		api.DELETE("/coupons/:id", h.ArchiveCoupon)
+		api.GET("/tax-rates", h.GetTaxRates)
+		api.POST("/tax-rates", h.CreateTaxRate)
+		api.DELETE("/tax-rates/:id", h.ArchiveTaxRate)
+		api.GET("/connected-accounts", h.GetConnectedAccounts)
+		api.POST("/connected-accounts", h.CreateConnectedAccount)
+		api.GET("/connected-accounts/:id", h.GetConnectedAccount)
+		api.PUT("/connected-accounts/:id", h.UpdateConnectedAccount)
+		api.POST("/connected-accounts/:id/onboarding-link", h.CreateOnboardingLink)
+		api.PUT("/products/:id/connected-account", h.AssignProductAccount)
+		api.DELETE("/products/:id/connected-account", h.RemoveProductAccount)
+		api.GET("/webhook-endpoints", h.GetWebhookEndpoints)
+		api.POST("/webhook-endpoints", h.CreateWebhookEndpoint)
+		api.GET("/webhook-endpoints/:id", h.GetWebhookEndpoint)
+		api.PUT("/webhook-endpoints/:id", h.UpdateWebhookEndpoint)
+		api.DELETE("/webhook-endpoints/:id", h.DisableWebhookEndpoint)
+		api.GET("/webhook-endpoints/:id/deliveries", h.GetWebhookDeliveries)
+		api.GET("/webhook-deliveries/:id", h.GetWebhookDelivery)
+		api.POST("/webhook-deliveries/:id/redeliver", h.RedeliverWebhook)
+		api.GET("/outbox-messages/:id", h.GetOutboxMessage)
+		api.POST("/outbox-messages/:id/requeue", h.RequeueOutboxMessage)
 	}
 
 	// Serve the frontend if available
@@ -2,30 +2,93 @@ package api
 
 import (
 	"bytes"
+	"context"
+	"database/sql"
 	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"stripe-go-spike/internal/audit"
+	"stripe-go-spike/internal/data"
 	"stripe-go-spike/internal/db"
+	"stripe-go-spike/internal/money"
+	"stripe-go-spike/internal/outbound"
+	"stripe-go-spike/internal/outbox"
 	"stripe-go-spike/internal/payments"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stripe/stripe-go/v82/webhook"
 	_ "modernc.org/sqlite"
 )
 
 // Use an empty embed.FS for tests; router handles missing assets gracefully.
 var frontendAssets embed.FS
 
-func TestHealth(t *testing.T) {
-	service := payments.NewService(payments.Config{})
-	// Create in-memory database for testing
+// testRouter serves the API over a fresh test database and the mock gateway.
+type testRouter struct {
+	*gin.Engine
+	service  *payments.Service
+	database *sql.DB
+	queries  *db.Queries
+	audit    *audit.Service
+	secret   string
+}
+
+// newTestRouter builds a router for cfg with strict audit types. The database is
+// closed when the test ends.
+func newTestRouter(t *testing.T, cfg payments.Config) *testRouter {
+	t.Helper()
 	database, err := db.NewTestConnection()
 	if err != nil {
 		t.Fatalf("Failed to create test database: %v", err)
 	}
-	defer database.Close()
+	t.Cleanup(func() { database.Close() })
+	service := payments.NewService(cfg)
 	queries := db.New(database)
-	router := NewRouter(service, database, queries, frontendAssets)
+	auditService := audit.NewService(queries, audit.WithStrictTypes())
+	return &testRouter{
+		Engine:   NewRouter(service, database, queries, auditService, frontendAssets),
+		service:  service,
+		database: database,
+		queries:  queries,
+		audit:    auditService,
+		secret:   cfg.WebhookSecret,
+	}
+}
+
+// send serves a JSON request.
+func (r *testRouter) send(method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// webhook posts a Stripe event signed with the configured webhook secret.
+func (r *testRouter) webhook(payload string) *httptest.ResponseRecorder {
+	return r.signedWebhook(r.secret, payload)
+}
+
+// signedWebhook posts a Stripe event signed with secret.
+func (r *testRouter) signedWebhook(secret, payload string) *httptest.ResponseRecorder {
+	signed := webhook.GenerateTestSignedPayload(&webhook.UnsignedPayload{Payload: []byte(payload), Secret: secret})
+	req := httptest.NewRequest("POST", "/api/webhook", bytes.NewReader(signed.Payload))
+	req.Header.Set("Stripe-Signature", signed.Header)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestHealth(t *testing.T) {
+	router := newTestRouter(t, payments.Config{})
 
 	req, _ := http.NewRequest("GET", "/api/health", nil)
 	w := httptest.NewRecorder()
@@ -36,15 +99,7 @@ func TestHealth(t *testing.T) {
 }
 
 func TestCreateCheckoutSession(t *testing.T) {
-	service := payments.NewService(payments.Config{})
-	// Create in-memory database for testing
-	database, err := db.NewTestConnection()
-	if err != nil {
-		t.Fatalf("Failed to create test database: %v", err)
-	}
-	defer database.Close()
-	queries := db.New(database)
-	router := NewRouter(service, database, queries, frontendAssets)
+	router := newTestRouter(t, payments.Config{})
 
 	reqBody := `{"user_id": "luke", "product_id": "lumaweave"}`
 	req := httptest.NewRequest("POST", "/api/checkout-session", bytes.NewBufferString(reqBody))
@@ -56,3 +111,1029 @@ func TestCreateCheckoutSession(t *testing.T) {
 	assert.Contains(t, w.Body.String(), "\"session_id\"")
 	assert.Contains(t, w.Body.String(), "\"url\"")
 }
+
+func TestStreamAuditEventsResumesAndFollows(t *testing.T) {
+	router := newTestRouter(t, payments.Config{})
+
+	for _, file := range []string{"first", "second", "third"} {
+		assert.NoError(t, router.audit.Log(context.Background(), audit.AuditArchived.New("", audit.ArchivePayload{File: file})))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest("GET", "/api/audit-events/stream?subsystem=system", nil).WithContext(ctx)
+	req.Header.Set("Last-Event-ID", "1")
+	w := newFlushRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		router.ServeHTTP(w, req)
+		close(done)
+	}()
+
+	// The stream subscribes before it replays and flushes the backlog
+	<-w.flushed
+	assert.NoError(t, router.audit.Log(context.Background(), audit.WebhookReceived.New("", audit.WebhookReceivedPayload{})))
+	assert.NoError(t, router.audit.Log(context.Background(), audit.AuditArchived.New("", audit.ArchivePayload{File: "live"})))
+	// Events reach the subscriber in order, so the filtered one was skipped by now
+	<-w.flushed
+	cancel()
+	<-done
+
+	body := w.Body.String()
+	assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	assert.NotContains(t, body, "id: 1\n")
+	assert.Contains(t, body, "id: 2\n")
+	assert.Contains(t, body, "id: 3\n")
+	assert.Contains(t, body, `\"file\":\"live\"`)
+	assert.NotContains(t, body, "webhook.received")
+}
+
+// flushRecorder signals every flush of a streamed response.
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushed chan struct{}
+}
+
+func newFlushRecorder() *flushRecorder {
+	return &flushRecorder{ResponseRecorder: httptest.NewRecorder(), flushed: make(chan struct{}, 16)}
+}
+
+func (r *flushRecorder) Flush() {
+	r.ResponseRecorder.Flush()
+	r.flushed <- struct{}{}
+}
+
+func TestRedeliveredWebhooksAreSkipped(t *testing.T) {
+	router := newTestRouter(t, payments.Config{WebhookSecret: "whsec_test"})
+
+	w := router.send("POST", "/api/checkout-session", `{"user_id": "luke", "product_id": "lumaweave"}`)
+	var resp CheckoutSessionResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	for range 3 {
+		w := router.webhook(`{"id": "evt_again", "object": "event", "type": "checkout.session.completed",
+			"data": {"object": {"id": "` + resp.SessionID + `"}}}`)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+
+	inbox, err := router.queries.ListWebhookInboxByRefs(context.Background(), db.ListWebhookInboxByRefsParams{
+		SessionID: sql.NullString{String: resp.SessionID, Valid: true}})
+	assert.NoError(t, err)
+	if assert.Len(t, inbox, 1, "the event is kept once") {
+		assert.Equal(t, "processed", inbox[0].Status)
+	}
+	var timeline TimelineResponse
+	assert.NoError(t, json.Unmarshal(router.send("GET", "/api/transactions/"+resp.TransactionID+"/timeline", "").Body.Bytes(), &timeline))
+	webhooks := 0
+	for _, entry := range timeline.Entries {
+		if entry.Kind == "webhook" {
+			webhooks++
+		}
+	}
+	assert.Equal(t, 1, webhooks)
+}
+
+func TestGetAuditEventsCombinesFilters(t *testing.T) {
+	router := newTestRouter(t, payments.Config{})
+
+	ctx := context.Background()
+	assert.NoError(t, router.audit.Log(ctx, audit.AuditArchived.New("", audit.ArchivePayload{File: "a"}).WithRefs("ref_1", "")))
+	assert.NoError(t, router.audit.Log(ctx, audit.AuditArchived.New("", audit.ArchivePayload{File: "b"}).WithRefs("ref_2", "")))
+	assert.NoError(t, router.audit.Log(ctx, audit.WebhookReceived.New("", audit.WebhookReceivedPayload{}).WithRefs("ref_1", "")))
+
+	req := httptest.NewRequest("GET", "/api/audit-events?subsystem=system&ref_id=ref_1", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Events []data.AuditEvent `json:"events"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	if assert.Len(t, resp.Events, 1) {
+		assert.Equal(t, "audit.archived", resp.Events[0].EventType)
+		assert.Contains(t, *resp.Events[0].Payload, `"a"`)
+	}
+}
+
+func TestTransactionTimeline(t *testing.T) {
+	router := newTestRouter(t, payments.Config{})
+
+	req := httptest.NewRequest("POST", "/api/checkout-session", bytes.NewBufferString(`{"user_id": "luke", "product_id": "lumaweave"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	txns, err := router.queries.ListAllTransactions(context.Background(), db.ListAllTransactionsParams{Limit: 10})
+	assert.NoError(t, err)
+	if !assert.Len(t, txns, 1) {
+		return
+	}
+	txn := txns[0]
+	sessionID := txn.StripeSessionID.String
+
+	_, err = router.queries.CreateWebhookInboxEntry(context.Background(), db.CreateWebhookInboxEntryParams{
+		StripeEventID: "evt_test",
+		EventType:     "checkout.session.completed",
+		SessionID:     txn.StripeSessionID,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, router.audit.Log(context.Background(), audit.TransactionCompleted.New("",
+		audit.TransactionStatusPayload{SessionID: sessionID}).WithRefs("", sessionID)))
+	assert.NoError(t, router.audit.Log(context.Background(), audit.TransactionCompleted.New("",
+		audit.TransactionStatusPayload{SessionID: "cs_other"}).WithRefs("", "cs_other")))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/transactions/"+txn.ID+"/timeline", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp TimelineResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	kinds := map[string]int{}
+	for _, entry := range resp.Entries {
+		kinds[entry.Kind+":"+entry.Status]++
+	}
+	assert.Equal(t, map[string]int{
+		"status_change:pending":   1,
+		"status_change:completed": 1,
+		"webhook:received":        1,
+	}, kinds)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/transactions/missing/timeline", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/transactions/luke", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), txn.ID)
+}
+
+func TestWebhookCommitsStatusAuditAndInboxTogether(t *testing.T) {
+	router := newTestRouter(t, payments.Config{WebhookSecret: "whsec_test"})
+	ctx := context.Background()
+
+	req := httptest.NewRequest("POST", "/api/checkout-session", bytes.NewBufferString(`{"user_id": "luke", "product_id": "lumaweave"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	txns, err := router.queries.ListAllTransactions(ctx, db.ListAllTransactionsParams{Limit: 10})
+	assert.NoError(t, err)
+	if !assert.Len(t, txns, 1) {
+		return
+	}
+	paymentIntentID := txns[0].StripePaymentIntentID
+
+	w := router.webhook(`{"id": "evt_test", "object": "event", "type": "payment_intent.succeeded",
+		"data": {"object": {"id": "` + paymentIntentID.String + `"}}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	txn, err := router.queries.GetTransaction(ctx, txns[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "completed", txn.Status)
+
+	completed, err := router.queries.GetAuditEventsByRefID(ctx, db.GetAuditEventsByRefIDParams{RefID: paymentIntentID, Limit: 10})
+	assert.NoError(t, err)
+	types := make([]string, len(completed))
+	for i, e := range completed {
+		types[i] = e.EventType
+	}
+	assert.Contains(t, types, audit.TransactionCompleted.Definition().EventType)
+
+	inbox, err := router.queries.ListWebhookInboxByRefs(ctx, db.ListWebhookInboxByRefsParams{PaymentIntentID: paymentIntentID})
+	assert.NoError(t, err)
+	if assert.Len(t, inbox, 1) {
+		assert.Equal(t, "processed", inbox[0].Status)
+	}
+}
+
+func TestCreateCheckoutSessionSelectsCurrency(t *testing.T) {
+	router := newTestRouter(t, payments.Config{})
+
+	checkout := func(body, acceptLanguage string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/checkout-session", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept-Language", acceptLanguage)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Jinny's profile currency is EUR; an explicit currency overrides it
+	assert.Equal(t, http.StatusOK, checkout(`{"user_id": "jinny", "product_id": "lumaweave"}`, "").Code)
+	assert.Equal(t, http.StatusOK, checkout(`{"user_id": "jinny", "product_id": "coffee-pods", "currency": "jpy"}`, "").Code)
+	// Luke has no preference, so the browser locale decides
+	assert.Equal(t, http.StatusOK, checkout(`{"user_id": "luke", "product_id": "echospout"}`, "en-GB,en;q=0.9").Code)
+
+	w := checkout(`{"user_id": "luke", "product_id": "lumaweave", "currency": "CHF"}`, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "CHF")
+
+	prices := map[string]string{}
+	for _, userID := range []string{"jinny", "luke"} {
+		txns, err := router.queries.ListTransactionsByUserID(context.Background(), db.ListTransactionsByUserIDParams{UserID: userID, Limit: 10})
+		assert.NoError(t, err)
+		for _, txn := range txns {
+			prices[txn.ProductID] = strconv.FormatInt(txn.Amount, 10) + " " + txn.Currency
+		}
+	}
+	assert.Equal(t, map[string]string{
+		"lumaweave":   "4599 EUR",
+		"coffee-pods": "4500 JPY",
+		"echospout":   "7199 GBP",
+	}, prices)
+}
+
+func TestGetProductsResolvesPrice(t *testing.T) {
+	router := newTestRouter(t, payments.Config{})
+
+	req := httptest.NewRequest("GET", "/api/products?user_id=jinny", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Products []struct {
+			ID     string `json:"id"`
+			Price  struct{ Currency, Display string }
+			Prices []struct{ Currency string }
+		} `json:"products"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.NotEmpty(t, resp.Products)
+	for _, p := range resp.Products {
+		assert.Equal(t, "EUR", p.Price.Currency, p.ID)
+		assert.Len(t, p.Prices, 4)
+	}
+
+	req = httptest.NewRequest("GET", "/api/products?currency=XYZ", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestWebhooksPostLedgerEntries(t *testing.T) {
+	router := newTestRouter(t, payments.Config{WebhookSecret: "whsec_test"})
+	ctx := context.Background()
+
+	req := httptest.NewRequest("POST", "/api/checkout-session", bytes.NewBufferString(`{"user_id": "luke", "product_id": "lumaweave"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	txns, err := router.queries.ListAllTransactions(ctx, db.ListAllTransactionsParams{Limit: 10})
+	assert.NoError(t, err)
+	if !assert.Len(t, txns, 1) {
+		return
+	}
+	txn := txns[0]
+
+	send := func(id, eventType, object string) {
+		w := router.webhook(`{"id": "` + id + `", "object": "event", "type": "` + eventType + `", "data": {"object": ` + object + `}}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	paymentIntent := txn.StripePaymentIntentID.String
+	gateway := router.service.Gateway().(*payments.FakeGateway)
+	gateway.SetChargeFee(paymentIntent, money.Amount{Minor: 175, Currency: "USD"})
+	send("evt_paid", "payment_intent.succeeded", `{"id": "`+paymentIntent+`", "amount_received": 4999, "currency": "usd"}`)
+	// Redelivery and the session event for the same payment post no second charge
+	send("evt_paid", "payment_intent.succeeded", `{"id": "`+paymentIntent+`", "amount_received": 4999, "currency": "usd"}`)
+	send("evt_session", "checkout.session.completed", `{"id": "`+txn.StripeSessionID.String+`", "payment_intent": "`+paymentIntent+`"}`)
+	send("evt_refund", "refund.created", `{"id": "re_1", "payment_intent": "`+paymentIntent+`", "amount": 1000, "currency": "usd"}`)
+
+	req = httptest.NewRequest("GET", "/api/ledger/balances/users/luke", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Balances []struct {
+			Account string
+			Amount  struct{ Minor int64 }
+		}
+		NetRevenue []struct {
+			Minor    int64
+			Currency string
+		} `json:"net_revenue"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	balances := map[string]int64{}
+	for _, b := range resp.Balances {
+		balances[b.Account] = b.Amount.Minor
+	}
+	assert.Equal(t, map[string]int64{"revenue": 4999, "refunds": 1000, "processing_fees": 175, "stripe_balance": 3824}, balances)
+	if assert.Len(t, resp.NetRevenue, 1) {
+		assert.Equal(t, int64(3824), resp.NetRevenue[0].Minor)
+		assert.Equal(t, "USD", resp.NetRevenue[0].Currency)
+	}
+
+	req = httptest.NewRequest("GET", "/api/ledger/balances", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"net_revenue":[{"minor":3824,"currency":"USD","display":"$38.24"}]`)
+
+	// Without the fee the payment isn't kept, so Stripe redelivers it
+	gateway.FailWith(errors.New("stripe unavailable"))
+	w = router.webhook(`{"id": "evt_paid_again", "object": "event", "type": "payment_intent.succeeded", "data": {"object": {"id": "` + paymentIntent + `"}}}`)
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+}
+
+func TestWebhooksOnlyMoveStatusForward(t *testing.T) {
+	router := newTestRouter(t, payments.Config{WebhookSecret: "whsec_test"})
+	ctx := context.Background()
+
+	checkout := func(user string) db.Transaction {
+		w := router.send("POST", "/api/checkout-session", `{"user_id": "`+user+`", "product_id": "lumaweave"}`)
+		var resp CheckoutSessionResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		txn, err := router.queries.GetTransaction(ctx, resp.TransactionID)
+		assert.NoError(t, err)
+		return txn
+	}
+	send := func(id, eventType, object string) {
+		w := router.webhook(`{"id": "` + id + `", "object": "event", "type": "` + eventType + `", "data": {"object": ` + object + `}}`)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+	status := func(txn db.Transaction) string {
+		got, err := router.queries.GetTransaction(ctx, txn.ID)
+		assert.NoError(t, err)
+		return got.Status
+	}
+
+	refunded := checkout("luke")
+	paymentIntent := refunded.StripePaymentIntentID.String
+	payment := `{"id": "` + paymentIntent + `", "amount_received": 4999, "currency": "usd"}`
+	send("evt_paid", "payment_intent.succeeded", payment)
+	send("evt_refund", "refund.created", `{"id": "re_1", "payment_intent": "`+paymentIntent+`", "amount": 1000, "currency": "usd"}`)
+	// Stripe redelivers the payment as a new event, then a stale failure arrives
+	send("evt_paid_late", "payment_intent.succeeded", payment)
+	send("evt_failed_late", "payment_intent.payment_failed", `{"id": "`+paymentIntent+`"}`)
+	assert.Equal(t, "refunded", status(refunded))
+
+	paid := checkout("jinny")
+	send("evt_paid_2", "payment_intent.succeeded", `{"id": "`+paid.StripePaymentIntentID.String+`", "amount_received": 4999, "currency": "usd"}`)
+	send("evt_failed_2", "payment_intent.payment_failed", `{"id": "`+paid.StripePaymentIntentID.String+`"}`)
+	assert.Equal(t, "completed", status(paid))
+
+	// The ignored events post no ledger entries and log no status changes
+	w := router.send("GET", "/api/ledger/balances/users/luke", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"net_revenue":[{"minor":3999,`)
+	events, err := router.queries.ListAuditEventsByRefs(ctx, db.ListAuditEventsByRefsParams{
+		RefID: sql.NullString{String: paymentIntent, Valid: true}, Limit: 100})
+	assert.NoError(t, err)
+	changes := map[string]int{}
+	for _, e := range events {
+		changes[e.EventType]++
+	}
+	assert.Equal(t, 1, changes["transaction.completed"])
+	assert.Equal(t, 1, changes["transaction.refunded"])
+	assert.Zero(t, changes["transaction.failed"])
+
+	inbox, err := router.queries.ListWebhookInboxByRefs(ctx, db.ListWebhookInboxByRefsParams{
+		PaymentIntentID: sql.NullString{String: paymentIntent, Valid: true}})
+	assert.NoError(t, err)
+	assert.Len(t, inbox, 4)
+	for _, entry := range inbox {
+		assert.Equal(t, "processed", entry.Status, entry.StripeEventID)
+	}
+}
+
+func TestCheckoutSessionReuseAndExpire(t *testing.T) {
+	router := newTestRouter(t, payments.Config{SessionTTL: time.Hour, Overridable: []string{payments.OptionLocale}})
+
+	checkout := func(body string) CheckoutSessionResponse {
+		req := httptest.NewRequest("POST", "/api/checkout-session", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp CheckoutSessionResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp
+	}
+	expire := func(sessionID string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/api/checkout-session/"+sessionID+"/expire", nil))
+		return w
+	}
+
+	first := checkout(`{"user_id": "luke", "product_id": "lumaweave"}`)
+	assert.False(t, first.Reused)
+	if assert.NotNil(t, first.ExpiresAt) {
+		assert.WithinDuration(t, time.Now().Add(time.Hour), first.ExpiresAt.Time, time.Minute)
+	}
+	txn, err := router.queries.GetTransaction(context.Background(), first.TransactionID)
+	assert.NoError(t, err)
+	assert.True(t, txn.ExpiresAt.Valid)
+
+	// The same cart gets the open session back; another product a new one
+	again := checkout(`{"user_id": "luke", "product_id": "lumaweave"}`)
+	assert.True(t, again.Reused)
+	assert.Equal(t, first.SessionID, again.SessionID)
+	assert.Equal(t, first.URL, again.URL)
+	other := checkout(`{"user_id": "luke", "product_id": "echospout"}`)
+	assert.NotEqual(t, first.SessionID, other.SessionID)
+
+	// Sessions are only reused with the same option overrides
+	german := checkout(`{"user_id": "luke", "product_id": "lumaweave", "options": {"locale": "de"}}`)
+	assert.False(t, german.Reused)
+	assert.True(t, checkout(`{"user_id": "luke", "product_id": "lumaweave", "options": {"locale": "de"}}`).Reused)
+	again = checkout(`{"user_id": "luke", "product_id": "lumaweave"}`)
+	assert.Equal(t, first.SessionID, again.SessionID, "the session without overrides is reused")
+
+	w := expire(first.SessionID)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"status":"cancelled"`)
+	assert.Equal(t, http.StatusConflict, expire(first.SessionID).Code)
+	assert.Equal(t, http.StatusNotFound, expire("cs_unknown").Code)
+
+	// Once expired, checking out starts over
+	fresh := checkout(`{"user_id": "luke", "product_id": "lumaweave"}`)
+	assert.False(t, fresh.Reused)
+	assert.NotEqual(t, first.SessionID, fresh.SessionID)
+}
+
+func TestGetCheckoutSessionReconcilesBeforeWebhook(t *testing.T) {
+	router := newTestRouter(t, payments.Config{})
+
+	req := httptest.NewRequest("POST", "/api/checkout-session", bytes.NewBufferString(`{"user_id": "luke", "product_id": "lumaweave"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var created CheckoutSessionResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+
+	verify := func(sessionID string) (int, CheckoutSessionStatusResponse) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/api/checkout-session/"+sessionID, nil))
+		var resp CheckoutSessionStatusResponse
+		if w.Code == http.StatusOK {
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		}
+		return w.Code, resp
+	}
+
+	code, resp := verify(created.SessionID)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "pending", resp.Status)
+	assert.Equal(t, "open", resp.SessionStatus)
+	assert.False(t, resp.Reconciled)
+
+	// The customer pays; the redirect arrives before the webhook
+	gateway := router.service.Gateway().(*payments.FakeGateway)
+	sess, err := gateway.CheckoutSession(context.Background(), created.SessionID)
+	assert.NoError(t, err)
+	sess.Status, sess.PaymentStatus = "complete", "paid"
+	gateway.AddCheckoutSession(sess)
+
+	code, resp = verify(created.SessionID)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "completed", resp.Status)
+	assert.Equal(t, "paid", resp.PaymentStatus)
+	assert.True(t, resp.Reconciled)
+	if assert.NotNil(t, resp.Transaction.StripePaymentIntentID) {
+		assert.Equal(t, sess.PaymentIntentID, *resp.Transaction.StripePaymentIntentID)
+	}
+
+	// Asking again changes nothing
+	code, resp = verify(created.SessionID)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "completed", resp.Status)
+	assert.False(t, resp.Reconciled)
+	entries, err := router.queries.ListJournalEntriesByTransactionID(context.Background(), sql.NullString{String: created.TransactionID, Valid: true})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	code, _ = verify("cs_unknown")
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestCreateCheckoutSessionOptionOverrides(t *testing.T) {
+	router := newTestRouter(t, payments.Config{Overridable: []string{payments.OptionLocale}})
+
+	checkout := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/checkout-session", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, checkout(`{"user_id": "luke", "product_id": "lumaweave", "options": {"locale": "fr"}}`).Code)
+	w := checkout(`{"user_id": "luke", "product_id": "echospout", "options": {"payment_method_types": ["card"]}}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "payment_method_types cannot be overridden")
+	assert.Equal(t, http.StatusBadRequest, checkout(`{"user_id": "luke", "product_id": "echospout", "options": {"locale": "xx"}}`).Code)
+}
+
+func TestCouponsAndPromoCodeCheckout(t *testing.T) {
+	router := newTestRouter(t, payments.Config{WebhookSecret: "whsec_test"})
+	ctx := context.Background()
+
+	coupon := func(w *httptest.ResponseRecorder) data.Coupon {
+		var c data.Coupon
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &c))
+		return c
+	}
+
+	w := router.send("POST", "/api/coupons", `{"code": "spring-10", "percent_off": 10, "per_user_limit": 1, "product_ids": ["lumaweave", "echospout"]}`)
+	if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+		return
+	}
+	spring := coupon(w)
+	assert.Equal(t, "SPRING-10", spring.Code)
+	assert.Equal(t, []string{"echospout", "lumaweave"}, spring.ProductIDs)
+	if assert.NotNil(t, spring.StripePromotionCodeID) {
+		assert.Contains(t, *spring.StripePromotionCodeID, "promo_mock_")
+	}
+	assert.Equal(t, http.StatusConflict, router.send("POST", "/api/coupons", `{"code": "SPRING-10", "percent_off": 5}`).Code)
+	assert.Equal(t, http.StatusBadRequest, router.send("POST", "/api/coupons", `{"code": "BAD", "percent_off": 5, "product_ids": ["nope"]}`).Code)
+
+	var resp CheckoutSessionResponse
+	w = router.send("POST", "/api/checkout-session", `{"user_id": "luke", "product_id": "lumaweave", "promo_code": "spring-10"}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, money.MustNew(4499, "USD"), resp.Amount)
+	if assert.NotNil(t, resp.Discount) {
+		assert.Equal(t, money.MustNew(500, "USD"), *resp.Discount)
+	}
+	txn, err := router.queries.GetTransaction(ctx, resp.TransactionID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4499), txn.Amount)
+	assert.Equal(t, int64(500), txn.DiscountAmount)
+	assert.Equal(t, sql.NullInt64{Int64: spring.ID, Valid: true}, txn.CouponID)
+
+	// The open session is reused; a new one would exceed the per-user limit
+	w = router.send("POST", "/api/checkout-session", `{"user_id": "luke", "product_id": "lumaweave", "promo_code": "SPRING-10"}`)
+	assert.Contains(t, w.Body.String(), `"reused":true`)
+	assert.Equal(t, http.StatusBadRequest, router.send("POST", "/api/checkout-session", `{"user_id": "luke", "product_id": "echospout", "promo_code": "SPRING-10"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, router.send("POST", "/api/checkout-session", `{"user_id": "jinny", "product_id": "coffee-pods", "promo_code": "SPRING-10"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, router.send("POST", "/api/checkout-session", `{"user_id": "jinny", "product_id": "lumaweave", "promo_code": "SPRING-10", "allow_promotion_codes": true}`).Code)
+
+	// Changing the limits replaces the promotion code
+	w = router.send("PUT", "/api/coupons/"+strconv.FormatInt(spring.ID, 10), `{"max_redemptions": 5, "per_user_limit": 1, "product_ids": ["lumaweave"]}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	updated := coupon(w)
+	assert.Equal(t, []string{"lumaweave"}, updated.ProductIDs)
+	assert.Equal(t, int64(1), updated.Redemptions)
+	assert.NotEqual(t, *spring.StripePromotionCodeID, *updated.StripePromotionCodeID)
+
+	// A code entered on the Checkout page is recorded from the webhook
+	w = router.send("POST", "/api/checkout-session", `{"user_id": "jinny", "product_id": "lumaweave", "allow_promotion_codes": true}`)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	router.webhook(`{"id": "evt_promo", "object": "event", "type": "checkout.session.completed",
+		"data": {"object": {"id": "` + resp.SessionID + `", "amount_total": 4139, "currency": "eur",
+		"total_details": {"amount_discount": 460},
+		"discounts": [{"coupon": "` + *updated.StripeCouponID + `", "promotion_code": "` + *updated.StripePromotionCodeID + `"}]}}}`)
+	txn, err = router.queries.GetTransaction(ctx, resp.TransactionID)
+	assert.NoError(t, err)
+	assert.Equal(t, "completed", txn.Status)
+	assert.Equal(t, int64(4139), txn.Amount)
+	assert.Equal(t, int64(460), txn.DiscountAmount)
+	assert.Equal(t, sql.NullInt64{Int64: spring.ID, Valid: true}, txn.CouponID)
+
+	// Stripe doesn't know the product restriction or per-user limit, so a code
+	// entered there that breaks them is recorded and flagged
+	w = router.send("POST", "/api/checkout-session", `{"user_id": "luke", "product_id": "echospout", "allow_promotion_codes": true}`)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	router.webhook(`{"id": "evt_promo_luke", "object": "event", "type": "checkout.session.completed",
+		"data": {"object": {"id": "` + resp.SessionID + `", "amount_total": 900, "currency": "usd",
+		"total_details": {"amount_discount": 100},
+		"discounts": [{"coupon": "` + *updated.StripeCouponID + `", "promotion_code": "` + *updated.StripePromotionCodeID + `"}]}}}`)
+	txn, err = router.queries.GetTransaction(ctx, resp.TransactionID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), txn.DiscountAmount)
+	violations, err := router.queries.GetAuditEventsByEventType(ctx, db.GetAuditEventsByEventTypeParams{
+		EventType: audit.CouponViolated.Definition().EventType, Limit: 10})
+	assert.NoError(t, err)
+	if assert.Len(t, violations, 1) {
+		assert.Equal(t, "luke", violations[0].UserID.String)
+		assert.Contains(t, violations[0].Payload.String, "doesn't apply to echospout")
+	}
+
+	// Archived coupons can't be redeemed or changed
+	w = router.send("DELETE", "/api/coupons/"+strconv.FormatInt(spring.ID, 10), "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotNil(t, coupon(w).ArchivedAt)
+	assert.Equal(t, http.StatusBadRequest, router.send("POST", "/api/checkout-session", `{"user_id": "admin", "product_id": "lumaweave", "promo_code": "SPRING-10"}`).Code)
+	assert.Equal(t, http.StatusConflict, router.send("PUT", "/api/coupons/"+strconv.FormatInt(spring.ID, 10), `{}`).Code)
+
+	w = router.send("GET", "/api/coupons", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"redemptions":3`)
+}
+
+func TestTaxRatesCheckoutAndReceipt(t *testing.T) {
+	router := newTestRouter(t, payments.Config{WebhookSecret: "whsec_test"})
+	ctx := context.Background()
+
+	w := router.send("POST", "/api/tax-rates", `{"country": "us", "name": "Sales tax", "rate_bps": 500}`)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	w = router.send("POST", "/api/tax-rates", `{"country": "US", "region": "ca", "name": "CA sales tax", "rate_bps": 725}`)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var california data.TaxRate
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &california))
+	assert.Equal(t, "CA", california.Region)
+	assert.Equal(t, "7.25", california.Percentage)
+	if assert.NotNil(t, california.StripeTaxRateID) {
+		assert.Contains(t, *california.StripeTaxRateID, "txr_mock_")
+	}
+	assert.Equal(t, http.StatusBadRequest, router.send("POST", "/api/tax-rates", `{"country": "USA", "name": "Sales tax", "rate_bps": 500}`).Code)
+	assert.Equal(t, http.StatusBadRequest, router.send("POST", "/api/tax-rates", `{"country": "US", "name": "Sales tax", "rate_bps": 20000}`).Code)
+
+	// 4999 less 10% is 4499, plus 7.25% (326.18) is 4825
+	_, err := router.queries.CreateCoupon(ctx, db.CreateCouponParams{Code: "TEN", PercentOff: 10, CreatedAt: db.Now(), UpdatedAt: db.Now()})
+	assert.NoError(t, err)
+	var resp CheckoutSessionResponse
+	w = router.send("POST", "/api/checkout-session", `{"user_id": "luke", "product_id": "lumaweave", "country": "US", "region": "CA", "promo_code": "TEN"}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, money.MustNew(4825, "USD"), resp.Amount)
+	if assert.NotNil(t, resp.Tax) {
+		assert.Equal(t, money.MustNew(326, "USD"), *resp.Tax)
+	}
+	sess, err := router.service.Gateway().CheckoutSession(ctx, resp.SessionID)
+	assert.NoError(t, err)
+	assert.Equal(t, money.MustNew(4825, "USD"), sess.Amount)
+
+	var receipt ReceiptResponse
+	w = router.send("GET", "/api/transactions/"+resp.TransactionID+"/receipt", "")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &receipt))
+	assert.Equal(t, money.MustNew(4999, "USD"), receipt.Subtotal)
+	assert.Equal(t, money.MustNew(500, "USD"), receipt.Discount)
+	assert.Equal(t, money.MustNew(326, "USD"), receipt.Tax)
+	assert.Equal(t, money.MustNew(4825, "USD"), receipt.Total)
+	if assert.Len(t, receipt.LineItems, 1) {
+		assert.Equal(t, data.GeneralTangibleGoods, receipt.LineItems[0].TaxCode)
+		assert.Equal(t, &california.ID, receipt.LineItems[0].TaxRateID)
+		assert.Equal(t, receipt.Total, receipt.LineItems[0].Total)
+	}
+
+	// The rate followed the requested region; a billing address elsewhere is flagged
+	completed := func(id, sessionID, country, region string) {
+		w := router.webhook(`{"id": "` + id + `", "object": "event", "type": "checkout.session.completed",
+			"data": {"object": {"id": "` + sessionID + `", "amount_total": 4825, "currency": "usd",
+			"customer_details": {"address": {"country": "` + country + `", "state": "` + region + `"}}}}}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	mismatches := func() []db.AuditEvent {
+		events, err := router.queries.GetAuditEventsByEventType(ctx, db.GetAuditEventsByEventTypeParams{
+			EventType: audit.TaxRateMismatch.Definition().EventType, Limit: 10})
+		assert.NoError(t, err)
+		return events
+	}
+	completed("evt_billed_ny", resp.SessionID, "US", "NY")
+	if flagged := mismatches(); assert.Len(t, flagged, 1) {
+		assert.Equal(t, "luke", flagged[0].UserID.String)
+		assert.Contains(t, flagged[0].Payload.String, `"charged_rate_bps":725`)
+		assert.Contains(t, flagged[0].Payload.String, `"expected_rate_bps":500`)
+	}
+
+	// Other states get the country-wide rate, other countries none
+	w = router.send("POST", "/api/checkout-session", `{"user_id": "admin", "product_id": "lumaweave", "country": "US", "region": "NY"}`)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, money.MustNew(5249, "USD"), resp.Amount)
+	completed("evt_billed_nj", resp.SessionID, "US", "NJ")
+	assert.Len(t, mismatches(), 1, "NJ has the same country-wide rate")
+	w = router.send("POST", "/api/checkout-session", `{"user_id": "jinny", "product_id": "lumaweave", "country": "DE"}`)
+	resp = CheckoutSessionResponse{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, money.MustNew(4599, "EUR"), resp.Amount)
+	assert.Nil(t, resp.Tax)
+
+	// Archived rates no longer apply
+	w = router.send("DELETE", "/api/tax-rates/"+strconv.FormatInt(california.ID, 10), "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = router.send("POST", "/api/checkout-session", `{"user_id": "admin", "product_id": "echospout", "country": "US", "region": "CA"}`)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, money.MustNew(9449, "USD"), resp.Amount, "5% of 8999 on top")
+	w = router.send("GET", "/api/tax-rates", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"archived_at"`)
+}
+
+func TestAutomaticTaxFromWebhook(t *testing.T) {
+	router := newTestRouter(t, payments.Config{WebhookSecret: "whsec_test", AutomaticTax: true})
+	ctx := context.Background()
+
+	// Local rates are ignored when Stripe calculates tax
+	_, err := router.queries.CreateTaxRate(ctx, db.CreateTaxRateParams{Country: "DE", Name: "VAT", RateBps: 1900, CreatedAt: db.Now()})
+	assert.NoError(t, err)
+	body := `{"user_id": "jinny", "product_id": "lumaweave", "country": "DE"}`
+	req := httptest.NewRequest("POST", "/api/checkout-session", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp CheckoutSessionResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, money.MustNew(4599, "EUR"), resp.Amount)
+	assert.Nil(t, resp.Tax)
+
+	router.webhook(`{"id": "evt_tax", "object": "event", "type": "checkout.session.completed",
+		"data": {"object": {"id": "` + resp.SessionID + `", "amount_total": 5473, "currency": "eur",
+		"total_details": {"amount_discount": 0, "amount_tax": 874}}}}`)
+
+	txn, err := router.queries.GetTransaction(ctx, resp.TransactionID)
+	assert.NoError(t, err)
+	assert.Equal(t, "completed", txn.Status)
+	assert.Equal(t, int64(5473), txn.Amount)
+	assert.Equal(t, int64(874), txn.TaxAmount)
+	items, err := router.queries.ListTransactionLineItems(ctx, txn.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, items, 1) {
+		assert.Equal(t, int64(874), items[0].TaxAmount)
+		assert.False(t, items[0].TaxRateID.Valid)
+	}
+
+	// The collected tax is owed, not revenue
+	balances, err := router.queries.ListLedgerAccountBalances(ctx)
+	assert.NoError(t, err)
+	byAccount := map[string]int64{}
+	for _, b := range balances {
+		byAccount[b.Code] = b.Balance
+	}
+	assert.Equal(t, int64(-4599), byAccount["revenue"])
+	assert.Equal(t, int64(-874), byAccount["tax_payable"])
+}
+
+func TestConnectedAccountsAndVendorCheckout(t *testing.T) {
+	// With automatic tax the vendor's transfer is fixed and the platform keeps the rest
+	router := newTestRouter(t, payments.Config{WebhookSecret: "whsec_test", ConnectWebhookSecret: "whsec_connect", AutomaticTax: true})
+	ctx := context.Background()
+
+	webhookEvent := func(payload string) {
+		w := router.webhook(payload)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+	// Account events come from the Connect endpoint, signed with its own secret
+	connectEvent := func(payload string) {
+		w := router.signedWebhook("whsec_connect", payload)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+
+	w := router.send("POST", "/api/connected-accounts", `{"name": "Weavers Co", "email": "ops@weavers.example", "country": "de", "fee_bps": 1500}`)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var account data.ConnectedAccount
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &account))
+	assert.Contains(t, account.StripeAccountID, "acct_mock_")
+	assert.Equal(t, "DE", account.Country)
+	assert.False(t, account.ChargesEnabled)
+	assert.Equal(t, http.StatusBadRequest, router.send("POST", "/api/connected-accounts", `{"name": "Weavers Co", "country": "DE", "fee_bps": -1}`).Code)
+	path := "/api/connected-accounts/" + strconv.FormatInt(account.ID, 10)
+	w = router.send("PUT", path, `{"fee_bps": 1000}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"fee_bps":1000`)
+
+	w = router.send("POST", path+"/onboarding-link", "")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var link payments.OnboardingLink
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &link))
+	assert.Equal(t, "http://localhost:8060/app?onboarding=complete", link.URL)
+
+	w = router.send("PUT", "/api/products/lumaweave/connected-account", `{"connected_account_id": `+strconv.FormatInt(account.ID, 10)+`}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, http.StatusBadRequest, router.send("PUT", "/api/products/echospout/connected-account", `{"connected_account_id": 999}`).Code)
+	var products ProductsResponse
+	assert.NoError(t, json.Unmarshal(router.send("GET", "/api/products", "").Body.Bytes(), &products))
+	for _, p := range products.Products {
+		if p.ID == "lumaweave" {
+			assert.Equal(t, &account.ID, p.ConnectedAccountID)
+		} else {
+			assert.Nil(t, p.ConnectedAccountID, p.ID)
+		}
+	}
+
+	// The vendor can't sell before onboarding is complete
+	checkout := `{"user_id": "jinny", "product_id": "lumaweave"}`
+	assert.Equal(t, http.StatusConflict, router.send("POST", "/api/checkout-session", checkout).Code)
+	accountEvent := func(id string, created int64, enabled bool) string {
+		return fmt.Sprintf(`{"id": %q, "object": "event", "type": "account.updated", "created": %d,
+			"data": {"object": {"id": %q, "object": "account",
+			"charges_enabled": %t, "payouts_enabled": %t, "details_submitted": true}}}`,
+			id, created, account.StripeAccountID, enabled, enabled)
+	}
+	assert.Equal(t, http.StatusBadRequest,
+		router.signedWebhook("whsec_other", accountEvent("evt_forged", 1700000200, true)).Code)
+	connectEvent(accountEvent("evt_acct", 1700000200, true))
+	// Delivered late, an older event doesn't roll the account back
+	connectEvent(accountEvent("evt_acct_stale", 1700000100, false))
+	w = router.send("GET", path, "")
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &account))
+	assert.True(t, account.ChargesEnabled)
+	assert.True(t, account.PayoutsEnabled)
+	events, err := router.queries.GetAuditEventsByEventType(ctx, db.GetAuditEventsByEventTypeParams{EventType: "connected_account.updated", Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, events, 2, "fee change and onboarding")
+
+	assert.Equal(t, http.StatusBadRequest,
+		router.send("POST", "/api/checkout-session", `{"user_id": "jinny", "product_id": "lumaweave", "allow_promotion_codes": true}`).Code)
+
+	// 10% of 4599 is 459.9
+	w = router.send("POST", "/api/checkout-session", checkout)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp CheckoutSessionResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	txn, err := router.queries.GetTransaction(ctx, resp.TransactionID)
+	assert.NoError(t, err)
+	assert.Equal(t, sql.NullInt64{Int64: account.ID, Valid: true}, txn.ConnectedAccountID)
+	assert.Equal(t, int64(460), txn.ApplicationFeeAmount)
+	assert.Equal(t, int64(4139), txn.TransferAmount)
+
+	// Stripe adds 874 tax, which the platform keeps
+	webhookEvent(`{"id": "evt_paid", "object": "event", "type": "checkout.session.completed",
+		"data": {"object": {"id": "` + resp.SessionID + `", "amount_total": 5473, "currency": "eur",
+		"total_details": {"amount_discount": 0, "amount_tax": 874}}}}`)
+	var txns TransactionsResponse
+	assert.NoError(t, json.Unmarshal(router.send("GET", "/api/transactions/jinny", "").Body.Bytes(), &txns))
+	if assert.Len(t, txns.Transactions, 1) {
+		got := txns.Transactions[0]
+		assert.Equal(t, "completed", got.Status)
+		assert.Equal(t, &account.ID, got.ConnectedAccountID)
+		if assert.NotNil(t, got.ApplicationFee) && assert.NotNil(t, got.Transfer) {
+			assert.Equal(t, money.MustNew(1334, "EUR"), *got.ApplicationFee)
+			assert.Equal(t, money.MustNew(4139, "EUR"), *got.Transfer)
+		}
+	}
+	balances, err := router.queries.ListLedgerAccountBalances(ctx)
+	assert.NoError(t, err)
+	byAccount := map[string]int64{}
+	for _, b := range balances {
+		byAccount[b.Code] = b.Balance
+	}
+	assert.Equal(t, int64(4139), byAccount["vendor_transfers"])
+	assert.Equal(t, int64(1334), byAccount["stripe_balance"])
+	assert.Equal(t, int64(-874), byAccount["tax_payable"])
+
+	// Taken back, the product is the platform's own again
+	assert.Equal(t, http.StatusNoContent, router.send("DELETE", "/api/products/lumaweave/connected-account", "").Code)
+	w = router.send("POST", "/api/checkout-session", `{"user_id": "luke", "product_id": "lumaweave"}`)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	txn, err = router.queries.GetTransaction(ctx, resp.TransactionID)
+	assert.NoError(t, err)
+	assert.False(t, txn.ConnectedAccountID.Valid)
+	assert.Zero(t, txn.TransferAmount)
+}
+
+func TestOutboundWebhooks(t *testing.T) {
+	router := newTestRouter(t, payments.Config{WebhookSecret: "whsec_test"})
+
+	webhookEvent := func(payload string) {
+		w := router.webhook(payload)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var mu sync.Mutex
+	var received []*http.Request
+	var bodies [][]byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body bytes.Buffer
+		_, _ = body.ReadFrom(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, r)
+		bodies = append(bodies, body.Bytes())
+	}))
+	defer receiver.Close()
+
+	assert.Equal(t, http.StatusBadRequest, router.send("POST", "/api/webhook-endpoints", `{"url": "`+receiver.URL+`", "event_types": ["coupon.created"]}`).Code)
+	w := router.send("POST", "/api/webhook-endpoints", `{"url": "`+receiver.URL+`", "event_types": ["transaction.refunded", "transaction.completed", "transaction.failed"]}`)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var endpoint data.WebhookEndpoint
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &endpoint))
+	assert.Contains(t, endpoint.Secret, "whsec_")
+	assert.Equal(t, []string{"transaction.completed", "transaction.failed", "transaction.refunded"}, endpoint.EventTypes)
+	path := "/api/webhook-endpoints/" + strconv.FormatInt(endpoint.ID, 10)
+	assert.NotContains(t, router.send("GET", path, "").Body.String(), endpoint.Secret, "secret is only shown once")
+
+	w = router.send("POST", "/api/checkout-session", `{"user_id": "jinny", "product_id": "lumaweave"}`)
+	var resp CheckoutSessionResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+	// Both completion events report the same change; the refund is another, and
+	// a late failure for the refunded payment reports none
+	webhookEvent(`{"id": "evt_1", "object": "event", "type": "checkout.session.completed",
+		"data": {"object": {"id": "` + resp.SessionID + `", "payment_intent": "pi_out"}}}`)
+	webhookEvent(`{"id": "evt_2", "object": "event", "type": "payment_intent.succeeded",
+		"data": {"object": {"id": "pi_out"}}}`)
+	webhookEvent(`{"id": "evt_3", "object": "event", "type": "refund.created",
+		"data": {"object": {"id": "re_1", "payment_intent": "pi_out", "amount": 1000, "currency": "usd"}}}`)
+	webhookEvent(`{"id": "evt_4", "object": "event", "type": "payment_intent.payment_failed",
+		"data": {"object": {"id": "pi_out"}}}`)
+
+	// The outbox relay queues the deliveries
+	store := db.NewStore(router.database, router.queries)
+	mux := outbox.NewMux()
+	outbound.Subscribe(mux, store)
+	relayed, err := (&outbox.Relay{Store: store, Sink: mux}).Run(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, relayed.Published)
+
+	var deliveries WebhookDeliveriesResponse
+	assert.NoError(t, json.Unmarshal(router.send("GET", path+"/deliveries", "").Body.Bytes(), &deliveries))
+	if !assert.Len(t, deliveries.WebhookDeliveries, 2) {
+		return
+	}
+	completed := deliveries.WebhookDeliveries[1]
+	assert.Equal(t, "transaction.completed", completed.EventType)
+	assert.Equal(t, "pending", completed.Status)
+	assert.Equal(t, "transaction.refunded", deliveries.WebhookDeliveries[0].EventType)
+
+	// They are the outbox's events, keyed by transaction
+	rows, err := router.database.Query("SELECT topic, message_key, payload FROM outbox_messages ORDER BY id")
+	if assert.NoError(t, err) {
+		var messages []db.OutboxMessage
+		for rows.Next() {
+			var m db.OutboxMessage
+			assert.NoError(t, rows.Scan(&m.Topic, &m.MessageKey, &m.Payload))
+			messages = append(messages, m)
+		}
+		assert.NoError(t, rows.Close())
+		if assert.Len(t, messages, 2) {
+			assert.Equal(t, "transaction.completed", messages[0].Topic)
+			assert.Equal(t, "transaction.refunded", messages[1].Topic)
+			assert.Equal(t, resp.TransactionID, messages[0].MessageKey)
+			assert.Contains(t, messages[0].Payload, completed.EventID)
+		}
+	}
+
+	deliveryPath := "/api/webhook-deliveries/" + strconv.FormatInt(completed.ID, 10)
+	w = router.send("POST", deliveryPath+"/redeliver", "")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var delivery data.WebhookDelivery
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &delivery))
+	assert.Equal(t, "succeeded", delivery.Status)
+	assert.Len(t, delivery.AttemptLog, 1)
+
+	mu.Lock()
+	if assert.Len(t, received, 1) {
+		assert.Equal(t, completed.EventID, received[0].Header.Get(outbound.HeaderEventID))
+		assert.NoError(t, outbound.Verify(endpoint.Secret, received[0].Header.Get(outbound.HeaderSignature), bodies[0], time.Minute, time.Now()))
+		var event struct {
+			Type string `json:"type"`
+			Data struct {
+				Transaction data.Transaction `json:"transaction"`
+			} `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(bodies[0], &event))
+		assert.Equal(t, "transaction.completed", event.Type)
+		assert.Equal(t, resp.TransactionID, event.Data.Transaction.ID)
+		assert.Equal(t, "completed", event.Data.Transaction.Status)
+	}
+	mu.Unlock()
+
+	// A disabled endpoint gets nothing more
+	w = router.send("DELETE", path, "")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"active":false`)
+	assert.Equal(t, http.StatusConflict, router.send("POST", deliveryPath+"/redeliver", "").Code)
+	assert.Equal(t, http.StatusNotFound, router.send("GET", "/api/webhook-deliveries/999", "").Code)
+}
+
+func TestRequeueDeadOutboxMessage(t *testing.T) {
+	router := newTestRouter(t, payments.Config{WebhookSecret: "whsec_test"})
+	ctx := context.Background()
+	store := db.NewStore(router.database, router.queries)
+
+	w := router.send("POST", "/api/checkout-session", `{"user_id": "jinny", "product_id": "lumaweave"}`)
+	var resp CheckoutSessionResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	w = router.webhook(`{"id": "evt_1", "object": "event", "type": "checkout.session.completed",
+		"data": {"object": {"id": "` + resp.SessionID + `", "payment_intent": "pi_dead"}}}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// A consumer that rejects the message kills it on its last attempt
+	failing := outbox.SinkFunc(func(ctx context.Context, m outbox.Message) error { return errors.New("consumer down") })
+	relayed, err := (&outbox.Relay{Store: store, Sink: failing, Audit: router.audit, MaxAttempts: 1}).Run(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, relayed.Dead)
+	var id int64
+	assert.NoError(t, router.database.QueryRow("SELECT id FROM outbox_messages").Scan(&id))
+	path := "/api/outbox-messages/" + strconv.FormatInt(id, 10)
+
+	var message data.OutboxMessage
+	w = router.send("GET", path, "")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &message))
+	assert.Equal(t, "dead", message.Status)
+	assert.Equal(t, "consumer down", message.LastError)
+	assert.Equal(t, resp.TransactionID, message.Key)
+
+	w = router.send("POST", path+"/requeue", "")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &message))
+	assert.Equal(t, "pending", message.Status)
+	assert.Zero(t, message.Attempts)
+	assert.Equal(t, http.StatusConflict, router.send("POST", path+"/requeue", "").Code, "only dead messages are requeued")
+	assert.Equal(t, http.StatusNotFound, router.send("POST", "/api/outbox-messages/999/requeue", "").Code)
+	assert.Equal(t, http.StatusBadRequest, router.send("GET", "/api/outbox-messages/abc", "").Code)
+
+	requeued, err := router.queries.GetAuditEventsByEventType(ctx, db.GetAuditEventsByEventTypeParams{
+		EventType: audit.OutboxMessageRequeued.Definition().EventType, Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, requeued, 1)
+
+	// Once the consumer is fixed the message is published
+	relayed, err = (&outbox.Relay{Store: store, Sink: outbox.SinkFunc(func(ctx context.Context, m outbox.Message) error { return nil })}).Run(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, relayed.Published)
+	assert.Contains(t, router.send("GET", path, "").Body.String(), `"status":"published"`)
+}
@@ -0,0 +1,286 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"stripe-go-spike/internal/audit"
+	"stripe-go-spike/internal/data"
+	"stripe-go-spike/internal/db"
+	"stripe-go-spike/internal/money"
+	"stripe-go-spike/internal/payments"
+	"stripe-go-spike/internal/tax"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TaxRateRequest creates a tax rate. Region and TaxCode narrow it to part of the
+// country and to one product tax code; the most specific rate applies.
+type TaxRateRequest struct {
+	Country string `json:"country" binding:"required"`
+	Region  string `json:"region"`
+	TaxCode string `json:"tax_code"`
+	Name    string `json:"name" binding:"required"`
+	RateBps int64  `json:"rate_bps"` // basis points: 1900 is 19%
+}
+
+type TaxRatesResponse struct {
+	TaxRates []data.TaxRate `json:"tax_rates"`
+}
+
+// ReceiptResponse itemises a transaction: Subtotal less Discount plus Tax is
+// Total, the amount charged.
+type ReceiptResponse struct {
+	Transaction data.Transaction `json:"transaction"`
+	LineItems   []data.LineItem  `json:"line_items"`
+	Subtotal    money.Amount     `json:"subtotal"`
+	Discount    money.Amount     `json:"discount"`
+	Tax         money.Amount     `json:"tax"`
+	Total       money.Amount     `json:"total"`
+}
+
+// GetTaxRates lists tax rates by country, region and tax code, archived ones
+// included (admin view)
+func (h *Handlers) GetTaxRates(c *gin.Context) {
+	limit := int64(50)
+	offset := int64(0)
+
+	// Parse pagination parameters
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.ParseInt(limitStr, 10, 64); err == nil && l > 0 {
+			limit = l
+		}
+	}
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		if o, err := strconv.ParseInt(offsetStr, 10, 64); err == nil && o >= 0 {
+			offset = o
+		}
+	}
+
+	rows, err := h.store.ListTaxRates(c.Request.Context(), db.ListTaxRatesParams{Limit: limit, Offset: offset})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tax rates"})
+		return
+	}
+	result := make([]data.TaxRate, len(rows))
+	for i, row := range rows {
+		result[i] = toTaxRate(row)
+	}
+	c.JSON(http.StatusOK, TaxRatesResponse{TaxRates: result})
+}
+
+// CreateTaxRate saves a tax rate and mirrors it to Stripe. Rates can't be
+// edited: archive the old one and create its replacement (admin)
+func (h *Handlers) CreateTaxRate(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req TaxRateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rate := tax.Rate{
+		Country: req.Country,
+		Region:  req.Region,
+		TaxCode: req.TaxCode,
+		Name:    req.Name,
+		RateBps: req.RateBps,
+	}.Normalize()
+	if err := rate.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	stripeTaxRateID, err := h.service.CreateTaxRate(ctx, payments.TaxRateParams{
+		Name:    rate.Name,
+		Country: rate.Country,
+		Region:  rate.Region,
+		RateBps: rate.RateBps,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	var created db.TaxRate
+	var logged db.AuditEvent
+	err = h.store.WithTx(ctx, func(q db.Querier) error {
+		var err error
+		created, err = q.CreateTaxRate(ctx, db.CreateTaxRateParams{
+			Country:         rate.Country,
+			Region:          rate.Region,
+			TaxCode:         rate.TaxCode,
+			Name:            rate.Name,
+			RateBps:         rate.RateBps,
+			StripeTaxRateID: sql.NullString{String: stripeTaxRateID, Valid: true},
+			CreatedAt:       db.Now(),
+		})
+		if err != nil {
+			return err
+		}
+		logged, err = h.auditService.LogTx(ctx, q, audit.TaxRateCreated.New(
+			"Tax rate "+created.Name+" created", taxRatePayload(created),
+		).WithRefs(created.StripeTaxRateID.String, ""))
+		return err
+	})
+	if err != nil {
+		log.Printf("failed to create tax rate %s: %v", rate.Name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create tax rate"})
+		return
+	}
+	h.auditService.Publish(logged)
+	c.JSON(http.StatusCreated, toTaxRate(created))
+}
+
+// ArchiveTaxRate stops a tax rate from applying to new checkouts and archives its
+// Stripe tax rate. Transactions keep the tax they were charged (admin)
+func (h *Handlers) ArchiveTaxRate(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tax rate ID"})
+		return
+	}
+	rate, err := h.store.GetTaxRate(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tax rate not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tax rate"})
+		return
+	}
+	if rate.ArchivedAt.Valid {
+		c.JSON(http.StatusOK, toTaxRate(rate))
+		return
+	}
+	if err := h.service.ArchiveTaxRate(ctx, rate.StripeTaxRateID.String); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	archivedAt := db.NullTimestamp{Timestamp: db.Now(), Valid: true}
+	var logged db.AuditEvent
+	err = h.store.WithTx(ctx, func(q db.Querier) error {
+		if err := q.ArchiveTaxRate(ctx, db.ArchiveTaxRateParams{ArchivedAt: archivedAt, ID: rate.ID}); err != nil {
+			return err
+		}
+		var err error
+		logged, err = h.auditService.LogTx(ctx, q, audit.TaxRateArchived.New(
+			"Tax rate "+rate.Name+" archived", taxRatePayload(rate),
+		).WithRefs(rate.StripeTaxRateID.String, ""))
+		return err
+	})
+	if err != nil {
+		log.Printf("failed to archive tax rate %d: %v", rate.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to archive tax rate"})
+		return
+	}
+	h.auditService.Publish(logged)
+
+	rate.ArchivedAt = archivedAt
+	c.JSON(http.StatusOK, toTaxRate(rate))
+}
+
+// GetTransactionReceipt itemises a transaction with its discount and tax.
+// Transactions from before line items were recorded get one made up from the
+// transaction itself.
+func (h *Handlers) GetTransactionReceipt(c *gin.Context) {
+	ctx := c.Request.Context()
+	txn, err := h.store.GetTransaction(ctx, c.Param("id"))
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transaction"})
+		return
+	}
+	items, err := h.store.ListTransactionLineItems(ctx, txn.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch line items"})
+		return
+	}
+	if len(items) == 0 {
+		items = []db.TransactionLineItem{{
+			TransactionID:  txn.ID,
+			ProductID:      txn.ProductID,
+			Description:    txn.ProductName,
+			Quantity:       1,
+			UnitAmount:     txn.Amount + txn.DiscountAmount - txn.TaxAmount,
+			Currency:       txn.Currency,
+			DiscountAmount: txn.DiscountAmount,
+			TaxAmount:      txn.TaxAmount,
+		}}
+	}
+
+	receipt := ReceiptResponse{
+		Transaction: data.NewTransaction(txn),
+		LineItems:   make([]data.LineItem, len(items)),
+		Subtotal:    money.Amount{Currency: txn.Currency},
+		Discount:    money.Amount{Currency: txn.Currency},
+		Tax:         money.Amount{Currency: txn.Currency},
+		Total:       money.Amount{Minor: txn.Amount, Currency: txn.Currency},
+	}
+	for i, item := range items {
+		receipt.LineItems[i] = toLineItem(item)
+		receipt.Subtotal.Minor += item.UnitAmount * item.Quantity
+		receipt.Discount.Minor += item.DiscountAmount
+		receipt.Tax.Minor += item.TaxAmount
+	}
+	c.JSON(http.StatusOK, receipt)
+}
+
+// toTaxRate converts a stored tax rate into its API representation
+func toTaxRate(rate db.TaxRate) data.TaxRate {
+	result := data.TaxRate{
+		ID:         rate.ID,
+		Country:    rate.Country,
+		Region:     rate.Region,
+		TaxCode:    rate.TaxCode,
+		Name:       rate.Name,
+		RateBps:    rate.RateBps,
+		Percentage: tax.Percentage(rate.RateBps),
+		CreatedAt:  rate.CreatedAt,
+		ArchivedAt: rate.ArchivedAt.Ptr(),
+	}
+	if rate.StripeTaxRateID.Valid {
+		result.StripeTaxRateID = &rate.StripeTaxRateID.String
+	}
+	return result
+}
+
+// toLineItem converts a stored line item into its API representation
+func toLineItem(item db.TransactionLineItem) data.LineItem {
+	result := data.LineItem{
+		ProductID:   item.ProductID,
+		Description: item.Description,
+		Quantity:    item.Quantity,
+		UnitAmount:  money.Amount{Minor: item.UnitAmount, Currency: item.Currency},
+		Discount:    amountPtr(money.Amount{Minor: item.DiscountAmount, Currency: item.Currency}),
+		Tax:         amountPtr(money.Amount{Minor: item.TaxAmount, Currency: item.Currency}),
+		TaxCode:     item.TaxCode,
+		Total: money.Amount{
+			Minor:    item.UnitAmount*item.Quantity - item.DiscountAmount + item.TaxAmount,
+			Currency: item.Currency,
+		},
+	}
+	if item.TaxRateID.Valid {
+		result.TaxRateID = &item.TaxRateID.Int64
+	}
+	return result
+}
+
+func taxRatePayload(rate db.TaxRate) audit.TaxRatePayload {
+	return audit.TaxRatePayload{
+		TaxRateID:       rate.ID,
+		Country:         rate.Country,
+		Region:          rate.Region,
+		TaxCode:         rate.TaxCode,
+		Name:            rate.Name,
+		RateBps:         rate.RateBps,
+		StripeTaxRateID: rate.StripeTaxRateID.String,
+	}
+}
@@ -0,0 +1,184 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"sort"
+	"stripe-go-spike/internal/audit"
+	"stripe-go-spike/internal/data"
+	"stripe-go-spike/internal/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// timelineAuditLimit caps the audit events collected for one transaction
+const timelineAuditLimit = 1000
+
+// Timeline entry kinds
+const (
+	TimelineStatusChange = "status_change"
+	TimelineAuditEvent   = "audit_event"
+	TimelineWebhook      = "webhook"
+)
+
+// TimelineEntry is one item of a transaction timeline. Exactly one of AuditEvent
+// and Webhook is set, except for status changes derived from the transaction row.
+type TimelineEntry struct {
+	Timestamp  db.Timestamp            `json:"timestamp"`
+	Kind       string                  `json:"kind"`
+	Type       string                  `json:"type"`
+	Status     string                  `json:"status,omitempty"`
+	AuditEvent *data.AuditEvent        `json:"audit_event,omitempty"`
+	Webhook    *data.WebhookInboxEntry `json:"webhook,omitempty"`
+}
+
+// TimelineResponse is the merged history of one transaction
+type TimelineResponse struct {
+	Transaction     data.Transaction `json:"transaction"`
+	SessionID       *string          `json:"session_id,omitempty"`
+	PaymentIntentID *string          `json:"payment_intent_id,omitempty"`
+	Entries         []TimelineEntry  `json:"entries"`
+}
+
+// transactionStatuses maps the audit events that change a transaction's status
+// to the status they set.
+var transactionStatuses = map[string]string{
+	audit.TransactionCreated.Definition().EventType:   "pending",
+	audit.TransactionCompleted.Definition().EventType: "completed",
+	audit.TransactionFailed.Definition().EventType:    "failed",
+	audit.TransactionCancelled.Definition().EventType: "cancelled",
+	audit.TransactionRefunded.Definition().EventType:  "refunded",
+}
+
+// GetTransactionTimeline returns every audit event, webhook inbox entry and status
+// change for a transaction, correlated by transaction, session and payment intent
+// ID and ordered by time.
+func (h *Handlers) GetTransactionTimeline(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	txn, err := h.store.GetTransaction(ctx, c.Param("id"))
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transaction"})
+		return
+	}
+
+	events, err := h.store.ListAuditEventsByRefs(ctx, db.ListAuditEventsByRefsParams{
+		RefID:   sql.NullString{String: txn.ID, Valid: true},
+		RefID_2: txn.StripeSessionID,
+		RefID_3: txn.StripePaymentIntentID,
+		Limit:   timelineAuditLimit,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch audit events"})
+		return
+	}
+
+	webhooks, err := h.store.ListWebhookInboxByRefs(ctx, db.ListWebhookInboxByRefsParams{
+		SessionID:       txn.StripeSessionID,
+		PaymentIntentID: txn.StripePaymentIntentID,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch webhooks"})
+		return
+	}
+
+	transaction := data.NewTransaction(txn)
+	c.JSON(http.StatusOK, TimelineResponse{
+		Transaction:     transaction,
+		SessionID:       transaction.StripeSessionID,
+		PaymentIntentID: transaction.StripePaymentIntentID,
+		Entries:         buildTimeline(transaction, events, webhooks),
+	})
+}
+
+// buildTimeline merges audit events and webhooks into one chronological list.
+// Status changes come from the transaction.* audit events; when those are missing
+// (e.g. archived by retention) the creation and refund are taken from the row.
+func buildTimeline(txn data.Transaction, events []db.AuditEvent, webhooks []db.WebhookInbox) []TimelineEntry {
+	entries := make([]TimelineEntry, 0, len(events)+len(webhooks)+2)
+	seen := make(map[string]bool)
+
+	for _, event := range events {
+		auditEvent := toAuditEvent(event)
+		entry := TimelineEntry{
+			Timestamp:  auditEvent.Timestamp,
+			Kind:       TimelineAuditEvent,
+			Type:       event.EventType,
+			AuditEvent: &auditEvent,
+		}
+		if status, ok := transactionStatuses[event.EventType]; ok && event.Subsystem == audit.SubsystemPayment {
+			entry.Kind = TimelineStatusChange
+			entry.Status = status
+			seen[status] = true
+		}
+		entries = append(entries, entry)
+	}
+
+	if !seen["pending"] {
+		entries = append(entries, TimelineEntry{
+			Timestamp: txn.CreatedAt,
+			Kind:      TimelineStatusChange,
+			Type:      audit.TransactionCreated.Definition().EventType,
+			Status:    "pending",
+		})
+	}
+	if txn.RefundDate != nil && !seen["refunded"] {
+		entries = append(entries, TimelineEntry{
+			Timestamp: *txn.RefundDate,
+			Kind:      TimelineStatusChange,
+			Type:      audit.TransactionRefunded.Definition().EventType,
+			Status:    "refunded",
+		})
+	}
+
+	for _, webhook := range webhooks {
+		entry := toWebhookInboxEntry(webhook)
+		entries = append(entries, TimelineEntry{
+			Timestamp: entry.ReceivedAt,
+			Kind:      TimelineWebhook,
+			Type:      webhook.EventType,
+			Status:    webhook.Status,
+			Webhook:   &entry,
+		})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Timestamp.Before(entries[j].Timestamp.Time)
+	})
+	return entries
+}
+
+// toWebhookInboxEntry converts a stored inbox row into its API representation
+func toWebhookInboxEntry(w db.WebhookInbox) data.WebhookInboxEntry {
+	return data.WebhookInboxEntry{
+		ID:            w.ID,
+		StripeEventID: w.StripeEventID,
+		EventType:     w.EventType,
+		SessionID: func() *string {
+			if w.SessionID.Valid {
+				return &w.SessionID.String
+			}
+			return nil
+		}(),
+		PaymentIntentID: func() *string {
+			if w.PaymentIntentID.Valid {
+				return &w.PaymentIntentID.String
+			}
+			return nil
+		}(),
+		Status: w.Status,
+		Error: func() *string {
+			if w.Error.Valid {
+				return &w.Error.String
+			}
+			return nil
+		}(),
+		ReceivedAt:  w.ReceivedAt,
+		ProcessedAt: w.ProcessedAt.Ptr(),
+	}
+}
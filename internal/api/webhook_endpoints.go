@@ -0,0 +1,396 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"stripe-go-spike/internal/audit"
+	"stripe-go-spike/internal/data"
+	"stripe-go-spike/internal/db"
+	"stripe-go-spike/internal/outbound"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WebhookEndpointRequest registers or changes an outbound webhook endpoint.
+type WebhookEndpointRequest struct {
+	URL         string   `json:"url" binding:"required"`
+	EventTypes  []string `json:"event_types"` // empty for every event type
+	Description string   `json:"description"`
+	Active      *bool    `json:"active"` // updates only; unchanged when omitted
+}
+
+type WebhookEndpointsResponse struct {
+	WebhookEndpoints []data.WebhookEndpoint `json:"webhook_endpoints"`
+}
+
+type WebhookDeliveriesResponse struct {
+	WebhookDeliveries []data.WebhookDelivery `json:"webhook_deliveries"`
+}
+
+// GetWebhookEndpoints lists outbound webhook endpoints (admin view)
+func (h *Handlers) GetWebhookEndpoints(c *gin.Context) {
+	limit := int64(50)
+	offset := int64(0)
+
+	// Parse pagination parameters
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.ParseInt(limitStr, 10, 64); err == nil && l > 0 {
+			limit = l
+		}
+	}
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		if o, err := strconv.ParseInt(offsetStr, 10, 64); err == nil && o >= 0 {
+			offset = o
+		}
+	}
+
+	rows, err := h.store.ListWebhookEndpoints(c.Request.Context(), db.ListWebhookEndpointsParams{Limit: limit, Offset: offset})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch webhook endpoints"})
+		return
+	}
+	result := make([]data.WebhookEndpoint, len(rows))
+	for i, row := range rows {
+		result[i] = toWebhookEndpoint(row)
+	}
+	c.JSON(http.StatusOK, WebhookEndpointsResponse{WebhookEndpoints: result})
+}
+
+// GetWebhookEndpoint returns one outbound webhook endpoint (admin view)
+func (h *Handlers) GetWebhookEndpoint(c *gin.Context) {
+	endpoint, ok := h.lookupWebhookEndpoint(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, toWebhookEndpoint(endpoint))
+}
+
+// CreateWebhookEndpoint registers an outbound webhook endpoint. The response
+// carries the signing secret, which isn't shown again (admin)
+func (h *Handlers) CreateWebhookEndpoint(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req WebhookEndpointRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	spec := outbound.EndpointSpec{URL: req.URL, EventTypes: req.EventTypes, Description: req.Description}.Normalize()
+	if err := spec.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	secret, err := outbound.NewSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate signing secret"})
+		return
+	}
+
+	now := db.Now()
+	var created db.WebhookEndpoint
+	var logged db.AuditEvent
+	err = h.store.WithTx(ctx, func(q db.Querier) error {
+		var err error
+		created, err = q.CreateWebhookEndpoint(ctx, db.CreateWebhookEndpointParams{
+			Url:         spec.URL,
+			Secret:      secret,
+			EventTypes:  outbound.FormatEventTypes(spec.EventTypes),
+			Description: spec.Description,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		})
+		if err != nil {
+			return err
+		}
+		logged, err = h.auditService.LogTx(ctx, q, audit.WebhookEndpointCreated.New(
+			"Webhook endpoint "+created.Url+" registered", webhookEndpointPayload(created),
+		))
+		return err
+	})
+	if err != nil {
+		log.Printf("failed to create webhook endpoint %s: %v", spec.URL, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook endpoint"})
+		return
+	}
+	h.auditService.Publish(logged)
+
+	result := toWebhookEndpoint(created)
+	result.Secret = created.Secret
+	c.JSON(http.StatusCreated, result)
+}
+
+// UpdateWebhookEndpoint changes an endpoint's URL, event types or description,
+// and enables or disables it. Queued deliveries keep going to the endpoint
+// (admin)
+func (h *Handlers) UpdateWebhookEndpoint(c *gin.Context) {
+	endpoint, ok := h.lookupWebhookEndpoint(c)
+	if !ok {
+		return
+	}
+	var req WebhookEndpointRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	spec := outbound.EndpointSpec{URL: req.URL, EventTypes: req.EventTypes, Description: req.Description}.Normalize()
+	if err := spec.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	active := endpoint.Active
+	if req.Active != nil {
+		active = *req.Active
+	}
+	h.saveWebhookEndpoint(c, db.UpdateWebhookEndpointParams{
+		Url:         spec.URL,
+		EventTypes:  outbound.FormatEventTypes(spec.EventTypes),
+		Description: spec.Description,
+		Active:      active,
+		ID:          endpoint.ID,
+	}, "Webhook endpoint "+spec.URL+" updated")
+}
+
+// DisableWebhookEndpoint stops queueing events for an endpoint and fails its
+// pending deliveries on their next attempt. Its delivery log is kept (admin)
+func (h *Handlers) DisableWebhookEndpoint(c *gin.Context) {
+	endpoint, ok := h.lookupWebhookEndpoint(c)
+	if !ok {
+		return
+	}
+	h.saveWebhookEndpoint(c, db.UpdateWebhookEndpointParams{
+		Url:         endpoint.Url,
+		EventTypes:  endpoint.EventTypes,
+		Description: endpoint.Description,
+		Active:      false,
+		ID:          endpoint.ID,
+	}, "Webhook endpoint "+endpoint.Url+" disabled")
+}
+
+// saveWebhookEndpoint updates an endpoint with its audit event and writes the
+// response.
+func (h *Handlers) saveWebhookEndpoint(c *gin.Context, params db.UpdateWebhookEndpointParams, info string) {
+	ctx := c.Request.Context()
+	params.UpdatedAt = db.Now()
+
+	var updated db.WebhookEndpoint
+	var logged db.AuditEvent
+	err := h.store.WithTx(ctx, func(q db.Querier) error {
+		var err error
+		updated, err = q.UpdateWebhookEndpoint(ctx, params)
+		if err != nil {
+			return err
+		}
+		logged, err = h.auditService.LogTx(ctx, q, audit.WebhookEndpointUpdated.New(info, webhookEndpointPayload(updated)))
+		return err
+	})
+	if err != nil {
+		log.Printf("failed to update webhook endpoint %d: %v", params.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update webhook endpoint"})
+		return
+	}
+	h.auditService.Publish(logged)
+	c.JSON(http.StatusOK, toWebhookEndpoint(updated))
+}
+
+// GetWebhookDeliveries lists an endpoint's deliveries, newest first (admin
+// view)
+func (h *Handlers) GetWebhookDeliveries(c *gin.Context) {
+	endpoint, ok := h.lookupWebhookEndpoint(c)
+	if !ok {
+		return
+	}
+	limit := int64(50)
+	offset := int64(0)
+
+	// Parse pagination parameters
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.ParseInt(limitStr, 10, 64); err == nil && l > 0 {
+			limit = l
+		}
+	}
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		if o, err := strconv.ParseInt(offsetStr, 10, 64); err == nil && o >= 0 {
+			offset = o
+		}
+	}
+
+	rows, err := h.store.ListWebhookDeliveriesByEndpoint(c.Request.Context(), db.ListWebhookDeliveriesByEndpointParams{
+		EndpointID: endpoint.ID,
+		Limit:      limit,
+		Offset:     offset,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch webhook deliveries"})
+		return
+	}
+	result := make([]data.WebhookDelivery, len(rows))
+	for i, row := range rows {
+		result[i] = toWebhookDelivery(row)
+	}
+	c.JSON(http.StatusOK, WebhookDeliveriesResponse{WebhookDeliveries: result})
+}
+
+// GetWebhookDelivery returns a delivery with its payload and every attempt
+// (admin view)
+func (h *Handlers) GetWebhookDelivery(c *gin.Context) {
+	delivery, ok := h.lookupWebhookDelivery(c)
+	if !ok {
+		return
+	}
+	h.writeWebhookDelivery(c, delivery)
+}
+
+// RedeliverWebhook queues a delivery again with a fresh set of retries and
+// makes its first attempt right away, e.g. once a failing endpoint is fixed
+// (admin)
+func (h *Handlers) RedeliverWebhook(c *gin.Context) {
+	ctx := c.Request.Context()
+	delivery, ok := h.lookupWebhookDelivery(c)
+	if !ok {
+		return
+	}
+	endpoint, err := h.store.GetWebhookEndpoint(ctx, delivery.EndpointID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch webhook endpoint"})
+		return
+	}
+	if !endpoint.Active {
+		c.JSON(http.StatusConflict, gin.H{"error": "Webhook endpoint is disabled"})
+		return
+	}
+
+	now := db.Now()
+	delivery, err = h.store.ResetWebhookDelivery(ctx, db.ResetWebhookDeliveryParams{
+		NextAttemptAt: db.NullTimestamp{Timestamp: now, Valid: true},
+		UpdatedAt:     now,
+		ID:            delivery.ID,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue webhook delivery"})
+		return
+	}
+	// A failed attempt is part of the response; the delivery is retried as usual
+	delivery, err = h.dispatcher.Deliver(ctx, delivery)
+	if err != nil {
+		log.Printf("failed to redeliver webhook delivery %d: %v", delivery.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to redeliver webhook"})
+		return
+	}
+	h.writeWebhookDelivery(c, delivery)
+}
+
+// writeWebhookDelivery writes a delivery with its attempts.
+func (h *Handlers) writeWebhookDelivery(c *gin.Context, delivery db.WebhookDelivery) {
+	attempts, err := h.store.ListWebhookDeliveryAttempts(c.Request.Context(), delivery.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch webhook delivery attempts"})
+		return
+	}
+	result := toWebhookDelivery(delivery)
+	result.Payload = json.RawMessage(delivery.Payload)
+	for _, attempt := range attempts {
+		result.AttemptLog = append(result.AttemptLog, data.WebhookDeliveryAttempt{
+			AttemptedAt: attempt.AttemptedAt,
+			StatusCode:  nullInt64Ptr(attempt.StatusCode),
+			Error:       attempt.Error.String,
+			DurationMs:  attempt.DurationMs,
+		})
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// lookupWebhookEndpoint loads the webhook endpoint named by the :id parameter,
+// writing the error response if there is none.
+func (h *Handlers) lookupWebhookEndpoint(c *gin.Context) (db.WebhookEndpoint, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook endpoint ID"})
+		return db.WebhookEndpoint{}, false
+	}
+	endpoint, err := h.store.GetWebhookEndpoint(c.Request.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook endpoint not found"})
+		return db.WebhookEndpoint{}, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch webhook endpoint"})
+		return db.WebhookEndpoint{}, false
+	}
+	return endpoint, true
+}
+
+// lookupWebhookDelivery loads the webhook delivery named by the :id parameter,
+// writing the error response if there is none.
+func (h *Handlers) lookupWebhookDelivery(c *gin.Context) (db.WebhookDelivery, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook delivery ID"})
+		return db.WebhookDelivery{}, false
+	}
+	delivery, err := h.store.GetWebhookDelivery(c.Request.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook delivery not found"})
+		return db.WebhookDelivery{}, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch webhook delivery"})
+		return db.WebhookDelivery{}, false
+	}
+	return delivery, true
+}
+
+// toWebhookEndpoint converts a stored webhook endpoint into its API
+// representation, without its secret
+func toWebhookEndpoint(endpoint db.WebhookEndpoint) data.WebhookEndpoint {
+	eventTypes := outbound.ParseEventTypes(endpoint.EventTypes)
+	if eventTypes == nil {
+		eventTypes = []string{}
+	}
+	return data.WebhookEndpoint{
+		ID:          endpoint.ID,
+		URL:         endpoint.Url,
+		EventTypes:  eventTypes,
+		Description: endpoint.Description,
+		Active:      endpoint.Active,
+		CreatedAt:   endpoint.CreatedAt,
+		UpdatedAt:   endpoint.UpdatedAt,
+	}
+}
+
+// toWebhookDelivery converts a stored webhook delivery into its API
+// representation, without its payload
+func toWebhookDelivery(delivery db.WebhookDelivery) data.WebhookDelivery {
+	return data.WebhookDelivery{
+		ID:             delivery.ID,
+		EndpointID:     delivery.EndpointID,
+		EventID:        delivery.EventID,
+		EventType:      delivery.EventType,
+		Status:         delivery.Status,
+		Attempts:       delivery.Attempts,
+		NextAttemptAt:  delivery.NextAttemptAt.Ptr(),
+		LastStatusCode: nullInt64Ptr(delivery.LastStatusCode),
+		LastError:      delivery.LastError.String,
+		CreatedAt:      delivery.CreatedAt,
+		UpdatedAt:      delivery.UpdatedAt,
+	}
+}
+
+func nullInt64Ptr(n sql.NullInt64) *int64 {
+	if !n.Valid {
+		return nil
+	}
+	return &n.Int64
+}
+
+func webhookEndpointPayload(endpoint db.WebhookEndpoint) audit.WebhookEndpointPayload {
+	return audit.WebhookEndpointPayload{
+		EndpointID: endpoint.ID,
+		URL:        endpoint.Url,
+		EventTypes: endpoint.EventTypes,
+		Active:     endpoint.Active,
+	}
+}
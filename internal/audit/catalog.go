@@ -0,0 +1,171 @@
+package audit
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Severity classifies how important an audit event is.
+type Severity string
+
+const (
+	SeverityDebug   Severity = "debug"
+	SeverityInfo    Severity = "info"
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error"
+)
+
+// ErrUnknownEventType is returned in strict mode for events missing from the catalog.
+var ErrUnknownEventType = errors.New("unknown audit event type")
+
+// ErrPayloadMismatch is returned in strict mode when a payload has the wrong type.
+var ErrPayloadMismatch = errors.New("audit payload does not match event definition")
+
+// PayloadField describes one JSON field of an event payload.
+type PayloadField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// Definition describes one registered audit event type.
+type Definition struct {
+	Subsystem   string         `json:"subsystem"`
+	EventType   string         `json:"event_type"`
+	Severity    Severity       `json:"severity"`
+	Description string         `json:"description"`
+	Payload     []PayloadField `json:"payload"`
+
+	payloadType reflect.Type
+}
+
+func (d Definition) key() string {
+	return d.Subsystem + "/" + d.EventType
+}
+
+var catalog = struct {
+	sync.RWMutex
+	defs map[string]Definition
+}{defs: make(map[string]Definition)}
+
+// EventDef is a typed handle on a registered event; P is its payload struct.
+type EventDef[P any] struct {
+	def Definition
+}
+
+// Define registers an event type with payload P. It panics on duplicates, so
+// definitions belong in package-level vars.
+func Define[P any](subsystem, eventType string, severity Severity, description string) EventDef[P] {
+	t := reflect.TypeOf((*P)(nil)).Elem()
+	def := Definition{
+		Subsystem:   subsystem,
+		EventType:   eventType,
+		Severity:    severity,
+		Description: description,
+		Payload:     payloadFields(t),
+		payloadType: t,
+	}
+
+	catalog.Lock()
+	defer catalog.Unlock()
+	if _, exists := catalog.defs[def.key()]; exists {
+		panic(fmt.Sprintf("audit event %s defined twice", def.key()))
+	}
+	catalog.defs[def.key()] = def
+	return EventDef[P]{def: def}
+}
+
+// Definition returns the registered definition.
+func (d EventDef[P]) Definition() Definition {
+	return d.def
+}
+
+// New builds an event of this type; chain WithUser/WithRefs for optional fields.
+func (d EventDef[P]) New(information string, payload P) Event {
+	return Event{
+		Subsystem:   d.def.Subsystem,
+		EventType:   d.def.EventType,
+		Information: information,
+		Payload:     payload,
+	}
+}
+
+// WithUser sets the user the event relates to.
+func (e Event) WithUser(userID string) Event {
+	if userID != "" {
+		e.UserID = &userID
+	}
+	return e
+}
+
+// WithRefs sets the primary and secondary reference IDs; empty strings are left unset.
+func (e Event) WithRefs(refID, refID2 string) Event {
+	if refID != "" {
+		e.RefID = &refID
+	}
+	if refID2 != "" {
+		e.RefID2 = &refID2
+	}
+	return e
+}
+
+// Lookup returns the definition registered for subsystem and event type.
+func Lookup(subsystem, eventType string) (Definition, bool) {
+	catalog.RLock()
+	defer catalog.RUnlock()
+	def, ok := catalog.defs[subsystem+"/"+eventType]
+	return def, ok
+}
+
+// Types lists all registered definitions ordered by subsystem and event type.
+func Types() []Definition {
+	catalog.RLock()
+	defer catalog.RUnlock()
+	defs := make([]Definition, 0, len(catalog.defs))
+	for _, def := range catalog.defs {
+		defs = append(defs, def)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].key() < defs[j].key() })
+	return defs
+}
+
+// validate checks an event against the catalog.
+func (e Event) validate() error {
+	def, ok := Lookup(e.Subsystem, e.EventType)
+	if !ok {
+		return fmt.Errorf("%w: %s/%s", ErrUnknownEventType, e.Subsystem, e.EventType)
+	}
+	if e.Payload != nil && reflect.TypeOf(e.Payload) != def.payloadType {
+		return fmt.Errorf("%w: %s/%s expects %s, got %T", ErrPayloadMismatch, e.Subsystem, e.EventType, def.payloadType, e.Payload)
+	}
+	return nil
+}
+
+// payloadFields lists the JSON fields of a payload struct.
+func payloadFields(t reflect.Type) []PayloadField {
+	fields := []PayloadField{}
+	if t.Kind() != reflect.Struct {
+		return fields
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("json"); ok {
+			tagName, _, _ := strings.Cut(tag, ",")
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				name = tagName
+			}
+		}
+		fields = append(fields, PayloadField{Name: name, Type: f.Type.String()})
+	}
+	return fields
+}
@@ -0,0 +1,43 @@
+package audit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStrictServiceRejectsUnknownEvents(t *testing.T) {
+	svc := NewService(newTestQueries(t), WithStrictTypes())
+	ctx := context.Background()
+
+	err := svc.Log(ctx, Event{Subsystem: SubsystemPayment, EventType: "transaction.update_faild"})
+	assert.ErrorIs(t, err, ErrUnknownEventType)
+
+	err = svc.Log(ctx, Event{
+		Subsystem: SubsystemPayment,
+		EventType: "transaction.completed",
+		Payload:   map[string]interface{}{"payment_intent_id": "pi_123"},
+	})
+	assert.ErrorIs(t, err, ErrPayloadMismatch)
+
+	require.NoError(t, svc.Log(ctx, TransactionCompleted.New("ok", TransactionStatusPayload{PaymentIntentID: "pi_123"}).WithRefs("pi_123", "")))
+	assert.Equal(t, uint64(2), svc.Stats().Failed)
+}
+
+func TestCatalogListsDefinitions(t *testing.T) {
+	def, ok := Lookup(SubsystemStripe, "webhook.received")
+	require.True(t, ok)
+	assert.Equal(t, SeverityDebug, def.Severity)
+	assert.Contains(t, def.Payload, PayloadField{Name: "raw_body", Type: "string"})
+
+	types := Types()
+	require.NotEmpty(t, types)
+	for i := 1; i < len(types); i++ {
+		assert.Less(t, types[i-1].key(), types[i].key())
+	}
+	assert.Panics(t, func() {
+		Define[ErrorPayload](SubsystemStripe, "webhook.received", SeverityInfo, "duplicate")
+	})
+}
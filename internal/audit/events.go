@@ -0,0 +1,273 @@
+package audit
+
+// Subsystems used by the event catalog.
+const (
+	SubsystemStripe  = "stripe"
+	SubsystemPayment = "payment"
+	SubsystemSystem  = "system"
+)
+
+// ErrorPayload carries only a failure message.
+type ErrorPayload struct {
+	Error string `json:"error"`
+}
+
+// CheckoutFailedPayload is logged when a checkout session cannot be created.
+type CheckoutFailedPayload struct {
+	TransactionID string `json:"transaction_id"`
+	Error         string `json:"error"`
+}
+
+// WebhookReceivedPayload records an incoming webhook before it is verified.
+type WebhookReceivedPayload struct {
+	BodyLength   int    `json:"body_length"`
+	HasSignature bool   `json:"has_signature"`
+	Signature    string `json:"signature"`
+	RawBody      string `json:"raw_body"`
+}
+
+// WebhookFailedPayload records a webhook that failed verification or parsing.
+type WebhookFailedPayload struct {
+	Error     string `json:"error"`
+	Signature string `json:"signature"`
+}
+
+// WebhookProcessedPayload summarises a verified webhook event.
+type WebhookProcessedPayload struct {
+	EventType       string `json:"event_type"`
+	SessionID       string `json:"session_id"`
+	PaymentIntentID string `json:"payment_intent_id"`
+	Status          string `json:"status"`
+}
+
+// SessionRefPayload identifies a checkout session and its payment intent.
+type SessionRefPayload struct {
+	SessionID       string `json:"session_id,omitempty"`
+	PaymentIntentID string `json:"payment_intent_id,omitempty"`
+}
+
+// TransactionCreatedPayload describes a new pending transaction.
+type TransactionCreatedPayload struct {
+	TransactionID   string `json:"transaction_id"`
+	UserID          string `json:"user_id"`
+	ProductID       string `json:"product_id"`
+	ProductName     string `json:"product_name"`
+	Amount          int64  `json:"amount"`
+	Currency        string `json:"currency"`
+	CurrencySource  string `json:"currency_source,omitempty"` // which hint chose the currency
+	SessionID       string `json:"session_id"`
+	PaymentIntentID string `json:"payment_intent_id"`
+	CouponCode      string `json:"coupon_code,omitempty"`
+	DiscountAmount  int64  `json:"discount_amount,omitempty"` // taken off the price
+	TaxAmount       int64  `json:"tax_amount,omitempty"`      // added to give Amount
+	// Vendor sales record the connected account and the split of Amount
+	ConnectedAccountID   string `json:"connected_account_id,omitempty"`
+	ApplicationFeeAmount int64  `json:"application_fee_amount,omitempty"`
+	TransferAmount       int64  `json:"transfer_amount,omitempty"`
+}
+
+// TransactionStatusPayload describes a transaction status change and the Stripe
+// event that caused it.
+type TransactionStatusPayload struct {
+	SessionID       string `json:"session_id,omitempty"`
+	PaymentIntentID string `json:"payment_intent_id,omitempty"`
+	EventType       string `json:"event_type,omitempty"`
+}
+
+// TransactionUpdateFailedPayload is TransactionStatusPayload plus the database error.
+type TransactionUpdateFailedPayload struct {
+	SessionID       string `json:"session_id,omitempty"`
+	PaymentIntentID string `json:"payment_intent_id,omitempty"`
+	EventType       string `json:"event_type,omitempty"`
+	Error           string `json:"error"`
+}
+
+// TransactionReconciledPayload records a transaction repaired to match Stripe.
+type TransactionReconciledPayload struct {
+	TransactionID   string `json:"transaction_id"`
+	SessionID       string `json:"session_id,omitempty"`
+	PaymentIntentID string `json:"payment_intent_id,omitempty"`
+	Mismatch        string `json:"mismatch"`
+	FromStatus      string `json:"from_status"`
+	ToStatus        string `json:"to_status"`
+	Detail          string `json:"detail,omitempty"`
+}
+
+// CouponViolationPayload describes a promotion code entered at Stripe that broke
+// its coupon's product restriction or redemption limits.
+type CouponViolationPayload struct {
+	CouponID      int64  `json:"coupon_id"`
+	Code          string `json:"code"`
+	TransactionID string `json:"transaction_id"`
+	SessionID     string `json:"session_id"`
+	Error         string `json:"error"`
+}
+
+// CouponPayload describes a coupon as saved and the Stripe objects mirroring it.
+type CouponPayload struct {
+	CouponID              int64  `json:"coupon_id"`
+	Code                  string `json:"code"`
+	PercentOff            int64  `json:"percent_off,omitempty"`
+	AmountOff             int64  `json:"amount_off,omitempty"`
+	Currency              string `json:"currency,omitempty"`
+	ExpiresAt             string `json:"expires_at,omitempty"`
+	MaxRedemptions        int64  `json:"max_redemptions,omitempty"`
+	PerUserLimit          int64  `json:"per_user_limit,omitempty"`
+	StripeCouponID        string `json:"stripe_coupon_id,omitempty"`
+	StripePromotionCodeID string `json:"stripe_promotion_code_id,omitempty"`
+}
+
+// TaxRatePayload describes a tax rate and the Stripe tax rate mirroring it.
+type TaxRatePayload struct {
+	TaxRateID       int64  `json:"tax_rate_id"`
+	Country         string `json:"country"`
+	Region          string `json:"region,omitempty"`
+	TaxCode         string `json:"tax_code,omitempty"`
+	Name            string `json:"name"`
+	RateBps         int64  `json:"rate_bps"`
+	StripeTaxRateID string `json:"stripe_tax_rate_id,omitempty"`
+}
+
+// TaxMismatchPayload describes a completed checkout whose billing address calls
+// for another tax rate than the one charged, which followed the requested country.
+type TaxMismatchPayload struct {
+	TransactionID   string `json:"transaction_id"`
+	SessionID       string `json:"session_id"`
+	BillingCountry  string `json:"billing_country"`
+	BillingRegion   string `json:"billing_region,omitempty"`
+	ChargedRateID   int64  `json:"charged_rate_id,omitempty"`
+	ChargedRateBps  int64  `json:"charged_rate_bps"`
+	ExpectedRateID  int64  `json:"expected_rate_id,omitempty"`
+	ExpectedRateBps int64  `json:"expected_rate_bps"`
+}
+
+// ConnectedAccountPayload describes a vendor's connected account.
+type ConnectedAccountPayload struct {
+	ConnectedAccountID int64  `json:"connected_account_id"`
+	StripeAccountID    string `json:"stripe_account_id"`
+	Name               string `json:"name"`
+	Country            string `json:"country"`
+	FeeBps             int64  `json:"fee_bps"`
+	ChargesEnabled     bool   `json:"charges_enabled"`
+	PayoutsEnabled     bool   `json:"payouts_enabled"`
+	DetailsSubmitted   bool   `json:"details_submitted"`
+}
+
+// ProductAccountPayload records a product assigned to a vendor, or taken back
+// by the platform when ConnectedAccountID is 0.
+type ProductAccountPayload struct {
+	ProductID          string `json:"product_id"`
+	ConnectedAccountID int64  `json:"connected_account_id,omitempty"`
+	StripeAccountID    string `json:"stripe_account_id,omitempty"`
+}
+
+// WebhookEndpointPayload describes an outbound webhook endpoint, without its
+// secret.
+type WebhookEndpointPayload struct {
+	EndpointID int64  `json:"endpoint_id"`
+	URL        string `json:"url"`
+	EventTypes string `json:"event_types,omitempty"` // empty for every type
+	Active     bool   `json:"active"`
+}
+
+// WebhookDeliveryPayload describes an outbound webhook delivery and its last
+// attempt.
+type WebhookDeliveryPayload struct {
+	DeliveryID int64  `json:"delivery_id"`
+	EndpointID int64  `json:"endpoint_id"`
+	EventID    string `json:"event_id"`
+	EventType  string `json:"event_type"`
+	Attempts   int64  `json:"attempts"`
+	StatusCode int64  `json:"status_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// OutboxMessagePayload describes an outbox message and its last attempt.
+type OutboxMessagePayload struct {
+	MessageID int64  `json:"message_id"`
+	Topic     string `json:"topic"`
+	Key       string `json:"key"`
+	Attempts  int64  `json:"attempts"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ReconciliationPayload summarises a reconciliation run against Stripe.
+type ReconciliationPayload struct {
+	From       string `json:"from"`
+	To         string `json:"to"`
+	Checked    int    `json:"checked"`
+	Mismatches int    `json:"mismatches"`
+	Repaired   int    `json:"repaired"`
+	Error      string `json:"error,omitempty"`
+}
+
+// ArchivePayload summarises an audit archival run.
+type ArchivePayload struct {
+	File     string `json:"file"`
+	Archived int64  `json:"archived"`
+	Deleted  int64  `json:"deleted"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Stripe events
+var (
+	CheckoutSessionFailed    = Define[CheckoutFailedPayload](SubsystemStripe, "checkout_session.failed", SeverityError, "Creating a Stripe checkout session failed")
+	CheckoutSessionCompleted = Define[SessionRefPayload](SubsystemStripe, "checkout_session.completed", SeverityInfo, "Stripe reported a checkout session as completed")
+	WebhookReadFailed        = Define[ErrorPayload](SubsystemStripe, "webhook.read_failed", SeverityError, "The webhook request body could not be read")
+	WebhookReceived          = Define[WebhookReceivedPayload](SubsystemStripe, "webhook.received", SeverityDebug, "A webhook request arrived, before signature verification")
+	WebhookProcessingFailed  = Define[WebhookFailedPayload](SubsystemStripe, "webhook.processing_failed", SeverityWarning, "A webhook failed signature verification or parsing")
+	WebhookProcessed         = Define[WebhookProcessedPayload](SubsystemStripe, "webhook.processed", SeverityInfo, "A verified webhook event was parsed")
+)
+
+// Payment events
+var (
+	TransactionCreated      = Define[TransactionCreatedPayload](SubsystemPayment, "transaction.created", SeverityInfo, "A pending transaction was created for a checkout session")
+	TransactionCompleted    = Define[TransactionStatusPayload](SubsystemPayment, "transaction.completed", SeverityInfo, "A transaction was marked as completed")
+	TransactionFailed       = Define[TransactionStatusPayload](SubsystemPayment, "transaction.failed", SeverityWarning, "A transaction was marked as failed")
+	TransactionCancelled    = Define[TransactionStatusPayload](SubsystemPayment, "transaction.cancelled", SeverityInfo, "A transaction was marked as cancelled")
+	TransactionRefunded     = Define[TransactionStatusPayload](SubsystemPayment, "transaction.refunded", SeverityWarning, "A transaction was refunded or disputed")
+	TransactionUpdateFailed = Define[TransactionUpdateFailedPayload](SubsystemPayment, "transaction.update_failed", SeverityError, "Persisting a transaction status change failed")
+	TransactionReconciled   = Define[TransactionReconciledPayload](SubsystemPayment, "transaction.reconciled", SeverityWarning, "Reconciliation repaired a transaction to match Stripe")
+)
+
+// Coupon events
+var (
+	CouponCreated  = Define[CouponPayload](SubsystemPayment, "coupon.created", SeverityInfo, "A coupon was created and mirrored to Stripe")
+	CouponUpdated  = Define[CouponPayload](SubsystemPayment, "coupon.updated", SeverityInfo, "A coupon's expiry or redemption limits were changed")
+	CouponArchived = Define[CouponPayload](SubsystemPayment, "coupon.archived", SeverityInfo, "A coupon was archived and its promotion code deactivated")
+	CouponViolated = Define[CouponViolationPayload](SubsystemPayment, "coupon.violated", SeverityWarning, "A promotion code entered at Stripe broke its coupon's product restriction or limits")
+)
+
+// Tax events
+var (
+	TaxRateCreated  = Define[TaxRatePayload](SubsystemPayment, "tax_rate.created", SeverityInfo, "A tax rate was created and mirrored to Stripe")
+	TaxRateArchived = Define[TaxRatePayload](SubsystemPayment, "tax_rate.archived", SeverityInfo, "A tax rate was archived and its Stripe tax rate deactivated")
+	TaxRateMismatch = Define[TaxMismatchPayload](SubsystemPayment, "tax_rate.mismatch", SeverityWarning, "The tax rate charged doesn't match the customer's billing address")
+)
+
+// Marketplace events
+var (
+	ConnectedAccountCreated = Define[ConnectedAccountPayload](SubsystemPayment, "connected_account.created", SeverityInfo, "A vendor's connected account was created at Stripe")
+	ConnectedAccountUpdated = Define[ConnectedAccountPayload](SubsystemPayment, "connected_account.updated", SeverityInfo, "A connected account's fee or onboarding status changed")
+	ProductAccountAssigned  = Define[ProductAccountPayload](SubsystemPayment, "product.account_assigned", SeverityInfo, "A product was assigned to a vendor's connected account")
+	ProductAccountRemoved   = Define[ProductAccountPayload](SubsystemPayment, "product.account_removed", SeverityInfo, "A product was taken back from a vendor")
+)
+
+// Outbound webhook events
+var (
+	WebhookEndpointCreated = Define[WebhookEndpointPayload](SubsystemSystem, "webhook_endpoint.created", SeverityInfo, "An outbound webhook endpoint was registered")
+	WebhookEndpointUpdated = Define[WebhookEndpointPayload](SubsystemSystem, "webhook_endpoint.updated", SeverityInfo, "An outbound webhook endpoint was changed or disabled")
+	WebhookDeliveryFailed  = Define[WebhookDeliveryPayload](SubsystemSystem, "webhook_delivery.failed", SeverityWarning, "An outbound webhook delivery failed its last retry")
+)
+
+// Outbox events
+var (
+	OutboxMessageDead     = Define[OutboxMessagePayload](SubsystemSystem, "outbox_message.dead", SeverityError, "An outbox message failed its last attempt and is no longer retried")
+	OutboxMessageRequeued = Define[OutboxMessagePayload](SubsystemSystem, "outbox_message.requeued", SeverityInfo, "A dead outbox message was given a fresh set of attempts")
+)
+
+// System events
+var (
+	AuditArchived           = Define[ArchivePayload](SubsystemSystem, "audit.archived", SeverityInfo, "Expired audit events were archived and deleted")
+	ReconciliationCompleted = Define[ReconciliationPayload](SubsystemSystem, "reconciliation.completed", SeverityInfo, "A reconciliation run against Stripe finished")
+)
@@ -0,0 +1,299 @@
+package audit
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"stripe-go-spike/internal/db"
+)
+
+// ExportFormat is the output encoding of an audit export.
+type ExportFormat string
+
+const (
+	FormatCSV    ExportFormat = "csv"
+	FormatNDJSON ExportFormat = "ndjson"
+)
+
+// exportPageSize bounds how many rows are held in memory while streaming an export.
+const exportPageSize = 500
+
+// payloadColumnPrefix marks a column that is extracted from the payload JSON.
+const payloadColumnPrefix = "payload."
+
+// DefaultExportColumns are used when ExportOptions.Columns is empty.
+var DefaultExportColumns = []string{
+	"id", "timestamp", "subsystem", "event_type", "user_id", "information", "ref_id", "ref_id2", "payload",
+}
+
+// ExportOptions selects the rows and columns written by Export.
+type ExportOptions struct {
+	Format ExportFormat
+	From   time.Time // inclusive; zero means unbounded
+	To     time.Time // exclusive; zero means unbounded
+	Filter Filter
+	// Columns lists base columns (see DefaultExportColumns) and/or payload fields
+	// written as "payload.<path>", where <path> is dot-separated, e.g.
+	// "payload.session_id" or "payload.charge.payment_intent".
+	Columns []string
+}
+
+// Validate checks the options and fills defaults.
+func (o *ExportOptions) Validate() error {
+	switch o.Format {
+	case "":
+		o.Format = FormatCSV
+	case FormatCSV, FormatNDJSON:
+	default:
+		return fmt.Errorf("unsupported export format %q", o.Format)
+	}
+	if !o.From.IsZero() && !o.To.IsZero() && !o.From.Before(o.To) {
+		return fmt.Errorf("export range is empty: from %s is not before to %s", o.From, o.To)
+	}
+	if len(o.Columns) == 0 {
+		o.Columns = DefaultExportColumns
+	}
+	for _, col := range o.Columns {
+		if strings.HasPrefix(col, payloadColumnPrefix) && len(col) > len(payloadColumnPrefix) {
+			continue
+		}
+		if !isBaseColumn(col) {
+			return fmt.Errorf("unknown export column %q", col)
+		}
+	}
+	return nil
+}
+
+func isBaseColumn(col string) bool {
+	for _, c := range DefaultExportColumns {
+		if c == col {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseExportTime accepts RFC3339 timestamps or plain dates (YYYY-MM-DD, UTC midnight).
+// An empty string yields the zero time, i.e. an unbounded side of the range.
+func ParseExportTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q: use RFC3339 or YYYY-MM-DD", s)
+	}
+	return t, nil
+}
+
+// ParseExportColumns splits a comma-separated column list, ignoring blanks.
+func ParseExportColumns(s string) []string {
+	var cols []string
+	for _, col := range strings.Split(s, ",") {
+		if col = strings.TrimSpace(col); col != "" {
+			cols = append(cols, col)
+		}
+	}
+	return cols
+}
+
+// Export streams the matching audit events to w page by page and returns how many
+// rows were written. Rows are ordered by id.
+func (s *Service) Export(ctx context.Context, w io.Writer, opts ExportOptions) (int, error) {
+	if err := opts.Validate(); err != nil {
+		return 0, err
+	}
+
+	enc, err := newExportEncoder(w, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	params := db.ListAuditEventsForExportParams{
+		Timestamp_2: db.NewTimestamp(time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)),
+		Subsystem:   opts.Filter.Subsystem,
+		EventType:   opts.Filter.EventType,
+		UserID:      opts.Filter.UserID,
+		RefID:       opts.Filter.RefID,
+		RefId2:      opts.Filter.RefID2,
+		Limit:       exportPageSize,
+	}
+	if !opts.From.IsZero() {
+		params.Timestamp = db.NewTimestamp(opts.From)
+	}
+	if !opts.To.IsZero() {
+		params.Timestamp_2 = db.NewTimestamp(opts.To)
+	}
+
+	written := 0
+	for {
+		events, err := s.queries.ListAuditEventsForExport(ctx, params)
+		if err != nil {
+			return written, fmt.Errorf("list audit events: %w", err)
+		}
+		for _, event := range events {
+			params.ID = event.ID
+			if err := enc.encode(event); err != nil {
+				return written, err
+			}
+			written++
+		}
+		if err := enc.flush(); err != nil {
+			return written, err
+		}
+		if len(events) < exportPageSize {
+			return written, nil
+		}
+	}
+}
+
+// exportEncoder writes rows in one output format.
+type exportEncoder struct {
+	columns []string
+	csv     *csv.Writer
+	json    *json.Encoder
+	flusher interface{ Flush() }
+}
+
+func newExportEncoder(w io.Writer, opts ExportOptions) (*exportEncoder, error) {
+	enc := &exportEncoder{columns: opts.Columns}
+	if f, ok := w.(interface{ Flush() }); ok {
+		enc.flusher = f
+	}
+	switch opts.Format {
+	case FormatCSV:
+		enc.csv = csv.NewWriter(w)
+		if err := enc.csv.Write(opts.Columns); err != nil {
+			return nil, fmt.Errorf("write csv header: %w", err)
+		}
+	case FormatNDJSON:
+		enc.json = json.NewEncoder(w)
+	}
+	return enc, nil
+}
+
+func (e *exportEncoder) encode(event db.AuditEvent) error {
+	var payload interface{}
+	if event.Payload.Valid {
+		// Non-JSON payloads simply yield empty payload.* columns
+		dec := json.NewDecoder(strings.NewReader(event.Payload.String))
+		dec.UseNumber()
+		if err := dec.Decode(&payload); err != nil {
+			payload = nil
+		}
+	}
+
+	if e.csv != nil {
+		record := make([]string, len(e.columns))
+		for i, col := range e.columns {
+			record[i] = columnString(event, payload, col)
+		}
+		if err := e.csv.Write(record); err != nil {
+			return fmt.Errorf("write csv row: %w", err)
+		}
+		return nil
+	}
+
+	row := make(map[string]interface{}, len(e.columns))
+	for _, col := range e.columns {
+		row[col] = columnValue(event, payload, col)
+	}
+	if err := e.json.Encode(row); err != nil {
+		return fmt.Errorf("write ndjson row: %w", err)
+	}
+	return nil
+}
+
+func (e *exportEncoder) flush() error {
+	if e.csv != nil {
+		e.csv.Flush()
+		if err := e.csv.Error(); err != nil {
+			return fmt.Errorf("flush csv: %w", err)
+		}
+	}
+	if e.flusher != nil {
+		e.flusher.Flush()
+	}
+	return nil
+}
+
+// columnValue returns a JSON-friendly value for an export column.
+func columnValue(event db.AuditEvent, payload interface{}, col string) interface{} {
+	switch col {
+	case "id":
+		return event.ID
+	case "timestamp":
+		return event.Timestamp.String()
+	case "subsystem":
+		return event.Subsystem
+	case "event_type":
+		return event.EventType
+	case "user_id":
+		return nullString(event.UserID.String, event.UserID.Valid)
+	case "information":
+		return nullString(event.Information.String, event.Information.Valid)
+	case "ref_id":
+		return nullString(event.RefID.String, event.RefID.Valid)
+	case "ref_id2":
+		return nullString(event.RefId2.String, event.RefId2.Valid)
+	case "payload":
+		if !event.Payload.Valid {
+			return nil
+		}
+		if payload != nil {
+			return payload
+		}
+		return event.Payload.String
+	}
+	return lookupPath(payload, strings.TrimPrefix(col, payloadColumnPrefix))
+}
+
+// columnString renders an export column as a CSV cell.
+func columnString(event db.AuditEvent, payload interface{}, col string) string {
+	switch v := columnValue(event, payload, col).(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		if col == "payload" {
+			return event.Payload.String
+		}
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
+
+// lookupPath walks a dot-separated path through decoded JSON objects.
+func lookupPath(v interface{}, path string) interface{} {
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = obj[key]
+	}
+	return v
+}
+
+func nullString(s string, valid bool) interface{} {
+	if !valid {
+		return nil
+	}
+	return s
+}
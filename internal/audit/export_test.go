@@ -0,0 +1,61 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stripe-go-spike/internal/db"
+)
+
+func TestExportFlattensPayloadColumns(t *testing.T) {
+	queries := newTestQueries(t)
+	svc := NewService(queries, WithStrictTypes())
+	ctx := context.Background()
+	require.NoError(t, queries.ImportAuditEvent(ctx, db.ImportAuditEventParams{
+		ID:        1,
+		Timestamp: db.NewTimestamp(time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)),
+		Subsystem: "payment",
+		EventType: "transaction.created",
+		Payload:   sql.NullString{String: `{"amount":4999,"charge":{"payment_intent":"pi_123"}}`, Valid: true},
+	}))
+	require.NoError(t, queries.SyncAuditEventIDs(ctx))
+	require.NoError(t, svc.Log(ctx, WebhookReceived.New("received", WebhookReceivedPayload{BodyLength: 2})))
+
+	var buf bytes.Buffer
+	n, err := svc.Export(ctx, &buf, ExportOptions{
+		Format:  FormatCSV,
+		Columns: []string{"id", "event_type", "payload.amount", "payload.charge.payment_intent"},
+		Filter:  Filter{Subsystem: "payment"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"id", "event_type", "payload.amount", "payload.charge.payment_intent"},
+		{"1", "transaction.created", "4999", "pi_123"},
+	}, records)
+
+	buf.Reset()
+	n, err = svc.Export(ctx, &buf, ExportOptions{Format: FormatNDJSON})
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	var row map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &row))
+	assert.Equal(t, "webhook.received", row["event_type"])
+
+	_, err = svc.Export(ctx, &buf, ExportOptions{Columns: []string{"nope"}})
+	assert.Error(t, err)
+}
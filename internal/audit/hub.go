@@ -0,0 +1,133 @@
+package audit
+
+import (
+	"sync"
+
+	"stripe-go-spike/internal/db"
+)
+
+// subscriberBuffer is how many events a subscriber may lag behind before it is dropped.
+const subscriberBuffer = 256
+
+// Filter selects audit events by the fields the list, stream and export endpoints
+// accept. Empty fields match everything; non-empty fields must all match, in Match
+// and in the queries built by ListParams alike.
+type Filter struct {
+	Subsystem string
+	EventType string
+	UserID    string
+	RefID     string
+	RefID2    string
+}
+
+// Match reports whether the event satisfies the filter.
+func (f Filter) Match(e db.AuditEvent) bool {
+	if f.Subsystem != "" && e.Subsystem != f.Subsystem {
+		return false
+	}
+	if f.EventType != "" && e.EventType != f.EventType {
+		return false
+	}
+	if f.UserID != "" && (!e.UserID.Valid || e.UserID.String != f.UserID) {
+		return false
+	}
+	if f.RefID != "" && (!e.RefID.Valid || e.RefID.String != f.RefID) {
+		return false
+	}
+	if f.RefID2 != "" && (!e.RefId2.Valid || e.RefId2.String != f.RefID2) {
+		return false
+	}
+	return true
+}
+
+// ListParams returns the parameters of the db.ListAuditEvents page matching the filter.
+func (f Filter) ListParams(limit, offset int64) db.ListAuditEventsParams {
+	return db.ListAuditEventsParams{
+		Subsystem: f.Subsystem,
+		EventType: f.EventType,
+		UserID:    f.UserID,
+		RefID:     f.RefID,
+		RefId2:    f.RefID2,
+		Limit:     limit,
+		Offset:    offset,
+	}
+}
+
+// Subscription receives newly logged events matching its filter on C.
+// C is closed when the subscription is closed or when the subscriber falls too far
+// behind; clients are expected to reconnect and resume from the last event ID.
+type Subscription struct {
+	C <-chan db.AuditEvent
+
+	ch     chan db.AuditEvent
+	filter Filter
+	hub    *Hub
+}
+
+// Close unregisters the subscription. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.hub.remove(s)
+}
+
+// Hub fans out newly logged audit events to subscribers.
+type Hub struct {
+	mu   sync.Mutex
+	subs map[*Subscription]struct{}
+}
+
+// NewHub creates an empty hub.
+func NewHub() *Hub {
+	return &Hub{subs: make(map[*Subscription]struct{})}
+}
+
+// Subscribe registers a subscriber for events matching filter.
+func (h *Hub) Subscribe(filter Filter) *Subscription {
+	ch := make(chan db.AuditEvent, subscriberBuffer)
+	sub := &Subscription{C: ch, ch: ch, filter: filter, hub: h}
+
+	h.mu.Lock()
+	h.subs[sub] = struct{}{}
+	h.mu.Unlock()
+	return sub
+}
+
+// Publish delivers events to matching subscribers without blocking the caller.
+func (h *Hub) Publish(events ...db.AuditEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subs {
+		for _, e := range events {
+			if !sub.filter.Match(e) {
+				continue
+			}
+			if !h.deliver(sub, e) {
+				break
+			}
+		}
+	}
+}
+
+// deliver sends e to sub and reports whether the subscriber is still connected.
+// Callers must hold h.mu.
+func (h *Hub) deliver(sub *Subscription, e db.AuditEvent) bool {
+	select {
+	case sub.ch <- e:
+		return true
+	default:
+		// Slow subscriber: disconnect it so it resumes via Last-Event-ID
+		delete(h.subs, sub)
+		close(sub.ch)
+		return false
+	}
+}
+
+func (h *Hub) remove(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subs[sub]; ok {
+		delete(h.subs, sub)
+		close(sub.ch)
+	}
+}
@@ -0,0 +1,308 @@
+package audit
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"stripe-go-spike/internal/db"
+)
+
+// RetentionRule keeps events matching Pattern for MaxAge. Patterns use path.Match
+// syntax against the event type ("transaction.*") or, when they contain a slash,
+// against "subsystem/event_type" ("stripe/*").
+type RetentionRule struct {
+	Pattern string
+	MaxAge  time.Duration
+}
+
+// Match reports whether the rule applies to the given subsystem and event type.
+func (r RetentionRule) Match(subsystem, eventType string) bool {
+	subject := eventType
+	if strings.Contains(r.Pattern, "/") {
+		subject = subsystem + "/" + eventType
+	}
+	ok, _ := path.Match(r.Pattern, subject)
+	return ok
+}
+
+// RetentionPolicy is an ordered list of rules; the first matching rule wins.
+// Events that match no rule are kept forever.
+type RetentionPolicy struct {
+	Rules []RetentionRule
+}
+
+// ParseRetentionPolicy parses "pattern=age" pairs separated by commas, e.g.
+// "webhook.received=30d,transaction.*=7y,*=365d". Ages accept Go durations plus the
+// units d (days), w (weeks) and y (365 days).
+func ParseRetentionPolicy(s string) (RetentionPolicy, error) {
+	var policy RetentionPolicy
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		pattern, age, ok := strings.Cut(part, "=")
+		if !ok {
+			return RetentionPolicy{}, fmt.Errorf("retention rule %q: expected pattern=age", part)
+		}
+		pattern = strings.TrimSpace(pattern)
+		if _, err := path.Match(pattern, ""); err != nil {
+			return RetentionPolicy{}, fmt.Errorf("retention rule %q: %w", part, err)
+		}
+		maxAge, err := parseAge(strings.TrimSpace(age))
+		if err != nil {
+			return RetentionPolicy{}, fmt.Errorf("retention rule %q: %w", part, err)
+		}
+		policy.Rules = append(policy.Rules, RetentionRule{Pattern: pattern, MaxAge: maxAge})
+	}
+	return policy, nil
+}
+
+func parseAge(s string) (time.Duration, error) {
+	units := map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour, "y": 365 * 24 * time.Hour}
+	for suffix, unit := range units {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			v, err := strconv.Atoi(n)
+			if err != nil || v <= 0 {
+				return 0, fmt.Errorf("invalid age %q", s)
+			}
+			return time.Duration(v) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+	return d, nil
+}
+
+// MaxAge returns the retention for an event and whether any rule applies.
+func (p RetentionPolicy) MaxAge(subsystem, eventType string) (time.Duration, bool) {
+	for _, r := range p.Rules {
+		if r.Match(subsystem, eventType) {
+			return r.MaxAge, true
+		}
+	}
+	return 0, false
+}
+
+// minAge is the shortest retention in the policy; nothing younger can expire.
+func (p RetentionPolicy) minAge() time.Duration {
+	var min time.Duration
+	for i, r := range p.Rules {
+		if i == 0 || r.MaxAge < min {
+			min = r.MaxAge
+		}
+	}
+	return min
+}
+
+// ArchiveRecord is one audit row as stored in archive files. Payloads are kept as
+// the original text so a re-import is lossless; archives written before timestamps
+// were normalized are read in their old format.
+type ArchiveRecord struct {
+	ID          int64        `json:"id"`
+	Timestamp   db.Timestamp `json:"timestamp"`
+	Subsystem   string       `json:"subsystem"`
+	EventType   string       `json:"event_type"`
+	UserID      *string      `json:"user_id,omitempty"`
+	Information *string      `json:"information,omitempty"`
+	Payload     *string      `json:"payload,omitempty"`
+	RefID       *string      `json:"ref_id,omitempty"`
+	RefID2      *string      `json:"ref_id2,omitempty"`
+}
+
+// ArchiveResult summarises one archival run.
+type ArchiveResult struct {
+	File     string `json:"file,omitempty"`
+	Archived int64  `json:"archived"`
+	Deleted  int64  `json:"deleted"`
+}
+
+// Archiver moves expired audit events to gzip-compressed NDJSON files.
+type Archiver struct {
+	Queries   *db.Queries
+	Policy    RetentionPolicy
+	Dir       string
+	BatchSize int
+	Now       func() time.Time // defaults to time.Now
+}
+
+// Run archives and deletes every expired event. Each batch is written as its own
+// gzip member and synced to disk before the rows are deleted, so a crash never
+// loses rows and leaves a readable archive.
+func (a *Archiver) Run(ctx context.Context) (ArchiveResult, error) {
+	var result ArchiveResult
+	if len(a.Policy.Rules) == 0 {
+		return result, nil
+	}
+	batchSize := a.BatchSize
+	if batchSize <= 0 {
+		batchSize = exportPageSize
+	}
+	now := time.Now
+	if a.Now != nil {
+		now = a.Now
+	}
+	runAt := now().UTC()
+
+	// Only rows older than the shortest retention can possibly have expired
+	params := db.ListAuditEventsForExportParams{
+		Timestamp_2: db.NewTimestamp(runAt.Add(-a.Policy.minAge())),
+		Limit:       int64(batchSize),
+	}
+
+	var f *os.File
+	defer func() {
+		if f != nil {
+			f.Close()
+		}
+	}()
+
+	for {
+		events, err := a.Queries.ListAuditEventsForExport(ctx, params)
+		if err != nil {
+			return result, fmt.Errorf("list audit events: %w", err)
+		}
+
+		var expired []db.AuditEvent
+		for _, event := range events {
+			params.ID = event.ID
+			maxAge, ok := a.Policy.MaxAge(event.Subsystem, event.EventType)
+			if !ok {
+				continue
+			}
+			if !event.Timestamp.Before(runAt.Add(-maxAge)) {
+				continue
+			}
+			expired = append(expired, event)
+		}
+
+		if len(expired) > 0 {
+			if f == nil {
+				if err := os.MkdirAll(a.Dir, 0o755); err != nil {
+					return result, fmt.Errorf("create archive dir: %w", err)
+				}
+				result.File = filepath.Join(a.Dir, fmt.Sprintf("audit-archive-%s.ndjson.gz", runAt.Format("20060102T150405Z")))
+				f, err = os.OpenFile(result.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+				if err != nil {
+					return result, fmt.Errorf("open archive: %w", err)
+				}
+			}
+			if err := writeArchiveBatch(f, expired); err != nil {
+				return result, err
+			}
+			result.Archived += int64(len(expired))
+
+			ids := make([]int64, len(expired))
+			for i, event := range expired {
+				ids[i] = event.ID
+			}
+			deleted, err := a.Queries.DeleteAuditEvents(ctx, ids)
+			if err != nil {
+				return result, fmt.Errorf("delete archived events: %w", err)
+			}
+			result.Deleted += deleted
+		}
+
+		if len(events) < batchSize {
+			return result, nil
+		}
+	}
+}
+
+func writeArchiveBatch(f *os.File, events []db.AuditEvent) error {
+	gz := gzip.NewWriter(f)
+	enc := json.NewEncoder(gz)
+	for _, event := range events {
+		if err := enc.Encode(toArchiveRecord(event)); err != nil {
+			return fmt.Errorf("write archive: %w", err)
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("write archive: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("sync archive: %w", err)
+	}
+	return nil
+}
+
+func toArchiveRecord(e db.AuditEvent) ArchiveRecord {
+	opt := func(s sql.NullString) *string {
+		if s.Valid {
+			return &s.String
+		}
+		return nil
+	}
+	return ArchiveRecord{
+		ID:          e.ID,
+		Timestamp:   e.Timestamp,
+		Subsystem:   e.Subsystem,
+		EventType:   e.EventType,
+		UserID:      opt(e.UserID),
+		Information: opt(e.Information),
+		Payload:     opt(e.Payload),
+		RefID:       opt(e.RefID),
+		RefID2:      opt(e.RefId2),
+	}
+}
+
+// ImportArchive re-inserts the events from an archive file, keeping their original
+// ids and timestamps. Rows that already exist are skipped, so imports are idempotent.
+// It returns the number of records read.
+func ImportArchive(ctx context.Context, queries *db.Queries, r io.Reader) (int64, error) {
+	gz, err := gzip.NewReader(bufio.NewReader(r))
+	if err != nil {
+		return 0, fmt.Errorf("open archive: %w", err)
+	}
+	defer gz.Close()
+
+	null := func(s *string) sql.NullString {
+		if s == nil {
+			return sql.NullString{}
+		}
+		return sql.NullString{String: *s, Valid: true}
+	}
+
+	var n int64
+	dec := json.NewDecoder(gz)
+	for {
+		var rec ArchiveRecord
+		if err := dec.Decode(&rec); err != nil {
+			if errors.Is(err, io.EOF) {
+				if err := queries.SyncAuditEventIDs(ctx); err != nil {
+					return n, fmt.Errorf("sync audit event ids: %w", err)
+				}
+				return n, nil
+			}
+			return n, fmt.Errorf("read archive record %d: %w", n+1, err)
+		}
+		if err := queries.ImportAuditEvent(ctx, db.ImportAuditEventParams{
+			ID:          rec.ID,
+			Timestamp:   rec.Timestamp,
+			Subsystem:   rec.Subsystem,
+			EventType:   rec.EventType,
+			UserID:      null(rec.UserID),
+			Information: null(rec.Information),
+			Payload:     null(rec.Payload),
+			RefID:       null(rec.RefID),
+			RefId2:      null(rec.RefID2),
+		}); err != nil {
+			return n, fmt.Errorf("import event %d: %w", rec.ID, err)
+		}
+		n++
+	}
+}
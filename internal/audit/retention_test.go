@@ -0,0 +1,79 @@
+package audit
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stripe-go-spike/internal/db"
+)
+
+func TestParseRetentionPolicy(t *testing.T) {
+	policy, err := ParseRetentionPolicy("webhook.received=30d, stripe/*=1w, transaction.*=7y")
+	require.NoError(t, err)
+
+	age, ok := policy.MaxAge("stripe", "webhook.received")
+	assert.True(t, ok)
+	assert.Equal(t, 30*24*time.Hour, age)
+	age, _ = policy.MaxAge("stripe", "checkout_session.completed")
+	assert.Equal(t, 7*24*time.Hour, age)
+	age, _ = policy.MaxAge("payment", "transaction.refunded")
+	assert.Equal(t, 7*365*24*time.Hour, age)
+	_, ok = policy.MaxAge("system", "audit.archived")
+	assert.False(t, ok)
+
+	_, err = ParseRetentionPolicy("webhook.received")
+	assert.Error(t, err)
+	_, err = ParseRetentionPolicy("webhook.received=soon")
+	assert.Error(t, err)
+}
+
+func TestArchiverArchivesExpiredAndReimports(t *testing.T) {
+	queries := newTestQueries(t)
+	ctx := context.Background()
+	insert := func(id int64, ts, subsystem, eventType string) {
+		timestamp, err := db.ParseTimestamp(ts)
+		require.NoError(t, err)
+		require.NoError(t, queries.ImportAuditEvent(ctx, db.ImportAuditEventParams{
+			ID: id, Timestamp: timestamp, Subsystem: subsystem, EventType: eventType,
+		}))
+	}
+	insert(1, "2025-01-01 10:00:00", "stripe", "webhook.received")
+	insert(2, "2025-03-01 10:00:00", "stripe", "webhook.received")
+	insert(3, "2025-01-01 10:00:00", "payment", "transaction.completed")
+	insert(4, "2025-01-01 10:00:00", "system", "unmatched")
+
+	policy, err := ParseRetentionPolicy("webhook.received=30d,transaction.*=7y")
+	require.NoError(t, err)
+	archiver := &Archiver{
+		Queries:   queries,
+		Policy:    policy,
+		Dir:       t.TempDir(),
+		BatchSize: 1,
+		Now:       func() time.Time { return time.Date(2025, 3, 15, 0, 0, 0, 0, time.UTC) },
+	}
+	result, err := archiver.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), result.Archived)
+	assert.Equal(t, int64(1), result.Deleted)
+
+	remaining, err := queries.GetAllAuditEvents(ctx, db.GetAllAuditEventsParams{Limit: 10})
+	require.NoError(t, err)
+	assert.Len(t, remaining, 3)
+
+	f, err := os.Open(result.File)
+	require.NoError(t, err)
+	defer f.Close()
+	n, err := ImportArchive(ctx, queries, f)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	restored, err := queries.GetAuditEventsAfterID(ctx, db.GetAuditEventsAfterIDParams{ID: 0, Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, restored, 1)
+	assert.Equal(t, "2025-01-01T10:00:00.000Z", restored[0].Timestamp.String())
+}
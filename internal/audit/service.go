@@ -5,22 +5,77 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"log"
 	"stripe-go-spike/internal/db"
+	"sync/atomic"
 )
 
 // Service handles audit event logging
 type Service struct {
 	queries *db.Queries
+	writer  *Writer // nil when events are written synchronously
+	hub     *Hub
+	strict  bool
+	failed  atomic.Uint64
 }
 
-// NewService creates a new audit service
-func NewService(queries *db.Queries) *Service {
-	return &Service{
+// Option configures a Service
+type Option func(*Service)
+
+// WithStrictTypes rejects events that are not in the catalog or whose payload
+// type does not match their definition. Tests enable it; production only warns.
+func WithStrictTypes() Option {
+	return func(s *Service) { s.strict = true }
+}
+
+// NewService creates a new audit service that writes each event synchronously
+func NewService(queries *db.Queries, opts ...Option) *Service {
+	s := &Service{
 		queries: queries,
+		hub:     NewHub(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// NewAsyncService creates an audit service that buffers events and writes them in batches
+func NewAsyncService(queries *db.Queries, cfg WriterConfig, opts ...Option) (*Service, error) {
+	s := NewService(queries, opts...)
+	writer, err := NewWriter(queries, cfg, s.hub)
+	if err != nil {
+		return nil, err
+	}
+	s.writer = writer
+	return s, nil
+}
+
+// Subscribe streams newly logged events matching filter until the subscription is closed
+func (s *Service) Subscribe(filter Filter) *Subscription {
+	return s.hub.Subscribe(filter)
+}
+
+// Close flushes buffered events. It is a no-op for synchronous services.
+func (s *Service) Close(ctx context.Context) error {
+	if s.writer == nil {
+		return nil
 	}
+	return s.writer.Close(ctx)
 }
 
-// Event represents an audit event
+// Stats returns the audit write counters
+func (s *Service) Stats() Stats {
+	var stats Stats
+	if s.writer != nil {
+		stats = s.writer.Stats()
+	}
+	stats.Failed += s.failed.Load()
+	return stats
+}
+
+// Event represents an audit event. Build events from the catalog definitions
+// (e.g. TransactionCompleted.New) rather than filling the fields by hand.
 type Event struct {
 	Subsystem   string
 	EventType   string
@@ -31,14 +86,71 @@ type Event struct {
 	RefID2      *string // Secondary reference ID (e.g., session_id)
 }
 
-// Log records an audit event
+// Log records an audit event. Failures are logged and counted in Stats so callers
+// that ignore the returned error still leave a trace.
 func (s *Service) Log(ctx context.Context, event Event) error {
+	err := s.log(ctx, event)
+	if err != nil {
+		s.failed.Add(1)
+		log.Printf("audit: failed to log %s/%s: %v", event.Subsystem, event.EventType, err)
+	}
+	return err
+}
+
+func (s *Service) log(ctx context.Context, event Event) error {
+	if err := event.validate(); err != nil {
+		if s.strict {
+			return err
+		}
+		log.Printf("audit: %v", err)
+	}
+	params, err := event.params()
+	if err != nil {
+		return err
+	}
+	if s.writer != nil {
+		return s.writer.Enqueue(ctx, params)
+	}
+	stored, err := s.queries.CreateAuditEvent(ctx, params)
+	if err != nil {
+		return err
+	}
+	s.hub.Publish(stored)
+	return nil
+}
+
+// LogTx writes an audit event through q, typically the queries of a db.Store unit
+// of work, so the event commits or rolls back with the change it describes. It
+// bypasses the async writer and returns the stored row instead of publishing it:
+// call Publish once the transaction has committed. Errors are returned (and should
+// abort the transaction) rather than counted.
+func (s *Service) LogTx(ctx context.Context, q db.Querier, event Event) (db.AuditEvent, error) {
+	if err := event.validate(); err != nil {
+		if s.strict {
+			return db.AuditEvent{}, err
+		}
+		log.Printf("audit: %v", err)
+	}
+	params, err := event.params()
+	if err != nil {
+		return db.AuditEvent{}, err
+	}
+	return q.CreateAuditEvent(ctx, params)
+}
+
+// Publish streams events written with LogTx to subscribers
+func (s *Service) Publish(events ...db.AuditEvent) {
+	s.hub.Publish(events...)
+}
+
+// params converts the event into the insert parameters
+func (event Event) params() (db.CreateAuditEventParams, error) {
 	var payloadJSON sql.NullString
 
 	if event.Payload != nil {
 		payloadBytes, err := json.Marshal(event.Payload)
 		if err != nil {
-			return fmt.Errorf("failed to marshal payload: %w", err)
+			return db.CreateAuditEventParams{}, fmt.Errorf("failed to marshal payload: %w", err)
 		}
 		payloadJSON = sql.NullString{String: string(payloadBytes), Valid: true}
 	}
@@ -58,7 +170,7 @@ func (s *Service) Log(ctx context.Context, event Event) error {
 		refID2 = sql.NullString{String: *event.RefID2, Valid: true}
 	}
 
-	return s.queries.CreateAuditEvent(ctx, db.CreateAuditEventParams{
+	return db.CreateAuditEventParams{
 		Subsystem:   event.Subsystem,
 		EventType:   event.EventType,
 		UserID:      userID,
@@ -66,63 +178,5 @@ func (s *Service) Log(ctx context.Context, event Event) error {
 		Payload:     payloadJSON,
 		RefID:       refID,
 		RefId2:      refID2,
-	})
-}
-
-// LogStripe logs a Stripe-related event
-func (s *Service) LogStripe(ctx context.Context, eventType, information string, userID *string, payload interface{}) error {
-	return s.Log(ctx, Event{
-		Subsystem:   "stripe",
-		EventType:   eventType,
-		UserID:      userID,
-		Information: information,
-		Payload:     payload,
-	})
-}
-
-// LogStripeWithRefs logs a Stripe-related event with reference IDs
-func (s *Service) LogStripeWithRefs(ctx context.Context, eventType, information string, userID *string, payload interface{}, refID, refID2 *string) error {
-	return s.Log(ctx, Event{
-		Subsystem:   "stripe",
-		EventType:   eventType,
-		UserID:      userID,
-		Information: information,
-		Payload:     payload,
-		RefID:       refID,
-		RefID2:      refID2,
-	})
-}
-
-// LogPayment logs a payment-related event
-func (s *Service) LogPayment(ctx context.Context, eventType, information string, userID *string, payload interface{}) error {
-	return s.Log(ctx, Event{
-		Subsystem:   "payment",
-		EventType:   eventType,
-		UserID:      userID,
-		Information: information,
-		Payload:     payload,
-	})
-}
-
-// LogPaymentWithRefs logs a payment-related event with reference IDs
-func (s *Service) LogPaymentWithRefs(ctx context.Context, eventType, information string, userID *string, payload interface{}, refID, refID2 *string) error {
-	return s.Log(ctx, Event{
-		Subsystem:   "payment",
-		EventType:   eventType,
-		UserID:      userID,
-		Information: information,
-		Payload:     payload,
-		RefID:       refID,
-		RefID2:      refID2,
-	})
-}
-
-// LogSystem logs a system-related event
-func (s *Service) LogSystem(ctx context.Context, eventType, information string, payload interface{}) error {
-	return s.Log(ctx, Event{
-		Subsystem:   "system",
-		EventType:   eventType,
-		Information: information,
-		Payload:     payload,
-	})
+	}, nil
 }
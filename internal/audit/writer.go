@@ -0,0 +1,384 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"stripe-go-spike/internal/db"
+)
+
+// OverflowPolicy decides what the Writer does when its buffer is full.
+type OverflowPolicy string
+
+const (
+	// OverflowBlock makes callers wait until there is room in the buffer, their
+	// context is done or the writer is closed.
+	OverflowBlock OverflowPolicy = "block"
+	// OverflowDrop discards the event and increments the dropped counter.
+	OverflowDrop OverflowPolicy = "drop"
+	// OverflowSpill appends the event as NDJSON to WriterConfig.SpillPath.
+	OverflowSpill OverflowPolicy = "spill"
+)
+
+// ErrWriterClosed is returned when events are enqueued after Close.
+var ErrWriterClosed = errors.New("audit writer closed")
+
+// WriterConfig holds the tuning knobs of the asynchronous audit writer.
+type WriterConfig struct {
+	BufferSize    int
+	BatchSize     int
+	FlushInterval time.Duration
+	Overflow      OverflowPolicy
+	SpillPath     string
+}
+
+// DefaultWriterConfig returns sensible defaults for a single server instance.
+func DefaultWriterConfig() WriterConfig {
+	return WriterConfig{
+		BufferSize:    1024,
+		BatchSize:     100,
+		FlushInterval: time.Second,
+		Overflow:      OverflowBlock,
+		SpillPath:     "_data/audit-spill.ndjson",
+	}
+}
+
+// Validate checks the configuration and fills zero values with defaults.
+func (c *WriterConfig) Validate() error {
+	def := DefaultWriterConfig()
+	if c.BufferSize <= 0 {
+		c.BufferSize = def.BufferSize
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = def.BatchSize
+	}
+	if c.BatchSize > db.MaxAuditEventsBatch {
+		c.BatchSize = db.MaxAuditEventsBatch
+	}
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = def.FlushInterval
+	}
+	switch c.Overflow {
+	case "":
+		c.Overflow = def.Overflow
+	case OverflowBlock, OverflowDrop:
+	case OverflowSpill:
+		if c.SpillPath == "" {
+			c.SpillPath = def.SpillPath
+		}
+	default:
+		return fmt.Errorf("unknown audit overflow policy %q", c.Overflow)
+	}
+	return nil
+}
+
+// Stats exposes the writer counters for monitoring.
+type Stats struct {
+	Enqueued   uint64 `json:"enqueued"`
+	Written    uint64 `json:"written"`
+	Dropped    uint64 `json:"dropped"`
+	Spilled    uint64 `json:"spilled"`
+	Failed     uint64 `json:"failed"`
+	Batches    uint64 `json:"batches"`
+	QueueDepth int    `json:"queue_depth"`
+	QueueSize  int    `json:"queue_size"`
+}
+
+// Writer buffers audit events in a bounded channel and persists them in batches
+// from a single background goroutine. Events left in the spill file by an earlier
+// run are written first.
+type Writer struct {
+	queries *db.Queries
+	cfg     WriterConfig
+	hub     *Hub // receives each written batch; may be nil
+	events  chan db.CreateAuditEventParams
+	quit    chan struct{} // closed by Close to wake blocked senders
+	done    chan struct{}
+
+	mu      sync.RWMutex // guards closed against concurrent sends
+	closed  bool
+	waiting sync.WaitGroup // senders blocked on a full buffer
+
+	spillMu sync.Mutex
+
+	enqueued atomic.Uint64
+	written  atomic.Uint64
+	dropped  atomic.Uint64
+	spilled  atomic.Uint64
+	failed   atomic.Uint64
+	batches  atomic.Uint64
+}
+
+// NewWriter creates a writer and starts its flush loop. Written events are published
+// to hub when it is not nil.
+func NewWriter(queries *db.Queries, cfg WriterConfig, hub *Hub) (*Writer, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	w := &Writer{
+		queries: queries,
+		cfg:     cfg,
+		hub:     hub,
+		events:  make(chan db.CreateAuditEventParams, cfg.BufferSize),
+		quit:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go w.run()
+	return w, nil
+}
+
+// Enqueue hands an event to the writer, applying the overflow policy when the
+// buffer is full. With OverflowBlock it waits for room and returns ctx.Err() if
+// ctx is done first, or ErrWriterClosed if the writer is closed meanwhile.
+func (w *Writer) Enqueue(ctx context.Context, p db.CreateAuditEventParams) error {
+	w.mu.RLock()
+	if w.closed {
+		w.mu.RUnlock()
+		return ErrWriterClosed
+	}
+
+	select {
+	case w.events <- p:
+		w.mu.RUnlock()
+		w.enqueued.Add(1)
+		return nil
+	default:
+	}
+
+	switch w.cfg.Overflow {
+	case OverflowDrop:
+		w.mu.RUnlock()
+		w.dropped.Add(1)
+		return nil
+	case OverflowSpill:
+		w.mu.RUnlock()
+		return w.spill([]db.CreateAuditEventParams{p})
+	}
+
+	// Wait without the lock so Close is not held up; Close keeps the channel
+	// open until every waiting sender has returned
+	w.waiting.Add(1)
+	w.mu.RUnlock()
+	defer w.waiting.Done()
+	select {
+	case w.events <- p:
+		w.enqueued.Add(1)
+		return nil
+	case <-w.quit:
+		return ErrWriterClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting events and flushes what is buffered. It returns ctx.Err()
+// if the flush does not finish before the context is done.
+func (w *Writer) Close(ctx context.Context) error {
+	w.mu.Lock()
+	first := !w.closed
+	w.closed = true
+	w.mu.Unlock()
+
+	if first {
+		close(w.quit)
+		w.waiting.Wait()
+		close(w.events)
+	}
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stats returns a snapshot of the writer counters.
+func (w *Writer) Stats() Stats {
+	return Stats{
+		Enqueued:   w.enqueued.Load(),
+		Written:    w.written.Load(),
+		Dropped:    w.dropped.Load(),
+		Spilled:    w.spilled.Load(),
+		Failed:     w.failed.Load(),
+		Batches:    w.batches.Load(),
+		QueueDepth: len(w.events),
+		QueueSize:  cap(w.events),
+	}
+}
+
+func (w *Writer) run() {
+	defer close(w.done)
+
+	if err := w.replaySpill(); err != nil {
+		log.Printf("audit: failed to replay spill file: %v", err)
+	}
+
+	ticker := time.NewTicker(w.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]db.CreateAuditEventParams, 0, w.cfg.BatchSize)
+	for {
+		select {
+		case p, ok := <-w.events:
+			if !ok {
+				w.flush(batch)
+				return
+			}
+			batch = append(batch, p)
+			if len(batch) >= w.cfg.BatchSize {
+				w.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				w.flush(batch)
+				batch = batch[:0]
+			}
+		}
+	}
+}
+
+func (w *Writer) flush(batch []db.CreateAuditEventParams) {
+	if len(batch) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	written, err := w.queries.CreateAuditEvents(ctx, batch)
+	if err != nil {
+		log.Printf("audit: failed to write batch of %d events: %v", len(batch), err)
+		// Keep the events on disk when spilling is configured rather than losing them
+		if w.cfg.Overflow == OverflowSpill {
+			if spillErr := w.spill(batch); spillErr == nil {
+				return
+			}
+		}
+		w.failed.Add(uint64(len(batch)))
+		return
+	}
+	w.written.Add(uint64(len(batch)))
+	w.batches.Add(1)
+	if w.hub != nil {
+		w.hub.Publish(written...)
+	}
+}
+
+// spill appends events to the spill file as NDJSON so they can be replayed later.
+func (w *Writer) spill(events []db.CreateAuditEventParams) error {
+	w.spillMu.Lock()
+	defer w.spillMu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(w.cfg.SpillPath), 0o755); err != nil {
+		w.failed.Add(uint64(len(events)))
+		return fmt.Errorf("create spill dir: %w", err)
+	}
+	f, err := os.OpenFile(w.cfg.SpillPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		w.failed.Add(uint64(len(events)))
+		return fmt.Errorf("open spill file: %w", err)
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	for i, e := range events {
+		if err := enc.Encode(e); err != nil {
+			w.failed.Add(uint64(len(events) - i))
+			return fmt.Errorf("write spill file: %w", err)
+		}
+		w.spilled.Add(1)
+	}
+	return nil
+}
+
+// replaySpill writes the events of the spill file left by an earlier run. The file
+// is first renamed so events spilled meanwhile go to a new one; if writing fails,
+// the events not yet written stay in the renamed file for the next start.
+func (w *Writer) replaySpill() error {
+	if w.cfg.SpillPath == "" {
+		return nil
+	}
+	replayPath := w.cfg.SpillPath + ".replay"
+	if _, err := os.Stat(replayPath); errors.Is(err, os.ErrNotExist) {
+		if err := os.Rename(w.cfg.SpillPath, replayPath); errors.Is(err, os.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	events, err := readSpill(replayPath)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	for len(events) > 0 {
+		batch := events[:min(len(events), w.cfg.BatchSize)]
+		written, err := w.queries.CreateAuditEvents(ctx, batch)
+		if err != nil {
+			if rewriteErr := writeSpill(replayPath, events); rewriteErr != nil {
+				return errors.Join(err, rewriteErr)
+			}
+			return fmt.Errorf("%d events left in %s: %w", len(events), replayPath, err)
+		}
+		w.written.Add(uint64(len(batch)))
+		w.batches.Add(1)
+		if w.hub != nil {
+			w.hub.Publish(written...)
+		}
+		events = events[len(batch):]
+	}
+	log.Printf("audit: replayed spill file %s", w.cfg.SpillPath)
+	return os.Remove(replayPath)
+}
+
+// readSpill reads an NDJSON spill file, skipping lines that do not decode.
+func readSpill(path string) ([]db.CreateAuditEventParams, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var events []db.CreateAuditEventParams
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		var e db.CreateAuditEventParams
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			log.Printf("audit: skipping line %d of %s: %v", line, path, err)
+			continue
+		}
+		events = append(events, e)
+	}
+	return events, sc.Err()
+}
+
+// writeSpill replaces the file at path with events as NDJSON.
+func writeSpill(path string, events []db.CreateAuditEventParams) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, e := range events {
+		if err := enc.Encode(e); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
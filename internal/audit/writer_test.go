@@ -0,0 +1,130 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stripe-go-spike/internal/db"
+)
+
+func newTestQueries(t *testing.T) *db.Queries {
+	t.Helper()
+	database, err := db.NewTestConnection()
+	require.NoError(t, err)
+	t.Cleanup(func() { database.Close() })
+	return db.New(database)
+}
+
+func TestAsyncServiceFlushesOnClose(t *testing.T) {
+	queries := newTestQueries(t)
+	svc, err := NewAsyncService(queries, WriterConfig{BatchSize: 3, FlushInterval: time.Hour}, WithStrictTypes())
+	require.NoError(t, err)
+
+	for i := 0; i < 7; i++ {
+		require.NoError(t, svc.Log(context.Background(), AuditArchived.New("async", ArchivePayload{Archived: int64(i)})))
+	}
+	require.NoError(t, svc.Close(context.Background()))
+
+	events, err := queries.GetAllAuditEvents(context.Background(), db.GetAllAuditEventsParams{Limit: 100})
+	require.NoError(t, err)
+	assert.Len(t, events, 7)
+
+	stats := svc.Stats()
+	assert.Equal(t, uint64(7), stats.Written)
+	assert.Equal(t, uint64(3), stats.Batches)
+	assert.ErrorIs(t, svc.Log(context.Background(), AuditArchived.New("late", ArchivePayload{})), ErrWriterClosed)
+}
+
+func TestWriterOverflowPolicies(t *testing.T) {
+	// Writers are built without their flush loop so the buffer stays full
+	newStalled := func(cfg WriterConfig) *Writer {
+		require.NoError(t, cfg.Validate())
+		done := make(chan struct{})
+		close(done)
+		return &Writer{
+			cfg:    cfg,
+			events: make(chan db.CreateAuditEventParams, cfg.BufferSize),
+			quit:   make(chan struct{}),
+			done:   done,
+		}
+	}
+	event := db.CreateAuditEventParams{Subsystem: "system", EventType: "test.event"}
+	ctx := context.Background()
+
+	t.Run("drop", func(t *testing.T) {
+		w := newStalled(WriterConfig{BufferSize: 1, Overflow: OverflowDrop})
+		require.NoError(t, w.Enqueue(ctx, event))
+		require.NoError(t, w.Enqueue(ctx, event))
+		require.NoError(t, w.Enqueue(ctx, event))
+		assert.Equal(t, uint64(1), w.Stats().Enqueued)
+		assert.Equal(t, uint64(2), w.Stats().Dropped)
+	})
+
+	t.Run("spill", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "spill.ndjson")
+		w := newStalled(WriterConfig{BufferSize: 1, Overflow: OverflowSpill, SpillPath: path})
+		require.NoError(t, w.Enqueue(ctx, event))
+		require.NoError(t, w.Enqueue(ctx, event))
+		require.NoError(t, w.Enqueue(ctx, event))
+		assert.Equal(t, uint64(2), w.Stats().Spilled)
+
+		f, err := os.Open(path)
+		require.NoError(t, err)
+		defer f.Close()
+		lines := 0
+		for sc := bufio.NewScanner(f); sc.Scan(); {
+			lines++
+		}
+		assert.Equal(t, 2, lines)
+	})
+
+	t.Run("block", func(t *testing.T) {
+		w := newStalled(WriterConfig{BufferSize: 1, Overflow: OverflowBlock})
+		require.NoError(t, w.Enqueue(ctx, event))
+
+		timeout, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, w.Enqueue(timeout, event), context.DeadlineExceeded)
+
+		// A sender waiting for room is released by Close rather than holding it up
+		errs := make(chan error, 1)
+		go func() { errs <- w.Enqueue(ctx, event) }()
+		require.NoError(t, w.Close(ctx))
+		assert.ErrorIs(t, <-errs, ErrWriterClosed)
+		assert.Equal(t, uint64(1), w.Stats().Enqueued)
+	})
+}
+
+func TestWriterReplaysSpillFile(t *testing.T) {
+	queries := newTestQueries(t)
+	path := filepath.Join(t.TempDir(), "spill.ndjson")
+	spilled := make([]db.CreateAuditEventParams, 5)
+	for i := range spilled {
+		spilled[i] = db.CreateAuditEventParams{Subsystem: "system", EventType: "test.spilled"}
+	}
+	require.NoError(t, writeSpill(path, spilled))
+
+	w, err := NewWriter(queries, WriterConfig{BatchSize: 2, Overflow: OverflowSpill, SpillPath: path}, nil)
+	require.NoError(t, err)
+	require.NoError(t, w.Close(context.Background()))
+
+	events, err := queries.GetAllAuditEvents(context.Background(), db.GetAllAuditEventsParams{Limit: 100})
+	require.NoError(t, err)
+	assert.Len(t, events, 5)
+	assert.Equal(t, uint64(5), w.Stats().Written)
+	assert.NoFileExists(t, path)
+	assert.NoFileExists(t, path+".replay")
+}
+
+func TestWriterConfigClampsBatchSize(t *testing.T) {
+	cfg := WriterConfig{BatchSize: 10000}
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, db.MaxAuditEventsBatch, cfg.BatchSize)
+}
@@ -0,0 +1,117 @@
+// Package connect handles the marketplace side of checkout: products sold by
+// partner vendors through Stripe Connect connected accounts, and the split of
+// each charge between the platform and the vendor.
+package connect
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+
+	"stripe-go-spike/internal/db"
+	"stripe-go-spike/internal/money"
+)
+
+var (
+	// ErrInvalidAccount is returned for connected accounts that can't be saved.
+	ErrInvalidAccount = errors.New("invalid connected account")
+	// ErrNotReady is returned when checking out a product whose vendor can't
+	// accept charges yet, e.g. before onboarding is complete.
+	ErrNotReady = errors.New("vendor can't accept payments yet")
+)
+
+// MaxFeeBps is the highest platform fee accepted, 100%.
+const MaxFeeBps = 10000
+
+var countryPattern = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// AccountSpec describes a vendor's connected account.
+type AccountSpec struct {
+	Name    string
+	Email   string // optional; prefilled in onboarding
+	Country string
+	FeeBps  int64 // platform's share of each charge before tax, in basis points
+}
+
+// Normalize returns the spec with surrounding space trimmed and the country in
+// upper case.
+func (s AccountSpec) Normalize() AccountSpec {
+	s.Name = strings.TrimSpace(s.Name)
+	s.Email = strings.TrimSpace(s.Email)
+	s.Country = strings.ToUpper(strings.TrimSpace(s.Country))
+	return s
+}
+
+// Validate checks a normalized spec.
+func (s AccountSpec) Validate() error {
+	switch {
+	case s.Name == "" || len(s.Name) > 100:
+		return fmt.Errorf("%w: name must be 1-100 characters", ErrInvalidAccount)
+	case !countryPattern.MatchString(s.Country):
+		return fmt.Errorf("%w: country must be an ISO 3166-1 alpha-2 code", ErrInvalidAccount)
+	}
+	if s.Email != "" {
+		if _, err := mail.ParseAddress(s.Email); err != nil {
+			return fmt.Errorf("%w: email is not an address", ErrInvalidAccount)
+		}
+	}
+	return ValidateFee(s.FeeBps)
+}
+
+// ValidateFee checks a platform fee.
+func ValidateFee(bps int64) error {
+	if bps < 0 || bps > MaxFeeBps {
+		return fmt.Errorf("%w: fee must be between 0 and %d basis points", ErrInvalidAccount, MaxFeeBps)
+	}
+	return nil
+}
+
+// Split divides a charge between the platform and the vendor: Fee plus
+// Transfer is the amount charged.
+type Split struct {
+	// Fee is what the platform keeps: its share plus any tax, which the platform
+	// owes as the seller of record
+	Fee      money.Amount
+	Transfer money.Amount
+}
+
+// ComputeSplit splits total, of which tax is tax, for a vendor taking feeBps.
+// The platform's share is rounded half up to the minor unit.
+func ComputeSplit(total, tax money.Amount, feeBps int64) (Split, error) {
+	net := total
+	if tax.IsPositive() {
+		var err error
+		if net, err = total.Sub(tax); err != nil {
+			return Split{}, err
+		}
+	}
+	if net.Minor < 0 {
+		return Split{}, fmt.Errorf("tax %s is more than the total %s", tax, total)
+	}
+	fee := money.Amount{Minor: (net.Minor*feeBps+5000)/10000 + tax.Minor, Currency: total.Currency}
+	transfer, err := total.Sub(fee)
+	if err != nil {
+		return Split{}, err
+	}
+	return Split{Fee: fee, Transfer: transfer}, nil
+}
+
+// Lookup returns the connected account selling a product, if it isn't one of
+// the platform's own. A vendor that can't accept charges is an ErrNotReady.
+func Lookup(ctx context.Context, q db.Querier, productID string) (db.ConnectedAccount, bool, error) {
+	account, err := q.GetProductConnectedAccount(ctx, productID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return db.ConnectedAccount{}, false, nil
+	}
+	if err != nil {
+		return db.ConnectedAccount{}, false, err
+	}
+	if !account.ChargesEnabled {
+		return account, true, fmt.Errorf("%w: %s hasn't finished onboarding", ErrNotReady, account.Name)
+	}
+	return account, true, nil
+}
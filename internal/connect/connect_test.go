@@ -0,0 +1,88 @@
+package connect
+
+import (
+	"context"
+	"testing"
+
+	"stripe-go-spike/internal/db"
+	"stripe-go-spike/internal/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputeSplit(t *testing.T) {
+	// 12.5% of 4999 is 624.875
+	split, err := ComputeSplit(money.MustNew(4999, "USD"), money.Amount{Currency: "USD"}, 1250)
+	require.NoError(t, err)
+	assert.Equal(t, Split{Fee: money.MustNew(625, "USD"), Transfer: money.MustNew(4374, "USD")}, split)
+
+	// The platform keeps the tax on top of its share
+	split, err = ComputeSplit(money.MustNew(5949, "EUR"), money.MustNew(950, "EUR"), 1000)
+	require.NoError(t, err)
+	assert.Equal(t, Split{Fee: money.MustNew(1450, "EUR"), Transfer: money.MustNew(4499, "EUR")}, split)
+
+	split, err = ComputeSplit(money.MustNew(7500, "JPY"), money.Amount{Currency: "JPY"}, 0)
+	require.NoError(t, err)
+	assert.True(t, split.Fee.IsZero())
+	assert.Equal(t, money.MustNew(7500, "JPY"), split.Transfer)
+
+	_, err = ComputeSplit(money.MustNew(100, "USD"), money.MustNew(200, "USD"), 1000)
+	assert.Error(t, err)
+}
+
+func TestAccountSpecValidate(t *testing.T) {
+	valid := AccountSpec{Name: " Weavers Co ", Email: "ops@weavers.example", Country: "de", FeeBps: 1000}.Normalize()
+	assert.Equal(t, "DE", valid.Country)
+	assert.Equal(t, "Weavers Co", valid.Name)
+	assert.NoError(t, valid.Validate())
+
+	for name, spec := range map[string]AccountSpec{
+		"name":    {Country: "DE"},
+		"country": {Name: "Weavers", Country: "DEU"},
+		"email":   {Name: "Weavers", Country: "DE", Email: "ops"},
+		"fee":     {Name: "Weavers", Country: "DE", FeeBps: 10001},
+	} {
+		assert.ErrorIs(t, spec.Validate(), ErrInvalidAccount, name)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	database, err := db.NewTestConnection()
+	require.NoError(t, err)
+	defer database.Close()
+	q := db.New(database)
+	ctx := context.Background()
+
+	_, ok, err := Lookup(ctx, q, "lumaweave")
+	require.NoError(t, err)
+	assert.False(t, ok, "platform product")
+
+	account, err := q.CreateConnectedAccount(ctx, db.CreateConnectedAccountParams{
+		StripeAccountID: "acct_1",
+		Name:            "Weavers",
+		Country:         "DE",
+		FeeBps:          1000,
+		CreatedAt:       db.Now(),
+		UpdatedAt:       db.Now(),
+	})
+	require.NoError(t, err)
+	require.NoError(t, q.SetProductAccount(ctx, db.SetProductAccountParams{ProductID: "lumaweave", ConnectedAccountID: account.ID}))
+	_, ok, err = Lookup(ctx, q, "lumaweave")
+	assert.True(t, ok)
+	assert.ErrorIs(t, err, ErrNotReady)
+
+	n, err := q.UpdateConnectedAccountStatus(ctx, db.UpdateConnectedAccountStatusParams{
+		ChargesEnabled:  true,
+		UpdatedAt:       db.Now(),
+		StripeAccountID: "acct_1",
+	})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, n)
+	got, ok, err := Lookup(ctx, q, "lumaweave")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, account.ID, got.ID)
+	assert.True(t, got.ChargesEnabled)
+	assert.False(t, got.PayoutsEnabled)
+}
@@ -0,0 +1,197 @@
+// Package coupons validates coupons and works out what they take off a price.
+// Coupons are stored locally and mirrored to Stripe as a coupon with one
+// promotion code; redemption limits, expiry and product restrictions are checked
+// here before a checkout session is created.
+package coupons
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"stripe-go-spike/internal/db"
+	"stripe-go-spike/internal/money"
+)
+
+var (
+	// ErrInvalid is returned for coupon definitions that can't be saved.
+	ErrInvalid = errors.New("invalid coupon")
+	// ErrNotFound is returned for codes that don't name a coupon.
+	ErrNotFound = errors.New("coupon not found")
+	// ErrNotRedeemable is returned when a coupon exists but can't be used for the
+	// purchase: it is archived or expired, restricted to other products, in another
+	// currency or used up.
+	ErrNotRedeemable = errors.New("coupon cannot be redeemed")
+)
+
+// codePattern matches the codes Stripe accepts for promotion codes.
+var codePattern = regexp.MustCompile(`^[A-Z0-9_-]{3,32}$`)
+
+// NormalizeCode returns the code as stored: trimmed and upper case, so that
+// customers can enter it in any case.
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Spec is a coupon definition. Exactly one of PercentOff and AmountOff is set.
+type Spec struct {
+	Code           string
+	PercentOff     int64        // 1-100
+	AmountOff      money.Amount // in one currency
+	ExpiresAt      time.Time    // zero for no expiry
+	MaxRedemptions int64        // across all users; zero for no limit
+	PerUserLimit   int64        // zero for no limit
+	ProductIDs     []string     // empty for every product
+}
+
+// Validate checks a new coupon. The code must already be normalized.
+func (s Spec) Validate(now time.Time) error {
+	if !codePattern.MatchString(s.Code) {
+		return fmt.Errorf("%w: code must be 3-32 letters, digits, dashes or underscores", ErrInvalid)
+	}
+	switch {
+	case s.PercentOff != 0 && !s.AmountOff.IsZero():
+		return fmt.Errorf("%w: set either percent_off or amount_off", ErrInvalid)
+	case s.PercentOff != 0:
+		if s.PercentOff < 1 || s.PercentOff > 100 {
+			return fmt.Errorf("%w: percent_off must be between 1 and 100", ErrInvalid)
+		}
+	case s.AmountOff.IsPositive():
+		if err := s.AmountOff.Validate(); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+	default:
+		return fmt.Errorf("%w: percent_off or a positive amount_off is required", ErrInvalid)
+	}
+	return ValidateLimits(s.ExpiresAt, s.MaxRedemptions, s.PerUserLimit, now)
+}
+
+// ValidateLimits checks the expiry and redemption limits of a coupon, which can
+// change after it is created.
+func ValidateLimits(expiresAt time.Time, maxRedemptions, perUserLimit int64, now time.Time) error {
+	if !expiresAt.IsZero() && !expiresAt.After(now) {
+		return fmt.Errorf("%w: expires_at must be in the future", ErrInvalid)
+	}
+	if maxRedemptions < 0 || perUserLimit < 0 {
+		return fmt.Errorf("%w: redemption limits can't be negative", ErrInvalid)
+	}
+	if maxRedemptions > 0 && perUserLimit > maxRedemptions {
+		return fmt.Errorf("%w: per_user_limit exceeds max_redemptions", ErrInvalid)
+	}
+	return nil
+}
+
+// Discount returns what the coupon takes off price. Percentages round half up
+// to the currency's minor unit, and no discount exceeds the price. Amount-off
+// coupons only apply to prices in their currency.
+func Discount(c db.Coupon, price money.Amount) (money.Amount, error) {
+	var minor int64
+	if c.PercentOff > 0 {
+		minor = (price.Minor*c.PercentOff + 50) / 100
+	} else {
+		if c.Currency.String != price.Currency {
+			return money.Amount{}, fmt.Errorf("%w: %s is only valid for %s prices", ErrNotRedeemable, c.Code, c.Currency.String)
+		}
+		minor = c.AmountOff
+	}
+	return money.Amount{Minor: min(minor, price.Minor), Currency: price.Currency}, nil
+}
+
+// Redemption is a coupon applied to a price.
+type Redemption struct {
+	Coupon   db.Coupon
+	Discount money.Amount
+	Total    money.Amount // price less discount
+}
+
+// Apply looks up the coupon for code and applies it to a product's price. It
+// doesn't check redemption limits; see CheckLimits and Claim.
+func Apply(ctx context.Context, q db.Querier, code, productID string, price money.Amount, now time.Time) (Redemption, error) {
+	c, err := q.GetCouponByCode(ctx, NormalizeCode(code))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Redemption{}, fmt.Errorf("%w: %s", ErrNotFound, NormalizeCode(code))
+	}
+	if err != nil {
+		return Redemption{}, err
+	}
+	if c.ArchivedAt.Valid {
+		return Redemption{}, fmt.Errorf("%w: %s is archived", ErrNotRedeemable, c.Code)
+	}
+	if c.ExpiresAt.Valid && !c.ExpiresAt.Timestamp.After(now) {
+		return Redemption{}, fmt.Errorf("%w: %s has expired", ErrNotRedeemable, c.Code)
+	}
+	products, err := q.ListCouponProducts(ctx, c.ID)
+	if err != nil {
+		return Redemption{}, err
+	}
+	if len(products) > 0 && !slices.Contains(products, productID) {
+		return Redemption{}, fmt.Errorf("%w: %s doesn't apply to %s", ErrNotRedeemable, c.Code, productID)
+	}
+	discount, err := Discount(c, price)
+	if err != nil {
+		return Redemption{}, err
+	}
+	total, err := price.Sub(discount)
+	if err != nil {
+		return Redemption{}, err
+	}
+	return Redemption{Coupon: c, Discount: discount, Total: total}, nil
+}
+
+// CheckLimits reports ErrNotRedeemable once the coupon has been redeemed
+// max_redemptions times, or per_user_limit times by the user. Pending
+// transactions count until their session expires or is cancelled. Outside the
+// unit of work that records the redemption it is only a hint; see Claim.
+func CheckLimits(ctx context.Context, q db.Querier, c db.Coupon, userID string) error {
+	couponID := sql.NullInt64{Int64: c.ID, Valid: true}
+	if c.MaxRedemptions.Valid {
+		n, err := q.CountCouponRedemptions(ctx, couponID)
+		if err != nil {
+			return err
+		}
+		if n >= c.MaxRedemptions.Int64 {
+			return fmt.Errorf("%w: %s has been fully redeemed", ErrNotRedeemable, c.Code)
+		}
+	}
+	if c.PerUserLimit.Valid {
+		n, err := q.CountCouponRedemptionsByUser(ctx, db.CountCouponRedemptionsByUserParams{CouponID: couponID, UserID: userID})
+		if err != nil {
+			return err
+		}
+		if n >= c.PerUserLimit.Int64 {
+			return fmt.Errorf("%w: %s has already been used by %s", ErrNotRedeemable, c.Code, userID)
+		}
+	}
+	return nil
+}
+
+// Claim checks the coupon's limits in the unit of work that records a new
+// redemption, before it is recorded. The coupon stays locked until the unit of
+// work ends, so concurrent checkouts can't both take its last redemption.
+func Claim(ctx context.Context, q db.Querier, c db.Coupon, userID string) error {
+	if err := q.LockCoupon(ctx, c.ID); err != nil {
+		return err
+	}
+	return CheckLimits(ctx, q, c, userID)
+}
+
+// CheckEntered checks a coupon whose promotion code the customer entered on the
+// Stripe Checkout page. Stripe enforces its expiry and max_redemptions but not
+// its product restriction or per_user_limit, so the redemption may already break
+// them; this reports ErrNotRedeemable if it does. Like Claim, it runs in the unit
+// of work that records the redemption, before it is recorded.
+func CheckEntered(ctx context.Context, q db.Querier, c db.Coupon, productID, userID string) error {
+	products, err := q.ListCouponProducts(ctx, c.ID)
+	if err != nil {
+		return err
+	}
+	if len(products) > 0 && !slices.Contains(products, productID) {
+		return fmt.Errorf("%w: %s doesn't apply to %s", ErrNotRedeemable, c.Code, productID)
+	}
+	return Claim(ctx, q, c, userID)
+}
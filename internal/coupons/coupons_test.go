@@ -0,0 +1,133 @@
+package coupons
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"stripe-go-spike/internal/db"
+	"stripe-go-spike/internal/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpecValidate(t *testing.T) {
+	now := time.Now()
+	valid := Spec{Code: "SPRING-25", PercentOff: 25}
+	assert.NoError(t, valid.Validate(now))
+
+	for name, spec := range map[string]Spec{
+		"short code":       {Code: "AB", PercentOff: 10},
+		"lower case code":  {Code: "spring", PercentOff: 10},
+		"no discount":      {Code: "SPRING"},
+		"both discounts":   {Code: "SPRING", PercentOff: 10, AmountOff: money.MustNew(500, "USD")},
+		"percent over 100": {Code: "SPRING", PercentOff: 101},
+		"unknown currency": {Code: "SPRING", AmountOff: money.Amount{Minor: 500, Currency: "XYZ"}},
+		"expired":          {Code: "SPRING", PercentOff: 10, ExpiresAt: now.Add(-time.Hour)},
+		"negative limit":   {Code: "SPRING", PercentOff: 10, MaxRedemptions: -1},
+		"user over total":  {Code: "SPRING", PercentOff: 10, MaxRedemptions: 1, PerUserLimit: 2},
+	} {
+		assert.ErrorIs(t, spec.Validate(now), ErrInvalid, name)
+	}
+}
+
+func TestDiscount(t *testing.T) {
+	percent := db.Coupon{Code: "THIRD", PercentOff: 33}
+	d, err := Discount(percent, money.MustNew(4999, "USD"))
+	require.NoError(t, err)
+	assert.Equal(t, money.MustNew(1650, "USD"), d, "1649.67 rounds up")
+	d, err = Discount(percent, money.MustNew(7500, "JPY"))
+	require.NoError(t, err)
+	assert.Equal(t, money.MustNew(2475, "JPY"), d)
+
+	fixed := db.Coupon{Code: "FIVER", AmountOff: 500, Currency: sql.NullString{String: "USD", Valid: true}}
+	d, err = Discount(fixed, money.MustNew(4999, "USD"))
+	require.NoError(t, err)
+	assert.Equal(t, money.MustNew(500, "USD"), d)
+	d, err = Discount(fixed, money.MustNew(299, "USD"))
+	require.NoError(t, err)
+	assert.Equal(t, money.MustNew(299, "USD"), d, "capped at the price")
+	_, err = Discount(fixed, money.MustNew(4599, "EUR"))
+	assert.ErrorIs(t, err, ErrNotRedeemable)
+}
+
+func TestApplyAndCheckLimits(t *testing.T) {
+	database, err := db.NewTestConnection()
+	require.NoError(t, err)
+	defer database.Close()
+	queries := db.New(database)
+	ctx := context.Background()
+	now := time.Now()
+
+	create := func(code string, expiresAt db.NullTimestamp, maxRedemptions, perUser int64, products ...string) db.Coupon {
+		c, err := queries.CreateCoupon(ctx, db.CreateCouponParams{
+			Code:           code,
+			PercentOff:     10,
+			ExpiresAt:      expiresAt,
+			MaxRedemptions: sql.NullInt64{Int64: maxRedemptions, Valid: maxRedemptions > 0},
+			PerUserLimit:   sql.NullInt64{Int64: perUser, Valid: perUser > 0},
+			CreatedAt:      db.NewTimestamp(now),
+			UpdatedAt:      db.NewTimestamp(now),
+		})
+		require.NoError(t, err)
+		for _, p := range products {
+			require.NoError(t, queries.AddCouponProduct(ctx, db.AddCouponProductParams{CouponID: c.ID, ProductID: p}))
+		}
+		return c
+	}
+	redeem := func(c db.Coupon, id, userID, status string) {
+		require.NoError(t, queries.CreateTransaction(ctx, db.CreateTransactionParams{
+			ID:          id,
+			UserID:      userID,
+			ProductID:   "lumaweave",
+			ProductName: "LumaWeave Reactive Threads",
+			Amount:      4499,
+			Currency:    "USD",
+			Status:      status,
+			CreatedAt:   db.NewTimestamp(now),
+			UpdatedAt:   db.NewTimestamp(now),
+			CouponID:    sql.NullInt64{Int64: c.ID, Valid: true},
+		}))
+	}
+	price := money.MustNew(4999, "USD")
+
+	limited := create("LIMITED", db.NullTimestamp{}, 2, 1, "lumaweave")
+	r, err := Apply(ctx, queries, " limited ", "lumaweave", price, now)
+	require.NoError(t, err)
+	assert.Equal(t, limited.ID, r.Coupon.ID)
+	assert.Equal(t, money.MustNew(500, "USD"), r.Discount)
+	assert.Equal(t, money.MustNew(4499, "USD"), r.Total)
+
+	_, err = Apply(ctx, queries, "LIMITED", "echospout", price, now)
+	assert.ErrorIs(t, err, ErrNotRedeemable, "restricted to lumaweave")
+	_, err = Apply(ctx, queries, "MISSING", "lumaweave", price, now)
+	assert.ErrorIs(t, err, ErrNotFound)
+	create("EXPIRED", db.NewNullTimestamp(now.Add(-time.Minute)), 0, 0)
+	_, err = Apply(ctx, queries, "EXPIRED", "lumaweave", price, now)
+	assert.ErrorIs(t, err, ErrNotRedeemable)
+
+	// Cancelled transactions give their redemption back
+	require.NoError(t, CheckLimits(ctx, queries, limited, "luke"))
+	redeem(limited, "cancelled", "luke", "cancelled")
+	require.NoError(t, CheckLimits(ctx, queries, limited, "luke"))
+	redeem(limited, "pending", "luke", "pending")
+	assert.ErrorIs(t, CheckLimits(ctx, queries, limited, "luke"), ErrNotRedeemable, "per-user limit")
+	require.NoError(t, CheckLimits(ctx, queries, limited, "jinny"))
+	redeem(limited, "completed", "jinny", "completed")
+	assert.ErrorIs(t, CheckLimits(ctx, queries, limited, "admin"), ErrNotRedeemable, "max redemptions")
+
+	// Claim and CheckEntered run in the unit of work that records the redemption
+	store := db.NewStore(database, queries)
+	err = store.WithTx(ctx, func(q db.Querier) error { return Claim(ctx, q, limited, "admin") })
+	assert.ErrorIs(t, err, ErrNotRedeemable)
+	open := create("OPEN", db.NullTimestamp{}, 0, 1, "lumaweave")
+	err = store.WithTx(ctx, func(q db.Querier) error { return CheckEntered(ctx, q, open, "echospout", "luke") })
+	assert.ErrorIs(t, err, ErrNotRedeemable, "restricted to lumaweave")
+	err = store.WithTx(ctx, func(q db.Querier) error { return CheckEntered(ctx, q, open, "lumaweave", "luke") })
+	assert.NoError(t, err)
+	redeem(open, "entered", "luke", "completed")
+	err = store.WithTx(ctx, func(q db.Querier) error { return CheckEntered(ctx, q, open, "lumaweave", "luke") })
+	assert.ErrorIs(t, err, ErrNotRedeemable, "per-user limit")
+}
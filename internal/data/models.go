@@ -1,66 +1,165 @@
 package data
 
-import "time"
+import (
+	"encoding/json"
+
+	"stripe-go-spike/internal/db"
+	"stripe-go-spike/internal/money"
+)
 
 type User struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
-	Role string `json:"role"` // "user" or "admin"
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Role     string `json:"role"`               // "user" or "admin"
+	Currency string `json:"currency,omitempty"` // preferred currency for checkout
 }
 
 type Product struct {
-	ID          string `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	Price       int64  `json:"price"` // price in cents
+	ID          string         `json:"id"`
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Prices      []money.Amount `json:"prices"` // at most one per currency
+	// TaxCode is the Stripe product tax code that picks the tax rate
+	TaxCode string `json:"tax_code"`
 }
 
 type Transaction struct {
-	ID                    string     `json:"id"`
-	UserID                string     `json:"user_id"`
-	ProductID             string     `json:"product_id"`
-	ProductName           string     `json:"product_name"`
-	Amount                int64      `json:"amount"`
-	StripeSessionID       *string    `json:"stripe_session_id,omitempty"`
-	StripePaymentIntentID *string    `json:"stripe_payment_intent_id,omitempty"`
-	Status                string     `json:"status"`
-	CreatedAt             time.Time  `json:"created_at"`
-	UpdatedAt             time.Time  `json:"updated_at"`
-	RefundDate            *time.Time `json:"refund_date,omitempty"`
+	ID                    string        `json:"id"`
+	UserID                string        `json:"user_id"`
+	ProductID             string        `json:"product_id"`
+	ProductName           string        `json:"product_name"`
+	Amount                money.Amount  `json:"amount"`
+	StripeSessionID       *string       `json:"stripe_session_id,omitempty"`
+	StripePaymentIntentID *string       `json:"stripe_payment_intent_id,omitempty"`
+	Status                string        `json:"status"`
+	CreatedAt             db.Timestamp  `json:"created_at"`
+	UpdatedAt             db.Timestamp  `json:"updated_at"`
+	RefundDate            *db.Timestamp `json:"refund_date,omitempty"`
+	ExpiresAt             *db.Timestamp `json:"expires_at,omitempty"` // when the checkout session expires
+	CouponID              *int64        `json:"coupon_id,omitempty"`
+	// Discount is what the coupon took off and Tax what was added on top; Amount
+	// is what was charged
+	Discount *money.Amount `json:"discount,omitempty"`
+	Tax      *money.Amount `json:"tax,omitempty"`
+	// Vendor sales name the vendor's connected account and split Amount into the
+	// platform's ApplicationFee and the vendor's Transfer
+	ConnectedAccountID *int64        `json:"connected_account_id,omitempty"`
+	ApplicationFee     *money.Amount `json:"application_fee,omitempty"`
+	Transfer           *money.Amount `json:"transfer,omitempty"`
+}
+
+// NewTransaction converts a stored transaction row into its API representation
+func NewTransaction(txn db.Transaction) Transaction {
+	return Transaction{
+		ID:          txn.ID,
+		UserID:      txn.UserID,
+		ProductID:   txn.ProductID,
+		ProductName: txn.ProductName,
+		Amount:      money.Amount{Minor: txn.Amount, Currency: txn.Currency},
+		StripeSessionID: func() *string {
+			if txn.StripeSessionID.Valid {
+				return &txn.StripeSessionID.String
+			}
+			return nil
+		}(),
+		StripePaymentIntentID: func() *string {
+			if txn.StripePaymentIntentID.Valid {
+				return &txn.StripePaymentIntentID.String
+			}
+			return nil
+		}(),
+		Status:     txn.Status,
+		CreatedAt:  txn.CreatedAt,
+		UpdatedAt:  txn.UpdatedAt,
+		RefundDate: txn.RefundDate.Ptr(),
+		ExpiresAt:  txn.ExpiresAt.Ptr(),
+		CouponID: func() *int64 {
+			if txn.CouponID.Valid {
+				return &txn.CouponID.Int64
+			}
+			return nil
+		}(),
+		Discount: optionalAmount(money.Amount{Minor: txn.DiscountAmount, Currency: txn.Currency}),
+		Tax:      optionalAmount(money.Amount{Minor: txn.TaxAmount, Currency: txn.Currency}),
+		ConnectedAccountID: func() *int64 {
+			if txn.ConnectedAccountID.Valid {
+				return &txn.ConnectedAccountID.Int64
+			}
+			return nil
+		}(),
+		ApplicationFee: optionalAmount(money.Amount{Minor: txn.ApplicationFeeAmount, Currency: txn.Currency}),
+		Transfer:       optionalAmount(money.Amount{Minor: txn.TransferAmount, Currency: txn.Currency}),
+	}
+}
+
+// optionalAmount returns nil for no amount, to leave it out of responses.
+func optionalAmount(amount money.Amount) *money.Amount {
+	if !amount.IsPositive() {
+		return nil
+	}
+	return &amount
 }
 
 // Hardcoded users for the spike
 var Users = []User{
 	{ID: "luke", Name: "Luke", Role: "user"},
-	{ID: "jinny", Name: "Jinny", Role: "user"},
+	{ID: "jinny", Name: "Jinny", Role: "user", Currency: "EUR"},
 	{ID: "admin", Name: "ADMIN", Role: "admin"},
 }
 
+// GeneralTangibleGoods is Stripe's tax code for physical goods with no more
+// specific category.
+const GeneralTangibleGoods = "txcd_99999999"
+
 // Hardcoded products for the spike
 var Products = []Product{
 	{
 		ID:          "lumaweave",
 		Name:        "LumaWeave Reactive Threads",
 		Description: "Smart textile technology",
-		Price:       4999, // $49.99
+		Prices: []money.Amount{
+			money.MustNew(4999, "USD"),
+			money.MustNew(4599, "EUR"),
+			money.MustNew(3999, "GBP"),
+			money.MustNew(7500, "JPY"),
+		},
+		TaxCode: GeneralTangibleGoods,
 	},
 	{
 		ID:          "coffee-pods",
 		Name:        "Atmospheric Coffee Pods",
 		Description: "Premium coffee experience",
-		Price:       2999, // $29.99
+		Prices: []money.Amount{
+			money.MustNew(2999, "USD"),
+			money.MustNew(2799, "EUR"),
+			money.MustNew(2499, "GBP"),
+			money.MustNew(4500, "JPY"),
+		},
+		TaxCode: GeneralTangibleGoods,
 	},
 	{
 		ID:          "echospout",
 		Name:        "EchoSprout Memory Plants",
 		Description: "Living memory storage",
-		Price:       8999, // $89.99
+		Prices: []money.Amount{
+			money.MustNew(8999, "USD"),
+			money.MustNew(8299, "EUR"),
+			money.MustNew(7199, "GBP"),
+			money.MustNew(13500, "JPY"),
+		},
+		TaxCode: GeneralTangibleGoods,
 	},
 	{
 		ID:          "pocketforge",
 		Name:        "PocketForge Nano Printer",
 		Description: "Miniature 3D printing",
-		Price:       19999, // $199.99
+		Prices: []money.Amount{
+			money.MustNew(19999, "USD"),
+			money.MustNew(18499, "EUR"),
+			money.MustNew(15999, "GBP"),
+			money.MustNew(30000, "JPY"),
+		},
+		TaxCode: GeneralTangibleGoods,
 	},
 }
 
@@ -86,11 +185,142 @@ func GetProductByID(id string) *Product {
 
 // AuditEvent represents an audit event for API responses
 type AuditEvent struct {
-	ID          int64     `json:"id"`
-	Timestamp   time.Time `json:"timestamp"`
-	Subsystem   string    `json:"subsystem"`
-	EventType   string    `json:"event_type"`
-	UserID      *string   `json:"user_id,omitempty"`
-	Information *string   `json:"information,omitempty"`
-	Payload     *string   `json:"payload,omitempty"`
+	ID          int64        `json:"id"`
+	Timestamp   db.Timestamp `json:"timestamp"`
+	Subsystem   string       `json:"subsystem"`
+	EventType   string       `json:"event_type"`
+	UserID      *string      `json:"user_id,omitempty"`
+	Information *string      `json:"information,omitempty"`
+	Payload     *string      `json:"payload,omitempty"`
+	RefID       *string      `json:"ref_id,omitempty"`
+	RefID2      *string      `json:"ref_id2,omitempty"`
+}
+
+// WebhookInboxEntry represents a received Stripe webhook for API responses
+type WebhookInboxEntry struct {
+	ID              int64         `json:"id"`
+	StripeEventID   string        `json:"stripe_event_id"`
+	EventType       string        `json:"event_type"`
+	SessionID       *string       `json:"session_id,omitempty"`
+	PaymentIntentID *string       `json:"payment_intent_id,omitempty"`
+	Status          string        `json:"status"` // "received", "processed" or "failed"
+	Error           *string       `json:"error,omitempty"`
+	ReceivedAt      db.Timestamp  `json:"received_at"`
+	ProcessedAt     *db.Timestamp `json:"processed_at,omitempty"`
+}
+
+// Coupon represents a coupon for API responses
+type Coupon struct {
+	ID                    int64         `json:"id"`
+	Code                  string        `json:"code"`
+	PercentOff            int64         `json:"percent_off,omitempty"`
+	AmountOff             *money.Amount `json:"amount_off,omitempty"`
+	ExpiresAt             *db.Timestamp `json:"expires_at,omitempty"`
+	MaxRedemptions        *int64        `json:"max_redemptions,omitempty"`
+	PerUserLimit          *int64        `json:"per_user_limit,omitempty"`
+	ProductIDs            []string      `json:"product_ids"` // empty for every product
+	Redemptions           int64         `json:"redemptions"`
+	StripeCouponID        *string       `json:"stripe_coupon_id,omitempty"`
+	StripePromotionCodeID *string       `json:"stripe_promotion_code_id,omitempty"`
+	CreatedAt             db.Timestamp  `json:"created_at"`
+	UpdatedAt             db.Timestamp  `json:"updated_at"`
+	ArchivedAt            *db.Timestamp `json:"archived_at,omitempty"`
+}
+
+// TaxRate represents a local tax rate for API responses
+type TaxRate struct {
+	ID              int64         `json:"id"`
+	Country         string        `json:"country"`
+	Region          string        `json:"region,omitempty"`   // empty for the whole country
+	TaxCode         string        `json:"tax_code,omitempty"` // empty for every product
+	Name            string        `json:"name"`
+	RateBps         int64         `json:"rate_bps"`
+	Percentage      string        `json:"percentage"` // e.g. "8.25"
+	StripeTaxRateID *string       `json:"stripe_tax_rate_id,omitempty"`
+	CreatedAt       db.Timestamp  `json:"created_at"`
+	ArchivedAt      *db.Timestamp `json:"archived_at,omitempty"`
+}
+
+// LineItem represents a transaction line item for API responses
+type LineItem struct {
+	ProductID   string        `json:"product_id"`
+	Description string        `json:"description"`
+	Quantity    int64         `json:"quantity"`
+	UnitAmount  money.Amount  `json:"unit_amount"`
+	Discount    *money.Amount `json:"discount,omitempty"`
+	Tax         *money.Amount `json:"tax,omitempty"`
+	TaxCode     string        `json:"tax_code,omitempty"`
+	TaxRateID   *int64        `json:"tax_rate_id,omitempty"`
+	Total       money.Amount  `json:"total"` // unit amount times quantity, less discount, plus tax
+}
+
+// ConnectedAccount represents a vendor's Stripe connected account for API
+// responses
+type ConnectedAccount struct {
+	ID               int64        `json:"id"`
+	StripeAccountID  string       `json:"stripe_account_id"`
+	Name             string       `json:"name"`
+	Email            string       `json:"email,omitempty"`
+	Country          string       `json:"country"`
+	FeeBps           int64        `json:"fee_bps"` // platform's share before tax
+	ChargesEnabled   bool         `json:"charges_enabled"`
+	PayoutsEnabled   bool         `json:"payouts_enabled"`
+	DetailsSubmitted bool         `json:"details_submitted"`
+	CreatedAt        db.Timestamp `json:"created_at"`
+	UpdatedAt        db.Timestamp `json:"updated_at"`
+}
+
+// WebhookEndpoint represents an outbound webhook endpoint for API responses.
+// The signing secret is only returned when the endpoint is created.
+type WebhookEndpoint struct {
+	ID          int64        `json:"id"`
+	URL         string       `json:"url"`
+	Secret      string       `json:"secret,omitempty"`
+	EventTypes  []string     `json:"event_types"` // empty for every event type
+	Description string       `json:"description,omitempty"`
+	Active      bool         `json:"active"`
+	CreatedAt   db.Timestamp `json:"created_at"`
+	UpdatedAt   db.Timestamp `json:"updated_at"`
+}
+
+// WebhookDelivery represents an event queued for an outbound webhook endpoint
+// for API responses
+type WebhookDelivery struct {
+	ID             int64                    `json:"id"`
+	EndpointID     int64                    `json:"endpoint_id"`
+	EventID        string                   `json:"event_id"`
+	EventType      string                   `json:"event_type"`
+	Payload        json.RawMessage          `json:"payload,omitempty"`
+	Status         string                   `json:"status"` // pending, succeeded or failed
+	Attempts       int64                    `json:"attempts"`
+	NextAttemptAt  *db.Timestamp            `json:"next_attempt_at,omitempty"`
+	LastStatusCode *int64                   `json:"last_status_code,omitempty"`
+	LastError      string                   `json:"last_error,omitempty"`
+	CreatedAt      db.Timestamp             `json:"created_at"`
+	UpdatedAt      db.Timestamp             `json:"updated_at"`
+	AttemptLog     []WebhookDeliveryAttempt `json:"attempt_log,omitempty"`
+}
+
+// WebhookDeliveryAttempt represents one try at sending a delivery for API
+// responses
+type WebhookDeliveryAttempt struct {
+	AttemptedAt db.Timestamp `json:"attempted_at"`
+	StatusCode  *int64       `json:"status_code,omitempty"`
+	Error       string       `json:"error,omitempty"`
+	DurationMs  int64        `json:"duration_ms"`
+}
+
+// OutboxMessage represents a transactional outbox message for API responses
+type OutboxMessage struct {
+	ID            int64           `json:"id"`
+	Topic         string          `json:"topic"`
+	Key           string          `json:"key"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        string          `json:"status"` // pending, published or dead
+	Attempts      int64           `json:"attempts"`
+	NextAttemptAt db.Timestamp    `json:"next_attempt_at"`
+	LastError     string          `json:"last_error,omitempty"`
+	CreatedAt     db.Timestamp    `json:"created_at"`
+	PublishedAt   *db.Timestamp   `json:"published_at,omitempty"`
+	DeadAt        *db.Timestamp   `json:"dead_at,omitempty"`
 }
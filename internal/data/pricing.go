@@ -0,0 +1,116 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"stripe-go-spike/internal/money"
+)
+
+// DefaultCurrency is charged when no hint matches a price of the product.
+const DefaultCurrency = "USD"
+
+// ErrCurrencyUnavailable is returned when a product has no price in an explicitly
+// requested currency.
+var ErrCurrencyUnavailable = errors.New("product is not priced in the requested currency")
+
+// CurrencySource records which hint decided the currency of a price.
+type CurrencySource string
+
+const (
+	CurrencyFromRequest CurrencySource = "request"
+	CurrencyFromProfile CurrencySource = "profile"
+	CurrencyFromCountry CurrencySource = "country"
+	CurrencyFromLocale  CurrencySource = "locale"
+	CurrencyFromDefault CurrencySource = "default"
+)
+
+// CurrencyHints are the inputs for picking a currency, in order of precedence.
+type CurrencyHints struct {
+	Requested      string // explicit currency code; must be available if set
+	User           *User  // the user's preferred currency
+	Country        string // ISO 3166-1 country code, e.g. from the client
+	AcceptLanguage string // the request's Accept-Language header
+}
+
+// PriceIn returns the product's price in currency (any case).
+func (p Product) PriceIn(currency string) (money.Amount, bool) {
+	for _, price := range p.Prices {
+		if strings.EqualFold(price.Currency, currency) {
+			return price, true
+		}
+	}
+	return money.Amount{}, false
+}
+
+// SelectPrice picks the price to charge. An explicit currency must be known and
+// offered by the product; the other hints are tried in order and skipped when the
+// product has no price in them. Without a match the DefaultCurrency price (or the
+// first listed price) is used.
+func (p Product) SelectPrice(h CurrencyHints) (money.Amount, CurrencySource, error) {
+	if h.Requested != "" {
+		if _, ok := money.LookupCurrency(h.Requested); !ok {
+			return money.Amount{}, "", fmt.Errorf("%w %q", money.ErrUnknownCurrency, h.Requested)
+		}
+		price, ok := p.PriceIn(h.Requested)
+		if !ok {
+			return money.Amount{}, "", fmt.Errorf("%w: %s", ErrCurrencyUnavailable, strings.ToUpper(h.Requested))
+		}
+		return price, CurrencyFromRequest, nil
+	}
+	if h.User != nil && h.User.Currency != "" {
+		if price, ok := p.PriceIn(h.User.Currency); ok {
+			return price, CurrencyFromProfile, nil
+		}
+	}
+	if currency, ok := money.CurrencyForCountry(h.Country); ok {
+		if price, ok := p.PriceIn(currency); ok {
+			return price, CurrencyFromCountry, nil
+		}
+	}
+	for _, country := range localeCountries(h.AcceptLanguage) {
+		if currency, ok := money.CurrencyForCountry(country); ok {
+			if price, ok := p.PriceIn(currency); ok {
+				return price, CurrencyFromLocale, nil
+			}
+		}
+	}
+	if price, ok := p.PriceIn(DefaultCurrency); ok {
+		return price, CurrencyFromDefault, nil
+	}
+	if len(p.Prices) == 0 {
+		return money.Amount{}, "", fmt.Errorf("product %s has no price", p.ID)
+	}
+	return p.Prices[0], CurrencyFromDefault, nil
+}
+
+// languageCountries resolves language-only tags whose country is unambiguous.
+var languageCountries = map[string]string{"ja": "JP", "ko": "KR", "vi": "VN"}
+
+// localeCountries extracts country codes from an Accept-Language header in the
+// order the client listed them ("de-CH,de;q=0.9,en-GB;q=0.8" -> CH, GB).
+func localeCountries(header string) []string {
+	var countries []string
+	for _, part := range strings.Split(header, ",") {
+		tag, _, _ := strings.Cut(strings.TrimSpace(part), ";")
+		subtags := strings.FieldsFunc(tag, func(r rune) bool { return r == '-' || r == '_' })
+		if len(subtags) == 0 {
+			continue
+		}
+		found := false
+		for _, sub := range subtags[1:] {
+			if len(sub) == 2 {
+				countries = append(countries, strings.ToUpper(sub))
+				found = true
+				break
+			}
+		}
+		if !found {
+			if country, ok := languageCountries[strings.ToLower(subtags[0])]; ok {
+				countries = append(countries, country)
+			}
+		}
+	}
+	return countries
+}
@@ -0,0 +1,44 @@
+package data
+
+import (
+	"testing"
+
+	"stripe-go-spike/internal/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectPrice(t *testing.T) {
+	product := *GetProductByID("lumaweave")
+	jinny := GetUserByID("jinny")
+	luke := GetUserByID("luke")
+
+	for _, tc := range []struct {
+		name   string
+		hints  CurrencyHints
+		want   money.Amount
+		source CurrencySource
+	}{
+		{"explicit request wins", CurrencyHints{Requested: "jpy", User: jinny}, money.MustNew(7500, "JPY"), CurrencyFromRequest},
+		{"profile", CurrencyHints{User: jinny, Country: "GB"}, money.MustNew(4599, "EUR"), CurrencyFromProfile},
+		{"country", CurrencyHints{User: luke, Country: "gb"}, money.MustNew(3999, "GBP"), CurrencyFromCountry},
+		{"locale region", CurrencyHints{AcceptLanguage: "de-DE,de;q=0.9,en;q=0.8"}, money.MustNew(4599, "EUR"), CurrencyFromLocale},
+		{"locale skips unpriced", CurrencyHints{AcceptLanguage: "de-CH, en-GB;q=0.8"}, money.MustNew(3999, "GBP"), CurrencyFromLocale},
+		{"language only", CurrencyHints{AcceptLanguage: "ja"}, money.MustNew(7500, "JPY"), CurrencyFromLocale},
+		{"unpriced country falls back", CurrencyHints{Country: "CH"}, money.MustNew(4999, "USD"), CurrencyFromDefault},
+		{"no hints", CurrencyHints{}, money.MustNew(4999, "USD"), CurrencyFromDefault},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			price, source, err := product.SelectPrice(tc.hints)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, price)
+			assert.Equal(t, tc.source, source)
+		})
+	}
+
+	_, _, err := product.SelectPrice(CurrencyHints{Requested: "CHF"})
+	assert.ErrorIs(t, err, ErrCurrencyUnavailable)
+	_, _, err = product.SelectPrice(CurrencyHints{Requested: "XYZ"})
+	assert.ErrorIs(t, err, money.ErrUnknownCurrency)
+}
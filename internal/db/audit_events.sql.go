@@ -10,7 +10,7 @@ import (
 	"database/sql"
 )
 
-const createAuditEvent = `-- name: CreateAuditEvent :exec
+const createAuditEvent = `-- name: CreateAuditEvent :one
 INSERT INTO audit_events (
     subsystem,
     event_type,
@@ -20,6 +20,7 @@ INSERT INTO audit_events (
     ref_id,
     ref_id2
 ) VALUES (?, ?, ?, ?, ?, ?, ?)
+RETURNING id, timestamp, subsystem, event_type, user_id, information, payload, ref_id, ref_id2
 `
 
 type CreateAuditEventParams struct {
@@ -32,8 +33,8 @@ type CreateAuditEventParams struct {
 	RefId2      sql.NullString `json:"ref_id2"`
 }
 
-func (q *Queries) CreateAuditEvent(ctx context.Context, arg CreateAuditEventParams) error {
-	_, err := q.exec(ctx, q.createAuditEventStmt, createAuditEvent,
+func (q *Queries) CreateAuditEvent(ctx context.Context, arg CreateAuditEventParams) (AuditEvent, error) {
+	row := q.queryRow(ctx, q.createAuditEventStmt, createAuditEvent,
 		arg.Subsystem,
 		arg.EventType,
 		arg.UserID,
@@ -42,7 +43,19 @@ func (q *Queries) CreateAuditEvent(ctx context.Context, arg CreateAuditEventPara
 		arg.RefID,
 		arg.RefId2,
 	)
-	return err
+	var i AuditEvent
+	err := row.Scan(
+		&i.ID,
+		&i.Timestamp,
+		&i.Subsystem,
+		&i.EventType,
+		&i.UserID,
+		&i.Information,
+		&i.Payload,
+		&i.RefID,
+		&i.RefId2,
+	)
+	return i, err
 }
 
 const getAllAuditEvents = `-- name: GetAllAuditEvents :many
@@ -89,6 +102,51 @@ func (q *Queries) GetAllAuditEvents(ctx context.Context, arg GetAllAuditEventsPa
 	return items, nil
 }
 
+const getAuditEventsAfterID = `-- name: GetAuditEventsAfterID :many
+SELECT id, timestamp, subsystem, event_type, user_id, information, payload, ref_id, ref_id2 FROM audit_events
+WHERE id > ?
+ORDER BY id ASC
+LIMIT ?
+`
+
+type GetAuditEventsAfterIDParams struct {
+	ID    int64 `json:"id"`
+	Limit int64 `json:"limit"`
+}
+
+func (q *Queries) GetAuditEventsAfterID(ctx context.Context, arg GetAuditEventsAfterIDParams) ([]AuditEvent, error) {
+	rows, err := q.query(ctx, q.getAuditEventsAfterIDStmt, getAuditEventsAfterID, arg.ID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []AuditEvent{}
+	for rows.Next() {
+		var i AuditEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.Timestamp,
+			&i.Subsystem,
+			&i.EventType,
+			&i.UserID,
+			&i.Information,
+			&i.Payload,
+			&i.RefID,
+			&i.RefId2,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getAuditEventsByEventType = `-- name: GetAuditEventsByEventType :many
 SELECT id, timestamp, subsystem, event_type, user_id, information, payload, ref_id, ref_id2 FROM audit_events
 WHERE event_type = ?
@@ -379,10 +437,10 @@ LIMIT ? OFFSET ?
 `
 
 type GetAuditEventsInDateRangeParams struct {
-	Timestamp   string `json:"timestamp"`
-	Timestamp_2 string `json:"timestamp_2"`
-	Limit       int64  `json:"limit"`
-	Offset      int64  `json:"offset"`
+	Timestamp   Timestamp `json:"timestamp"`
+	Timestamp_2 Timestamp `json:"timestamp_2"`
+	Limit       int64     `json:"limit"`
+	Offset      int64     `json:"offset"`
 }
 
 func (q *Queries) GetAuditEventsInDateRange(ctx context.Context, arg GetAuditEventsInDateRangeParams) ([]AuditEvent, error) {
@@ -422,3 +480,228 @@ func (q *Queries) GetAuditEventsInDateRange(ctx context.Context, arg GetAuditEve
 	}
 	return items, nil
 }
+
+const importAuditEvent = `-- name: ImportAuditEvent :exec
+INSERT INTO audit_events (
+    id,
+    timestamp,
+    subsystem,
+    event_type,
+    user_id,
+    information,
+    payload,
+    ref_id,
+    ref_id2
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (id) DO NOTHING
+`
+
+type ImportAuditEventParams struct {
+	ID          int64          `json:"id"`
+	Timestamp   Timestamp      `json:"timestamp"`
+	Subsystem   string         `json:"subsystem"`
+	EventType   string         `json:"event_type"`
+	UserID      sql.NullString `json:"user_id"`
+	Information sql.NullString `json:"information"`
+	Payload     sql.NullString `json:"payload"`
+	RefID       sql.NullString `json:"ref_id"`
+	RefId2      sql.NullString `json:"ref_id2"`
+}
+
+func (q *Queries) ImportAuditEvent(ctx context.Context, arg ImportAuditEventParams) error {
+	_, err := q.exec(ctx, q.importAuditEventStmt, importAuditEvent,
+		arg.ID,
+		arg.Timestamp,
+		arg.Subsystem,
+		arg.EventType,
+		arg.UserID,
+		arg.Information,
+		arg.Payload,
+		arg.RefID,
+		arg.RefId2,
+	)
+	return err
+}
+
+const listAuditEventsByRefs = `-- name: ListAuditEventsByRefs :many
+SELECT id, timestamp, subsystem, event_type, user_id, information, payload, ref_id, ref_id2 FROM audit_events
+WHERE ref_id IN (?1, ?2, ?3) OR ref_id2 IN (?1, ?2, ?3)
+ORDER BY id ASC
+LIMIT ?4
+`
+
+type ListAuditEventsByRefsParams struct {
+	RefID   sql.NullString `json:"ref_id"`
+	RefID_2 sql.NullString `json:"ref_id_2"`
+	RefID_3 sql.NullString `json:"ref_id_3"`
+	Limit   int64          `json:"limit"`
+}
+
+func (q *Queries) ListAuditEventsByRefs(ctx context.Context, arg ListAuditEventsByRefsParams) ([]AuditEvent, error) {
+	rows, err := q.query(ctx, q.listAuditEventsByRefsStmt, listAuditEventsByRefs,
+		arg.RefID,
+		arg.RefID_2,
+		arg.RefID_3,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []AuditEvent{}
+	for rows.Next() {
+		var i AuditEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.Timestamp,
+			&i.Subsystem,
+			&i.EventType,
+			&i.UserID,
+			&i.Information,
+			&i.Payload,
+			&i.RefID,
+			&i.RefId2,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAuditEventsForExport = `-- name: ListAuditEventsForExport :many
+SELECT id, timestamp, subsystem, event_type, user_id, information, payload, ref_id, ref_id2 FROM audit_events
+WHERE id > ?1 AND timestamp >= ?2 AND timestamp < ?3
+  AND (?4 = '' OR subsystem = ?4)
+  AND (?5 = '' OR event_type = ?5)
+  AND (?6 = '' OR user_id = ?6)
+  AND (?7 = '' OR ref_id = ?7)
+  AND (?8 = '' OR ref_id2 = ?8)
+ORDER BY id ASC
+LIMIT ?9
+`
+
+type ListAuditEventsForExportParams struct {
+	ID          int64     `json:"id"`
+	Timestamp   Timestamp `json:"timestamp"`
+	Timestamp_2 Timestamp `json:"timestamp_2"`
+	Subsystem   string    `json:"subsystem"`
+	EventType   string    `json:"event_type"`
+	UserID      string    `json:"user_id"`
+	RefID       string    `json:"ref_id"`
+	RefId2      string    `json:"ref_id2"`
+	Limit       int64     `json:"limit"`
+}
+
+// Empty filters match every event; the others must all match.
+func (q *Queries) ListAuditEventsForExport(ctx context.Context, arg ListAuditEventsForExportParams) ([]AuditEvent, error) {
+	rows, err := q.query(ctx, q.listAuditEventsForExportStmt, listAuditEventsForExport,
+		arg.ID,
+		arg.Timestamp,
+		arg.Timestamp_2,
+		arg.Subsystem,
+		arg.EventType,
+		arg.UserID,
+		arg.RefID,
+		arg.RefId2,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []AuditEvent{}
+	for rows.Next() {
+		var i AuditEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.Timestamp,
+			&i.Subsystem,
+			&i.EventType,
+			&i.UserID,
+			&i.Information,
+			&i.Payload,
+			&i.RefID,
+			&i.RefId2,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAuditEvents = `-- name: ListAuditEvents :many
+SELECT id, timestamp, subsystem, event_type, user_id, information, payload, ref_id, ref_id2 FROM audit_events
+WHERE (?1 = '' OR subsystem = ?1)
+  AND (?2 = '' OR event_type = ?2)
+  AND (?3 = '' OR user_id = ?3)
+  AND (?4 = '' OR ref_id = ?4)
+  AND (?5 = '' OR ref_id2 = ?5)
+ORDER BY timestamp DESC, id DESC
+LIMIT ?6 OFFSET ?7
+`
+
+type ListAuditEventsParams struct {
+	Subsystem string `json:"subsystem"`
+	EventType string `json:"event_type"`
+	UserID    string `json:"user_id"`
+	RefID     string `json:"ref_id"`
+	RefId2    string `json:"ref_id2"`
+	Limit     int64  `json:"limit"`
+	Offset    int64  `json:"offset"`
+}
+
+// Empty filters match every event; the others must all match.
+func (q *Queries) ListAuditEvents(ctx context.Context, arg ListAuditEventsParams) ([]AuditEvent, error) {
+	rows, err := q.query(ctx, q.listAuditEventsStmt, listAuditEvents,
+		arg.Subsystem,
+		arg.EventType,
+		arg.UserID,
+		arg.RefID,
+		arg.RefId2,
+		arg.Limit,
+		arg.Offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []AuditEvent{}
+	for rows.Next() {
+		var i AuditEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.Timestamp,
+			&i.Subsystem,
+			&i.EventType,
+			&i.UserID,
+			&i.Information,
+			&i.Payload,
+			&i.RefID,
+			&i.RefId2,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
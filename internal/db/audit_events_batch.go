@@ -0,0 +1,116 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+)
+
+const createAuditEventsPrefix = `INSERT INTO audit_events (
+    subsystem,
+    event_type,
+    user_id,
+    information,
+    payload,
+    ref_id,
+    ref_id2
+) VALUES `
+
+const createAuditEventsReturning = `
+RETURNING id, timestamp, subsystem, event_type, user_id, information, payload, ref_id, ref_id2`
+
+// MaxAuditEventsBatch is the most events CreateAuditEvents can insert at once: each
+// binds 7 variables and SQLite builds may allow no more than 999 per statement.
+const MaxAuditEventsBatch = 999 / 7
+
+// CreateAuditEvents inserts several audit events with a single multi-row INSERT and
+// returns the stored rows ordered by id. sqlc cannot generate variadic VALUES lists,
+// so this lives next to the generated code. Callers split larger slices into batches
+// of at most MaxAuditEventsBatch.
+func (q *Queries) CreateAuditEvents(ctx context.Context, args []CreateAuditEventParams) ([]AuditEvent, error) {
+	if len(args) == 0 {
+		return []AuditEvent{}, nil
+	}
+	var sb strings.Builder
+	sb.WriteString(createAuditEventsPrefix)
+	values := make([]interface{}, 0, len(args)*7)
+	for i, arg := range args {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString("(?, ?, ?, ?, ?, ?, ?)")
+		values = append(values,
+			arg.Subsystem,
+			arg.EventType,
+			arg.UserID,
+			arg.Information,
+			arg.Payload,
+			arg.RefID,
+			arg.RefId2,
+		)
+	}
+	sb.WriteString(createAuditEventsReturning)
+
+	rows, err := q.db.QueryContext(ctx, sb.String(), values...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := make([]AuditEvent, 0, len(args))
+	for rows.Next() {
+		var i AuditEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.Timestamp,
+			&i.Subsystem,
+			&i.EventType,
+			&i.UserID,
+			&i.Information,
+			&i.Payload,
+			&i.RefID,
+			&i.RefId2,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// RETURNING does not guarantee row order
+	sort.Slice(items, func(a, b int) bool { return items[a].ID < items[b].ID })
+	return items, nil
+}
+
+// DeleteAuditEvents removes the audit events with the given ids and returns how many
+// rows were deleted.
+func (q *Queries) DeleteAuditEvents(ctx context.Context, ids []int64) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	res, err := q.db.ExecContext(ctx, "DELETE FROM audit_events WHERE id IN ("+placeholders+")", args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// SyncAuditEventIDs moves the PostgreSQL id sequence past the highest stored id, so
+// inserts after ImportAuditEvent don't collide with imported rows. SQLite's
+// AUTOINCREMENT already accounts for explicit ids, and Queries bound to a
+// transaction can't tell their dialect, so both are no-ops.
+func (q *Queries) SyncAuditEventIDs(ctx context.Context) error {
+	sqlDB, ok := q.db.(*sql.DB)
+	if !ok || DialectOf(sqlDB) != DialectPostgres {
+		return nil
+	}
+	_, err := q.db.ExecContext(ctx,
+		"SELECT setval(pg_get_serial_sequence('audit_events', 'id'), (SELECT COALESCE(MAX(id), 0) + 1 FROM audit_events), false)")
+	return err
+}
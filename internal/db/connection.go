@@ -1,11 +1,13 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/tursodatabase/libsql-client-go/libsql"
 	_ "modernc.org/sqlite"
@@ -14,9 +16,16 @@ import (
 // DefaultLocalDBPath is used when no DB_PATH is provided.
 const DefaultLocalDBPath = "_data/db-spike-stripe.sqlite3"
 
-// NewConnection returns a *sql.DB connected either to Turso (when TURSO_DATABASE_URL is set)
-// or to a local SQLite database file. You can override the local path with DB_PATH.
+// NewConnection returns a *sql.DB connected to PostgreSQL (when DATABASE_URL is a
+// postgres:// or postgresql:// URL), to Turso (when TURSO_DATABASE_URL is set) or to a
+// local SQLite database file. You can override the local path with DB_PATH.
 func NewConnection() (*sql.DB, error) {
+	if url := os.Getenv("DATABASE_URL"); url != "" {
+		if !strings.HasPrefix(url, "postgres://") && !strings.HasPrefix(url, "postgresql://") {
+			return nil, errors.New("DATABASE_URL must be a postgres:// or postgresql:// URL")
+		}
+		return NewPostgresConnection(url)
+	}
 	if os.Getenv("TURSO_DATABASE_URL") != "" {
 		return NewTursoConnection()
 	}
@@ -32,8 +41,9 @@ func NewLocalSQLite(path string) (*sql.DB, error) {
 	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
 		return nil, fmt.Errorf("create db dir: %w", err)
 	}
-	// Enable foreign keys and WAL for better concurrency
-	dsn := fmt.Sprintf("file:%s?_pragma=foreign_keys(ON)&_pragma=journal_mode(WAL)", path)
+	// Enable foreign keys and WAL for better concurrency; wait on locks held by
+	// other processes (e.g. a replica migrating) instead of failing with SQLITE_BUSY
+	dsn := fmt.Sprintf("file:%s?_pragma=foreign_keys(ON)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)", path)
 	db, err := sql.Open("sqlite", dsn)
 	if err != nil {
 		return nil, err
@@ -55,22 +65,24 @@ func NewTursoConnection() (*sql.DB, error) {
 	return sql.OpenDB(connector), nil
 }
 
-// NewTestConnection creates a new in-memory SQLite connection for testing
+// NewTestConnection creates a new in-memory SQLite connection for testing, or a
+// PostgreSQL connection to a fresh schema when TEST_DATABASE_URL is set.
 func NewTestConnection() (*sql.DB, error) {
+	if url := os.Getenv("TEST_DATABASE_URL"); url != "" {
+		return newPostgresTestConnection(url)
+	}
+
 	dsn := ":memory:"
 	db, err := sql.Open("sqlite", dsn)
 	if err != nil {
 		return nil, err
 	}
+	// Every pooled connection to :memory: is a separate database, so keep exactly one
+	db.SetMaxOpenConns(1)
 
 	// Run migrations for testing
-	migrations := os.DirFS("../../db/migrations")
-	if err := RunMigrations(db, migrations, ""); err != nil {
-		// Try alternative path for when tests are run from project root
-		migrations = os.DirFS("db/migrations")
-		if err := RunMigrations(db, migrations, ""); err != nil {
-			return nil, fmt.Errorf("failed to run test migrations: %w", err)
-		}
+	if _, err := NewEmbeddedMigrator(db).Up(context.Background(), 0); err != nil {
+		return nil, fmt.Errorf("failed to run test migrations: %w", err)
 	}
 
 	return db, nil
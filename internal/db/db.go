@@ -24,18 +24,99 @@ func New(db DBTX) *Queries {
 func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	q := Queries{db: db}
 	var err error
+	if q.addCouponProductStmt, err = db.PrepareContext(ctx, addCouponProduct); err != nil {
+		return nil, fmt.Errorf("error preparing query AddCouponProduct: %w", err)
+	}
+	if q.applyStripeDiscountStmt, err = db.PrepareContext(ctx, applyStripeDiscount); err != nil {
+		return nil, fmt.Errorf("error preparing query ApplyStripeDiscount: %w", err)
+	}
+	if q.applyStripeTaxStmt, err = db.PrepareContext(ctx, applyStripeTax); err != nil {
+		return nil, fmt.Errorf("error preparing query ApplyStripeTax: %w", err)
+	}
+	if q.archiveCouponStmt, err = db.PrepareContext(ctx, archiveCoupon); err != nil {
+		return nil, fmt.Errorf("error preparing query ArchiveCoupon: %w", err)
+	}
+	if q.archiveTaxRateStmt, err = db.PrepareContext(ctx, archiveTaxRate); err != nil {
+		return nil, fmt.Errorf("error preparing query ArchiveTaxRate: %w", err)
+	}
+	if q.cancelPendingTransactionStmt, err = db.PrepareContext(ctx, cancelPendingTransaction); err != nil {
+		return nil, fmt.Errorf("error preparing query CancelPendingTransaction: %w", err)
+	}
+	if q.claimOutboxMessageStmt, err = db.PrepareContext(ctx, claimOutboxMessage); err != nil {
+		return nil, fmt.Errorf("error preparing query ClaimOutboxMessage: %w", err)
+	}
+	if q.claimWebhookDeliveryStmt, err = db.PrepareContext(ctx, claimWebhookDelivery); err != nil {
+		return nil, fmt.Errorf("error preparing query ClaimWebhookDelivery: %w", err)
+	}
+	if q.countActiveTaxRatesStmt, err = db.PrepareContext(ctx, countActiveTaxRates); err != nil {
+		return nil, fmt.Errorf("error preparing query CountActiveTaxRates: %w", err)
+	}
+	if q.countCouponRedemptionsStmt, err = db.PrepareContext(ctx, countCouponRedemptions); err != nil {
+		return nil, fmt.Errorf("error preparing query CountCouponRedemptions: %w", err)
+	}
+	if q.countCouponRedemptionsByUserStmt, err = db.PrepareContext(ctx, countCouponRedemptionsByUser); err != nil {
+		return nil, fmt.Errorf("error preparing query CountCouponRedemptionsByUser: %w", err)
+	}
 	if q.createAuditEventStmt, err = db.PrepareContext(ctx, createAuditEvent); err != nil {
 		return nil, fmt.Errorf("error preparing query CreateAuditEvent: %w", err)
 	}
+	if q.createConnectedAccountStmt, err = db.PrepareContext(ctx, createConnectedAccount); err != nil {
+		return nil, fmt.Errorf("error preparing query CreateConnectedAccount: %w", err)
+	}
+	if q.createCouponStmt, err = db.PrepareContext(ctx, createCoupon); err != nil {
+		return nil, fmt.Errorf("error preparing query CreateCoupon: %w", err)
+	}
+	if q.createJournalEntryStmt, err = db.PrepareContext(ctx, createJournalEntry); err != nil {
+		return nil, fmt.Errorf("error preparing query CreateJournalEntry: %w", err)
+	}
+	if q.createLedgerPostingStmt, err = db.PrepareContext(ctx, createLedgerPosting); err != nil {
+		return nil, fmt.Errorf("error preparing query CreateLedgerPosting: %w", err)
+	}
+	if q.createOutboxMessageStmt, err = db.PrepareContext(ctx, createOutboxMessage); err != nil {
+		return nil, fmt.Errorf("error preparing query CreateOutboxMessage: %w", err)
+	}
+	if q.createTaxRateStmt, err = db.PrepareContext(ctx, createTaxRate); err != nil {
+		return nil, fmt.Errorf("error preparing query CreateTaxRate: %w", err)
+	}
 	if q.createTransactionStmt, err = db.PrepareContext(ctx, createTransaction); err != nil {
 		return nil, fmt.Errorf("error preparing query CreateTransaction: %w", err)
 	}
+	if q.createTransactionLineItemStmt, err = db.PrepareContext(ctx, createTransactionLineItem); err != nil {
+		return nil, fmt.Errorf("error preparing query CreateTransactionLineItem: %w", err)
+	}
+	if q.createWebhookDeliveryStmt, err = db.PrepareContext(ctx, createWebhookDelivery); err != nil {
+		return nil, fmt.Errorf("error preparing query CreateWebhookDelivery: %w", err)
+	}
+	if q.createWebhookDeliveryAttemptStmt, err = db.PrepareContext(ctx, createWebhookDeliveryAttempt); err != nil {
+		return nil, fmt.Errorf("error preparing query CreateWebhookDeliveryAttempt: %w", err)
+	}
+	if q.createWebhookEndpointStmt, err = db.PrepareContext(ctx, createWebhookEndpoint); err != nil {
+		return nil, fmt.Errorf("error preparing query CreateWebhookEndpoint: %w", err)
+	}
+	if q.createWebhookInboxEntryStmt, err = db.PrepareContext(ctx, createWebhookInboxEntry); err != nil {
+		return nil, fmt.Errorf("error preparing query CreateWebhookInboxEntry: %w", err)
+	}
 	if q.deleteCacheKeyStmt, err = db.PrepareContext(ctx, deleteCacheKey); err != nil {
 		return nil, fmt.Errorf("error preparing query DeleteCacheKey: %w", err)
 	}
+	if q.deleteCouponProductsStmt, err = db.PrepareContext(ctx, deleteCouponProducts); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteCouponProducts: %w", err)
+	}
+	if q.deleteProductAccountStmt, err = db.PrepareContext(ctx, deleteProductAccount); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteProductAccount: %w", err)
+	}
+	if q.deletePublishedOutboxMessagesStmt, err = db.PrepareContext(ctx, deletePublishedOutboxMessages); err != nil {
+		return nil, fmt.Errorf("error preparing query DeletePublishedOutboxMessages: %w", err)
+	}
+	if q.ensureLedgerAccountStmt, err = db.PrepareContext(ctx, ensureLedgerAccount); err != nil {
+		return nil, fmt.Errorf("error preparing query EnsureLedgerAccount: %w", err)
+	}
 	if q.getAllAuditEventsStmt, err = db.PrepareContext(ctx, getAllAuditEvents); err != nil {
 		return nil, fmt.Errorf("error preparing query GetAllAuditEvents: %w", err)
 	}
+	if q.getAuditEventsAfterIDStmt, err = db.PrepareContext(ctx, getAuditEventsAfterID); err != nil {
+		return nil, fmt.Errorf("error preparing query GetAuditEventsAfterID: %w", err)
+	}
 	if q.getAuditEventsByEventTypeStmt, err = db.PrepareContext(ctx, getAuditEventsByEventType); err != nil {
 		return nil, fmt.Errorf("error preparing query GetAuditEventsByEventType: %w", err)
 	}
@@ -60,24 +141,168 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	if q.getCacheValueStmt, err = db.PrepareContext(ctx, getCacheValue); err != nil {
 		return nil, fmt.Errorf("error preparing query GetCacheValue: %w", err)
 	}
+	if q.getConnectedAccountStmt, err = db.PrepareContext(ctx, getConnectedAccount); err != nil {
+		return nil, fmt.Errorf("error preparing query GetConnectedAccount: %w", err)
+	}
+	if q.getConnectedAccountByStripeIDStmt, err = db.PrepareContext(ctx, getConnectedAccountByStripeID); err != nil {
+		return nil, fmt.Errorf("error preparing query GetConnectedAccountByStripeID: %w", err)
+	}
+	if q.getCouponStmt, err = db.PrepareContext(ctx, getCoupon); err != nil {
+		return nil, fmt.Errorf("error preparing query GetCoupon: %w", err)
+	}
+	if q.getCouponByCodeStmt, err = db.PrepareContext(ctx, getCouponByCode); err != nil {
+		return nil, fmt.Errorf("error preparing query GetCouponByCode: %w", err)
+	}
+	if q.getCouponByStripePromotionCodeIDStmt, err = db.PrepareContext(ctx, getCouponByStripePromotionCodeID); err != nil {
+		return nil, fmt.Errorf("error preparing query GetCouponByStripePromotionCodeID: %w", err)
+	}
+	if q.getOpenTransactionForCartStmt, err = db.PrepareContext(ctx, getOpenTransactionForCart); err != nil {
+		return nil, fmt.Errorf("error preparing query GetOpenTransactionForCart: %w", err)
+	}
+	if q.getOutboxMessageStmt, err = db.PrepareContext(ctx, getOutboxMessage); err != nil {
+		return nil, fmt.Errorf("error preparing query GetOutboxMessage: %w", err)
+	}
+	if q.getProductConnectedAccountStmt, err = db.PrepareContext(ctx, getProductConnectedAccount); err != nil {
+		return nil, fmt.Errorf("error preparing query GetProductConnectedAccount: %w", err)
+	}
+	if q.getTaxRateStmt, err = db.PrepareContext(ctx, getTaxRate); err != nil {
+		return nil, fmt.Errorf("error preparing query GetTaxRate: %w", err)
+	}
 	if q.getTransactionStmt, err = db.PrepareContext(ctx, getTransaction); err != nil {
 		return nil, fmt.Errorf("error preparing query GetTransaction: %w", err)
 	}
+	if q.getTransactionByPaymentIntentIDStmt, err = db.PrepareContext(ctx, getTransactionByPaymentIntentID); err != nil {
+		return nil, fmt.Errorf("error preparing query GetTransactionByPaymentIntentID: %w", err)
+	}
 	if q.getTransactionByStripeSessionIDStmt, err = db.PrepareContext(ctx, getTransactionByStripeSessionID); err != nil {
 		return nil, fmt.Errorf("error preparing query GetTransactionByStripeSessionID: %w", err)
 	}
+	if q.getWebhookDeliveryStmt, err = db.PrepareContext(ctx, getWebhookDelivery); err != nil {
+		return nil, fmt.Errorf("error preparing query GetWebhookDelivery: %w", err)
+	}
+	if q.getWebhookEndpointStmt, err = db.PrepareContext(ctx, getWebhookEndpoint); err != nil {
+		return nil, fmt.Errorf("error preparing query GetWebhookEndpoint: %w", err)
+	}
+	if q.importAuditEventStmt, err = db.PrepareContext(ctx, importAuditEvent); err != nil {
+		return nil, fmt.Errorf("error preparing query ImportAuditEvent: %w", err)
+	}
+	if q.listActiveTaxRatesByCountryStmt, err = db.PrepareContext(ctx, listActiveTaxRatesByCountry); err != nil {
+		return nil, fmt.Errorf("error preparing query ListActiveTaxRatesByCountry: %w", err)
+	}
+	if q.listActiveWebhookEndpointsStmt, err = db.PrepareContext(ctx, listActiveWebhookEndpoints); err != nil {
+		return nil, fmt.Errorf("error preparing query ListActiveWebhookEndpoints: %w", err)
+	}
 	if q.listAllTransactionsStmt, err = db.PrepareContext(ctx, listAllTransactions); err != nil {
 		return nil, fmt.Errorf("error preparing query ListAllTransactions: %w", err)
 	}
+	if q.listAuditEventsStmt, err = db.PrepareContext(ctx, listAuditEvents); err != nil {
+		return nil, fmt.Errorf("error preparing query ListAuditEvents: %w", err)
+	}
+	if q.listAuditEventsByRefsStmt, err = db.PrepareContext(ctx, listAuditEventsByRefs); err != nil {
+		return nil, fmt.Errorf("error preparing query ListAuditEventsByRefs: %w", err)
+	}
+	if q.listAuditEventsForExportStmt, err = db.PrepareContext(ctx, listAuditEventsForExport); err != nil {
+		return nil, fmt.Errorf("error preparing query ListAuditEventsForExport: %w", err)
+	}
 	if q.listCacheStmt, err = db.PrepareContext(ctx, listCache); err != nil {
 		return nil, fmt.Errorf("error preparing query ListCache: %w", err)
 	}
+	if q.listConnectedAccountsStmt, err = db.PrepareContext(ctx, listConnectedAccounts); err != nil {
+		return nil, fmt.Errorf("error preparing query ListConnectedAccounts: %w", err)
+	}
+	if q.listCouponProductsStmt, err = db.PrepareContext(ctx, listCouponProducts); err != nil {
+		return nil, fmt.Errorf("error preparing query ListCouponProducts: %w", err)
+	}
+	if q.listCouponsStmt, err = db.PrepareContext(ctx, listCoupons); err != nil {
+		return nil, fmt.Errorf("error preparing query ListCoupons: %w", err)
+	}
+	if q.listDueOutboxMessagesStmt, err = db.PrepareContext(ctx, listDueOutboxMessages); err != nil {
+		return nil, fmt.Errorf("error preparing query ListDueOutboxMessages: %w", err)
+	}
+	if q.listDueWebhookDeliveriesStmt, err = db.PrepareContext(ctx, listDueWebhookDeliveries); err != nil {
+		return nil, fmt.Errorf("error preparing query ListDueWebhookDeliveries: %w", err)
+	}
+	if q.listJournalEntriesByTransactionIDStmt, err = db.PrepareContext(ctx, listJournalEntriesByTransactionID); err != nil {
+		return nil, fmt.Errorf("error preparing query ListJournalEntriesByTransactionID: %w", err)
+	}
+	if q.listLedgerAccountBalancesStmt, err = db.PrepareContext(ctx, listLedgerAccountBalances); err != nil {
+		return nil, fmt.Errorf("error preparing query ListLedgerAccountBalances: %w", err)
+	}
+	if q.listLedgerUserBalancesStmt, err = db.PrepareContext(ctx, listLedgerUserBalances); err != nil {
+		return nil, fmt.Errorf("error preparing query ListLedgerUserBalances: %w", err)
+	}
+	if q.listPendingTransactionsCreatedBeforeStmt, err = db.PrepareContext(ctx, listPendingTransactionsCreatedBefore); err != nil {
+		return nil, fmt.Errorf("error preparing query ListPendingTransactionsCreatedBefore: %w", err)
+	}
+	if q.listProductAccountsStmt, err = db.PrepareContext(ctx, listProductAccounts); err != nil {
+		return nil, fmt.Errorf("error preparing query ListProductAccounts: %w", err)
+	}
+	if q.listTaxRatesStmt, err = db.PrepareContext(ctx, listTaxRates); err != nil {
+		return nil, fmt.Errorf("error preparing query ListTaxRates: %w", err)
+	}
+	if q.listTransactionLineItemsStmt, err = db.PrepareContext(ctx, listTransactionLineItems); err != nil {
+		return nil, fmt.Errorf("error preparing query ListTransactionLineItems: %w", err)
+	}
 	if q.listTransactionsByUserIDStmt, err = db.PrepareContext(ctx, listTransactionsByUserID); err != nil {
 		return nil, fmt.Errorf("error preparing query ListTransactionsByUserID: %w", err)
 	}
+	if q.listTransactionsCreatedBetweenStmt, err = db.PrepareContext(ctx, listTransactionsCreatedBetween); err != nil {
+		return nil, fmt.Errorf("error preparing query ListTransactionsCreatedBetween: %w", err)
+	}
+	if q.listWebhookDeliveriesByEndpointStmt, err = db.PrepareContext(ctx, listWebhookDeliveriesByEndpoint); err != nil {
+		return nil, fmt.Errorf("error preparing query ListWebhookDeliveriesByEndpoint: %w", err)
+	}
+	if q.listWebhookDeliveryAttemptsStmt, err = db.PrepareContext(ctx, listWebhookDeliveryAttempts); err != nil {
+		return nil, fmt.Errorf("error preparing query ListWebhookDeliveryAttempts: %w", err)
+	}
+	if q.listWebhookEndpointsStmt, err = db.PrepareContext(ctx, listWebhookEndpoints); err != nil {
+		return nil, fmt.Errorf("error preparing query ListWebhookEndpoints: %w", err)
+	}
+	if q.listWebhookInboxByRefsStmt, err = db.PrepareContext(ctx, listWebhookInboxByRefs); err != nil {
+		return nil, fmt.Errorf("error preparing query ListWebhookInboxByRefs: %w", err)
+	}
+	if q.lockCouponStmt, err = db.PrepareContext(ctx, lockCoupon); err != nil {
+		return nil, fmt.Errorf("error preparing query LockCoupon: %w", err)
+	}
+	if q.markOutboxMessagePublishedStmt, err = db.PrepareContext(ctx, markOutboxMessagePublished); err != nil {
+		return nil, fmt.Errorf("error preparing query MarkOutboxMessagePublished: %w", err)
+	}
+	if q.recordOutboxMessageFailureStmt, err = db.PrepareContext(ctx, recordOutboxMessageFailure); err != nil {
+		return nil, fmt.Errorf("error preparing query RecordOutboxMessageFailure: %w", err)
+	}
+	if q.recordWebhookDeliveryResultStmt, err = db.PrepareContext(ctx, recordWebhookDeliveryResult); err != nil {
+		return nil, fmt.Errorf("error preparing query RecordWebhookDeliveryResult: %w", err)
+	}
+	if q.repairTransactionStmt, err = db.PrepareContext(ctx, repairTransaction); err != nil {
+		return nil, fmt.Errorf("error preparing query RepairTransaction: %w", err)
+	}
+	if q.requeueOutboxMessageStmt, err = db.PrepareContext(ctx, requeueOutboxMessage); err != nil {
+		return nil, fmt.Errorf("error preparing query RequeueOutboxMessage: %w", err)
+	}
+	if q.resetWebhookDeliveryStmt, err = db.PrepareContext(ctx, resetWebhookDelivery); err != nil {
+		return nil, fmt.Errorf("error preparing query ResetWebhookDelivery: %w", err)
+	}
 	if q.setCacheValueStmt, err = db.PrepareContext(ctx, setCacheValue); err != nil {
 		return nil, fmt.Errorf("error preparing query SetCacheValue: %w", err)
 	}
+	if q.setProductAccountStmt, err = db.PrepareContext(ctx, setProductAccount); err != nil {
+		return nil, fmt.Errorf("error preparing query SetProductAccount: %w", err)
+	}
+	if q.setTransactionApplicationFeeStmt, err = db.PrepareContext(ctx, setTransactionApplicationFee); err != nil {
+		return nil, fmt.Errorf("error preparing query SetTransactionApplicationFee: %w", err)
+	}
+	if q.updateConnectedAccountFeeStmt, err = db.PrepareContext(ctx, updateConnectedAccountFee); err != nil {
+		return nil, fmt.Errorf("error preparing query UpdateConnectedAccountFee: %w", err)
+	}
+	if q.updateConnectedAccountStatusStmt, err = db.PrepareContext(ctx, updateConnectedAccountStatus); err != nil {
+		return nil, fmt.Errorf("error preparing query UpdateConnectedAccountStatus: %w", err)
+	}
+	if q.updateCouponStmt, err = db.PrepareContext(ctx, updateCoupon); err != nil {
+		return nil, fmt.Errorf("error preparing query UpdateCoupon: %w", err)
+	}
+	if q.updateLineItemAdjustmentsStmt, err = db.PrepareContext(ctx, updateLineItemAdjustments); err != nil {
+		return nil, fmt.Errorf("error preparing query UpdateLineItemAdjustments: %w", err)
+	}
 	if q.updateTransactionByPaymentIntentIDStmt, err = db.PrepareContext(ctx, updateTransactionByPaymentIntentID); err != nil {
 		return nil, fmt.Errorf("error preparing query UpdateTransactionByPaymentIntentID: %w", err)
 	}
@@ -90,31 +315,172 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	if q.updateTransactionWithStripeDataStmt, err = db.PrepareContext(ctx, updateTransactionWithStripeData); err != nil {
 		return nil, fmt.Errorf("error preparing query UpdateTransactionWithStripeData: %w", err)
 	}
+	if q.updateWebhookEndpointStmt, err = db.PrepareContext(ctx, updateWebhookEndpoint); err != nil {
+		return nil, fmt.Errorf("error preparing query UpdateWebhookEndpoint: %w", err)
+	}
+	if q.updateWebhookInboxStatusStmt, err = db.PrepareContext(ctx, updateWebhookInboxStatus); err != nil {
+		return nil, fmt.Errorf("error preparing query UpdateWebhookInboxStatus: %w", err)
+	}
 	return &q, nil
 }
 
 func (q *Queries) Close() error {
 	var err error
+	if q.addCouponProductStmt != nil {
+		if cerr := q.addCouponProductStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing addCouponProductStmt: %w", cerr)
+		}
+	}
+	if q.applyStripeDiscountStmt != nil {
+		if cerr := q.applyStripeDiscountStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing applyStripeDiscountStmt: %w", cerr)
+		}
+	}
+	if q.applyStripeTaxStmt != nil {
+		if cerr := q.applyStripeTaxStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing applyStripeTaxStmt: %w", cerr)
+		}
+	}
+	if q.archiveCouponStmt != nil {
+		if cerr := q.archiveCouponStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing archiveCouponStmt: %w", cerr)
+		}
+	}
+	if q.archiveTaxRateStmt != nil {
+		if cerr := q.archiveTaxRateStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing archiveTaxRateStmt: %w", cerr)
+		}
+	}
+	if q.cancelPendingTransactionStmt != nil {
+		if cerr := q.cancelPendingTransactionStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing cancelPendingTransactionStmt: %w", cerr)
+		}
+	}
+	if q.claimOutboxMessageStmt != nil {
+		if cerr := q.claimOutboxMessageStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing claimOutboxMessageStmt: %w", cerr)
+		}
+	}
+	if q.claimWebhookDeliveryStmt != nil {
+		if cerr := q.claimWebhookDeliveryStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing claimWebhookDeliveryStmt: %w", cerr)
+		}
+	}
+	if q.countActiveTaxRatesStmt != nil {
+		if cerr := q.countActiveTaxRatesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing countActiveTaxRatesStmt: %w", cerr)
+		}
+	}
+	if q.countCouponRedemptionsStmt != nil {
+		if cerr := q.countCouponRedemptionsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing countCouponRedemptionsStmt: %w", cerr)
+		}
+	}
+	if q.countCouponRedemptionsByUserStmt != nil {
+		if cerr := q.countCouponRedemptionsByUserStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing countCouponRedemptionsByUserStmt: %w", cerr)
+		}
+	}
 	if q.createAuditEventStmt != nil {
 		if cerr := q.createAuditEventStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing createAuditEventStmt: %w", cerr)
 		}
 	}
+	if q.createConnectedAccountStmt != nil {
+		if cerr := q.createConnectedAccountStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing createConnectedAccountStmt: %w", cerr)
+		}
+	}
+	if q.createCouponStmt != nil {
+		if cerr := q.createCouponStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing createCouponStmt: %w", cerr)
+		}
+	}
+	if q.createJournalEntryStmt != nil {
+		if cerr := q.createJournalEntryStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing createJournalEntryStmt: %w", cerr)
+		}
+	}
+	if q.createLedgerPostingStmt != nil {
+		if cerr := q.createLedgerPostingStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing createLedgerPostingStmt: %w", cerr)
+		}
+	}
+	if q.createOutboxMessageStmt != nil {
+		if cerr := q.createOutboxMessageStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing createOutboxMessageStmt: %w", cerr)
+		}
+	}
+	if q.createTaxRateStmt != nil {
+		if cerr := q.createTaxRateStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing createTaxRateStmt: %w", cerr)
+		}
+	}
 	if q.createTransactionStmt != nil {
 		if cerr := q.createTransactionStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing createTransactionStmt: %w", cerr)
 		}
 	}
+	if q.createTransactionLineItemStmt != nil {
+		if cerr := q.createTransactionLineItemStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing createTransactionLineItemStmt: %w", cerr)
+		}
+	}
+	if q.createWebhookDeliveryStmt != nil {
+		if cerr := q.createWebhookDeliveryStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing createWebhookDeliveryStmt: %w", cerr)
+		}
+	}
+	if q.createWebhookDeliveryAttemptStmt != nil {
+		if cerr := q.createWebhookDeliveryAttemptStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing createWebhookDeliveryAttemptStmt: %w", cerr)
+		}
+	}
+	if q.createWebhookEndpointStmt != nil {
+		if cerr := q.createWebhookEndpointStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing createWebhookEndpointStmt: %w", cerr)
+		}
+	}
+	if q.createWebhookInboxEntryStmt != nil {
+		if cerr := q.createWebhookInboxEntryStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing createWebhookInboxEntryStmt: %w", cerr)
+		}
+	}
 	if q.deleteCacheKeyStmt != nil {
 		if cerr := q.deleteCacheKeyStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing deleteCacheKeyStmt: %w", cerr)
 		}
 	}
+	if q.deleteCouponProductsStmt != nil {
+		if cerr := q.deleteCouponProductsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteCouponProductsStmt: %w", cerr)
+		}
+	}
+	if q.deleteProductAccountStmt != nil {
+		if cerr := q.deleteProductAccountStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteProductAccountStmt: %w", cerr)
+		}
+	}
+	if q.deletePublishedOutboxMessagesStmt != nil {
+		if cerr := q.deletePublishedOutboxMessagesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deletePublishedOutboxMessagesStmt: %w", cerr)
+		}
+	}
+	if q.ensureLedgerAccountStmt != nil {
+		if cerr := q.ensureLedgerAccountStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing ensureLedgerAccountStmt: %w", cerr)
+		}
+	}
 	if q.getAllAuditEventsStmt != nil {
 		if cerr := q.getAllAuditEventsStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing getAllAuditEventsStmt: %w", cerr)
 		}
 	}
+	if q.getAuditEventsAfterIDStmt != nil {
+		if cerr := q.getAuditEventsAfterIDStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getAuditEventsAfterIDStmt: %w", cerr)
+		}
+	}
 	if q.getAuditEventsByEventTypeStmt != nil {
 		if cerr := q.getAuditEventsByEventTypeStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing getAuditEventsByEventTypeStmt: %w", cerr)
@@ -155,36 +521,276 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing getCacheValueStmt: %w", cerr)
 		}
 	}
+	if q.getConnectedAccountStmt != nil {
+		if cerr := q.getConnectedAccountStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getConnectedAccountStmt: %w", cerr)
+		}
+	}
+	if q.getConnectedAccountByStripeIDStmt != nil {
+		if cerr := q.getConnectedAccountByStripeIDStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getConnectedAccountByStripeIDStmt: %w", cerr)
+		}
+	}
+	if q.getCouponStmt != nil {
+		if cerr := q.getCouponStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getCouponStmt: %w", cerr)
+		}
+	}
+	if q.getCouponByCodeStmt != nil {
+		if cerr := q.getCouponByCodeStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getCouponByCodeStmt: %w", cerr)
+		}
+	}
+	if q.getCouponByStripePromotionCodeIDStmt != nil {
+		if cerr := q.getCouponByStripePromotionCodeIDStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getCouponByStripePromotionCodeIDStmt: %w", cerr)
+		}
+	}
+	if q.getOpenTransactionForCartStmt != nil {
+		if cerr := q.getOpenTransactionForCartStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getOpenTransactionForCartStmt: %w", cerr)
+		}
+	}
+	if q.getOutboxMessageStmt != nil {
+		if cerr := q.getOutboxMessageStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getOutboxMessageStmt: %w", cerr)
+		}
+	}
+	if q.getProductConnectedAccountStmt != nil {
+		if cerr := q.getProductConnectedAccountStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getProductConnectedAccountStmt: %w", cerr)
+		}
+	}
+	if q.getTaxRateStmt != nil {
+		if cerr := q.getTaxRateStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getTaxRateStmt: %w", cerr)
+		}
+	}
 	if q.getTransactionStmt != nil {
 		if cerr := q.getTransactionStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing getTransactionStmt: %w", cerr)
 		}
 	}
+	if q.getTransactionByPaymentIntentIDStmt != nil {
+		if cerr := q.getTransactionByPaymentIntentIDStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getTransactionByPaymentIntentIDStmt: %w", cerr)
+		}
+	}
 	if q.getTransactionByStripeSessionIDStmt != nil {
 		if cerr := q.getTransactionByStripeSessionIDStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing getTransactionByStripeSessionIDStmt: %w", cerr)
 		}
 	}
+	if q.getWebhookDeliveryStmt != nil {
+		if cerr := q.getWebhookDeliveryStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getWebhookDeliveryStmt: %w", cerr)
+		}
+	}
+	if q.getWebhookEndpointStmt != nil {
+		if cerr := q.getWebhookEndpointStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getWebhookEndpointStmt: %w", cerr)
+		}
+	}
+	if q.importAuditEventStmt != nil {
+		if cerr := q.importAuditEventStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing importAuditEventStmt: %w", cerr)
+		}
+	}
+	if q.listActiveTaxRatesByCountryStmt != nil {
+		if cerr := q.listActiveTaxRatesByCountryStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listActiveTaxRatesByCountryStmt: %w", cerr)
+		}
+	}
+	if q.listActiveWebhookEndpointsStmt != nil {
+		if cerr := q.listActiveWebhookEndpointsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listActiveWebhookEndpointsStmt: %w", cerr)
+		}
+	}
 	if q.listAllTransactionsStmt != nil {
 		if cerr := q.listAllTransactionsStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing listAllTransactionsStmt: %w", cerr)
 		}
 	}
+	if q.listAuditEventsStmt != nil {
+		if cerr := q.listAuditEventsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listAuditEventsStmt: %w", cerr)
+		}
+	}
+	if q.listAuditEventsByRefsStmt != nil {
+		if cerr := q.listAuditEventsByRefsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listAuditEventsByRefsStmt: %w", cerr)
+		}
+	}
+	if q.listAuditEventsForExportStmt != nil {
+		if cerr := q.listAuditEventsForExportStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listAuditEventsForExportStmt: %w", cerr)
+		}
+	}
 	if q.listCacheStmt != nil {
 		if cerr := q.listCacheStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing listCacheStmt: %w", cerr)
 		}
 	}
+	if q.listConnectedAccountsStmt != nil {
+		if cerr := q.listConnectedAccountsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listConnectedAccountsStmt: %w", cerr)
+		}
+	}
+	if q.listCouponProductsStmt != nil {
+		if cerr := q.listCouponProductsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listCouponProductsStmt: %w", cerr)
+		}
+	}
+	if q.listCouponsStmt != nil {
+		if cerr := q.listCouponsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listCouponsStmt: %w", cerr)
+		}
+	}
+	if q.listDueOutboxMessagesStmt != nil {
+		if cerr := q.listDueOutboxMessagesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listDueOutboxMessagesStmt: %w", cerr)
+		}
+	}
+	if q.listDueWebhookDeliveriesStmt != nil {
+		if cerr := q.listDueWebhookDeliveriesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listDueWebhookDeliveriesStmt: %w", cerr)
+		}
+	}
+	if q.listJournalEntriesByTransactionIDStmt != nil {
+		if cerr := q.listJournalEntriesByTransactionIDStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listJournalEntriesByTransactionIDStmt: %w", cerr)
+		}
+	}
+	if q.listLedgerAccountBalancesStmt != nil {
+		if cerr := q.listLedgerAccountBalancesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listLedgerAccountBalancesStmt: %w", cerr)
+		}
+	}
+	if q.listLedgerUserBalancesStmt != nil {
+		if cerr := q.listLedgerUserBalancesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listLedgerUserBalancesStmt: %w", cerr)
+		}
+	}
+	if q.listPendingTransactionsCreatedBeforeStmt != nil {
+		if cerr := q.listPendingTransactionsCreatedBeforeStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listPendingTransactionsCreatedBeforeStmt: %w", cerr)
+		}
+	}
+	if q.listProductAccountsStmt != nil {
+		if cerr := q.listProductAccountsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listProductAccountsStmt: %w", cerr)
+		}
+	}
+	if q.listTaxRatesStmt != nil {
+		if cerr := q.listTaxRatesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listTaxRatesStmt: %w", cerr)
+		}
+	}
+	if q.listTransactionLineItemsStmt != nil {
+		if cerr := q.listTransactionLineItemsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listTransactionLineItemsStmt: %w", cerr)
+		}
+	}
 	if q.listTransactionsByUserIDStmt != nil {
 		if cerr := q.listTransactionsByUserIDStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing listTransactionsByUserIDStmt: %w", cerr)
 		}
 	}
+	if q.listTransactionsCreatedBetweenStmt != nil {
+		if cerr := q.listTransactionsCreatedBetweenStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listTransactionsCreatedBetweenStmt: %w", cerr)
+		}
+	}
+	if q.listWebhookDeliveriesByEndpointStmt != nil {
+		if cerr := q.listWebhookDeliveriesByEndpointStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listWebhookDeliveriesByEndpointStmt: %w", cerr)
+		}
+	}
+	if q.listWebhookDeliveryAttemptsStmt != nil {
+		if cerr := q.listWebhookDeliveryAttemptsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listWebhookDeliveryAttemptsStmt: %w", cerr)
+		}
+	}
+	if q.listWebhookEndpointsStmt != nil {
+		if cerr := q.listWebhookEndpointsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listWebhookEndpointsStmt: %w", cerr)
+		}
+	}
+	if q.listWebhookInboxByRefsStmt != nil {
+		if cerr := q.listWebhookInboxByRefsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listWebhookInboxByRefsStmt: %w", cerr)
+		}
+	}
+	if q.lockCouponStmt != nil {
+		if cerr := q.lockCouponStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing lockCouponStmt: %w", cerr)
+		}
+	}
+	if q.markOutboxMessagePublishedStmt != nil {
+		if cerr := q.markOutboxMessagePublishedStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing markOutboxMessagePublishedStmt: %w", cerr)
+		}
+	}
+	if q.recordOutboxMessageFailureStmt != nil {
+		if cerr := q.recordOutboxMessageFailureStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing recordOutboxMessageFailureStmt: %w", cerr)
+		}
+	}
+	if q.recordWebhookDeliveryResultStmt != nil {
+		if cerr := q.recordWebhookDeliveryResultStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing recordWebhookDeliveryResultStmt: %w", cerr)
+		}
+	}
+	if q.repairTransactionStmt != nil {
+		if cerr := q.repairTransactionStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing repairTransactionStmt: %w", cerr)
+		}
+	}
+	if q.requeueOutboxMessageStmt != nil {
+		if cerr := q.requeueOutboxMessageStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing requeueOutboxMessageStmt: %w", cerr)
+		}
+	}
+	if q.resetWebhookDeliveryStmt != nil {
+		if cerr := q.resetWebhookDeliveryStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing resetWebhookDeliveryStmt: %w", cerr)
+		}
+	}
 	if q.setCacheValueStmt != nil {
 		if cerr := q.setCacheValueStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing setCacheValueStmt: %w", cerr)
 		}
 	}
+	if q.setProductAccountStmt != nil {
+		if cerr := q.setProductAccountStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing setProductAccountStmt: %w", cerr)
+		}
+	}
+	if q.setTransactionApplicationFeeStmt != nil {
+		if cerr := q.setTransactionApplicationFeeStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing setTransactionApplicationFeeStmt: %w", cerr)
+		}
+	}
+	if q.updateConnectedAccountFeeStmt != nil {
+		if cerr := q.updateConnectedAccountFeeStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing updateConnectedAccountFeeStmt: %w", cerr)
+		}
+	}
+	if q.updateConnectedAccountStatusStmt != nil {
+		if cerr := q.updateConnectedAccountStatusStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing updateConnectedAccountStatusStmt: %w", cerr)
+		}
+	}
+	if q.updateCouponStmt != nil {
+		if cerr := q.updateCouponStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing updateCouponStmt: %w", cerr)
+		}
+	}
+	if q.updateLineItemAdjustmentsStmt != nil {
+		if cerr := q.updateLineItemAdjustmentsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing updateLineItemAdjustmentsStmt: %w", cerr)
+		}
+	}
 	if q.updateTransactionByPaymentIntentIDStmt != nil {
 		if cerr := q.updateTransactionByPaymentIntentIDStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing updateTransactionByPaymentIntentIDStmt: %w", cerr)
@@ -205,6 +811,16 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing updateTransactionWithStripeDataStmt: %w", cerr)
 		}
 	}
+	if q.updateWebhookEndpointStmt != nil {
+		if cerr := q.updateWebhookEndpointStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing updateWebhookEndpointStmt: %w", cerr)
+		}
+	}
+	if q.updateWebhookInboxStatusStmt != nil {
+		if cerr := q.updateWebhookInboxStatusStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing updateWebhookInboxStatusStmt: %w", cerr)
+		}
+	}
 	return err
 }
 
@@ -244,10 +860,37 @@ func (q *Queries) queryRow(ctx context.Context, stmt *sql.Stmt, query string, ar
 type Queries struct {
 	db                                                   DBTX
 	tx                                                   *sql.Tx
+	addCouponProductStmt                                 *sql.Stmt
+	applyStripeDiscountStmt                              *sql.Stmt
+	applyStripeTaxStmt                                   *sql.Stmt
+	archiveCouponStmt                                    *sql.Stmt
+	archiveTaxRateStmt                                   *sql.Stmt
+	cancelPendingTransactionStmt                         *sql.Stmt
+	claimOutboxMessageStmt                               *sql.Stmt
+	claimWebhookDeliveryStmt                             *sql.Stmt
+	countActiveTaxRatesStmt                              *sql.Stmt
+	countCouponRedemptionsStmt                           *sql.Stmt
+	countCouponRedemptionsByUserStmt                     *sql.Stmt
 	createAuditEventStmt                                 *sql.Stmt
+	createConnectedAccountStmt                           *sql.Stmt
+	createCouponStmt                                     *sql.Stmt
+	createJournalEntryStmt                               *sql.Stmt
+	createLedgerPostingStmt                              *sql.Stmt
+	createOutboxMessageStmt                              *sql.Stmt
+	createTaxRateStmt                                    *sql.Stmt
 	createTransactionStmt                                *sql.Stmt
+	createTransactionLineItemStmt                        *sql.Stmt
+	createWebhookDeliveryStmt                            *sql.Stmt
+	createWebhookDeliveryAttemptStmt                     *sql.Stmt
+	createWebhookEndpointStmt                            *sql.Stmt
+	createWebhookInboxEntryStmt                          *sql.Stmt
 	deleteCacheKeyStmt                                   *sql.Stmt
+	deleteCouponProductsStmt                             *sql.Stmt
+	deleteProductAccountStmt                             *sql.Stmt
+	deletePublishedOutboxMessagesStmt                    *sql.Stmt
+	ensureLedgerAccountStmt                              *sql.Stmt
 	getAllAuditEventsStmt                                *sql.Stmt
+	getAuditEventsAfterIDStmt                            *sql.Stmt
 	getAuditEventsByEventTypeStmt                        *sql.Stmt
 	getAuditEventsByRefIDStmt                            *sql.Stmt
 	getAuditEventsByRefID2Stmt                           *sql.Stmt
@@ -256,43 +899,170 @@ type Queries struct {
 	getAuditEventsByUserStmt                             *sql.Stmt
 	getAuditEventsInDateRangeStmt                        *sql.Stmt
 	getCacheValueStmt                                    *sql.Stmt
+	getConnectedAccountStmt                              *sql.Stmt
+	getConnectedAccountByStripeIDStmt                    *sql.Stmt
+	getCouponStmt                                        *sql.Stmt
+	getCouponByCodeStmt                                  *sql.Stmt
+	getCouponByStripePromotionCodeIDStmt                 *sql.Stmt
+	getOpenTransactionForCartStmt                        *sql.Stmt
+	getOutboxMessageStmt                                 *sql.Stmt
+	getProductConnectedAccountStmt                       *sql.Stmt
+	getTaxRateStmt                                       *sql.Stmt
 	getTransactionStmt                                   *sql.Stmt
+	getTransactionByPaymentIntentIDStmt                  *sql.Stmt
 	getTransactionByStripeSessionIDStmt                  *sql.Stmt
+	getWebhookDeliveryStmt                               *sql.Stmt
+	getWebhookEndpointStmt                               *sql.Stmt
+	importAuditEventStmt                                 *sql.Stmt
+	listActiveTaxRatesByCountryStmt                      *sql.Stmt
+	listActiveWebhookEndpointsStmt                       *sql.Stmt
 	listAllTransactionsStmt                              *sql.Stmt
+	listAuditEventsStmt                                  *sql.Stmt
+	listAuditEventsByRefsStmt                            *sql.Stmt
+	listAuditEventsForExportStmt                         *sql.Stmt
 	listCacheStmt                                        *sql.Stmt
+	listConnectedAccountsStmt                            *sql.Stmt
+	listCouponProductsStmt                               *sql.Stmt
+	listCouponsStmt                                      *sql.Stmt
+	listDueOutboxMessagesStmt                            *sql.Stmt
+	listDueWebhookDeliveriesStmt                         *sql.Stmt
+	listJournalEntriesByTransactionIDStmt                *sql.Stmt
+	listLedgerAccountBalancesStmt                        *sql.Stmt
+	listLedgerUserBalancesStmt                           *sql.Stmt
+	listPendingTransactionsCreatedBeforeStmt             *sql.Stmt
+	listProductAccountsStmt                              *sql.Stmt
+	listTaxRatesStmt                                     *sql.Stmt
+	listTransactionLineItemsStmt                         *sql.Stmt
 	listTransactionsByUserIDStmt                         *sql.Stmt
+	listTransactionsCreatedBetweenStmt                   *sql.Stmt
+	listWebhookDeliveriesByEndpointStmt                  *sql.Stmt
+	listWebhookDeliveryAttemptsStmt                      *sql.Stmt
+	listWebhookEndpointsStmt                             *sql.Stmt
+	listWebhookInboxByRefsStmt                           *sql.Stmt
+	lockCouponStmt                                       *sql.Stmt
+	markOutboxMessagePublishedStmt                       *sql.Stmt
+	recordOutboxMessageFailureStmt                       *sql.Stmt
+	recordWebhookDeliveryResultStmt                      *sql.Stmt
+	repairTransactionStmt                                *sql.Stmt
+	requeueOutboxMessageStmt                             *sql.Stmt
+	resetWebhookDeliveryStmt                             *sql.Stmt
 	setCacheValueStmt                                    *sql.Stmt
+	setProductAccountStmt                                *sql.Stmt
+	setTransactionApplicationFeeStmt                     *sql.Stmt
+	updateConnectedAccountFeeStmt                        *sql.Stmt
+	updateConnectedAccountStatusStmt                     *sql.Stmt
+	updateCouponStmt                                     *sql.Stmt
+	updateLineItemAdjustmentsStmt                        *sql.Stmt
 	updateTransactionByPaymentIntentIDStmt               *sql.Stmt
 	updateTransactionByPaymentIntentIDWithRefundDateStmt *sql.Stmt
 	updateTransactionStatusStmt                          *sql.Stmt
 	updateTransactionWithStripeDataStmt                  *sql.Stmt
+	updateWebhookEndpointStmt                            *sql.Stmt
+	updateWebhookInboxStatusStmt                         *sql.Stmt
 }
 
 func (q *Queries) WithTx(tx *sql.Tx) *Queries {
 	return &Queries{
-		db:                                     tx,
-		tx:                                     tx,
-		createAuditEventStmt:                   q.createAuditEventStmt,
-		createTransactionStmt:                  q.createTransactionStmt,
-		deleteCacheKeyStmt:                     q.deleteCacheKeyStmt,
-		getAllAuditEventsStmt:                  q.getAllAuditEventsStmt,
-		getAuditEventsByEventTypeStmt:          q.getAuditEventsByEventTypeStmt,
-		getAuditEventsByRefIDStmt:              q.getAuditEventsByRefIDStmt,
-		getAuditEventsByRefID2Stmt:             q.getAuditEventsByRefID2Stmt,
-		getAuditEventsBySubsystemStmt:          q.getAuditEventsBySubsystemStmt,
-		getAuditEventsBySubsystemAndTypeStmt:   q.getAuditEventsBySubsystemAndTypeStmt,
-		getAuditEventsByUserStmt:               q.getAuditEventsByUserStmt,
-		getAuditEventsInDateRangeStmt:          q.getAuditEventsInDateRangeStmt,
-		getCacheValueStmt:                      q.getCacheValueStmt,
-		getTransactionStmt:                     q.getTransactionStmt,
-		getTransactionByStripeSessionIDStmt:    q.getTransactionByStripeSessionIDStmt,
-		listAllTransactionsStmt:                q.listAllTransactionsStmt,
-		listCacheStmt:                          q.listCacheStmt,
-		listTransactionsByUserIDStmt:           q.listTransactionsByUserIDStmt,
-		setCacheValueStmt:                      q.setCacheValueStmt,
-		updateTransactionByPaymentIntentIDStmt: q.updateTransactionByPaymentIntentIDStmt,
+		db:                                                   tx,
+		tx:                                                   tx,
+		addCouponProductStmt:                                 q.addCouponProductStmt,
+		applyStripeDiscountStmt:                              q.applyStripeDiscountStmt,
+		applyStripeTaxStmt:                                   q.applyStripeTaxStmt,
+		archiveCouponStmt:                                    q.archiveCouponStmt,
+		archiveTaxRateStmt:                                   q.archiveTaxRateStmt,
+		cancelPendingTransactionStmt:                         q.cancelPendingTransactionStmt,
+		claimOutboxMessageStmt:                               q.claimOutboxMessageStmt,
+		claimWebhookDeliveryStmt:                             q.claimWebhookDeliveryStmt,
+		countActiveTaxRatesStmt:                              q.countActiveTaxRatesStmt,
+		countCouponRedemptionsStmt:                           q.countCouponRedemptionsStmt,
+		countCouponRedemptionsByUserStmt:                     q.countCouponRedemptionsByUserStmt,
+		createAuditEventStmt:                                 q.createAuditEventStmt,
+		createConnectedAccountStmt:                           q.createConnectedAccountStmt,
+		createCouponStmt:                                     q.createCouponStmt,
+		createJournalEntryStmt:                               q.createJournalEntryStmt,
+		createLedgerPostingStmt:                              q.createLedgerPostingStmt,
+		createOutboxMessageStmt:                              q.createOutboxMessageStmt,
+		createTaxRateStmt:                                    q.createTaxRateStmt,
+		createTransactionStmt:                                q.createTransactionStmt,
+		createTransactionLineItemStmt:                        q.createTransactionLineItemStmt,
+		createWebhookDeliveryStmt:                            q.createWebhookDeliveryStmt,
+		createWebhookDeliveryAttemptStmt:                     q.createWebhookDeliveryAttemptStmt,
+		createWebhookEndpointStmt:                            q.createWebhookEndpointStmt,
+		createWebhookInboxEntryStmt:                          q.createWebhookInboxEntryStmt,
+		deleteCacheKeyStmt:                                   q.deleteCacheKeyStmt,
+		deleteCouponProductsStmt:                             q.deleteCouponProductsStmt,
+		deleteProductAccountStmt:                             q.deleteProductAccountStmt,
+		deletePublishedOutboxMessagesStmt:                    q.deletePublishedOutboxMessagesStmt,
+		ensureLedgerAccountStmt:                              q.ensureLedgerAccountStmt,
+		getAllAuditEventsStmt:                                q.getAllAuditEventsStmt,
+		getAuditEventsAfterIDStmt:                            q.getAuditEventsAfterIDStmt,
+		getAuditEventsByEventTypeStmt:                        q.getAuditEventsByEventTypeStmt,
+		getAuditEventsByRefIDStmt:                            q.getAuditEventsByRefIDStmt,
+		getAuditEventsByRefID2Stmt:                           q.getAuditEventsByRefID2Stmt,
+		getAuditEventsBySubsystemStmt:                        q.getAuditEventsBySubsystemStmt,
+		getAuditEventsBySubsystemAndTypeStmt:                 q.getAuditEventsBySubsystemAndTypeStmt,
+		getAuditEventsByUserStmt:                             q.getAuditEventsByUserStmt,
+		getAuditEventsInDateRangeStmt:                        q.getAuditEventsInDateRangeStmt,
+		getCacheValueStmt:                                    q.getCacheValueStmt,
+		getConnectedAccountStmt:                              q.getConnectedAccountStmt,
+		getConnectedAccountByStripeIDStmt:                    q.getConnectedAccountByStripeIDStmt,
+		getCouponStmt:                                        q.getCouponStmt,
+		getCouponByCodeStmt:                                  q.getCouponByCodeStmt,
+		getCouponByStripePromotionCodeIDStmt:                 q.getCouponByStripePromotionCodeIDStmt,
+		getOpenTransactionForCartStmt:                        q.getOpenTransactionForCartStmt,
+		getOutboxMessageStmt:                                 q.getOutboxMessageStmt,
+		getProductConnectedAccountStmt:                       q.getProductConnectedAccountStmt,
+		getTaxRateStmt:                                       q.getTaxRateStmt,
+		getTransactionStmt:                                   q.getTransactionStmt,
+		getTransactionByPaymentIntentIDStmt:                  q.getTransactionByPaymentIntentIDStmt,
+		getTransactionByStripeSessionIDStmt:                  q.getTransactionByStripeSessionIDStmt,
+		getWebhookDeliveryStmt:                               q.getWebhookDeliveryStmt,
+		getWebhookEndpointStmt:                               q.getWebhookEndpointStmt,
+		importAuditEventStmt:                                 q.importAuditEventStmt,
+		listActiveTaxRatesByCountryStmt:                      q.listActiveTaxRatesByCountryStmt,
+		listActiveWebhookEndpointsStmt:                       q.listActiveWebhookEndpointsStmt,
+		listAllTransactionsStmt:                              q.listAllTransactionsStmt,
+		listAuditEventsStmt:                                  q.listAuditEventsStmt,
+		listAuditEventsByRefsStmt:                            q.listAuditEventsByRefsStmt,
+		listAuditEventsForExportStmt:                         q.listAuditEventsForExportStmt,
+		listCacheStmt:                                        q.listCacheStmt,
+		listConnectedAccountsStmt:                            q.listConnectedAccountsStmt,
+		listCouponProductsStmt:                               q.listCouponProductsStmt,
+		listCouponsStmt:                                      q.listCouponsStmt,
+		listDueOutboxMessagesStmt:                            q.listDueOutboxMessagesStmt,
+		listDueWebhookDeliveriesStmt:                         q.listDueWebhookDeliveriesStmt,
+		listJournalEntriesByTransactionIDStmt:                q.listJournalEntriesByTransactionIDStmt,
+		listLedgerAccountBalancesStmt:                        q.listLedgerAccountBalancesStmt,
+		listLedgerUserBalancesStmt:                           q.listLedgerUserBalancesStmt,
+		listPendingTransactionsCreatedBeforeStmt:             q.listPendingTransactionsCreatedBeforeStmt,
+		listProductAccountsStmt:                              q.listProductAccountsStmt,
+		listTaxRatesStmt:                                     q.listTaxRatesStmt,
+		listTransactionLineItemsStmt:                         q.listTransactionLineItemsStmt,
+		listTransactionsByUserIDStmt:                         q.listTransactionsByUserIDStmt,
+		listTransactionsCreatedBetweenStmt:                   q.listTransactionsCreatedBetweenStmt,
+		listWebhookDeliveriesByEndpointStmt:                  q.listWebhookDeliveriesByEndpointStmt,
+		listWebhookDeliveryAttemptsStmt:                      q.listWebhookDeliveryAttemptsStmt,
+		listWebhookEndpointsStmt:                             q.listWebhookEndpointsStmt,
+		listWebhookInboxByRefsStmt:                           q.listWebhookInboxByRefsStmt,
+		lockCouponStmt:                                       q.lockCouponStmt,
+		markOutboxMessagePublishedStmt:                       q.markOutboxMessagePublishedStmt,
+		recordOutboxMessageFailureStmt:                       q.recordOutboxMessageFailureStmt,
+		recordWebhookDeliveryResultStmt:                      q.recordWebhookDeliveryResultStmt,
+		repairTransactionStmt:                                q.repairTransactionStmt,
+		requeueOutboxMessageStmt:                             q.requeueOutboxMessageStmt,
+		resetWebhookDeliveryStmt:                             q.resetWebhookDeliveryStmt,
+		setCacheValueStmt:                                    q.setCacheValueStmt,
+		setProductAccountStmt:                                q.setProductAccountStmt,
+		setTransactionApplicationFeeStmt:                     q.setTransactionApplicationFeeStmt,
+		updateConnectedAccountFeeStmt:                        q.updateConnectedAccountFeeStmt,
+		updateConnectedAccountStatusStmt:                     q.updateConnectedAccountStatusStmt,
+		updateCouponStmt:                                     q.updateCouponStmt,
+		updateLineItemAdjustmentsStmt:                        q.updateLineItemAdjustmentsStmt,
+		updateTransactionByPaymentIntentIDStmt:               q.updateTransactionByPaymentIntentIDStmt,
 		updateTransactionByPaymentIntentIDWithRefundDateStmt: q.updateTransactionByPaymentIntentIDWithRefundDateStmt,
 		updateTransactionStatusStmt:                          q.updateTransactionStatusStmt,
 		updateTransactionWithStripeDataStmt:                  q.updateTransactionWithStripeDataStmt,
+		updateWebhookEndpointStmt:                            q.updateWebhookEndpointStmt,
+		updateWebhookInboxStatusStmt:                         q.updateWebhookInboxStatusStmt,
 	}
 }
@@ -1,69 +1,461 @@
 package db
 
 import (
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io/fs"
 	"path/filepath"
 	"sort"
+	"strings"
+
+	"stripe-go-spike/db/migrations"
 )
 
-// RunMigrations applies all .sql files in the given directory in lexical order.
-// It keeps track of executed scripts in a `_migrations` table and skips any that
-// have already been applied (matched by filename).
+// ErrMigrationModified is returned when an applied migration file no longer
+// matches the checksum recorded when it ran.
+var ErrMigrationModified = errors.New("applied migration was modified")
+
+// ErrIrreversible is returned when rolling back a migration without a down section.
+var ErrIrreversible = errors.New("migration has no down section")
+
+// downMarker starts the rollback section of a migration file. It is the
+// sql-migrate marker, which sqlc also recognises, so sqlc ignores the rollback
+// statements when it reads db/migrations as the schema.
+const downMarker = "-- +migrate down"
+
+// Migration is one .sql file from the migrations directory. Everything after a
+// `-- +migrate Down` line is the rollback script.
+type Migration struct {
+	Name     string
+	Checksum string // hex SHA-256 of the up section
+	SQL      string // up section
+	Down     string // down section, empty if the migration is irreversible
+}
+
+// MigrationRun is one recorded up or down run.
+type MigrationRun struct {
+	ID        int64
+	Name      string
+	Direction string // "up" or "down"
+	Checksum  string
+	Error     string // set when the run failed and was rolled back
+	RanAt     string
+}
+
+// MigrationStatus describes a migration file and/or an applied migration.
+type MigrationStatus struct {
+	Name       string
+	Applied    bool
+	AppliedAt  string
+	Modified   bool // applied checksum differs from the file
+	Missing    bool // applied but the file no longer exists
+	Reversible bool // the file has a down section
+}
+
+// Migrator applies and rolls back .sql files from a directory in lexical order.
+// It keeps track of applied scripts in a `_migrations` table together with their
+// checksums, and of every run in `_migration_runs`.
+type Migrator struct {
+	db      *sql.DB
+	dialect Dialect
+	fsys    fs.FS
+	dir     string
+}
+
+// NewMigrator returns a Migrator for the .sql files in dir of fsys.
+func NewMigrator(db *sql.DB, fsys fs.FS, dir string) *Migrator {
+	return &Migrator{db: db, dialect: DialectOf(db), fsys: fsys, dir: dir}
+}
+
+// NewEmbeddedMigrator returns a Migrator for the migrations embedded in the binary,
+// picking the directory that matches the connection's dialect.
+func NewEmbeddedMigrator(db *sql.DB) *Migrator {
+	return NewMigrator(db, migrations.FS, string(DialectOf(db)))
+}
+
+// RunMigrations applies all pending .sql files in the given directory in lexical
+// order, skipping any that have already been applied (matched by filename).
 func RunMigrations(db *sql.DB, migrationsFS fs.FS, dir string) error {
-	// Ensure the migrations tracking table exists
-	if _, err := db.Exec(`
-		CREATE TABLE IF NOT EXISTS _migrations (
-			name TEXT PRIMARY KEY,
-			applied_at TEXT NOT NULL DEFAULT (datetime('now'))
-		);
-	`); err != nil {
-		return fmt.Errorf("create _migrations table: %w", err)
-	}
+	_, err := NewMigrator(db, migrationsFS, dir).Up(context.Background(), 0)
+	return err
+}
 
-	entries, err := fs.Glob(migrationsFS, filepath.ToSlash(filepath.Join(dir, "*.sql")))
+// Migrations reads all migration files in lexical order.
+func (m *Migrator) Migrations() ([]Migration, error) {
+	entries, err := fs.Glob(m.fsys, filepath.ToSlash(filepath.Join(m.dir, "*.sql")))
 	if err != nil {
-		return fmt.Errorf("glob migrations: %w", err)
+		return nil, fmt.Errorf("glob migrations: %w", err)
 	}
 	sort.Strings(entries)
+	migrations := make([]Migration, 0, len(entries))
 	for _, name := range entries {
-		base := filepath.Base(name)
-
-		// Check if migration was already applied
-		var exists int
-		row := db.QueryRow("SELECT 1 FROM _migrations WHERE name = ? LIMIT 1", base)
-		scanErr := row.Scan(&exists)
-		if scanErr == nil {
-			// already applied
+		b, err := fs.ReadFile(m.fsys, name)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", name, err)
+		}
+		up, down := splitMigration(string(b))
+		sum := sha256.Sum256([]byte(up))
+		migrations = append(migrations, Migration{
+			Name:     filepath.Base(name),
+			Checksum: hex.EncodeToString(sum[:]),
+			SQL:      up,
+			Down:     down,
+		})
+	}
+	return migrations, nil
+}
+
+// Status lists every migration file and every applied migration, ordered by name.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	migrations, applied, err := m.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+	files := make(map[string]bool, len(migrations))
+	for _, mig := range migrations {
+		files[mig.Name] = true
+		st := MigrationStatus{Name: mig.Name, Reversible: strings.TrimSpace(mig.Down) != ""}
+		if a, ok := applied[mig.Name]; ok {
+			st.Applied = true
+			st.AppliedAt = a.appliedAt
+			st.Modified = a.checksum != mig.Checksum
+		}
+		statuses = append(statuses, st)
+	}
+	for name, a := range applied {
+		if !files[name] {
+			statuses = append(statuses, MigrationStatus{Name: name, Applied: true, AppliedAt: a.appliedAt, Missing: true})
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses, nil
+}
+
+// Pending returns the migrations Up(ctx, n) would apply, without applying them.
+// n <= 0 means all. It fails with ErrMigrationModified if an applied file changed.
+func (m *Migrator) Pending(ctx context.Context, n int) ([]Migration, error) {
+	migrations, applied, err := m.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var modified []string
+	var pending []Migration
+	for _, mig := range migrations {
+		a, ok := applied[mig.Name]
+		if !ok {
+			pending = append(pending, mig)
 			continue
 		}
-		if scanErr != nil && !errors.Is(scanErr, sql.ErrNoRows) {
-			return fmt.Errorf("check migration %s: %w", base, scanErr)
+		if a.checksum != mig.Checksum {
+			modified = append(modified, mig.Name)
 		}
+	}
+	if len(modified) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrMigrationModified, strings.Join(modified, ", "))
+	}
+	if n > 0 && n < len(pending) {
+		pending = pending[:n]
+	}
+	return pending, nil
+}
 
-		// Read migration file
-		b, err := fs.ReadFile(migrationsFS, name)
+// Up applies the next n pending migrations (all when n <= 0) and returns their
+// names. Each migration runs in its own transaction together with its
+// `_migrations` row.
+func (m *Migrator) Up(ctx context.Context, n int) ([]string, error) {
+	pending, err := m.Pending(ctx, n)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []string
+	for _, mig := range pending {
+		err := m.step(ctx, mig, "up", mig.SQL,
+			"INSERT INTO _migrations (name, checksum) VALUES (?, ?)", mig.Name, mig.Checksum)
 		if err != nil {
-			return fmt.Errorf("read %s: %w", name, err)
+			return done, err
 		}
+		done = append(done, mig.Name)
+	}
+	return done, nil
+}
 
-		// Apply inside a transaction so the insert into _migrations is atomic with the script
-		tx, err := db.Begin()
+// Down rolls back the last n applied migrations (n <= 0 means 1), newest first,
+// and returns their names. Every migration to roll back must have a down section.
+func (m *Migrator) Down(ctx context.Context, n int) ([]string, error) {
+	if n <= 0 {
+		n = 1
+	}
+	migrations, applied, err := m.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]Migration, len(migrations))
+	for _, mig := range migrations {
+		byName[mig.Name] = mig
+	}
+
+	names := make([]string, 0, len(applied))
+	for name := range applied {
+		names = append(names, name)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	if n < len(names) {
+		names = names[:n]
+	}
+
+	// Check everything up front so a missing down section doesn't stop halfway
+	targets := make([]Migration, 0, len(names))
+	for _, name := range names {
+		mig, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("roll back %s: migration file not found", name)
+		}
+		if applied[name].checksum != mig.Checksum {
+			return nil, fmt.Errorf("%w: %s", ErrMigrationModified, name)
+		}
+		if strings.TrimSpace(mig.Down) == "" {
+			return nil, fmt.Errorf("roll back %s: %w", name, ErrIrreversible)
+		}
+		targets = append(targets, mig)
+	}
+
+	var done []string
+	for _, mig := range targets {
+		if err := m.step(ctx, mig, "down", mig.Down, "DELETE FROM _migrations WHERE name = ?", mig.Name); err != nil {
+			return done, err
+		}
+		done = append(done, mig.Name)
+	}
+	return done, nil
+}
+
+// Redo rolls back the last applied migration and applies it again.
+func (m *Migrator) Redo(ctx context.Context) (string, error) {
+	done, err := m.Down(ctx, 1)
+	if err != nil {
+		return "", err
+	}
+	if len(done) == 0 {
+		return "", errors.New("no applied migrations")
+	}
+	migrations, err := m.Migrations()
+	if err != nil {
+		return done[0], err
+	}
+	for _, mig := range migrations {
+		if mig.Name == done[0] {
+			return mig.Name, m.step(ctx, mig, "up", mig.SQL,
+				"INSERT INTO _migrations (name, checksum) VALUES (?, ?)", mig.Name, mig.Checksum)
+		}
+	}
+	return done[0], fmt.Errorf("redo %s: migration file not found", done[0])
+}
+
+// History returns every recorded run, oldest first.
+func (m *Migrator) History(ctx context.Context) ([]MigrationRun, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+	rows, err := m.db.QueryContext(ctx, "SELECT id, name, direction, checksum, error, ran_at FROM _migration_runs ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("list migration runs: %w", err)
+	}
+	defer rows.Close()
+	var runs []MigrationRun
+	for rows.Next() {
+		var run MigrationRun
+		var checksum, runErr sql.NullString
+		if err := rows.Scan(&run.ID, &run.Name, &run.Direction, &checksum, &runErr, &run.RanAt); err != nil {
+			return nil, fmt.Errorf("list migration runs: %w", err)
+		}
+		run.Checksum = checksum.String
+		run.Error = runErr.String
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// step runs one migration script and its bookkeeping statement in a single
+// transaction, so a failure leaves the database at the previous version. The run
+// is recorded either way; failed runs are recorded after the rollback.
+func (m *Migrator) step(ctx context.Context, mig Migration, direction, script, record string, args ...any) error {
+	const logRun = "INSERT INTO _migration_runs (name, direction, checksum, error) VALUES (?, ?, ?, ?)"
+
+	err := func() error {
+		tx, err := m.db.BeginTx(ctx, nil)
 		if err != nil {
-			return fmt.Errorf("begin tx for %s: %w", base, err)
+			return fmt.Errorf("begin tx for %s: %w", mig.Name, err)
+		}
+		defer tx.Rollback()
+		if _, err := tx.ExecContext(ctx, script); err != nil {
+			return fmt.Errorf("%s %s: %w", direction, mig.Name, err)
 		}
-		if _, err := tx.Exec(string(b)); err != nil {
-			_ = tx.Rollback()
-			return fmt.Errorf("apply %s: %w", base, err)
+		if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+			return fmt.Errorf("record migration %s: %w", mig.Name, err)
 		}
-		if _, err := tx.Exec("INSERT INTO _migrations (name) VALUES (?)", base); err != nil {
-			_ = tx.Rollback()
-			return fmt.Errorf("record migration %s: %w", base, err)
+		if _, err := tx.ExecContext(ctx, logRun, mig.Name, direction, mig.Checksum, nil); err != nil {
+			return fmt.Errorf("record migration run %s: %w", mig.Name, err)
 		}
 		if err := tx.Commit(); err != nil {
-			return fmt.Errorf("commit migration %s: %w", base, err)
+			return fmt.Errorf("commit migration %s: %w", mig.Name, err)
+		}
+		return nil
+	}()
+	if err != nil {
+		_, _ = m.db.ExecContext(ctx, logRun, mig.Name, direction, mig.Checksum, err.Error())
+	}
+	return err
+}
+
+// splitMigration separates the up and down sections at the first `-- +migrate Down` line.
+// The newline ending the last up line belongs to the marker, so appending
+// "\n-- +migrate Down\n..." to an existing file leaves its up section (and checksum)
+// unchanged.
+func splitMigration(content string) (up, down string) {
+	offset := 0
+	for _, line := range strings.SplitAfter(content, "\n") {
+		if strings.ToLower(strings.TrimSpace(line)) == downMarker {
+			upEnd := offset
+			if upEnd > 0 {
+				upEnd--
+			}
+			return content[:upEnd], content[offset+len(line):]
+		}
+		offset += len(line)
+	}
+	return content, ""
+}
+
+// Version returns the name of the last applied migration, or "" if none.
+func (m *Migrator) Version(ctx context.Context) (string, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return "", err
+	}
+	var name sql.NullString
+	if err := m.db.QueryRowContext(ctx, "SELECT MAX(name) FROM _migrations").Scan(&name); err != nil {
+		return "", fmt.Errorf("read version: %w", err)
+	}
+	return name.String, nil
+}
+
+type appliedMigration struct {
+	checksum  string
+	appliedAt string
+}
+
+// load reads the migration files and the applied migrations. Rows recorded before
+// checksums were tracked are backfilled with the current file checksum.
+func (m *Migrator) load(ctx context.Context) ([]Migration, map[string]appliedMigration, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, nil, err
+	}
+	migrations, err := m.Migrations()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows, err := m.db.QueryContext(ctx, "SELECT name, checksum, applied_at FROM _migrations")
+	if err != nil {
+		return nil, nil, fmt.Errorf("list applied migrations: %w", err)
+	}
+	defer rows.Close()
+	applied := make(map[string]appliedMigration)
+	for rows.Next() {
+		var name string
+		var checksum sql.NullString
+		var a appliedMigration
+		if err := rows.Scan(&name, &checksum, &a.appliedAt); err != nil {
+			return nil, nil, fmt.Errorf("list applied migrations: %w", err)
+		}
+		a.checksum = checksum.String
+		applied[name] = a
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("list applied migrations: %w", err)
+	}
+	rows.Close()
+
+	for _, mig := range migrations {
+		if a, ok := applied[mig.Name]; ok && a.checksum == "" {
+			if _, err := m.db.ExecContext(ctx, "UPDATE _migrations SET checksum = ? WHERE name = ?", mig.Checksum, mig.Name); err != nil {
+				return nil, nil, fmt.Errorf("backfill checksum for %s: %w", mig.Name, err)
+			}
+			a.checksum = mig.Checksum
+			applied[mig.Name] = a
+		}
+	}
+	return migrations, applied, nil
+}
+
+// trackingTables holds the DDL for the bookkeeping tables per dialect.
+var trackingTables = map[Dialect]struct{ migrations, runs string }{
+	DialectSQLite: {
+		migrations: `
+			CREATE TABLE IF NOT EXISTS _migrations (
+				name TEXT PRIMARY KEY,
+				applied_at TEXT NOT NULL DEFAULT (datetime('now')),
+				checksum TEXT
+			);`,
+		runs: `
+			CREATE TABLE IF NOT EXISTS _migration_runs (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				name TEXT NOT NULL,
+				direction TEXT NOT NULL,
+				checksum TEXT,
+				error TEXT,
+				ran_at TEXT NOT NULL DEFAULT (datetime('now'))
+			);`,
+	},
+	DialectPostgres: {
+		migrations: `
+			CREATE TABLE IF NOT EXISTS _migrations (
+				name TEXT PRIMARY KEY,
+				applied_at TEXT NOT NULL DEFAULT (to_char(now() AT TIME ZONE 'UTC', 'YYYY-MM-DD HH24:MI:SS')),
+				checksum TEXT
+			);`,
+		runs: `
+			CREATE TABLE IF NOT EXISTS _migration_runs (
+				id BIGSERIAL PRIMARY KEY,
+				name TEXT NOT NULL,
+				direction TEXT NOT NULL,
+				checksum TEXT,
+				error TEXT,
+				ran_at TEXT NOT NULL DEFAULT (to_char(now() AT TIME ZONE 'UTC', 'YYYY-MM-DD HH24:MI:SS'))
+			);`,
+	},
+}
+
+// ensureTable creates the tracking tables and adds the checksum column to SQLite
+// tables created by earlier versions.
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	ddl := trackingTables[m.dialect]
+	if _, err := m.db.ExecContext(ctx, ddl.migrations); err != nil {
+		return fmt.Errorf("create _migrations table: %w", err)
+	}
+	if _, err := m.db.ExecContext(ctx, ddl.runs); err != nil {
+		return fmt.Errorf("create _migration_runs table: %w", err)
+	}
+	if m.dialect != DialectSQLite {
+		return nil
+	}
+
+	var hasChecksum int
+	if err := m.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM pragma_table_info('_migrations') WHERE name = 'checksum'",
+	).Scan(&hasChecksum); err != nil {
+		return fmt.Errorf("inspect _migrations table: %w", err)
+	}
+	if hasChecksum == 0 {
+		if _, err := m.db.ExecContext(ctx, "ALTER TABLE _migrations ADD COLUMN checksum TEXT"); err != nil {
+			return fmt.Errorf("add checksum column: %w", err)
 		}
 	}
 	return nil
@@ -0,0 +1,176 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// ErrLockHeld is returned when another instance holds the migration lock and the
+// caller did not wait (or waited too long) for it.
+var ErrLockHeld = errors.New("migration lock held by another instance")
+
+// lockTimeLayout has a fixed width so lease times compare correctly as text.
+const lockTimeLayout = "2006-01-02T15:04:05.000000000Z"
+
+// LockOptions controls the migration lock.
+type LockOptions struct {
+	// Owner identifies this instance; defaults to hostname, pid and a random suffix.
+	Owner string
+	// Lease is how long the lock stays valid without a heartbeat, so a crashed
+	// holder blocks the others for at most this long. Defaults to 30s.
+	Lease time.Duration
+	// Wait is how long to wait for a held lock; 0 returns ErrLockHeld immediately.
+	Wait time.Duration
+	// PollInterval is how often a waiting instance retries. Defaults to 1s.
+	PollInterval time.Duration
+}
+
+func (o LockOptions) withDefaults() LockOptions {
+	if o.Owner == "" {
+		o.Owner = defaultLockOwner()
+	}
+	if o.Lease <= 0 {
+		o.Lease = 30 * time.Second
+	}
+	if o.PollInterval <= 0 {
+		o.PollInterval = time.Second
+	}
+	return o
+}
+
+// LockOptionsFromEnv reads MIGRATION_LOCK_WAIT (default 2m; 0 gives up at once
+// when another instance is migrating) and MIGRATION_LOCK_LEASE (default 30s).
+func LockOptionsFromEnv() (LockOptions, error) {
+	opts := LockOptions{Wait: 2 * time.Minute}
+	if v := os.Getenv("MIGRATION_LOCK_WAIT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return opts, fmt.Errorf("MIGRATION_LOCK_WAIT: %w", err)
+		}
+		opts.Wait = d
+	}
+	if v := os.Getenv("MIGRATION_LOCK_LEASE"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return opts, fmt.Errorf("MIGRATION_LOCK_LEASE: %w", err)
+		}
+		opts.Lease = d
+	}
+	return opts, nil
+}
+
+func defaultLockOwner() string {
+	host, _ := os.Hostname()
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("%s:%d:%s", host, os.Getpid(), hex.EncodeToString(b))
+}
+
+// WithLock runs fn while holding the migration lock. The lock is a single row in
+// `_migration_lock` taken with an atomic upsert, so it works the same on local
+// SQLite and Turso/libSQL. A heartbeat renews the lease while fn runs; if the lease
+// is lost, fn's context is cancelled.
+func (m *Migrator) WithLock(ctx context.Context, opts LockOptions, fn func(ctx context.Context) error) error {
+	opts = opts.withDefaults()
+	if _, err := m.db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS _migration_lock (
+			id INTEGER PRIMARY KEY CHECK (id = 1),
+			owner TEXT NOT NULL,
+			acquired_at TEXT NOT NULL,
+			expires_at TEXT NOT NULL
+		);
+	`); err != nil {
+		return fmt.Errorf("create _migration_lock table: %w", err)
+	}
+
+	deadline := time.Now().Add(opts.Wait)
+	for {
+		ok, err := m.tryLock(ctx, opts)
+		if err != nil {
+			return err
+		}
+		if ok {
+			break
+		}
+		if !time.Now().Before(deadline) {
+			return ErrLockHeld
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(opts.PollInterval):
+		}
+	}
+	defer func() {
+		if _, err := m.db.ExecContext(context.Background(), "DELETE FROM _migration_lock WHERE id = 1 AND owner = ?", opts.Owner); err != nil {
+			log.Printf("release migration lock: %v", err)
+		}
+	}()
+
+	runCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	go m.heartbeat(runCtx, opts, cancel)
+
+	if err := fn(runCtx); err != nil {
+		if cause := context.Cause(runCtx); errors.Is(cause, ErrLockHeld) {
+			return fmt.Errorf("%w: %w", cause, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// tryLock takes the lock if it is free, expired or already ours.
+func (m *Migrator) tryLock(ctx context.Context, opts LockOptions) (bool, error) {
+	now := time.Now().UTC()
+	res, err := m.db.ExecContext(ctx, `
+		INSERT INTO _migration_lock (id, owner, acquired_at, expires_at) VALUES (1, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			owner = excluded.owner,
+			acquired_at = excluded.acquired_at,
+			expires_at = excluded.expires_at
+		WHERE _migration_lock.expires_at < excluded.acquired_at
+			OR _migration_lock.owner = excluded.owner
+	`, opts.Owner, now.Format(lockTimeLayout), now.Add(opts.Lease).Format(lockTimeLayout))
+	if err != nil {
+		return false, fmt.Errorf("acquire migration lock: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("acquire migration lock: %w", err)
+	}
+	return n == 1, nil
+}
+
+// heartbeat extends the lease every third of its length until ctx is done. Write
+// errors are retried on the next tick (the database may be busy with the
+// migration itself); finding the row owned by someone else cancels ctx.
+func (m *Migrator) heartbeat(ctx context.Context, opts LockOptions, cancel context.CancelCauseFunc) {
+	ticker := time.NewTicker(opts.Lease / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		res, err := m.db.ExecContext(ctx, "UPDATE _migration_lock SET expires_at = ? WHERE id = 1 AND owner = ?",
+			time.Now().UTC().Add(opts.Lease).Format(lockTimeLayout), opts.Owner)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("renew migration lock: %v", err)
+			}
+			continue
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			cancel(fmt.Errorf("%w: lease lost", ErrLockHeld))
+			return
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrationLock(t *testing.T) {
+	eachDatabase(t, func(t *testing.T, open func() *sql.DB) {
+		a := NewMigrator(open(), fstest.MapFS{}, "")
+		b := NewMigrator(open(), fstest.MapFS{}, "")
+		ctx := context.Background()
+
+		held := make(chan struct{})
+		release := make(chan struct{})
+		done := make(chan error)
+		go func() {
+			done <- a.WithLock(ctx, LockOptions{Owner: "a", Lease: time.Minute}, func(context.Context) error {
+				close(held)
+				<-release
+				return nil
+			})
+		}()
+		<-held
+
+		// Skipping
+		err := b.WithLock(ctx, LockOptions{Owner: "b"}, func(context.Context) error { return nil })
+		assert.ErrorIs(t, err, ErrLockHeld)
+
+		// Waiting
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			close(release)
+		}()
+		ran := false
+		err = b.WithLock(ctx, LockOptions{Owner: "b", Wait: 5 * time.Second, PollInterval: 20 * time.Millisecond}, func(context.Context) error {
+			ran = true
+			return nil
+		})
+		require.NoError(t, err)
+		assert.True(t, ran)
+		require.NoError(t, <-done)
+	})
+}
+
+func TestMigrationLockTakesOverExpiredLease(t *testing.T) {
+	eachDatabase(t, func(t *testing.T, open func() *sql.DB) {
+		database := open()
+		m := NewMigrator(database, fstest.MapFS{}, "")
+		ctx := context.Background()
+
+		// Create the table, then leave a lease behind as if the holder had crashed
+		require.NoError(t, m.WithLock(ctx, LockOptions{Owner: "setup"}, func(context.Context) error { return nil }))
+		_, err := database.Exec("INSERT INTO _migration_lock (id, owner, acquired_at, expires_at) VALUES (1, 'crashed', ?, ?)",
+			time.Now().UTC().Add(-time.Hour).Format(lockTimeLayout), time.Now().UTC().Add(-time.Minute).Format(lockTimeLayout))
+		require.NoError(t, err)
+
+		ran := false
+		require.NoError(t, m.WithLock(ctx, LockOptions{Owner: "b"}, func(context.Context) error {
+			ran = true
+			return nil
+		}))
+		assert.True(t, ran)
+
+		var count int
+		require.NoError(t, database.QueryRow("SELECT COUNT(*) FROM _migration_lock").Scan(&count))
+		assert.Equal(t, 0, count)
+	})
+}
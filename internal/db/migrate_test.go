@@ -0,0 +1,158 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+)
+
+func TestMigratorUpStatusAndChecksums(t *testing.T) {
+	eachDatabase(t, func(t *testing.T, open func() *sql.DB) {
+		database := open()
+
+		ctx := context.Background()
+		fsys := fstest.MapFS{
+			"0001_a.sql": {Data: []byte("CREATE TABLE a (id INTEGER);")},
+			"0002_b.sql": {Data: []byte("CREATE TABLE b (id INTEGER);")},
+			"0003_c.sql": {Data: []byte("CREATE TABLE c (id INTEGER);")},
+		}
+		m := NewMigrator(database, fsys, "")
+
+		applied, err := m.Up(ctx, 2)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"0001_a.sql", "0002_b.sql"}, applied)
+
+		version, err := m.Version(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "0002_b.sql", version)
+
+		pending, err := m.Pending(ctx, 0)
+		require.NoError(t, err)
+		require.Len(t, pending, 1)
+		assert.Equal(t, "0003_c.sql", pending[0].Name)
+
+		// Editing an applied migration is reported and blocks further runs
+		fsys["0001_a.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE a (id INTEGER, name TEXT);")}
+		statuses, err := m.Status(ctx)
+		require.NoError(t, err)
+		require.Len(t, statuses, 3)
+		assert.True(t, statuses[0].Modified)
+		assert.False(t, statuses[1].Modified)
+		assert.False(t, statuses[2].Applied)
+
+		_, err = m.Up(ctx, 0)
+		assert.ErrorIs(t, err, ErrMigrationModified)
+	})
+}
+
+func TestMigratorBackfillsLegacyRows(t *testing.T) {
+	database, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	database.SetMaxOpenConns(1)
+	defer database.Close()
+
+	// Tracking table as created before checksums existed
+	_, err = database.Exec(`
+		CREATE TABLE _migrations (name TEXT PRIMARY KEY, applied_at TEXT NOT NULL DEFAULT (datetime('now')));
+		CREATE TABLE a (id INTEGER);
+		INSERT INTO _migrations (name) VALUES ('0001_a.sql');
+	`)
+	require.NoError(t, err)
+
+	fsys := fstest.MapFS{"0001_a.sql": {Data: []byte("CREATE TABLE a (id INTEGER);")}}
+	applied, err := NewMigrator(database, fsys, "").Up(context.Background(), 0)
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+
+	var checksum sql.NullString
+	require.NoError(t, database.QueryRow("SELECT checksum FROM _migrations WHERE name = '0001_a.sql'").Scan(&checksum))
+	assert.Len(t, checksum.String, 64)
+}
+
+func TestMigratorDownAndRedo(t *testing.T) {
+	eachDatabase(t, func(t *testing.T, open func() *sql.DB) {
+		database := open()
+
+		ctx := context.Background()
+		fsys := fstest.MapFS{
+			"0001_a.sql": {Data: []byte("CREATE TABLE a (id INTEGER);")},
+			"0002_b.sql": {Data: []byte("CREATE TABLE b (id INTEGER);\n-- +migrate Down\nDROP TABLE b;\n")},
+			"0003_c.sql": {Data: []byte("CREATE TABLE c (id INTEGER);\n-- +migrate Down\nDROP TABLE c;\nDROP TABLE missing;\n")},
+		}
+		m := NewMigrator(database, fsys, "")
+		_, err := m.Up(ctx, 0)
+		require.NoError(t, err)
+
+		// A failing down script is rolled back completely
+		_, err = m.Down(ctx, 1)
+		require.Error(t, err)
+		version, err := m.Version(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "0003_c.sql", version)
+		_, err = database.Exec("SELECT * FROM c")
+		assert.NoError(t, err)
+
+		fsys["0003_c.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE c (id INTEGER);\n-- +migrate Down\nDROP TABLE c;\n")}
+		name, err := m.Redo(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "0003_c.sql", name)
+
+		done, err := m.Down(ctx, 2)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"0003_c.sql", "0002_b.sql"}, done)
+		version, err = m.Version(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "0001_a.sql", version)
+
+		_, err = m.Down(ctx, 1)
+		assert.ErrorIs(t, err, ErrIrreversible)
+
+		runs, err := m.History(ctx)
+		require.NoError(t, err)
+		var directions []string
+		for _, run := range runs {
+			failed := ""
+			if run.Error != "" {
+				failed = "!"
+			}
+			directions = append(directions, run.Direction+failed+" "+run.Name)
+		}
+		assert.Equal(t, []string{
+			"up 0001_a.sql", "up 0002_b.sql", "up 0003_c.sql",
+			"down! 0003_c.sql",
+			"down 0003_c.sql", "up 0003_c.sql",
+			"down 0003_c.sql", "down 0002_b.sql",
+		}, directions)
+	})
+}
+
+func TestSplitMigrationKeepsUpChecksum(t *testing.T) {
+	for _, original := range []string{"CREATE TABLE a (id INTEGER);", "CREATE TABLE a (id INTEGER);\n"} {
+		up, down := splitMigration(original + "\n-- +migrate Down\nDROP TABLE a;\n")
+		assert.Equal(t, original, up)
+		assert.Equal(t, "DROP TABLE a;\n", down)
+	}
+}
+
+func TestEmbeddedMigrationsRoundTrip(t *testing.T) {
+	eachDatabase(t, func(t *testing.T, open func() *sql.DB) {
+		database := open()
+
+		ctx := context.Background()
+		m := NewEmbeddedMigrator(database)
+		applied, err := m.Up(ctx, 0)
+		require.NoError(t, err)
+
+		rolledBack, err := m.Down(ctx, len(applied))
+		require.NoError(t, err)
+		assert.Len(t, rolledBack, len(applied))
+
+		_, err = m.Up(ctx, 0)
+		require.NoError(t, err)
+	})
+}
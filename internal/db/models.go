@@ -10,7 +10,7 @@ import (
 
 type AuditEvent struct {
 	ID          int64          `json:"id"`
-	Timestamp   string         `json:"timestamp"`
+	Timestamp   Timestamp      `json:"timestamp"`
 	Subsystem   string         `json:"subsystem"`
 	EventType   string         `json:"event_type"`
 	UserID      sql.NullString `json:"user_id"`
@@ -25,6 +25,100 @@ type Cache struct {
 	Value string `json:"value"`
 }
 
+type Coupon struct {
+	ID                    int64          `json:"id"`
+	Code                  string         `json:"code"`
+	PercentOff            int64          `json:"percent_off"`
+	AmountOff             int64          `json:"amount_off"`
+	Currency              sql.NullString `json:"currency"`
+	ExpiresAt             NullTimestamp  `json:"expires_at"`
+	MaxRedemptions        sql.NullInt64  `json:"max_redemptions"`
+	PerUserLimit          sql.NullInt64  `json:"per_user_limit"`
+	StripeCouponID        sql.NullString `json:"stripe_coupon_id"`
+	StripePromotionCodeID sql.NullString `json:"stripe_promotion_code_id"`
+	CreatedAt             Timestamp      `json:"created_at"`
+	UpdatedAt             Timestamp      `json:"updated_at"`
+	ArchivedAt            NullTimestamp  `json:"archived_at"`
+}
+
+type ConnectedAccount struct {
+	ID               int64         `json:"id"`
+	StripeAccountID  string        `json:"stripe_account_id"`
+	Name             string        `json:"name"`
+	Email            string        `json:"email"`
+	Country          string        `json:"country"`
+	FeeBps           int64         `json:"fee_bps"`
+	ChargesEnabled   bool          `json:"charges_enabled"`
+	PayoutsEnabled   bool          `json:"payouts_enabled"`
+	DetailsSubmitted bool          `json:"details_submitted"`
+	CreatedAt        Timestamp     `json:"created_at"`
+	UpdatedAt        Timestamp     `json:"updated_at"`
+	StatusUpdatedAt  NullTimestamp `json:"status_updated_at"`
+}
+
+type CouponProduct struct {
+	CouponID  int64  `json:"coupon_id"`
+	ProductID string `json:"product_id"`
+}
+
+type JournalEntry struct {
+	ID             int64          `json:"id"`
+	IdempotencyKey string         `json:"idempotency_key"`
+	Kind           string         `json:"kind"`
+	TransactionID  sql.NullString `json:"transaction_id"`
+	UserID         sql.NullString `json:"user_id"`
+	StripeEventID  sql.NullString `json:"stripe_event_id"`
+	Description    string         `json:"description"`
+	CreatedAt      Timestamp      `json:"created_at"`
+}
+
+type LedgerAccount struct {
+	ID        int64     `json:"id"`
+	Code      string    `json:"code"`
+	Type      string    `json:"type"`
+	Currency  string    `json:"currency"`
+	CreatedAt Timestamp `json:"created_at"`
+}
+
+type LedgerPosting struct {
+	ID             int64          `json:"id"`
+	JournalEntryID int64          `json:"journal_entry_id"`
+	AccountID      int64          `json:"account_id"`
+	UserID         sql.NullString `json:"user_id"`
+	Amount         int64          `json:"amount"`
+}
+
+type OutboxMessage struct {
+	ID            int64          `json:"id"`
+	Topic         string         `json:"topic"`
+	MessageKey    string         `json:"message_key"`
+	DedupeKey     string         `json:"dedupe_key"`
+	Payload       string         `json:"payload"`
+	Attempts      int64          `json:"attempts"`
+	NextAttemptAt Timestamp      `json:"next_attempt_at"`
+	PublishedAt   NullTimestamp  `json:"published_at"`
+	LastError     sql.NullString `json:"last_error"`
+	CreatedAt     Timestamp      `json:"created_at"`
+	DeadAt        NullTimestamp  `json:"dead_at"`
+}
+
+type ProductAccount struct {
+	ProductID          string `json:"product_id"`
+	ConnectedAccountID int64  `json:"connected_account_id"`
+}
+
+type TaxRate struct {
+	ID              int64          `json:"id"`
+	Country         string         `json:"country"`
+	Region          string         `json:"region"`
+	TaxCode         string         `json:"tax_code"`
+	Name            string         `json:"name"`
+	RateBps         int64          `json:"rate_bps"`
+	StripeTaxRateID sql.NullString `json:"stripe_tax_rate_id"`
+	CreatedAt       Timestamp      `json:"created_at"`
+	ArchivedAt      NullTimestamp  `json:"archived_at"`
+}
+
 type Transaction struct {
 	ID                    string         `json:"id"`
 	UserID                string         `json:"user_id"`
@@ -34,7 +128,78 @@ type Transaction struct {
 	StripeSessionID       sql.NullString `json:"stripe_session_id"`
 	StripePaymentIntentID sql.NullString `json:"stripe_payment_intent_id"`
 	Status                string         `json:"status"`
-	CreatedAt             string         `json:"created_at"`
-	UpdatedAt             string         `json:"updated_at"`
-	RefundDate            sql.NullString `json:"refund_date"`
+	CreatedAt             Timestamp      `json:"created_at"`
+	UpdatedAt             Timestamp      `json:"updated_at"`
+	RefundDate            NullTimestamp  `json:"refund_date"`
+	Currency              string         `json:"currency"`
+	ExpiresAt             NullTimestamp  `json:"expires_at"`
+	CouponID              sql.NullInt64  `json:"coupon_id"`
+	DiscountAmount        int64          `json:"discount_amount"`
+	TaxAmount             int64          `json:"tax_amount"`
+	ConnectedAccountID    sql.NullInt64  `json:"connected_account_id"`
+	ApplicationFeeAmount  int64          `json:"application_fee_amount"`
+	TransferAmount        int64          `json:"transfer_amount"`
+	CheckoutOptions       string         `json:"checkout_options"`
+}
+
+type TransactionLineItem struct {
+	ID             int64         `json:"id"`
+	TransactionID  string        `json:"transaction_id"`
+	ProductID      string        `json:"product_id"`
+	Description    string        `json:"description"`
+	Quantity       int64         `json:"quantity"`
+	UnitAmount     int64         `json:"unit_amount"`
+	Currency       string        `json:"currency"`
+	DiscountAmount int64         `json:"discount_amount"`
+	TaxAmount      int64         `json:"tax_amount"`
+	TaxCode        string        `json:"tax_code"`
+	TaxRateID      sql.NullInt64 `json:"tax_rate_id"`
+}
+
+type WebhookDelivery struct {
+	ID             int64          `json:"id"`
+	EndpointID     int64          `json:"endpoint_id"`
+	EventID        string         `json:"event_id"`
+	EventType      string         `json:"event_type"`
+	EventKey       string         `json:"event_key"`
+	Payload        string         `json:"payload"`
+	Status         string         `json:"status"`
+	Attempts       int64          `json:"attempts"`
+	NextAttemptAt  NullTimestamp  `json:"next_attempt_at"`
+	LastStatusCode sql.NullInt64  `json:"last_status_code"`
+	LastError      sql.NullString `json:"last_error"`
+	CreatedAt      Timestamp      `json:"created_at"`
+	UpdatedAt      Timestamp      `json:"updated_at"`
+}
+
+type WebhookDeliveryAttempt struct {
+	ID          int64          `json:"id"`
+	DeliveryID  int64          `json:"delivery_id"`
+	AttemptedAt Timestamp      `json:"attempted_at"`
+	StatusCode  sql.NullInt64  `json:"status_code"`
+	Error       sql.NullString `json:"error"`
+	DurationMs  int64          `json:"duration_ms"`
+}
+
+type WebhookEndpoint struct {
+	ID          int64     `json:"id"`
+	Url         string    `json:"url"`
+	Secret      string    `json:"secret"`
+	EventTypes  string    `json:"event_types"`
+	Description string    `json:"description"`
+	Active      bool      `json:"active"`
+	CreatedAt   Timestamp `json:"created_at"`
+	UpdatedAt   Timestamp `json:"updated_at"`
+}
+
+type WebhookInbox struct {
+	ID              int64          `json:"id"`
+	StripeEventID   string         `json:"stripe_event_id"`
+	EventType       string         `json:"event_type"`
+	SessionID       sql.NullString `json:"session_id"`
+	PaymentIntentID sql.NullString `json:"payment_intent_id"`
+	Status          string         `json:"status"`
+	Error           sql.NullString `json:"error"`
+	ReceivedAt      Timestamp      `json:"received_at"`
+	ProcessedAt     NullTimestamp  `json:"processed_at"`
 }
@@ -0,0 +1,192 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// Dialect identifies the SQL flavour of a connection.
+type Dialect string
+
+const (
+	DialectSQLite   Dialect = "sqlite"
+	DialectPostgres Dialect = "postgres"
+)
+
+// DialectOf reports the dialect of a connection opened by this package. Turso/libSQL
+// connections are SQLite.
+func DialectOf(db *sql.DB) Dialect {
+	if _, ok := db.Driver().(pgDriver); ok {
+		return DialectPostgres
+	}
+	return DialectSQLite
+}
+
+// NewPostgresConnection opens a PostgreSQL database through pgx. The queries in this
+// package are written with SQLite's ? placeholders, so the connection rewrites them
+// to $n before they reach the server; the generated Queries, the Migrator and the
+// hand-written batch queries therefore run unchanged on both databases.
+func NewPostgresConnection(url string) (*sql.DB, error) {
+	cfg, err := pgx.ParseConfig(url)
+	if err != nil {
+		return nil, fmt.Errorf("parse postgres url: %w", err)
+	}
+	return sql.OpenDB(pgConnector{stdlib.GetConnector(*cfg)}), nil
+}
+
+// newPostgresTestConnection creates a uniquely named schema, points the connection's
+// search_path at it and migrates it. The schemas are left behind, so use a
+// disposable database for TEST_DATABASE_URL.
+func newPostgresTestConnection(url string) (*sql.DB, error) {
+	open, err := newPostgresTestSchema(url)
+	if err != nil {
+		return nil, err
+	}
+	db := open()
+	if _, err := NewEmbeddedMigrator(db).Up(context.Background(), 0); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to run test migrations: %w", err)
+	}
+	return db, nil
+}
+
+// newPostgresTestSchema creates an empty, uniquely named schema and returns a
+// function opening connections whose search_path points at it.
+func newPostgresTestSchema(url string) (func() *sql.DB, error) {
+	cfg, err := pgx.ParseConfig(url)
+	if err != nil {
+		return nil, fmt.Errorf("parse postgres url: %w", err)
+	}
+	suffix := make([]byte, 6)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+	schema := "test_" + hex.EncodeToString(suffix)
+
+	admin := sql.OpenDB(pgConnector{stdlib.GetConnector(*cfg)})
+	_, err = admin.Exec("CREATE SCHEMA " + schema)
+	admin.Close()
+	if err != nil {
+		return nil, fmt.Errorf("create test schema: %w", err)
+	}
+
+	cfg.RuntimeParams["search_path"] = schema
+	return func() *sql.DB {
+		return sql.OpenDB(pgConnector{stdlib.GetConnector(*cfg)})
+	}, nil
+}
+
+type pgConnector struct {
+	driver.Connector
+}
+
+func (c pgConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return pgConn{conn.(*stdlib.Conn)}, nil
+}
+
+func (c pgConnector) Driver() driver.Driver {
+	return pgDriver{c.Connector.Driver()}
+}
+
+type pgDriver struct {
+	driver.Driver
+}
+
+func (d pgDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return pgConn{conn.(*stdlib.Conn)}, nil
+}
+
+// pgConn rewrites placeholders on every path a query can take; everything else
+// (transactions, named value checks, session resets) is the embedded pgx conn.
+type pgConn struct {
+	*stdlib.Conn
+}
+
+func (c pgConn) Prepare(query string) (driver.Stmt, error) {
+	return c.Conn.Prepare(Rebind(query))
+}
+
+func (c pgConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return c.Conn.PrepareContext(ctx, Rebind(query))
+}
+
+func (c pgConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.Conn.ExecContext(ctx, Rebind(query), args)
+}
+
+func (c pgConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.Conn.QueryContext(ctx, Rebind(query), args)
+}
+
+// Rebind converts ? and ?NNN placeholders to PostgreSQL's $n. Placeholders inside
+// string literals, quoted identifiers and comments are left alone.
+func Rebind(query string) string {
+	if !strings.Contains(query, "?") {
+		return query
+	}
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	n := 0
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(query[i+1:], c)
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end+2])
+			i += end + 1
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end+1])
+			i += end
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end+4])
+			i += end + 3
+		case c == '?':
+			j := i + 1
+			for j < len(query) && query[j] >= '0' && query[j] <= '9' {
+				j++
+			}
+			b.WriteByte('$')
+			if j > i+1 {
+				b.WriteString(query[i+1 : j])
+			} else {
+				n++
+				b.WriteString(strconv.Itoa(n))
+			}
+			i = j - 1
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
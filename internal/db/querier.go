@@ -10,10 +10,37 @@ import (
 )
 
 type Querier interface {
-	CreateAuditEvent(ctx context.Context, arg CreateAuditEventParams) error
+	AddCouponProduct(ctx context.Context, arg AddCouponProductParams) error
+	ApplyStripeDiscount(ctx context.Context, arg ApplyStripeDiscountParams) error
+	ApplyStripeTax(ctx context.Context, arg ApplyStripeTaxParams) error
+	ArchiveCoupon(ctx context.Context, arg ArchiveCouponParams) error
+	ArchiveTaxRate(ctx context.Context, arg ArchiveTaxRateParams) error
+	CancelPendingTransaction(ctx context.Context, arg CancelPendingTransactionParams) (int64, error)
+	ClaimOutboxMessage(ctx context.Context, arg ClaimOutboxMessageParams) (OutboxMessage, error)
+	ClaimWebhookDelivery(ctx context.Context, arg ClaimWebhookDeliveryParams) (WebhookDelivery, error)
+	CountActiveTaxRates(ctx context.Context) (int64, error)
+	CountCouponRedemptions(ctx context.Context, couponID sql.NullInt64) (int64, error)
+	CountCouponRedemptionsByUser(ctx context.Context, arg CountCouponRedemptionsByUserParams) (int64, error)
+	CreateAuditEvent(ctx context.Context, arg CreateAuditEventParams) (AuditEvent, error)
+	CreateConnectedAccount(ctx context.Context, arg CreateConnectedAccountParams) (ConnectedAccount, error)
+	CreateCoupon(ctx context.Context, arg CreateCouponParams) (Coupon, error)
+	CreateJournalEntry(ctx context.Context, arg CreateJournalEntryParams) (JournalEntry, error)
+	CreateLedgerPosting(ctx context.Context, arg CreateLedgerPostingParams) error
+	CreateOutboxMessage(ctx context.Context, arg CreateOutboxMessageParams) error
+	CreateTaxRate(ctx context.Context, arg CreateTaxRateParams) (TaxRate, error)
 	CreateTransaction(ctx context.Context, arg CreateTransactionParams) error
+	CreateTransactionLineItem(ctx context.Context, arg CreateTransactionLineItemParams) error
+	CreateWebhookDelivery(ctx context.Context, arg CreateWebhookDeliveryParams) error
+	CreateWebhookDeliveryAttempt(ctx context.Context, arg CreateWebhookDeliveryAttemptParams) error
+	CreateWebhookEndpoint(ctx context.Context, arg CreateWebhookEndpointParams) (WebhookEndpoint, error)
+	CreateWebhookInboxEntry(ctx context.Context, arg CreateWebhookInboxEntryParams) (WebhookInbox, error)
 	DeleteCacheKey(ctx context.Context, key string) error
+	DeleteCouponProducts(ctx context.Context, couponID int64) error
+	DeleteProductAccount(ctx context.Context, productID string) error
+	DeletePublishedOutboxMessages(ctx context.Context, publishedAt NullTimestamp) (int64, error)
+	EnsureLedgerAccount(ctx context.Context, arg EnsureLedgerAccountParams) (LedgerAccount, error)
 	GetAllAuditEvents(ctx context.Context, arg GetAllAuditEventsParams) ([]AuditEvent, error)
+	GetAuditEventsAfterID(ctx context.Context, arg GetAuditEventsAfterIDParams) ([]AuditEvent, error)
 	GetAuditEventsByEventType(ctx context.Context, arg GetAuditEventsByEventTypeParams) ([]AuditEvent, error)
 	GetAuditEventsByRefID(ctx context.Context, arg GetAuditEventsByRefIDParams) ([]AuditEvent, error)
 	GetAuditEventsByRefID2(ctx context.Context, arg GetAuditEventsByRefID2Params) ([]AuditEvent, error)
@@ -22,16 +49,66 @@ type Querier interface {
 	GetAuditEventsByUser(ctx context.Context, arg GetAuditEventsByUserParams) ([]AuditEvent, error)
 	GetAuditEventsInDateRange(ctx context.Context, arg GetAuditEventsInDateRangeParams) ([]AuditEvent, error)
 	GetCacheValue(ctx context.Context, key string) (string, error)
+	GetConnectedAccount(ctx context.Context, id int64) (ConnectedAccount, error)
+	GetConnectedAccountByStripeID(ctx context.Context, stripeAccountID string) (ConnectedAccount, error)
+	GetCoupon(ctx context.Context, id int64) (Coupon, error)
+	GetCouponByCode(ctx context.Context, code string) (Coupon, error)
+	GetCouponByStripePromotionCodeID(ctx context.Context, stripePromotionCodeID sql.NullString) (Coupon, error)
+	GetOpenTransactionForCart(ctx context.Context, arg GetOpenTransactionForCartParams) (Transaction, error)
+	GetOutboxMessage(ctx context.Context, id int64) (OutboxMessage, error)
+	GetProductConnectedAccount(ctx context.Context, productID string) (ConnectedAccount, error)
+	GetTaxRate(ctx context.Context, id int64) (TaxRate, error)
 	GetTransaction(ctx context.Context, id string) (Transaction, error)
+	GetTransactionByPaymentIntentID(ctx context.Context, stripePaymentIntentID sql.NullString) (Transaction, error)
 	GetTransactionByStripeSessionID(ctx context.Context, stripeSessionID sql.NullString) (Transaction, error)
+	GetWebhookDelivery(ctx context.Context, id int64) (WebhookDelivery, error)
+	GetWebhookEndpoint(ctx context.Context, id int64) (WebhookEndpoint, error)
+	ImportAuditEvent(ctx context.Context, arg ImportAuditEventParams) error
+	ListActiveTaxRatesByCountry(ctx context.Context, country string) ([]TaxRate, error)
+	ListActiveWebhookEndpoints(ctx context.Context) ([]WebhookEndpoint, error)
 	ListAllTransactions(ctx context.Context, arg ListAllTransactionsParams) ([]Transaction, error)
+	ListAuditEvents(ctx context.Context, arg ListAuditEventsParams) ([]AuditEvent, error)
+	ListAuditEventsByRefs(ctx context.Context, arg ListAuditEventsByRefsParams) ([]AuditEvent, error)
+	ListAuditEventsForExport(ctx context.Context, arg ListAuditEventsForExportParams) ([]AuditEvent, error)
 	ListCache(ctx context.Context) ([]Cache, error)
+	ListConnectedAccounts(ctx context.Context, arg ListConnectedAccountsParams) ([]ConnectedAccount, error)
+	ListCouponProducts(ctx context.Context, couponID int64) ([]string, error)
+	ListCoupons(ctx context.Context, arg ListCouponsParams) ([]Coupon, error)
+	ListDueOutboxMessages(ctx context.Context, arg ListDueOutboxMessagesParams) ([]OutboxMessage, error)
+	ListDueWebhookDeliveries(ctx context.Context, arg ListDueWebhookDeliveriesParams) ([]WebhookDelivery, error)
+	ListJournalEntriesByTransactionID(ctx context.Context, transactionID sql.NullString) ([]JournalEntry, error)
+	ListLedgerAccountBalances(ctx context.Context) ([]ListLedgerAccountBalancesRow, error)
+	ListLedgerUserBalances(ctx context.Context, userID sql.NullString) ([]ListLedgerUserBalancesRow, error)
+	ListPendingTransactionsCreatedBefore(ctx context.Context, arg ListPendingTransactionsCreatedBeforeParams) ([]Transaction, error)
+	ListProductAccounts(ctx context.Context) ([]ProductAccount, error)
+	ListTaxRates(ctx context.Context, arg ListTaxRatesParams) ([]TaxRate, error)
+	ListTransactionLineItems(ctx context.Context, transactionID string) ([]TransactionLineItem, error)
 	ListTransactionsByUserID(ctx context.Context, arg ListTransactionsByUserIDParams) ([]Transaction, error)
+	ListTransactionsCreatedBetween(ctx context.Context, arg ListTransactionsCreatedBetweenParams) ([]Transaction, error)
+	ListWebhookDeliveriesByEndpoint(ctx context.Context, arg ListWebhookDeliveriesByEndpointParams) ([]WebhookDelivery, error)
+	ListWebhookDeliveryAttempts(ctx context.Context, deliveryID int64) ([]WebhookDeliveryAttempt, error)
+	ListWebhookEndpoints(ctx context.Context, arg ListWebhookEndpointsParams) ([]WebhookEndpoint, error)
+	ListWebhookInboxByRefs(ctx context.Context, arg ListWebhookInboxByRefsParams) ([]WebhookInbox, error)
+	LockCoupon(ctx context.Context, id int64) error
+	MarkOutboxMessagePublished(ctx context.Context, arg MarkOutboxMessagePublishedParams) error
+	RecordOutboxMessageFailure(ctx context.Context, arg RecordOutboxMessageFailureParams) error
+	RecordWebhookDeliveryResult(ctx context.Context, arg RecordWebhookDeliveryResultParams) error
+	RepairTransaction(ctx context.Context, arg RepairTransactionParams) (int64, error)
+	RequeueOutboxMessage(ctx context.Context, arg RequeueOutboxMessageParams) (int64, error)
+	ResetWebhookDelivery(ctx context.Context, arg ResetWebhookDeliveryParams) (WebhookDelivery, error)
 	SetCacheValue(ctx context.Context, arg SetCacheValueParams) error
-	UpdateTransactionByPaymentIntentID(ctx context.Context, arg UpdateTransactionByPaymentIntentIDParams) error
-	UpdateTransactionByPaymentIntentIDWithRefundDate(ctx context.Context, arg UpdateTransactionByPaymentIntentIDWithRefundDateParams) error
+	SetProductAccount(ctx context.Context, arg SetProductAccountParams) error
+	SetTransactionApplicationFee(ctx context.Context, arg SetTransactionApplicationFeeParams) error
+	UpdateConnectedAccountFee(ctx context.Context, arg UpdateConnectedAccountFeeParams) (ConnectedAccount, error)
+	UpdateConnectedAccountStatus(ctx context.Context, arg UpdateConnectedAccountStatusParams) (int64, error)
+	UpdateCoupon(ctx context.Context, arg UpdateCouponParams) (Coupon, error)
+	UpdateLineItemAdjustments(ctx context.Context, arg UpdateLineItemAdjustmentsParams) error
+	UpdateTransactionByPaymentIntentID(ctx context.Context, arg UpdateTransactionByPaymentIntentIDParams) (int64, error)
+	UpdateTransactionByPaymentIntentIDWithRefundDate(ctx context.Context, arg UpdateTransactionByPaymentIntentIDWithRefundDateParams) (int64, error)
 	UpdateTransactionStatus(ctx context.Context, arg UpdateTransactionStatusParams) error
-	UpdateTransactionWithStripeData(ctx context.Context, arg UpdateTransactionWithStripeDataParams) error
+	UpdateTransactionWithStripeData(ctx context.Context, arg UpdateTransactionWithStripeDataParams) (int64, error)
+	UpdateWebhookEndpoint(ctx context.Context, arg UpdateWebhookEndpointParams) (WebhookEndpoint, error)
+	UpdateWebhookInboxStatus(ctx context.Context, arg UpdateWebhookInboxStatusParams) error
 }
 
 var _ Querier = (*Queries)(nil)
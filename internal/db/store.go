@@ -0,0 +1,54 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// Repository is what the rest of the service uses to reach the database: every
+// generated query, plus units of work. Code that runs inside a unit of work takes
+// the Querier it is handed, so it works the same in and out of a transaction.
+type Repository interface {
+	Querier
+	WithTx(ctx context.Context, fn func(q Querier) error) error
+}
+
+var _ Repository = (*Store)(nil)
+
+// Store is the Repository over the generated Queries, for every supported
+// database: SQLite, Turso and PostgreSQL (whose connection rewrites the
+// placeholders). Single statements go through the embedded Queries; writes that
+// must land together go through WithTx.
+type Store struct {
+	*Queries
+	db *sql.DB
+}
+
+// NewStore wraps database and its queries (which may be prepared) in a Store.
+func NewStore(database *sql.DB, queries *Queries) *Store {
+	return &Store{Queries: queries, db: database}
+}
+
+// WithTx runs fn as one unit of work: fn's queries share a database transaction
+// that is committed when fn returns nil and rolled back otherwise.
+//
+// Test databases hold a single connection, so fn must use q for every query; going
+// through the Store (or anything else backed by the same *sql.DB) inside fn blocks.
+func (s *Store) WithTx(ctx context.Context, fn func(q Querier) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	if err := fn(s.Queries.WithTx(tx)); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+			return fmt.Errorf("%w (rollback: %v)", err, rbErr)
+		}
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreWithTx(t *testing.T) {
+	eachDatabase(t, func(t *testing.T, open func() *sql.DB) {
+		database := open()
+		ctx := context.Background()
+		_, err := NewEmbeddedMigrator(database).Up(ctx, 0)
+		require.NoError(t, err)
+		store := NewStore(database, New(database))
+
+		err = store.WithTx(ctx, func(q Querier) error {
+			return q.SetCacheValue(ctx, SetCacheValueParams{Key: "committed", Value: "1"})
+		})
+		require.NoError(t, err)
+		value, err := store.GetCacheValue(ctx, "committed")
+		assert.NoError(t, err)
+		assert.Equal(t, "1", value)
+
+		boom := errors.New("boom")
+		err = store.WithTx(ctx, func(q Querier) error {
+			if err := q.SetCacheValue(ctx, SetCacheValueParams{Key: "rolled_back", Value: "1"}); err != nil {
+				return err
+			}
+			return boom
+		})
+		assert.ErrorIs(t, err, boom)
+		_, err = store.GetCacheValue(ctx, "rolled_back")
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+	})
+}
@@ -0,0 +1,156 @@
+package db
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// TimestampLayout is how timestamps are stored and serialized: UTC with millisecond
+// precision and a fixed width, so stored values sort and compare correctly as text.
+const TimestampLayout = "2006-01-02T15:04:05.000Z"
+
+// legacyTimestampLayouts are the formats written before migration 0006: RFC3339 from
+// the handlers and datetime('now') from the column defaults. Archives exported back
+// then still contain them.
+var legacyTimestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999",
+}
+
+// Timestamp is a UTC time truncated to milliseconds. sqlc maps every timestamp
+// column to it (see the overrides in sqlc.yaml).
+type Timestamp struct {
+	time.Time
+}
+
+// NewTimestamp converts t to UTC and truncates it to milliseconds.
+func NewTimestamp(t time.Time) Timestamp {
+	return Timestamp{t.UTC().Truncate(time.Millisecond)}
+}
+
+// Now returns the current time as a Timestamp.
+func Now() Timestamp {
+	return NewTimestamp(time.Now())
+}
+
+// ParseTimestamp parses a stored timestamp in the current or a legacy format.
+func ParseTimestamp(s string) (Timestamp, error) {
+	if t, err := time.Parse(TimestampLayout, s); err == nil {
+		return Timestamp{t}, nil
+	}
+	for _, layout := range legacyTimestampLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return NewTimestamp(t), nil
+		}
+	}
+	return Timestamp{}, fmt.Errorf("invalid timestamp %q", s)
+}
+
+// String formats t with TimestampLayout.
+func (t Timestamp) String() string {
+	return t.UTC().Format(TimestampLayout)
+}
+
+// Scan implements sql.Scanner.
+func (t *Timestamp) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case string:
+		parsed, err := ParseTimestamp(v)
+		if err != nil {
+			return err
+		}
+		*t = parsed
+	case []byte:
+		parsed, err := ParseTimestamp(string(v))
+		if err != nil {
+			return err
+		}
+		*t = parsed
+	case time.Time:
+		*t = NewTimestamp(v)
+	default:
+		return fmt.Errorf("cannot scan %T into Timestamp", src)
+	}
+	return nil
+}
+
+// Value implements driver.Valuer.
+func (t Timestamp) Value() (driver.Value, error) {
+	return t.String(), nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler and accepts the legacy formats.
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := ParseTimestamp(s)
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
+
+// NullTimestamp is a Timestamp that may be NULL.
+type NullTimestamp struct {
+	Timestamp Timestamp
+	Valid     bool
+}
+
+// NewNullTimestamp returns a valid NullTimestamp for t.
+func NewNullTimestamp(t time.Time) NullTimestamp {
+	return NullTimestamp{Timestamp: NewTimestamp(t), Valid: true}
+}
+
+// Ptr returns the timestamp, or nil when it is NULL.
+func (n NullTimestamp) Ptr() *Timestamp {
+	if !n.Valid {
+		return nil
+	}
+	return &n.Timestamp
+}
+
+// Scan implements sql.Scanner.
+func (n *NullTimestamp) Scan(src interface{}) error {
+	if src == nil {
+		*n = NullTimestamp{}
+		return nil
+	}
+	n.Valid = true
+	return n.Timestamp.Scan(src)
+}
+
+// Value implements driver.Valuer.
+func (n NullTimestamp) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	return n.Timestamp.Value()
+}
+
+// MarshalJSON implements json.Marshaler.
+func (n NullTimestamp) MarshalJSON() ([]byte, error) {
+	if !n.Valid {
+		return []byte("null"), nil
+	}
+	return n.Timestamp.MarshalJSON()
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (n *NullTimestamp) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*n = NullTimestamp{}
+		return nil
+	}
+	n.Valid = true
+	return n.Timestamp.UnmarshalJSON(data)
+}
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	want := time.Date(2025, 1, 2, 3, 4, 5, 678_000_000, time.UTC)
+	for _, s := range []string{
+		"2025-01-02T03:04:05.678Z",
+		"2025-01-02T03:04:05.678912Z",
+		"2025-01-02T04:04:05.678+01:00",
+		"2025-01-02 03:04:05.678",
+	} {
+		ts, err := ParseTimestamp(s)
+		require.NoError(t, err, s)
+		assert.True(t, ts.Equal(want), s)
+		assert.Equal(t, "2025-01-02T03:04:05.678Z", ts.String(), s)
+	}
+
+	ts, err := ParseTimestamp("2025-01-02 03:04:05")
+	require.NoError(t, err)
+	assert.Equal(t, "2025-01-02T03:04:05.000Z", ts.String())
+
+	_, err = ParseTimestamp("yesterday")
+	assert.Error(t, err)
+}
+
+func TestTimestampJSON(t *testing.T) {
+	var v struct {
+		At    Timestamp     `json:"at"`
+		Maybe NullTimestamp `json:"maybe"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(`{"at": "2025-01-02 03:04:05", "maybe": null}`), &v))
+	assert.False(t, v.Maybe.Valid)
+	out, err := json.Marshal(v)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"at": "2025-01-02T03:04:05.000Z", "maybe": null}`, string(out))
+}
+
+func TestNormalizeTimestampsMigration(t *testing.T) {
+	database, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	defer database.Close()
+	database.SetMaxOpenConns(1)
+	ctx := context.Background()
+
+	m := NewEmbeddedMigrator(database)
+	_, err = m.Up(ctx, 5)
+	require.NoError(t, err)
+	_, err = database.Exec(`INSERT INTO transactions (id, user_id, product_id, product_name, amount, status, created_at, updated_at, refund_date)
+		VALUES ('t1', 'luke', 'p', 'Product', 100, 'refunded', '2025-01-02T03:04:05Z', '2025-01-02 03:04:06', NULL)`)
+	require.NoError(t, err)
+	_, err = database.Exec(`INSERT INTO audit_events (subsystem, event_type) VALUES ('system', 'legacy')`)
+	require.NoError(t, err)
+
+	_, err = m.Up(ctx, 0)
+	require.NoError(t, err)
+
+	var createdAt, updatedAt string
+	require.NoError(t, database.QueryRow("SELECT created_at, updated_at FROM transactions WHERE id = 't1'").Scan(&createdAt, &updatedAt))
+	assert.Equal(t, "2025-01-02T03:04:05.000Z", createdAt)
+	assert.Equal(t, "2025-01-02T03:04:06.000Z", updatedAt)
+
+	q := New(database)
+	txn, err := q.GetTransaction(ctx, "t1")
+	require.NoError(t, err)
+	assert.False(t, txn.RefundDate.Valid)
+
+	// New rows get the default in the same layout
+	event, err := q.CreateAuditEvent(ctx, CreateAuditEventParams{Subsystem: "system", EventType: "fresh"})
+	require.NoError(t, err)
+	var raw string
+	require.NoError(t, database.QueryRow("SELECT timestamp FROM audit_events WHERE id = ?", event.ID).Scan(&raw))
+	_, err = time.Parse(TimestampLayout, raw)
+	assert.NoError(t, err, raw)
+
+	// The rebuilt table keeps counting ids after the migrated rows
+	assert.Equal(t, int64(2), event.ID)
+}
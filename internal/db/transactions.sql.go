@@ -10,9 +10,82 @@ import (
 	"database/sql"
 )
 
+const applyStripeDiscount = `-- name: ApplyStripeDiscount :exec
+UPDATE transactions
+SET amount = ?, discount_amount = ?, coupon_id = ?, updated_at = ?
+WHERE stripe_session_id = ? AND coupon_id IS NULL AND discount_amount = 0
+`
+
+type ApplyStripeDiscountParams struct {
+	Amount          int64          `json:"amount"`
+	DiscountAmount  int64          `json:"discount_amount"`
+	CouponID        sql.NullInt64  `json:"coupon_id"`
+	UpdatedAt       Timestamp      `json:"updated_at"`
+	StripeSessionID sql.NullString `json:"stripe_session_id"`
+}
+
+// Records a promotion code entered on the Stripe Checkout page. Transactions
+// created with a coupon keep theirs.
+func (q *Queries) ApplyStripeDiscount(ctx context.Context, arg ApplyStripeDiscountParams) error {
+	_, err := q.exec(ctx, q.applyStripeDiscountStmt, applyStripeDiscount,
+		arg.Amount,
+		arg.DiscountAmount,
+		arg.CouponID,
+		arg.UpdatedAt,
+		arg.StripeSessionID,
+	)
+	return err
+}
+
+const applyStripeTax = `-- name: ApplyStripeTax :exec
+UPDATE transactions
+SET amount = ?, tax_amount = ?, updated_at = ?
+WHERE stripe_session_id = ? AND tax_amount = 0
+`
+
+type ApplyStripeTaxParams struct {
+	Amount          int64          `json:"amount"`
+	TaxAmount       int64          `json:"tax_amount"`
+	UpdatedAt       Timestamp      `json:"updated_at"`
+	StripeSessionID sql.NullString `json:"stripe_session_id"`
+}
+
+// Records the tax Stripe automatic tax added to a session. Transactions taxed
+// locally keep their tax.
+func (q *Queries) ApplyStripeTax(ctx context.Context, arg ApplyStripeTaxParams) error {
+	_, err := q.exec(ctx, q.applyStripeTaxStmt, applyStripeTax,
+		arg.Amount,
+		arg.TaxAmount,
+		arg.UpdatedAt,
+		arg.StripeSessionID,
+	)
+	return err
+}
+
+const cancelPendingTransaction = `-- name: CancelPendingTransaction :execrows
+UPDATE transactions
+SET status = 'cancelled', updated_at = ?
+WHERE id = ? AND status = 'pending'
+`
+
+type CancelPendingTransactionParams struct {
+	UpdatedAt Timestamp `json:"updated_at"`
+	ID        string    `json:"id"`
+}
+
+// Cancels a transaction that is still pending and returns how many rows changed,
+// so a webhook that settled it first wins.
+func (q *Queries) CancelPendingTransaction(ctx context.Context, arg CancelPendingTransactionParams) (int64, error) {
+	result, err := q.exec(ctx, q.cancelPendingTransactionStmt, cancelPendingTransaction, arg.UpdatedAt, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const createTransaction = `-- name: CreateTransaction :exec
-INSERT INTO transactions (id, user_id, product_id, product_name, amount, stripe_session_id, stripe_payment_intent_id, status, created_at, updated_at, refund_date)
-VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+INSERT INTO transactions (id, user_id, product_id, product_name, amount, currency, stripe_session_id, stripe_payment_intent_id, status, created_at, updated_at, refund_date, expires_at, coupon_id, discount_amount, tax_amount, connected_account_id, application_fee_amount, transfer_amount, checkout_options)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 `
 
 type CreateTransactionParams struct {
@@ -21,12 +94,21 @@ type CreateTransactionParams struct {
 	ProductID             string         `json:"product_id"`
 	ProductName           string         `json:"product_name"`
 	Amount                int64          `json:"amount"`
+	Currency              string         `json:"currency"`
 	StripeSessionID       sql.NullString `json:"stripe_session_id"`
 	StripePaymentIntentID sql.NullString `json:"stripe_payment_intent_id"`
 	Status                string         `json:"status"`
-	CreatedAt             string         `json:"created_at"`
-	UpdatedAt             string         `json:"updated_at"`
-	RefundDate            sql.NullString `json:"refund_date"`
+	CreatedAt             Timestamp      `json:"created_at"`
+	UpdatedAt             Timestamp      `json:"updated_at"`
+	RefundDate            NullTimestamp  `json:"refund_date"`
+	ExpiresAt             NullTimestamp  `json:"expires_at"`
+	CouponID              sql.NullInt64  `json:"coupon_id"`
+	DiscountAmount        int64          `json:"discount_amount"`
+	TaxAmount             int64          `json:"tax_amount"`
+	ConnectedAccountID    sql.NullInt64  `json:"connected_account_id"`
+	ApplicationFeeAmount  int64          `json:"application_fee_amount"`
+	TransferAmount        int64          `json:"transfer_amount"`
+	CheckoutOptions       string         `json:"checkout_options"`
 }
 
 func (q *Queries) CreateTransaction(ctx context.Context, arg CreateTransactionParams) error {
@@ -36,18 +118,86 @@ func (q *Queries) CreateTransaction(ctx context.Context, arg CreateTransactionPa
 		arg.ProductID,
 		arg.ProductName,
 		arg.Amount,
+		arg.Currency,
 		arg.StripeSessionID,
 		arg.StripePaymentIntentID,
 		arg.Status,
 		arg.CreatedAt,
 		arg.UpdatedAt,
 		arg.RefundDate,
+		arg.ExpiresAt,
+		arg.CouponID,
+		arg.DiscountAmount,
+		arg.TaxAmount,
+		arg.ConnectedAccountID,
+		arg.ApplicationFeeAmount,
+		arg.TransferAmount,
+		arg.CheckoutOptions,
 	)
 	return err
 }
 
+const getOpenTransactionForCart = `-- name: GetOpenTransactionForCart :one
+SELECT id, user_id, product_id, product_name, amount, stripe_session_id, stripe_payment_intent_id, status, created_at, updated_at, refund_date, currency, expires_at, coupon_id, discount_amount, tax_amount, connected_account_id, application_fee_amount, transfer_amount, checkout_options
+FROM transactions
+WHERE user_id = ? AND product_id = ? AND amount = ? AND currency = ?
+  AND COALESCE(coupon_id, 0) = ? AND checkout_options = ?
+  AND status = 'pending' AND stripe_session_id IS NOT NULL AND expires_at > ?
+ORDER BY created_at DESC
+LIMIT 1
+`
+
+type GetOpenTransactionForCartParams struct {
+	UserID          string        `json:"user_id"`
+	ProductID       string        `json:"product_id"`
+	Amount          int64         `json:"amount"`
+	Currency        string        `json:"currency"`
+	CouponID        int64         `json:"coupon_id"`
+	CheckoutOptions string        `json:"checkout_options"`
+	ExpiresAt       NullTimestamp `json:"expires_at"`
+}
+
+// The newest pending transaction of the user for the same product, price,
+// coupon (0 for none) and checkout option overrides whose checkout session has
+// not expired yet.
+func (q *Queries) GetOpenTransactionForCart(ctx context.Context, arg GetOpenTransactionForCartParams) (Transaction, error) {
+	row := q.queryRow(ctx, q.getOpenTransactionForCartStmt, getOpenTransactionForCart,
+		arg.UserID,
+		arg.ProductID,
+		arg.Amount,
+		arg.Currency,
+		arg.CouponID,
+		arg.CheckoutOptions,
+		arg.ExpiresAt,
+	)
+	var i Transaction
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.ProductID,
+		&i.ProductName,
+		&i.Amount,
+		&i.StripeSessionID,
+		&i.StripePaymentIntentID,
+		&i.Status,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.RefundDate,
+		&i.Currency,
+		&i.ExpiresAt,
+		&i.CouponID,
+		&i.DiscountAmount,
+		&i.TaxAmount,
+		&i.ConnectedAccountID,
+		&i.ApplicationFeeAmount,
+		&i.TransferAmount,
+		&i.CheckoutOptions,
+	)
+	return i, err
+}
+
 const getTransaction = `-- name: GetTransaction :one
-SELECT id, user_id, product_id, product_name, amount, stripe_session_id, stripe_payment_intent_id, status, created_at, updated_at, refund_date
+SELECT id, user_id, product_id, product_name, amount, stripe_session_id, stripe_payment_intent_id, status, created_at, updated_at, refund_date, currency, expires_at, coupon_id, discount_amount, tax_amount, connected_account_id, application_fee_amount, transfer_amount, checkout_options
 FROM transactions
 WHERE id = ?
 LIMIT 1
@@ -68,12 +218,56 @@ func (q *Queries) GetTransaction(ctx context.Context, id string) (Transaction, e
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.RefundDate,
+		&i.Currency,
+		&i.ExpiresAt,
+		&i.CouponID,
+		&i.DiscountAmount,
+		&i.TaxAmount,
+		&i.ConnectedAccountID,
+		&i.ApplicationFeeAmount,
+		&i.TransferAmount,
+		&i.CheckoutOptions,
+	)
+	return i, err
+}
+
+const getTransactionByPaymentIntentID = `-- name: GetTransactionByPaymentIntentID :one
+SELECT id, user_id, product_id, product_name, amount, stripe_session_id, stripe_payment_intent_id, status, created_at, updated_at, refund_date, currency, expires_at, coupon_id, discount_amount, tax_amount, connected_account_id, application_fee_amount, transfer_amount, checkout_options
+FROM transactions
+WHERE stripe_payment_intent_id = ?
+LIMIT 1
+`
+
+func (q *Queries) GetTransactionByPaymentIntentID(ctx context.Context, stripePaymentIntentID sql.NullString) (Transaction, error) {
+	row := q.queryRow(ctx, q.getTransactionByPaymentIntentIDStmt, getTransactionByPaymentIntentID, stripePaymentIntentID)
+	var i Transaction
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.ProductID,
+		&i.ProductName,
+		&i.Amount,
+		&i.StripeSessionID,
+		&i.StripePaymentIntentID,
+		&i.Status,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.RefundDate,
+		&i.Currency,
+		&i.ExpiresAt,
+		&i.CouponID,
+		&i.DiscountAmount,
+		&i.TaxAmount,
+		&i.ConnectedAccountID,
+		&i.ApplicationFeeAmount,
+		&i.TransferAmount,
+		&i.CheckoutOptions,
 	)
 	return i, err
 }
 
 const getTransactionByStripeSessionID = `-- name: GetTransactionByStripeSessionID :one
-SELECT id, user_id, product_id, product_name, amount, stripe_session_id, stripe_payment_intent_id, status, created_at, updated_at, refund_date
+SELECT id, user_id, product_id, product_name, amount, stripe_session_id, stripe_payment_intent_id, status, created_at, updated_at, refund_date, currency, expires_at, coupon_id, discount_amount, tax_amount, connected_account_id, application_fee_amount, transfer_amount, checkout_options
 FROM transactions
 WHERE stripe_session_id = ?
 LIMIT 1
@@ -94,12 +288,21 @@ func (q *Queries) GetTransactionByStripeSessionID(ctx context.Context, stripeSes
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.RefundDate,
+		&i.Currency,
+		&i.ExpiresAt,
+		&i.CouponID,
+		&i.DiscountAmount,
+		&i.TaxAmount,
+		&i.ConnectedAccountID,
+		&i.ApplicationFeeAmount,
+		&i.TransferAmount,
+		&i.CheckoutOptions,
 	)
 	return i, err
 }
 
 const listAllTransactions = `-- name: ListAllTransactions :many
-SELECT id, user_id, product_id, product_name, amount, stripe_session_id, stripe_payment_intent_id, status, created_at, updated_at, refund_date
+SELECT id, user_id, product_id, product_name, amount, stripe_session_id, stripe_payment_intent_id, status, created_at, updated_at, refund_date, currency, expires_at, coupon_id, discount_amount, tax_amount, connected_account_id, application_fee_amount, transfer_amount, checkout_options
 FROM transactions
 ORDER BY created_at DESC
 LIMIT ? OFFSET ?
@@ -131,6 +334,73 @@ func (q *Queries) ListAllTransactions(ctx context.Context, arg ListAllTransactio
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.RefundDate,
+			&i.Currency,
+			&i.ExpiresAt,
+			&i.CouponID,
+			&i.DiscountAmount,
+			&i.TaxAmount,
+			&i.ConnectedAccountID,
+			&i.ApplicationFeeAmount,
+			&i.TransferAmount,
+			&i.CheckoutOptions,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPendingTransactionsCreatedBefore = `-- name: ListPendingTransactionsCreatedBefore :many
+SELECT id, user_id, product_id, product_name, amount, stripe_session_id, stripe_payment_intent_id, status, created_at, updated_at, refund_date, currency, expires_at, coupon_id, discount_amount, tax_amount, connected_account_id, application_fee_amount, transfer_amount, checkout_options
+FROM transactions
+WHERE status = 'pending' AND created_at < ?
+ORDER BY created_at ASC, id ASC
+LIMIT ? OFFSET ?
+`
+
+type ListPendingTransactionsCreatedBeforeParams struct {
+	CreatedAt Timestamp `json:"created_at"`
+	Limit     int64     `json:"limit"`
+	Offset    int64     `json:"offset"`
+}
+
+func (q *Queries) ListPendingTransactionsCreatedBefore(ctx context.Context, arg ListPendingTransactionsCreatedBeforeParams) ([]Transaction, error) {
+	rows, err := q.query(ctx, q.listPendingTransactionsCreatedBeforeStmt, listPendingTransactionsCreatedBefore, arg.CreatedAt, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Transaction{}
+	for rows.Next() {
+		var i Transaction
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.ProductID,
+			&i.ProductName,
+			&i.Amount,
+			&i.StripeSessionID,
+			&i.StripePaymentIntentID,
+			&i.Status,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.RefundDate,
+			&i.Currency,
+			&i.ExpiresAt,
+			&i.CouponID,
+			&i.DiscountAmount,
+			&i.TaxAmount,
+			&i.ConnectedAccountID,
+			&i.ApplicationFeeAmount,
+			&i.TransferAmount,
+			&i.CheckoutOptions,
 		); err != nil {
 			return nil, err
 		}
@@ -146,7 +416,7 @@ func (q *Queries) ListAllTransactions(ctx context.Context, arg ListAllTransactio
 }
 
 const listTransactionsByUserID = `-- name: ListTransactionsByUserID :many
-SELECT id, user_id, product_id, product_name, amount, stripe_session_id, stripe_payment_intent_id, status, created_at, updated_at, refund_date
+SELECT id, user_id, product_id, product_name, amount, stripe_session_id, stripe_payment_intent_id, status, created_at, updated_at, refund_date, currency, expires_at, coupon_id, discount_amount, tax_amount, connected_account_id, application_fee_amount, transfer_amount, checkout_options
 FROM transactions
 WHERE user_id = ?
 ORDER BY created_at DESC
@@ -180,6 +450,15 @@ func (q *Queries) ListTransactionsByUserID(ctx context.Context, arg ListTransact
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.RefundDate,
+			&i.Currency,
+			&i.ExpiresAt,
+			&i.CouponID,
+			&i.DiscountAmount,
+			&i.TaxAmount,
+			&i.ConnectedAccountID,
+			&i.ApplicationFeeAmount,
+			&i.TransferAmount,
+			&i.CheckoutOptions,
 		); err != nil {
 			return nil, err
 		}
@@ -194,44 +473,175 @@ func (q *Queries) ListTransactionsByUserID(ctx context.Context, arg ListTransact
 	return items, nil
 }
 
-const updateTransactionByPaymentIntentID = `-- name: UpdateTransactionByPaymentIntentID :exec
-UPDATE transactions 
-SET status = ?, updated_at = ?
-WHERE stripe_payment_intent_id = ?
+const listTransactionsCreatedBetween = `-- name: ListTransactionsCreatedBetween :many
+SELECT id, user_id, product_id, product_name, amount, stripe_session_id, stripe_payment_intent_id, status, created_at, updated_at, refund_date, currency, expires_at, coupon_id, discount_amount, tax_amount, connected_account_id, application_fee_amount, transfer_amount, checkout_options
+FROM transactions
+WHERE created_at >= ? AND created_at < ?
+ORDER BY created_at ASC, id ASC
+LIMIT ? OFFSET ?
 `
 
-type UpdateTransactionByPaymentIntentIDParams struct {
+type ListTransactionsCreatedBetweenParams struct {
+	CreatedAt   Timestamp `json:"created_at"`
+	CreatedAt_2 Timestamp `json:"created_at_2"`
+	Limit       int64     `json:"limit"`
+	Offset      int64     `json:"offset"`
+}
+
+func (q *Queries) ListTransactionsCreatedBetween(ctx context.Context, arg ListTransactionsCreatedBetweenParams) ([]Transaction, error) {
+	rows, err := q.query(ctx, q.listTransactionsCreatedBetweenStmt, listTransactionsCreatedBetween,
+		arg.CreatedAt,
+		arg.CreatedAt_2,
+		arg.Limit,
+		arg.Offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Transaction{}
+	for rows.Next() {
+		var i Transaction
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.ProductID,
+			&i.ProductName,
+			&i.Amount,
+			&i.StripeSessionID,
+			&i.StripePaymentIntentID,
+			&i.Status,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.RefundDate,
+			&i.Currency,
+			&i.ExpiresAt,
+			&i.CouponID,
+			&i.DiscountAmount,
+			&i.TaxAmount,
+			&i.ConnectedAccountID,
+			&i.ApplicationFeeAmount,
+			&i.TransferAmount,
+			&i.CheckoutOptions,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const repairTransaction = `-- name: RepairTransaction :execrows
+UPDATE transactions
+SET status = ?, stripe_payment_intent_id = ?, refund_date = ?, updated_at = ?
+WHERE id = ? AND status = ?
+`
+
+type RepairTransactionParams struct {
 	Status                string         `json:"status"`
-	UpdatedAt             string         `json:"updated_at"`
 	StripePaymentIntentID sql.NullString `json:"stripe_payment_intent_id"`
+	RefundDate            NullTimestamp  `json:"refund_date"`
+	UpdatedAt             Timestamp      `json:"updated_at"`
+	ID                    string         `json:"id"`
+	Status_2              string         `json:"status_2"`
+}
+
+// Repairs a transaction still in the given status and returns how many rows
+// changed, so concurrent repairs and webhooks apply a change once.
+func (q *Queries) RepairTransaction(ctx context.Context, arg RepairTransactionParams) (int64, error) {
+	result, err := q.exec(ctx, q.repairTransactionStmt, repairTransaction,
+		arg.Status,
+		arg.StripePaymentIntentID,
+		arg.RefundDate,
+		arg.UpdatedAt,
+		arg.ID,
+		arg.Status_2,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const setTransactionApplicationFee = `-- name: SetTransactionApplicationFee :exec
+UPDATE transactions
+SET application_fee_amount = ?, updated_at = ?
+WHERE id = ?
+`
+
+type SetTransactionApplicationFeeParams struct {
+	ApplicationFeeAmount int64     `json:"application_fee_amount"`
+	UpdatedAt            Timestamp `json:"updated_at"`
+	ID                   string    `json:"id"`
 }
 
-func (q *Queries) UpdateTransactionByPaymentIntentID(ctx context.Context, arg UpdateTransactionByPaymentIntentIDParams) error {
-	_, err := q.exec(ctx, q.updateTransactionByPaymentIntentIDStmt, updateTransactionByPaymentIntentID, arg.Status, arg.UpdatedAt, arg.StripePaymentIntentID)
+// Makes the platform's part of a vendor's transaction up to its amount, once
+// Stripe has added tax the vendor's transfer doesn't include.
+func (q *Queries) SetTransactionApplicationFee(ctx context.Context, arg SetTransactionApplicationFeeParams) error {
+	_, err := q.exec(ctx, q.setTransactionApplicationFeeStmt, setTransactionApplicationFee, arg.ApplicationFeeAmount, arg.UpdatedAt, arg.ID)
 	return err
 }
 
-const updateTransactionByPaymentIntentIDWithRefundDate = `-- name: UpdateTransactionByPaymentIntentIDWithRefundDate :exec
-UPDATE transactions 
-SET status = ?, updated_at = ?, refund_date = ?
-WHERE stripe_payment_intent_id = ?
+const updateTransactionByPaymentIntentID = `-- name: UpdateTransactionByPaymentIntentID :execrows
+UPDATE transactions
+SET status = ?1, updated_at = ?2
+WHERE stripe_payment_intent_id = ?3
+  AND (status = 'pending'
+    OR (status IN ('failed', 'cancelled') AND ?1 IN ('failed', 'cancelled', 'completed', 'refunded'))
+    OR (status = 'completed' AND ?1 IN ('completed', 'refunded'))
+    OR (status = 'refunded' AND ?1 = 'refunded'))
+`
+
+type UpdateTransactionByPaymentIntentIDParams struct {
+	Status                string         `json:"status"`
+	UpdatedAt             Timestamp      `json:"updated_at"`
+	StripePaymentIntentID sql.NullString `json:"stripe_payment_intent_id"`
+}
+
+// Moves a transaction's status forward like UpdateTransactionWithStripeData.
+func (q *Queries) UpdateTransactionByPaymentIntentID(ctx context.Context, arg UpdateTransactionByPaymentIntentIDParams) (int64, error) {
+	result, err := q.exec(ctx, q.updateTransactionByPaymentIntentIDStmt, updateTransactionByPaymentIntentID, arg.Status, arg.UpdatedAt, arg.StripePaymentIntentID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateTransactionByPaymentIntentIDWithRefundDate = `-- name: UpdateTransactionByPaymentIntentIDWithRefundDate :execrows
+UPDATE transactions
+SET status = ?1, updated_at = ?2, refund_date = ?3
+WHERE stripe_payment_intent_id = ?4
+  AND (status = 'pending'
+    OR (status IN ('failed', 'cancelled') AND ?1 IN ('failed', 'cancelled', 'completed', 'refunded'))
+    OR (status = 'completed' AND ?1 IN ('completed', 'refunded'))
+    OR (status = 'refunded' AND ?1 = 'refunded'))
 `
 
 type UpdateTransactionByPaymentIntentIDWithRefundDateParams struct {
 	Status                string         `json:"status"`
-	UpdatedAt             string         `json:"updated_at"`
-	RefundDate            sql.NullString `json:"refund_date"`
+	UpdatedAt             Timestamp      `json:"updated_at"`
+	RefundDate            NullTimestamp  `json:"refund_date"`
 	StripePaymentIntentID sql.NullString `json:"stripe_payment_intent_id"`
 }
 
-func (q *Queries) UpdateTransactionByPaymentIntentIDWithRefundDate(ctx context.Context, arg UpdateTransactionByPaymentIntentIDWithRefundDateParams) error {
-	_, err := q.exec(ctx, q.updateTransactionByPaymentIntentIDWithRefundDateStmt, updateTransactionByPaymentIntentIDWithRefundDate,
+// Moves a transaction's status forward like UpdateTransactionWithStripeData.
+func (q *Queries) UpdateTransactionByPaymentIntentIDWithRefundDate(ctx context.Context, arg UpdateTransactionByPaymentIntentIDWithRefundDateParams) (int64, error) {
+	result, err := q.exec(ctx, q.updateTransactionByPaymentIntentIDWithRefundDateStmt, updateTransactionByPaymentIntentIDWithRefundDate,
 		arg.Status,
 		arg.UpdatedAt,
 		arg.RefundDate,
 		arg.StripePaymentIntentID,
 	)
-	return err
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
 const updateTransactionStatus = `-- name: UpdateTransactionStatus :exec
@@ -241,9 +651,9 @@ WHERE id = ?
 `
 
 type UpdateTransactionStatusParams struct {
-	Status    string `json:"status"`
-	UpdatedAt string `json:"updated_at"`
-	ID        string `json:"id"`
+	Status    string    `json:"status"`
+	UpdatedAt Timestamp `json:"updated_at"`
+	ID        string    `json:"id"`
 }
 
 func (q *Queries) UpdateTransactionStatus(ctx context.Context, arg UpdateTransactionStatusParams) error {
@@ -251,25 +661,35 @@ func (q *Queries) UpdateTransactionStatus(ctx context.Context, arg UpdateTransac
 	return err
 }
 
-const updateTransactionWithStripeData = `-- name: UpdateTransactionWithStripeData :exec
-UPDATE transactions 
-SET stripe_payment_intent_id = ?, status = ?, updated_at = ?
-WHERE stripe_session_id = ?
+const updateTransactionWithStripeData = `-- name: UpdateTransactionWithStripeData :execrows
+UPDATE transactions
+SET stripe_payment_intent_id = ?1, status = ?2, updated_at = ?3
+WHERE stripe_session_id = ?4
+  AND (status = 'pending'
+    OR (status IN ('failed', 'cancelled') AND ?2 IN ('failed', 'cancelled', 'completed', 'refunded'))
+    OR (status = 'completed' AND ?2 IN ('completed', 'refunded'))
+    OR (status = 'refunded' AND ?2 = 'refunded'))
 `
 
 type UpdateTransactionWithStripeDataParams struct {
 	StripePaymentIntentID sql.NullString `json:"stripe_payment_intent_id"`
 	Status                string         `json:"status"`
-	UpdatedAt             string         `json:"updated_at"`
+	UpdatedAt             Timestamp      `json:"updated_at"`
 	StripeSessionID       sql.NullString `json:"stripe_session_id"`
 }
 
-func (q *Queries) UpdateTransactionWithStripeData(ctx context.Context, arg UpdateTransactionWithStripeDataParams) error {
-	_, err := q.exec(ctx, q.updateTransactionWithStripeDataStmt, updateTransactionWithStripeData,
+// Moves a transaction's status forward (pending, then failed or cancelled, then
+// completed, then refunded) and returns how many rows changed, so late or
+// redelivered webhooks cannot move it back.
+func (q *Queries) UpdateTransactionWithStripeData(ctx context.Context, arg UpdateTransactionWithStripeDataParams) (int64, error) {
+	result, err := q.exec(ctx, q.updateTransactionWithStripeDataStmt, updateTransactionWithStripeData,
 		arg.StripePaymentIntentID,
 		arg.Status,
 		arg.UpdatedAt,
 		arg.StripeSessionID,
 	)
-	return err
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
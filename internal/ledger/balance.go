@@ -0,0 +1,74 @@
+package ledger
+
+import (
+	"sort"
+
+	"stripe-go-spike/internal/db"
+	"stripe-go-spike/internal/money"
+)
+
+// Balance is an account's balance in the direction of its normal balance, so
+// revenue and refunds both show as positive amounts.
+type Balance struct {
+	Account string       `json:"account"`
+	Type    AccountType  `json:"type"`
+	Amount  money.Amount `json:"amount"`
+}
+
+// NewBalance converts a debit-positive sum of postings into a Balance.
+func NewBalance(code string, accountType AccountType, currency string, sum int64) Balance {
+	if accountType.CreditNormal() {
+		sum = -sum
+	}
+	return Balance{Account: code, Type: accountType, Amount: money.Amount{Minor: sum, Currency: currency}}
+}
+
+// AccountBalances converts ListLedgerAccountBalances rows.
+func AccountBalances(rows []db.ListLedgerAccountBalancesRow) []Balance {
+	balances := make([]Balance, len(rows))
+	for i, r := range rows {
+		balances[i] = NewBalance(r.Code, AccountType(r.Type), r.Currency, r.Balance)
+	}
+	return balances
+}
+
+// UserBalances converts ListLedgerUserBalances rows.
+func UserBalances(rows []db.ListLedgerUserBalancesRow) []Balance {
+	balances := make([]Balance, len(rows))
+	for i, r := range rows {
+		balances[i] = NewBalance(r.Code, AccountType(r.Type), r.Currency, r.Balance)
+	}
+	return balances
+}
+
+// NetRevenue returns revenue less refunds, disputes, vendor transfers and fees,
+// per currency and ordered by currency code. Collected tax is a liability, not
+// revenue.
+func NetRevenue(balances []Balance) ([]money.Amount, error) {
+	totals := map[string]money.Amount{}
+	for _, b := range balances {
+		total, ok := totals[b.Amount.Currency]
+		if !ok {
+			total = money.Amount{Currency: b.Amount.Currency}
+		}
+		var err error
+		switch b.Type {
+		case Revenue:
+			total, err = total.Add(b.Amount)
+		case ContraRevenue, Expense:
+			total, err = total.Sub(b.Amount)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		totals[b.Amount.Currency] = total
+	}
+	net := make([]money.Amount, 0, len(totals))
+	for _, total := range totals {
+		net = append(net, total)
+	}
+	sort.Slice(net, func(i, j int) bool { return net[i].Currency < net[j].Currency })
+	return net, nil
+}
@@ -0,0 +1,254 @@
+// Package ledger records money movements as balanced double-entry journal entries.
+//
+// Every entry debits and credits accounts by the same total, so the ledger as a
+// whole always sums to zero. Amounts are minor units of the entry's currency;
+// debits are positive and credits negative. Entries are never updated: a reversal
+// is a new entry.
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"stripe-go-spike/internal/db"
+	"stripe-go-spike/internal/money"
+)
+
+var (
+	// ErrUnbalanced is returned for entries whose postings don't sum to zero.
+	ErrUnbalanced = errors.New("journal entry does not balance")
+	// ErrDuplicate is returned when an entry with the same idempotency key was
+	// already posted, e.g. for a redelivered webhook.
+	ErrDuplicate = errors.New("journal entry already posted")
+)
+
+// AccountType determines an account's normal balance.
+type AccountType string
+
+const (
+	Asset         AccountType = "asset"
+	Revenue       AccountType = "revenue"
+	ContraRevenue AccountType = "contra_revenue" // reduces revenue, e.g. refunds
+	Expense       AccountType = "expense"
+	Liability     AccountType = "liability" // owed to others, e.g. collected tax
+)
+
+// CreditNormal reports whether credits increase the account, so its balance is
+// shown negated.
+func (t AccountType) CreditNormal() bool {
+	return t == Revenue || t == Liability
+}
+
+// Account is an entry in the chart of accounts. Accounts exist once per currency.
+type Account struct {
+	Code string
+	Type AccountType
+}
+
+// The chart of accounts.
+var (
+	StripeBalance  = Account{Code: "stripe_balance", Type: Asset}    // funds held by Stripe
+	SalesRevenue   = Account{Code: "revenue", Type: Revenue}         // completed charges
+	Refunds        = Account{Code: "refunds", Type: ContraRevenue}   // money returned to customers
+	DisputeLosses  = Account{Code: "disputes", Type: ContraRevenue}  // money withdrawn by disputes
+	ProcessingFees = Account{Code: "processing_fees", Type: Expense} // Stripe and dispute fees
+	TaxPayable     = Account{Code: "tax_payable", Type: Liability}   // tax collected for the tax authorities
+	// VendorTransfers is the vendors' share of marketplace sales, passed on to
+	// their connected accounts
+	VendorTransfers = Account{Code: "vendor_transfers", Type: ContraRevenue}
+)
+
+// Kind classifies journal entries.
+type Kind string
+
+const (
+	KindCharge   Kind = "charge"
+	KindRefund   Kind = "refund"
+	KindDispute  Kind = "dispute"
+	KindFee      Kind = "fee"
+	KindTransfer Kind = "transfer"
+)
+
+// Posting moves Amount minor units into (debit, positive) or out of (credit,
+// negative) an account.
+type Posting struct {
+	Account Account
+	Amount  int64
+}
+
+// Entry is a journal entry and its postings, all in one currency.
+type Entry struct {
+	// IdempotencyKey makes posting the same movement twice a no-op (ErrDuplicate)
+	IdempotencyKey string
+	Kind           Kind
+	TransactionID  string
+	UserID         string
+	StripeEventID  string
+	Description    string
+	Currency       string
+	Postings       []Posting
+}
+
+// Validate checks that the entry has a key, a known currency and at least two
+// non-zero postings that sum to zero.
+func (e Entry) Validate() error {
+	if e.IdempotencyKey == "" {
+		return errors.New("journal entry needs an idempotency key")
+	}
+	if err := (money.Amount{Currency: e.Currency}).Validate(); err != nil {
+		return err
+	}
+	if len(e.Postings) < 2 {
+		return fmt.Errorf("%w: %s has %d posting(s)", ErrUnbalanced, e.IdempotencyKey, len(e.Postings))
+	}
+	sum := money.Amount{Currency: e.Currency}
+	for _, p := range e.Postings {
+		if p.Amount == 0 {
+			return fmt.Errorf("journal entry %s has a zero posting to %s", e.IdempotencyKey, p.Account.Code)
+		}
+		var err error
+		if sum, err = sum.Add(money.Amount{Minor: p.Amount, Currency: e.Currency}); err != nil {
+			return err
+		}
+	}
+	if !sum.IsZero() {
+		return fmt.Errorf("%w: %s is off by %s", ErrUnbalanced, e.IdempotencyKey, sum)
+	}
+	return nil
+}
+
+// Post validates and writes the entry through q, normally inside Store.WithTx
+// together with the change it records.
+func Post(ctx context.Context, q db.Querier, e Entry) (db.JournalEntry, error) {
+	if err := e.Validate(); err != nil {
+		return db.JournalEntry{}, err
+	}
+	entry, err := q.CreateJournalEntry(ctx, db.CreateJournalEntryParams{
+		IdempotencyKey: e.IdempotencyKey,
+		Kind:           string(e.Kind),
+		TransactionID:  nullString(e.TransactionID),
+		UserID:         nullString(e.UserID),
+		StripeEventID:  nullString(e.StripeEventID),
+		Description:    e.Description,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return db.JournalEntry{}, fmt.Errorf("%w: %s", ErrDuplicate, e.IdempotencyKey)
+	}
+	if err != nil {
+		return db.JournalEntry{}, fmt.Errorf("create journal entry %s: %w", e.IdempotencyKey, err)
+	}
+	for _, p := range e.Postings {
+		account, err := q.EnsureLedgerAccount(ctx, db.EnsureLedgerAccountParams{
+			Code:     p.Account.Code,
+			Type:     string(p.Account.Type),
+			Currency: e.Currency,
+		})
+		if err != nil {
+			return db.JournalEntry{}, fmt.Errorf("ledger account %s: %w", p.Account.Code, err)
+		}
+		err = q.CreateLedgerPosting(ctx, db.CreateLedgerPostingParams{
+			JournalEntryID: entry.ID,
+			AccountID:      account.ID,
+			UserID:         entry.UserID,
+			Amount:         p.Amount,
+		})
+		if err != nil {
+			return db.JournalEntry{}, fmt.Errorf("post %s to %s: %w", e.IdempotencyKey, p.Account.Code, err)
+		}
+	}
+	return entry, nil
+}
+
+// Charge records a completed payment for txn: Stripe now holds the money and it is
+// earned revenue, except for the transaction's tax, which is owed. Only one charge
+// is posted per transaction.
+func Charge(txn db.Transaction, amount money.Amount, eventID string) Entry {
+	e := movement(KindCharge, "charge:"+txn.ID, txn, eventID, "Payment received",
+		amount, StripeBalance, SalesRevenue)
+	return splitTax(e, 1, taxShare(txn, amount))
+}
+
+// Refund records money returned to the customer of txn. Entries are keyed by the
+// Stripe refund ID, so the webhook and reconciliation post a refund only once.
+// The refunded share of the transaction's tax is no longer owed.
+func Refund(txn db.Transaction, amount money.Amount, refundID, eventID string) Entry {
+	e := movement(KindRefund, "refund:"+refundID, txn, eventID, "Payment refunded",
+		amount, Refunds, StripeBalance)
+	return splitTax(e, 0, taxShare(txn, amount))
+}
+
+// Dispute records money withdrawn from the Stripe balance by a dispute on txn,
+// along with its share of the transaction's tax.
+func Dispute(txn db.Transaction, amount money.Amount, disputeID, eventID string) Entry {
+	e := movement(KindDispute, "dispute:"+disputeID, txn, eventID, "Payment disputed",
+		amount, DisputeLosses, StripeBalance)
+	return splitTax(e, 0, taxShare(txn, amount))
+}
+
+// taxShare returns the part of amount that is tax: all of the transaction's tax
+// for the full amount, proportionally less (rounded half up) for part of it.
+func taxShare(txn db.Transaction, amount money.Amount) int64 {
+	if txn.TaxAmount <= 0 || txn.Amount <= 0 || amount.Currency != txn.Currency || amount.Minor <= 0 {
+		return 0
+	}
+	if amount.Minor >= txn.Amount {
+		return txn.TaxAmount
+	}
+	return (amount.Minor*txn.TaxAmount + txn.Amount/2) / txn.Amount
+}
+
+// splitTax moves tax out of the entry's revenue-side posting i into TaxPayable,
+// on the same side.
+func splitTax(e Entry, i int, tax int64) Entry {
+	if tax <= 0 {
+		return e
+	}
+	p := e.Postings[i]
+	if p.Amount < 0 {
+		tax = -tax
+	}
+	if p.Amount == tax {
+		e.Postings[i].Account = TaxPayable
+		return e
+	}
+	e.Postings[i].Amount -= tax
+	e.Postings = append(e.Postings, Posting{Account: TaxPayable, Amount: tax})
+	return e
+}
+
+// Fee records a Stripe fee charged against the balance for txn, keyed by the
+// Stripe object the fee belongs to.
+func Fee(txn db.Transaction, amount money.Amount, sourceID, eventID string) Entry {
+	return movement(KindFee, "fee:"+sourceID, txn, eventID, "Stripe fee",
+		amount, ProcessingFees, StripeBalance)
+}
+
+// Transfer records the vendor's share of txn leaving the Stripe balance for
+// their connected account. Destination charges transfer once, with the charge.
+func Transfer(txn db.Transaction, amount money.Amount, eventID string) Entry {
+	return movement(KindTransfer, "transfer:"+txn.ID, txn, eventID, "Transfer to vendor",
+		amount, VendorTransfers, StripeBalance)
+}
+
+// movement debits one account and credits another by amount.
+func movement(kind Kind, key string, txn db.Transaction, eventID, description string, amount money.Amount, debit, credit Account) Entry {
+	return Entry{
+		IdempotencyKey: key,
+		Kind:           kind,
+		TransactionID:  txn.ID,
+		UserID:         txn.UserID,
+		StripeEventID:  eventID,
+		Description:    description,
+		Currency:       amount.Currency,
+		Postings: []Posting{
+			{Account: debit, Amount: amount.Minor},
+			{Account: credit, Amount: -amount.Minor},
+		},
+	}
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
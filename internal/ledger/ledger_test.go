@@ -0,0 +1,134 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"stripe-go-spike/internal/db"
+	"stripe-go-spike/internal/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEntryValidate(t *testing.T) {
+	txn := db.Transaction{ID: "txn_1", UserID: "luke", Amount: 4999, Currency: "USD"}
+	assert.NoError(t, Charge(txn, money.MustNew(4999, "USD"), "evt_1").Validate())
+
+	unbalanced := Charge(txn, money.MustNew(4999, "USD"), "evt_1")
+	unbalanced.Postings[1].Amount = -4000
+	assert.ErrorIs(t, unbalanced.Validate(), ErrUnbalanced)
+
+	single := Charge(txn, money.MustNew(4999, "USD"), "evt_1")
+	single.Postings = single.Postings[:1]
+	assert.ErrorIs(t, single.Validate(), ErrUnbalanced)
+
+	assert.Error(t, Charge(txn, money.Amount{Currency: "USD"}, "evt_1").Validate())
+	assert.ErrorIs(t, Charge(txn, money.Amount{Minor: 1, Currency: "XYZ"}, "evt_1").Validate(), money.ErrUnknownCurrency)
+}
+
+func TestTaxSplit(t *testing.T) {
+	// 4999 plus 19% tax
+	txn := db.Transaction{ID: "txn_1", UserID: "jinny", Amount: 5949, TaxAmount: 950, Currency: "EUR"}
+
+	charge := Charge(txn, money.MustNew(5949, "EUR"), "evt_1")
+	require.NoError(t, charge.Validate())
+	assert.Equal(t, []Posting{
+		{Account: StripeBalance, Amount: 5949},
+		{Account: SalesRevenue, Amount: -4999},
+		{Account: TaxPayable, Amount: -950},
+	}, charge.Postings)
+
+	// Refunding 1000 returns 160 of tax, 159.69 rounded
+	refund := Refund(txn, money.MustNew(1000, "EUR"), "re_1", "evt_2")
+	require.NoError(t, refund.Validate())
+	assert.Equal(t, []Posting{
+		{Account: Refunds, Amount: 840},
+		{Account: StripeBalance, Amount: -1000},
+		{Account: TaxPayable, Amount: 160},
+	}, refund.Postings)
+
+	dispute := Dispute(txn, money.MustNew(5949, "EUR"), "dp_1", "evt_3")
+	require.NoError(t, dispute.Validate())
+	assert.Equal(t, int64(950), dispute.Postings[2].Amount)
+
+	untaxed := Charge(db.Transaction{ID: "txn_2", Amount: 4999, Currency: "EUR"}, money.MustNew(4999, "EUR"), "evt_4")
+	assert.Len(t, untaxed.Postings, 2)
+	assert.Equal(t, money.MustNew(950, "EUR"), NewBalance(TaxPayable.Code, Liability, "EUR", -950).Amount, "owed tax shows positive")
+}
+
+func TestTransfer(t *testing.T) {
+	// A vendor's 4999 sale with 950 tax; the vendor gets 4499
+	txn := db.Transaction{ID: "txn_1", UserID: "jinny", Amount: 5949, TaxAmount: 950, TransferAmount: 4499, Currency: "EUR"}
+	transfer := Transfer(txn, money.MustNew(txn.TransferAmount, "EUR"), "evt_1")
+	require.NoError(t, transfer.Validate())
+	assert.Equal(t, "transfer:txn_1", transfer.IdempotencyKey)
+	assert.Equal(t, []Posting{
+		{Account: VendorTransfers, Amount: 4499},
+		{Account: StripeBalance, Amount: -4499},
+	}, transfer.Postings)
+}
+
+func TestPostAndBalances(t *testing.T) {
+	database, err := db.NewTestConnection()
+	require.NoError(t, err)
+	defer database.Close()
+	q := db.New(database)
+	ctx := context.Background()
+
+	luke := db.Transaction{ID: "txn_luke", UserID: "luke", Amount: 4999, Currency: "USD"}
+	jinny := db.Transaction{ID: "txn_jinny", UserID: "jinny", Amount: 4599, Currency: "EUR"}
+	for _, e := range []Entry{
+		Charge(luke, money.MustNew(4999, "USD"), "evt_1"),
+		Fee(luke, money.MustNew(175, "USD"), "ch_1", "evt_1"),
+		Refund(luke, money.MustNew(1000, "USD"), "re_1", "evt_2"),
+		Charge(jinny, money.MustNew(4599, "EUR"), "evt_3"),
+		Dispute(jinny, money.MustNew(4599, "EUR"), "dp_1", "evt_4"),
+		Fee(jinny, money.MustNew(1500, "EUR"), "dp_1", "evt_4"),
+	} {
+		_, err := Post(ctx, q, e)
+		require.NoError(t, err, e.IdempotencyKey)
+	}
+
+	// A second charge for the same transaction is rejected and writes nothing
+	_, err = Post(ctx, q, Charge(luke, money.MustNew(4999, "USD"), "evt_5"))
+	assert.ErrorIs(t, err, ErrDuplicate)
+
+	rows, err := q.ListLedgerAccountBalances(ctx)
+	require.NoError(t, err)
+	balances := AccountBalances(rows)
+	byAccount := map[string]money.Amount{}
+	var sum int64
+	for _, b := range balances {
+		byAccount[b.Account+" "+b.Amount.Currency] = b.Amount
+	}
+	for _, r := range rows {
+		sum += r.Balance
+	}
+	assert.Zero(t, sum, "postings must sum to zero")
+	assert.Equal(t, map[string]money.Amount{
+		"disputes EUR":        money.MustNew(4599, "EUR"),
+		"processing_fees EUR": money.MustNew(1500, "EUR"),
+		"processing_fees USD": money.MustNew(175, "USD"),
+		"refunds USD":         money.MustNew(1000, "USD"),
+		"revenue EUR":         money.MustNew(4599, "EUR"),
+		"revenue USD":         money.MustNew(4999, "USD"),
+		"stripe_balance EUR":  money.MustNew(-1500, "EUR"),
+		"stripe_balance USD":  money.MustNew(3824, "USD"),
+	}, byAccount)
+
+	net, err := NetRevenue(balances)
+	require.NoError(t, err)
+	assert.Equal(t, []money.Amount{money.MustNew(-1500, "EUR"), money.MustNew(3824, "USD")}, net)
+
+	userRows, err := q.ListLedgerUserBalances(ctx, sql.NullString{String: "luke", Valid: true})
+	require.NoError(t, err)
+	net, err = NetRevenue(UserBalances(userRows))
+	require.NoError(t, err)
+	assert.Equal(t, []money.Amount{money.MustNew(3824, "USD")}, net)
+
+	entries, err := q.ListJournalEntriesByTransactionID(ctx, sql.NullString{String: "txn_luke", Valid: true})
+	require.NoError(t, err)
+	assert.Len(t, entries, 3)
+}
@@ -0,0 +1,87 @@
+package money
+
+import "strings"
+
+// Currency describes how an ISO 4217 currency is stored and displayed.
+type Currency struct {
+	Code     string // ISO 4217 code, upper case
+	Exponent int    // digits after the decimal point; amounts are stored in 10^-Exponent units
+	Symbol   string // display prefix; empty means the code is shown instead
+	// Whole marks currencies Stripe counts in a minor unit that is no longer in
+	// use: amounts are whole major units, stored as multiples of 10^Exponent and
+	// shown without decimals
+	Whole bool
+}
+
+// currencies lists the currencies accepted for payments. Exponents follow Stripe,
+// which treats the zero-decimal currencies below as having no minor unit and ISK,
+// for backwards compatibility, as having two.
+var currencies = map[string]Currency{}
+
+func init() {
+	for _, c := range []Currency{
+		// Two-decimal currencies
+		{Code: "USD", Exponent: 2, Symbol: "$"},
+		{Code: "EUR", Exponent: 2, Symbol: "€"},
+		{Code: "GBP", Exponent: 2, Symbol: "£"},
+		{Code: "CAD", Exponent: 2, Symbol: "CA$"},
+		{Code: "AUD", Exponent: 2, Symbol: "A$"},
+		{Code: "NZD", Exponent: 2, Symbol: "NZ$"},
+		{Code: "CHF", Exponent: 2},
+		{Code: "SEK", Exponent: 2},
+		{Code: "NOK", Exponent: 2},
+		{Code: "DKK", Exponent: 2},
+		{Code: "PLN", Exponent: 2},
+		{Code: "CZK", Exponent: 2},
+		{Code: "SGD", Exponent: 2},
+		{Code: "HKD", Exponent: 2},
+		{Code: "MXN", Exponent: 2},
+		{Code: "BRL", Exponent: 2, Symbol: "R$"},
+		{Code: "INR", Exponent: 2, Symbol: "₹"},
+		{Code: "ZAR", Exponent: 2},
+		{Code: "ISK", Exponent: 2, Whole: true},
+		// Zero-decimal currencies
+		{Code: "JPY", Exponent: 0, Symbol: "¥"},
+		{Code: "KRW", Exponent: 0, Symbol: "₩"},
+		{Code: "VND", Exponent: 0},
+		{Code: "CLP", Exponent: 0},
+		{Code: "PYG", Exponent: 0},
+		{Code: "UGX", Exponent: 0},
+		{Code: "XAF", Exponent: 0},
+		{Code: "XOF", Exponent: 0},
+		// Three-decimal currencies
+		{Code: "KWD", Exponent: 3},
+		{Code: "BHD", Exponent: 3},
+		{Code: "JOD", Exponent: 3},
+		{Code: "OMR", Exponent: 3},
+		{Code: "TND", Exponent: 3},
+	} {
+		currencies[c.Code] = c
+	}
+}
+
+// LookupCurrency returns the currency for an ISO 4217 code in any case.
+func LookupCurrency(code string) (Currency, bool) {
+	c, ok := currencies[strings.ToUpper(code)]
+	return c, ok
+}
+
+// countryCurrencies maps ISO 3166-1 alpha-2 country codes to their currency, for
+// picking a default from a country or locale hint.
+var countryCurrencies = map[string]string{
+	"US": "USD", "CA": "CAD", "MX": "MXN", "BR": "BRL", "CL": "CLP", "PY": "PYG",
+	"GB": "GBP", "IE": "EUR", "FR": "EUR", "DE": "EUR", "AT": "EUR", "BE": "EUR",
+	"NL": "EUR", "LU": "EUR", "IT": "EUR", "ES": "EUR", "PT": "EUR", "FI": "EUR",
+	"GR": "EUR", "SK": "EUR", "SI": "EUR", "EE": "EUR", "LV": "EUR", "LT": "EUR",
+	"HR": "EUR", "CY": "EUR", "MT": "EUR", "CH": "CHF", "LI": "CHF", "SE": "SEK",
+	"NO": "NOK", "DK": "DKK", "IS": "ISK", "PL": "PLN", "CZ": "CZK",
+	"JP": "JPY", "KR": "KRW", "VN": "VND", "SG": "SGD", "HK": "HKD", "IN": "INR",
+	"AU": "AUD", "NZ": "NZD", "ZA": "ZAR", "UG": "UGX",
+	"KW": "KWD", "BH": "BHD", "JO": "JOD", "OM": "OMR", "TN": "TND",
+}
+
+// CurrencyForCountry returns the currency used in a country, if known.
+func CurrencyForCountry(country string) (string, bool) {
+	c, ok := countryCurrencies[strings.ToUpper(country)]
+	return c, ok
+}
@@ -0,0 +1,224 @@
+// Package money represents amounts as integer minor units of an ISO 4217 currency.
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrUnknownCurrency is returned for currency codes missing from the table.
+	ErrUnknownCurrency = errors.New("unknown currency")
+	// ErrCurrencyMismatch is returned when combining amounts in different currencies.
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+	// ErrOverflow is returned when arithmetic would not fit in int64 minor units.
+	ErrOverflow = errors.New("amount overflows int64")
+)
+
+// Amount is a quantity of money in the currency's minor unit as Stripe counts it:
+// cents for USD, yen for JPY (no minor unit), fils for KWD (1/1000 dinar) and
+// hundredths of a króna for ISK, which are always whole krónur.
+type Amount struct {
+	Minor    int64
+	Currency string // ISO 4217 code, upper case
+}
+
+// New validates the currency and returns the amount with its code in upper case.
+func New(minor int64, currency string) (Amount, error) {
+	c, ok := LookupCurrency(currency)
+	if !ok {
+		return Amount{}, fmt.Errorf("%w %q", ErrUnknownCurrency, currency)
+	}
+	return Amount{Minor: minor, Currency: c.Code}, nil
+}
+
+// MustNew is New for amounts known to be valid, such as package-level prices.
+func MustNew(minor int64, currency string) Amount {
+	a, err := New(minor, currency)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+// Parse reads a decimal amount in major units ("49.99", "5000", "1.500") and rejects
+// more fraction digits than the currency has, or any for whole-unit currencies.
+func Parse(s, currency string) (Amount, error) {
+	c, ok := LookupCurrency(currency)
+	if !ok {
+		return Amount{}, fmt.Errorf("%w %q", ErrUnknownCurrency, currency)
+	}
+	neg := strings.HasPrefix(s, "-")
+	whole, frac, _ := strings.Cut(strings.TrimPrefix(s, "-"), ".")
+	maxFrac := c.Exponent
+	if c.Whole {
+		maxFrac = 0
+	}
+	if whole == "" || len(frac) > maxFrac || !isDigits(whole) || !isDigits(frac) {
+		return Amount{}, fmt.Errorf("invalid %s amount %q", c.Code, s)
+	}
+	digits := whole + frac + strings.Repeat("0", c.Exponent-len(frac))
+	minor, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		if errors.Is(err, strconv.ErrRange) {
+			return Amount{}, ErrOverflow
+		}
+		return Amount{}, fmt.Errorf("invalid %s amount %q", c.Code, s)
+	}
+	if neg {
+		minor = -minor
+	}
+	return Amount{Minor: minor, Currency: c.Code}, nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate checks that the currency is known and in canonical (upper) case.
+func (a Amount) Validate() error {
+	c, ok := LookupCurrency(a.Currency)
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnknownCurrency, a.Currency)
+	}
+	if c.Code != a.Currency {
+		return fmt.Errorf("currency %q must be upper case", a.Currency)
+	}
+	return nil
+}
+
+// IsZero reports whether the amount is zero.
+func (a Amount) IsZero() bool { return a.Minor == 0 }
+
+// IsPositive reports whether the amount is greater than zero.
+func (a Amount) IsPositive() bool { return a.Minor > 0 }
+
+// Add returns a+b. Both must have the same currency.
+func (a Amount) Add(b Amount) (Amount, error) {
+	if a.Currency != b.Currency {
+		return Amount{}, fmt.Errorf("%w: %s + %s", ErrCurrencyMismatch, a.Currency, b.Currency)
+	}
+	if (b.Minor > 0 && a.Minor > math.MaxInt64-b.Minor) || (b.Minor < 0 && a.Minor < math.MinInt64-b.Minor) {
+		return Amount{}, ErrOverflow
+	}
+	return Amount{Minor: a.Minor + b.Minor, Currency: a.Currency}, nil
+}
+
+// Sub returns a-b. Both must have the same currency.
+func (a Amount) Sub(b Amount) (Amount, error) {
+	if b.Minor == math.MinInt64 {
+		return Amount{}, ErrOverflow
+	}
+	return a.Add(Amount{Minor: -b.Minor, Currency: b.Currency})
+}
+
+// Mul returns a*n, e.g. a unit price times a quantity.
+func (a Amount) Mul(n int64) (Amount, error) {
+	if a.Minor == 0 || n == 0 {
+		return Amount{Currency: a.Currency}, nil
+	}
+	r := a.Minor * n
+	if r/n != a.Minor || (a.Minor == -1 && n == math.MinInt64) || (n == -1 && a.Minor == math.MinInt64) {
+		return Amount{}, ErrOverflow
+	}
+	return Amount{Minor: r, Currency: a.Currency}, nil
+}
+
+// Decimal formats the amount in major units without symbol or grouping ("1234.50").
+// Whole-unit currencies have no decimals ("5000" for 500000 ISK minor units).
+func (a Amount) Decimal() string {
+	exp, whole := 2, false
+	if c, ok := LookupCurrency(a.Currency); ok {
+		exp, whole = c.Exponent, c.Whole
+	}
+	// Work on the unsigned magnitude so math.MinInt64 formats correctly
+	sign := ""
+	mag := uint64(a.Minor)
+	if a.Minor < 0 {
+		sign = "-"
+		mag = -mag
+	}
+	digits := strconv.FormatUint(mag, 10)
+	if whole && mag%uint64(math.Pow10(exp)) == 0 {
+		digits = strings.TrimSuffix(digits, strings.Repeat("0", exp))
+		if digits == "" {
+			digits = "0"
+		}
+		exp = 0
+	}
+	if exp == 0 {
+		return sign + digits
+	}
+	if len(digits) <= exp {
+		digits = strings.Repeat("0", exp-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-exp] + "." + digits[len(digits)-exp:]
+}
+
+// String returns the amount with its code, e.g. "49.99 USD".
+func (a Amount) String() string {
+	return a.Decimal() + " " + a.Currency
+}
+
+// Format returns the amount for display: symbol (or code) and grouped thousands,
+// e.g. "$1,234.50", "¥5,000", "KWD 1.500", "-€3.00".
+func (a Amount) Format() string {
+	dec := strings.TrimPrefix(a.Decimal(), "-")
+	whole, frac, hasFrac := strings.Cut(dec, ".")
+	var b strings.Builder
+	if a.Minor < 0 {
+		b.WriteByte('-')
+	}
+	c, ok := LookupCurrency(a.Currency)
+	if ok && c.Symbol != "" {
+		b.WriteString(c.Symbol)
+	} else {
+		b.WriteString(a.Currency + " ")
+	}
+	for i, d := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(d)
+	}
+	if hasFrac {
+		b.WriteString("." + frac)
+	}
+	return b.String()
+}
+
+// amountJSON is the API representation: minor units for computation and the
+// formatted string for display.
+type amountJSON struct {
+	Minor    int64  `json:"minor"`
+	Currency string `json:"currency"`
+	Display  string `json:"display,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(amountJSON{Minor: a.Minor, Currency: a.Currency, Display: a.Format()})
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The display string is ignored.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	var v amountJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	parsed, err := New(v.Minor, v.Currency)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
@@ -0,0 +1,106 @@
+package money
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormat(t *testing.T) {
+	for _, tc := range []struct {
+		amount  Amount
+		decimal string
+		display string
+	}{
+		{MustNew(4999, "usd"), "49.99", "$49.99"},
+		{MustNew(123456789, "USD"), "1234567.89", "$1,234,567.89"},
+		{MustNew(5, "EUR"), "0.05", "€0.05"},
+		{MustNew(-300, "EUR"), "-3.00", "-€3.00"},
+		{MustNew(5000, "JPY"), "5000", "¥5,000"},
+		{MustNew(1500, "KWD"), "1.500", "KWD 1.500"},
+		{MustNew(7, "KWD"), "0.007", "KWD 0.007"},
+		{MustNew(500000, "ISK"), "5000", "ISK 5,000"},
+		{MustNew(0, "ISK"), "0", "ISK 0"},
+		{MustNew(math.MinInt64, "JPY"), "-9223372036854775808", "-¥9,223,372,036,854,775,808"},
+	} {
+		assert.Equal(t, tc.decimal, tc.amount.Decimal())
+		assert.Equal(t, tc.display, tc.amount.Format())
+	}
+}
+
+func TestParse(t *testing.T) {
+	a, err := Parse("49.9", "usd")
+	require.NoError(t, err)
+	assert.Equal(t, MustNew(4990, "USD"), a)
+
+	a, err = Parse("1.5", "KWD")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1500), a.Minor)
+
+	a, err = Parse("-5000", "JPY")
+	require.NoError(t, err)
+	assert.Equal(t, int64(-5000), a.Minor)
+
+	// Stripe counts ISK in hundredths although only whole krónur are used
+	a, err = Parse("5000", "isk")
+	require.NoError(t, err)
+	assert.Equal(t, MustNew(500000, "ISK"), a)
+
+	for _, bad := range []struct{ s, currency string }{
+		{"1.5", "JPY"},
+		{"1.50", "ISK"},
+		{"1.2345", "KWD"},
+		{"abc", "USD"},
+		{".5", "USD"},
+		{"--5", "USD"},
+		{"+5", "USD"},
+		{"1.-5", "USD"},
+		{"1", "XYZ"},
+	} {
+		_, err := Parse(bad.s, bad.currency)
+		assert.Error(t, err, bad)
+	}
+	_, err = Parse("92233720368547758.08", "USD")
+	assert.ErrorIs(t, err, ErrOverflow)
+}
+
+func TestArithmetic(t *testing.T) {
+	sum, err := MustNew(4999, "USD").Add(MustNew(1, "USD"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(5000), sum.Minor)
+
+	_, err = MustNew(1, "USD").Add(MustNew(1, "EUR"))
+	assert.ErrorIs(t, err, ErrCurrencyMismatch)
+	_, err = MustNew(math.MaxInt64, "USD").Add(MustNew(1, "USD"))
+	assert.ErrorIs(t, err, ErrOverflow)
+	_, err = MustNew(math.MinInt64, "USD").Sub(MustNew(1, "USD"))
+	assert.ErrorIs(t, err, ErrOverflow)
+	_, err = MustNew(0, "USD").Sub(MustNew(math.MinInt64, "USD"))
+	assert.ErrorIs(t, err, ErrOverflow)
+
+	total, err := MustNew(2999, "USD").Mul(3)
+	require.NoError(t, err)
+	assert.Equal(t, int64(8997), total.Minor)
+	_, err = MustNew(math.MaxInt64/2+1, "USD").Mul(2)
+	assert.ErrorIs(t, err, ErrOverflow)
+	_, err = MustNew(math.MinInt64, "USD").Mul(-1)
+	assert.ErrorIs(t, err, ErrOverflow)
+}
+
+func TestValidateAndJSON(t *testing.T) {
+	assert.NoError(t, MustNew(1, "usd").Validate())
+	assert.Error(t, Amount{Minor: 1, Currency: "usd"}.Validate())
+	assert.ErrorIs(t, Amount{Minor: 1, Currency: "XYZ"}.Validate(), ErrUnknownCurrency)
+
+	out, err := json.Marshal(MustNew(1500, "KWD"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"minor": 1500, "currency": "KWD", "display": "KWD 1.500"}`, string(out))
+
+	var a Amount
+	require.NoError(t, json.Unmarshal([]byte(`{"minor": 5000, "currency": "jpy"}`), &a))
+	assert.Equal(t, MustNew(5000, "JPY"), a)
+	assert.Error(t, json.Unmarshal([]byte(`{"minor": 1, "currency": "XYZ"}`), &a))
+}
@@ -0,0 +1,210 @@
+package outbound
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"stripe-go-spike/internal/audit"
+	"stripe-go-spike/internal/db"
+	"stripe-go-spike/internal/outbox"
+)
+
+const (
+	// DefaultMaxAttempts tries a delivery for about an hour (see Backoff)
+	DefaultMaxAttempts = 8
+	// DefaultTimeout is how long an endpoint has to respond
+	DefaultTimeout = 10 * time.Second
+
+	firstRetry = 30 * time.Second
+	maxRetry   = 6 * time.Hour
+	batchSize  = 100
+)
+
+// Backoff returns how long to wait after a delivery's failed attempts before
+// the next one: 30 seconds, doubling with each attempt up to 6 hours.
+func Backoff(attempts int64) time.Duration {
+	return outbox.ExponentialBackoff(firstRetry, maxRetry, attempts)
+}
+
+// Dispatcher sends queued deliveries. A 2xx response is a success; other
+// responses and errors are retried with Backoff until MaxAttempts, after which
+// the delivery fails and a webhook_delivery.failed audit event is logged. Every
+// attempt is recorded. Endpoints may see an event more than once, e.g. when a
+// response is lost, and should use its ID to drop repeats.
+type Dispatcher struct {
+	Store db.Repository
+	Audit *audit.Service // optional
+	// Client sends the requests (default: an http.Client with DefaultTimeout)
+	Client *http.Client
+	// MaxAttempts is how often a delivery is tried (default DefaultMaxAttempts)
+	MaxAttempts int64
+}
+
+// DispatchResult counts the deliveries handled by one run.
+type DispatchResult struct {
+	Attempted int `json:"attempted"`
+	Succeeded int `json:"succeeded"`
+	Retrying  int `json:"retrying"`
+	Failed    int `json:"failed"`
+}
+
+// Run sends every delivery that is due. Database errors abort the run.
+func (d *Dispatcher) Run(ctx context.Context) (DispatchResult, error) {
+	var result DispatchResult
+	for {
+		due, err := d.Store.ListDueWebhookDeliveries(ctx, db.ListDueWebhookDeliveriesParams{
+			NextAttemptAt: db.NullTimestamp{Timestamp: db.Now(), Valid: true},
+			Limit:         batchSize,
+		})
+		if err != nil {
+			return result, fmt.Errorf("list due webhook deliveries: %w", err)
+		}
+		// Every delivery sent leaves the due list: done, or due again later
+		for _, delivery := range due {
+			sent, err := d.Deliver(ctx, delivery)
+			if err != nil {
+				return result, err
+			}
+			if sent.Attempts == delivery.Attempts {
+				continue // claimed by another dispatcher
+			}
+			result.Attempted++
+			switch sent.Status {
+			case "succeeded":
+				result.Succeeded++
+			case "failed":
+				result.Failed++
+			default:
+				result.Retrying++
+			}
+		}
+		if len(due) < batchSize {
+			return result, nil
+		}
+	}
+}
+
+// Deliver makes one attempt at a pending delivery, unless another dispatcher
+// has claimed it, and returns the delivery as it then stands.
+func (d *Dispatcher) Deliver(ctx context.Context, delivery db.WebhookDelivery) (db.WebhookDelivery, error) {
+	client := d.client()
+	start := time.Now()
+
+	// Hide the delivery from other dispatchers while it is sent; if this one
+	// dies, it is due again after the lease
+	lease := db.NewTimestamp(start.Add(client.Timeout + time.Minute))
+	claimed, err := d.Store.ClaimWebhookDelivery(ctx, db.ClaimWebhookDeliveryParams{
+		NextAttemptAt:   db.NullTimestamp{Timestamp: lease, Valid: true},
+		UpdatedAt:       db.NewTimestamp(start),
+		ID:              delivery.ID,
+		NextAttemptAt_2: delivery.NextAttemptAt,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return d.Store.GetWebhookDelivery(ctx, delivery.ID)
+	}
+	if err != nil {
+		return delivery, fmt.Errorf("claim webhook delivery %d: %w", delivery.ID, err)
+	}
+	endpoint, err := d.Store.GetWebhookEndpoint(ctx, claimed.EndpointID)
+	if err != nil {
+		return claimed, fmt.Errorf("get webhook endpoint %d: %w", claimed.EndpointID, err)
+	}
+
+	var statusCode int
+	if endpoint.Active {
+		statusCode, err = send(ctx, client, endpoint, claimed)
+	} else {
+		err = errors.New("endpoint is disabled")
+	}
+	return d.record(ctx, claimed, endpoint, start, statusCode, err)
+}
+
+// record saves an attempt and its outcome.
+func (d *Dispatcher) record(ctx context.Context, delivery db.WebhookDelivery, endpoint db.WebhookEndpoint, start time.Time, statusCode int, sendErr error) (db.WebhookDelivery, error) {
+	now := time.Now()
+	attempts := delivery.Attempts + 1
+	result := db.RecordWebhookDeliveryResultParams{
+		Status:         "succeeded",
+		LastStatusCode: sql.NullInt64{Int64: int64(statusCode), Valid: statusCode != 0},
+		UpdatedAt:      db.NewTimestamp(now),
+		ID:             delivery.ID,
+	}
+	if sendErr != nil {
+		result.LastError = sql.NullString{String: sendErr.Error(), Valid: true}
+		if attempts >= d.maxAttempts() || !endpoint.Active {
+			result.Status = "failed"
+		} else {
+			result.Status = "pending"
+			result.NextAttemptAt = db.NullTimestamp{Timestamp: db.NewTimestamp(now.Add(Backoff(attempts))), Valid: true}
+		}
+	}
+
+	var logged db.AuditEvent
+	err := d.Store.WithTx(ctx, func(q db.Querier) error {
+		err := q.CreateWebhookDeliveryAttempt(ctx, db.CreateWebhookDeliveryAttemptParams{
+			DeliveryID:  delivery.ID,
+			AttemptedAt: db.NewTimestamp(start),
+			StatusCode:  result.LastStatusCode,
+			Error:       result.LastError,
+			DurationMs:  now.Sub(start).Milliseconds(),
+		})
+		if err != nil {
+			return err
+		}
+		if err := q.RecordWebhookDeliveryResult(ctx, result); err != nil {
+			return err
+		}
+		if result.Status != "failed" || d.Audit == nil {
+			return nil
+		}
+		logged, err = d.Audit.LogTx(ctx, q, audit.WebhookDeliveryFailed.New(
+			"Webhook delivery to "+endpoint.Url+" failed",
+			audit.WebhookDeliveryPayload{
+				DeliveryID: delivery.ID,
+				EndpointID: endpoint.ID,
+				EventID:    delivery.EventID,
+				EventType:  delivery.EventType,
+				Attempts:   attempts,
+				StatusCode: result.LastStatusCode.Int64,
+				Error:      result.LastError.String,
+			},
+		).WithRefs(delivery.EventID, ""))
+		return err
+	})
+	if err != nil {
+		return delivery, fmt.Errorf("record webhook delivery %d: %w", delivery.ID, err)
+	}
+	if logged.ID != 0 {
+		d.Audit.Publish(logged)
+	}
+	return d.Store.GetWebhookDelivery(ctx, delivery.ID)
+}
+
+// send posts the delivery's payload to the endpoint and returns the response
+// status, with an error for anything but a 2xx.
+func send(ctx context.Context, client *http.Client, endpoint db.WebhookEndpoint, delivery db.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	header := http.Header{}
+	header.Set(HeaderEventID, delivery.EventID)
+	header.Set(HeaderEventType, delivery.EventType)
+	header.Set(HeaderSignature, Sign(endpoint.Secret, time.Now(), body))
+	return outbox.Post(ctx, client, endpoint.Url, header, body)
+}
+
+func (d *Dispatcher) client() *http.Client {
+	if d.Client != nil {
+		return d.Client
+	}
+	return &http.Client{Timeout: DefaultTimeout}
+}
+
+func (d *Dispatcher) maxAttempts() int64 {
+	if d.MaxAttempts <= 0 {
+		return DefaultMaxAttempts
+	}
+	return d.MaxAttempts
+}
@@ -0,0 +1,102 @@
+package outbound
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"stripe-go-spike/internal/audit"
+	"stripe-go-spike/internal/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, 30*time.Second, Backoff(1))
+	assert.Equal(t, time.Minute, Backoff(2))
+	assert.Equal(t, 32*time.Minute, Backoff(7))
+	assert.Equal(t, 6*time.Hour, Backoff(20))
+}
+
+func TestDispatcher(t *testing.T) {
+	database, err := db.NewTestConnection()
+	require.NoError(t, err)
+	defer database.Close()
+	queries := db.New(database)
+	ctx := context.Background()
+
+	var received []*http.Request
+	var bodies [][]byte
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, r)
+		bodies = append(bodies, body)
+	}))
+	defer ok.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "database is down", http.StatusInternalServerError)
+	}))
+	defer broken.Close()
+
+	createEndpoint(t, queries, ok.URL, "")
+	createEndpoint(t, queries, broken.URL, "")
+	_, err = Enqueue(ctx, queries, Event{ID: "evt_1", Type: EventTransactionCompleted, Key: "1:completed", CreatedAt: time.Now(), Data: map[string]int{"amount": 4999}})
+	require.NoError(t, err)
+
+	dispatcher := &Dispatcher{
+		Store:       db.NewStore(database, queries),
+		Audit:       audit.NewService(queries, audit.WithStrictTypes()),
+		MaxAttempts: 2,
+	}
+	result, err := dispatcher.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, DispatchResult{Attempted: 2, Succeeded: 1, Retrying: 1}, result)
+
+	require.Len(t, received, 1)
+	assert.Equal(t, "evt_1", received[0].Header.Get(HeaderEventID))
+	assert.Equal(t, EventTransactionCompleted, received[0].Header.Get(HeaderEventType))
+	assert.NoError(t, Verify("whsec_test", received[0].Header.Get(HeaderSignature), bodies[0], time.Minute, time.Now()))
+
+	// The failed delivery waits for its retry
+	result, err = dispatcher.Run(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, result.Attempted)
+
+	due, err := queries.ListDueWebhookDeliveries(ctx, db.ListDueWebhookDeliveriesParams{
+		NextAttemptAt: db.NullTimestamp{Timestamp: db.NewTimestamp(time.Now().Add(time.Minute)), Valid: true},
+		Limit:         10,
+	})
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	assert.Equal(t, int64(1), due[0].Attempts)
+	assert.Equal(t, sql.NullInt64{Int64: 500, Valid: true}, due[0].LastStatusCode)
+	assert.Contains(t, due[0].LastError.String, "database is down")
+
+	// Its last attempt fails it for good
+	delivery, err := dispatcher.Deliver(ctx, due[0])
+	require.NoError(t, err)
+	assert.Equal(t, "failed", delivery.Status)
+	assert.Equal(t, int64(2), delivery.Attempts)
+	assert.False(t, delivery.NextAttemptAt.Valid)
+
+	attempts, err := queries.ListWebhookDeliveryAttempts(ctx, delivery.ID)
+	require.NoError(t, err)
+	assert.Len(t, attempts, 2)
+
+	events, err := queries.ListAuditEventsByRefs(ctx, db.ListAuditEventsByRefsParams{
+		RefID: sql.NullString{String: "evt_1", Valid: true}, Limit: 10,
+	})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, audit.WebhookDeliveryFailed.Definition().EventType, events[0].EventType)
+
+	// A stale copy of the delivery isn't sent again
+	delivery, err = dispatcher.Deliver(ctx, due[0])
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), delivery.Attempts)
+}
@@ -0,0 +1,280 @@
+// Package outbound sends transaction events to our own downstream systems
+// (fulfillment, CRM) as webhooks. Endpoints subscribe to event types. Events
+// are written to the outbox with the change they report; the outbox consumer
+// registered by Subscribe queues each one as a delivery per endpoint, and a
+// Dispatcher sends the queued deliveries, signed with the endpoint's secret,
+// retrying failures with exponential backoff.
+package outbound
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"stripe-go-spike/internal/data"
+	"stripe-go-spike/internal/db"
+	"stripe-go-spike/internal/outbox"
+
+	"github.com/google/uuid"
+)
+
+// Event types sent to endpoints. They match the audit event types of the
+// transaction status changes.
+const (
+	EventTransactionCompleted = "transaction.completed"
+	EventTransactionFailed    = "transaction.failed"
+	EventTransactionCancelled = "transaction.cancelled"
+	EventTransactionRefunded  = "transaction.refunded"
+)
+
+// EventTypes lists the event types an endpoint can subscribe to.
+var EventTypes = []string{EventTransactionCompleted, EventTransactionFailed, EventTransactionCancelled, EventTransactionRefunded}
+
+// Headers of a delivery request.
+const (
+	HeaderEventID   = "X-Webhook-Id"
+	HeaderEventType = "X-Webhook-Event"
+	// HeaderSignature is "t=<unix time>,v1=<hex HMAC-SHA256>" over
+	// "<unix time>.<body>", keyed with the endpoint secret
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// ErrInvalidEndpoint is returned for endpoints that can't be saved.
+var ErrInvalidEndpoint = errors.New("invalid webhook endpoint")
+
+// ErrInvalidSignature is returned by Verify.
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// EndpointSpec describes a webhook endpoint.
+type EndpointSpec struct {
+	URL         string
+	EventTypes  []string // empty for every event type
+	Description string
+}
+
+// Normalize returns the spec with surrounding space trimmed and the event types
+// sorted without duplicates.
+func (s EndpointSpec) Normalize() EndpointSpec {
+	s.URL = strings.TrimSpace(s.URL)
+	s.Description = strings.TrimSpace(s.Description)
+	types := make([]string, 0, len(s.EventTypes))
+	for _, t := range s.EventTypes {
+		types = append(types, strings.ToLower(strings.TrimSpace(t)))
+	}
+	slices.Sort(types)
+	s.EventTypes = slices.Compact(types)
+	return s
+}
+
+// Validate checks a normalized spec.
+func (s EndpointSpec) Validate() error {
+	u, err := url.Parse(s.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http(s) URL", ErrInvalidEndpoint)
+	}
+	for _, t := range s.EventTypes {
+		if !slices.Contains(EventTypes, t) {
+			return fmt.Errorf("%w: unknown event type %q", ErrInvalidEndpoint, t)
+		}
+	}
+	if len(s.Description) > 200 {
+		return fmt.Errorf("%w: description must be at most 200 characters", ErrInvalidEndpoint)
+	}
+	return nil
+}
+
+// FormatEventTypes is how an endpoint's event types are stored.
+func FormatEventTypes(types []string) string {
+	return strings.Join(types, ",")
+}
+
+// ParseEventTypes reads stored event types; nil means every type.
+func ParseEventTypes(stored string) []string {
+	if stored == "" {
+		return nil
+	}
+	return strings.Split(stored, ",")
+}
+
+// Subscribed reports whether endpoint receives events of eventType.
+func Subscribed(endpoint db.WebhookEndpoint, eventType string) bool {
+	types := ParseEventTypes(endpoint.EventTypes)
+	return len(types) == 0 || slices.Contains(types, eventType)
+}
+
+// NewSecret returns a random signing secret for an endpoint.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// Event is what an endpoint receives. Key identifies the change the event
+// reports, so the same change is queued once however often it is seen.
+type Event struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	Key       string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// QueueTransactionEvent writes the change of txn to its current status to the
+// outbox, keyed by transaction, whose relay hands it to the endpoints and the
+// other consumers. dedupeKey names the change (TransactionEventKey), so it is
+// written once however often and by whichever path it is seen. Call it in the
+// unit of work that changes the status.
+func QueueTransactionEvent(ctx context.Context, q db.Querier, txn db.Transaction, dedupeKey string) error {
+	event := Event{
+		ID:        "evt_" + uuid.New().String(),
+		Type:      "transaction." + txn.Status,
+		CreatedAt: time.Now().UTC(),
+		Data:      map[string]any{"transaction": data.NewTransaction(txn)},
+	}
+	return outbox.Add(ctx, q, outbox.Entry{
+		Topic:     event.Type,
+		Key:       txn.ID,
+		DedupeKey: dedupeKey,
+		Payload:   event,
+	})
+}
+
+// TransactionEventKey names a transaction's change to status. Each refund or
+// dispute is a change of its own, named by its Stripe object ID.
+func TransactionEventKey(transactionID, status, objectID string) string {
+	key := transactionID + ":" + status
+	if status == "refunded" && objectID != "" {
+		key += ":" + objectID
+	}
+	return key
+}
+
+// Enqueue queues event for every active endpoint subscribed to its type and
+// returns how many deliveries were queued. Call it inside a unit of work, so
+// an event is queued for all endpoints or none.
+func Enqueue(ctx context.Context, q db.Querier, event Event) (int, error) {
+	endpoints, err := q.ListActiveWebhookEndpoints(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var payload []byte
+	queued := 0
+	now := db.NewTimestamp(event.CreatedAt)
+	for _, endpoint := range endpoints {
+		if !Subscribed(endpoint, event.Type) {
+			continue
+		}
+		if payload == nil {
+			if payload, err = json.Marshal(event); err != nil {
+				return queued, err
+			}
+		}
+		err := q.CreateWebhookDelivery(ctx, db.CreateWebhookDeliveryParams{
+			EndpointID:    endpoint.ID,
+			EventID:       event.ID,
+			EventType:     event.Type,
+			EventKey:      event.Key,
+			Payload:       string(payload),
+			NextAttemptAt: db.NullTimestamp{Timestamp: now, Valid: true},
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		})
+		if err != nil {
+			return queued, err
+		}
+		queued++
+	}
+	return queued, nil
+}
+
+// Subscribe registers the outbox consumer that queues the events of every
+// event type for their endpoints. The outbox writes an event once per change,
+// so its deliveries are keyed by the event ID, and a message the relay
+// publishes again is queued once.
+func Subscribe(mux *outbox.Mux, store db.Repository) {
+	for _, eventType := range EventTypes {
+		mux.Handle(eventType, func(ctx context.Context, m outbox.Message) error {
+			event, err := decodeEvent(m.Payload)
+			if err != nil {
+				return fmt.Errorf("decode outbox message %d: %w", m.ID, err)
+			}
+			return store.WithTx(ctx, func(q db.Querier) error {
+				_, err := Enqueue(ctx, q, event)
+				return err
+			})
+		})
+	}
+}
+
+// decodeEvent reads an event written to the outbox, keeping its data as it was
+// marshalled.
+func decodeEvent(payload []byte) (Event, error) {
+	var stored struct {
+		ID        string          `json:"id"`
+		Type      string          `json:"type"`
+		CreatedAt time.Time       `json:"created_at"`
+		Data      json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(payload, &stored); err != nil {
+		return Event{}, err
+	}
+	return Event{
+		ID:        stored.ID,
+		Type:      stored.Type,
+		Key:       stored.ID,
+		CreatedAt: stored.CreatedAt,
+		Data:      stored.Data,
+	}, nil
+}
+
+// Sign returns the signature header for body sent at t.
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + signature(secret, ts, body)
+}
+
+// Verify checks a signature header against body, rejecting signatures older
+// than tolerance. Receivers use it to authenticate deliveries.
+func Verify(secret, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	var ts, sig string
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(part, "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sig = v
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || sig == "" {
+		return fmt.Errorf("%w: malformed header", ErrInvalidSignature)
+	}
+	if now.Sub(time.Unix(unix, 0)) > tolerance {
+		return fmt.Errorf("%w: timestamp too old", ErrInvalidSignature)
+	}
+	if !hmac.Equal([]byte(sig), []byte(signature(secret, ts, body))) {
+		return fmt.Errorf("%w: signature mismatch", ErrInvalidSignature)
+	}
+	return nil
+}
+
+func signature(secret, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,138 @@
+package outbound
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"stripe-go-spike/internal/db"
+	"stripe-go-spike/internal/outbox"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndpointSpecValidate(t *testing.T) {
+	valid := EndpointSpec{
+		URL:        " https://crm.example/hooks ",
+		EventTypes: []string{"transaction.refunded", " Transaction.Completed", "transaction.refunded"},
+	}.Normalize()
+	assert.Equal(t, "https://crm.example/hooks", valid.URL)
+	assert.Equal(t, []string{EventTransactionCompleted, EventTransactionRefunded}, valid.EventTypes)
+	assert.NoError(t, valid.Validate())
+
+	for name, spec := range map[string]EndpointSpec{
+		"relative url": {URL: "/hooks"},
+		"scheme":       {URL: "ftp://crm.example/hooks"},
+		"event type":   {URL: "https://crm.example", EventTypes: []string{"product.created"}},
+	} {
+		assert.ErrorIs(t, spec.Validate(), ErrInvalidEndpoint, name)
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	body := []byte(`{"id":"evt_1"}`)
+	now := time.Unix(1700000000, 0)
+	header := Sign("whsec_test", now, body)
+	assert.Regexp(t, `^t=1700000000,v1=[0-9a-f]{64}$`, header)
+
+	assert.NoError(t, Verify("whsec_test", header, body, 5*time.Minute, now.Add(time.Minute)))
+	assert.ErrorIs(t, Verify("whsec_other", header, body, 5*time.Minute, now), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify("whsec_test", header, []byte(`{"id":"evt_2"}`), 5*time.Minute, now), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify("whsec_test", header, body, 5*time.Minute, now.Add(time.Hour)), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify("whsec_test", "v1=abc", body, 5*time.Minute, now), ErrInvalidSignature)
+}
+
+func TestEnqueue(t *testing.T) {
+	database, err := db.NewTestConnection()
+	require.NoError(t, err)
+	defer database.Close()
+	q := db.New(database)
+	ctx := context.Background()
+
+	all := createEndpoint(t, q, "https://all.example", "")
+	refunds := createEndpoint(t, q, "https://refunds.example", EventTransactionRefunded)
+	disabled := createEndpoint(t, q, "https://disabled.example", "")
+	_, err = q.UpdateWebhookEndpoint(ctx, db.UpdateWebhookEndpointParams{
+		Url: disabled.Url, EventTypes: disabled.EventTypes, Active: false, UpdatedAt: db.Now(), ID: disabled.ID,
+	})
+	require.NoError(t, err)
+
+	event := Event{ID: "evt_1", Type: EventTransactionCompleted, Key: "1:completed", CreatedAt: time.Now(), Data: map[string]int{"amount": 4999}}
+	queued, err := Enqueue(ctx, q, event)
+	require.NoError(t, err)
+	assert.Equal(t, 1, queued)
+
+	// The same change seen again, e.g. from a redelivered Stripe event
+	event.ID = "evt_2"
+	queued, err = Enqueue(ctx, q, event)
+	require.NoError(t, err)
+	assert.Equal(t, 1, queued)
+
+	deliveries, err := q.ListWebhookDeliveriesByEndpoint(ctx, db.ListWebhookDeliveriesByEndpointParams{EndpointID: all.ID, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1, "queued once per change")
+	assert.Equal(t, "evt_1", deliveries[0].EventID)
+	assert.Equal(t, "pending", deliveries[0].Status)
+	assert.JSONEq(t, `{"id":"evt_1","type":"transaction.completed","created_at":"`+
+		event.CreatedAt.Format(time.RFC3339Nano)+`","data":{"amount":4999}}`, deliveries[0].Payload)
+
+	for _, endpoint := range []db.WebhookEndpoint{refunds, disabled} {
+		deliveries, err := q.ListWebhookDeliveriesByEndpoint(ctx, db.ListWebhookDeliveriesByEndpointParams{EndpointID: endpoint.ID, Limit: 10})
+		require.NoError(t, err)
+		assert.Empty(t, deliveries, endpoint.Url)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	database, err := db.NewTestConnection()
+	require.NoError(t, err)
+	defer database.Close()
+	q := db.New(database)
+	ctx := context.Background()
+	endpoint := createEndpoint(t, q, "https://all.example", "")
+
+	event := Event{ID: "evt_1", Type: EventTransactionCompleted, CreatedAt: time.Now(), Data: map[string]int{"amount": 4999}}
+	require.NoError(t, outbox.Add(ctx, q, outbox.Entry{Topic: event.Type, Key: "txn_1", DedupeKey: "txn_1:completed", Payload: event}))
+
+	// The first round fails elsewhere, so the consumer sees the message twice
+	store := db.NewStore(database, q)
+	mux := outbox.NewMux()
+	Subscribe(mux, store)
+	failing := true
+	relay := &outbox.Relay{Store: store, Sink: outbox.Fanout(mux, outbox.SinkFunc(func(context.Context, outbox.Message) error {
+		if failing {
+			return errors.New("broker unavailable")
+		}
+		return nil
+	})), Retention: -1}
+	_, err = relay.Run(ctx)
+	require.NoError(t, err)
+	failing = false
+	_, err = database.Exec("UPDATE outbox_messages SET next_attempt_at = ?", db.Now())
+	require.NoError(t, err)
+	result, err := relay.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Published)
+
+	deliveries, err := q.ListWebhookDeliveriesByEndpoint(ctx, db.ListWebhookDeliveriesByEndpointParams{EndpointID: endpoint.ID, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1, "queued once per message")
+	assert.Equal(t, "evt_1", deliveries[0].EventID)
+	assert.JSONEq(t, `{"id":"evt_1","type":"transaction.completed","created_at":"`+
+		event.CreatedAt.Format(time.RFC3339Nano)+`","data":{"amount":4999}}`, deliveries[0].Payload)
+}
+
+func createEndpoint(t *testing.T, q *db.Queries, url, eventTypes string) db.WebhookEndpoint {
+	t.Helper()
+	endpoint, err := q.CreateWebhookEndpoint(context.Background(), db.CreateWebhookEndpointParams{
+		Url:        url,
+		Secret:     "whsec_test",
+		EventTypes: eventTypes,
+		CreatedAt:  db.Now(),
+		UpdatedAt:  db.Now(),
+	})
+	require.NoError(t, err)
+	return endpoint
+}
@@ -0,0 +1,71 @@
+// Package outbox is a transactional outbox for the side effects of payment
+// events: emails, fulfillment, notifying other services. A message is written
+// with Add in the same database transaction as the change it describes, so it
+// exists exactly when the change commits, and a Relay publishes it to a Sink
+// afterwards.
+//
+// Publishing is at least once. A message is retried until its sink accepts it
+// or its attempts run out, and is published again if the relay stops between
+// publishing it and recording that it did, so consumers should drop message IDs
+// they have already handled. Messages with the same key are published in the
+// order they were written, except that a dead message, one that ran out of
+// attempts, no longer holds back the ones after it.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"stripe-go-spike/internal/db"
+)
+
+// Entry is a message to write.
+type Entry struct {
+	Topic string // what happened, e.g. transaction.completed
+	Key   string // messages with the same key publish in order, e.g. a transaction ID
+	// DedupeKey identifies the change, so the same change is written once however
+	// often it is seen
+	DedupeKey string
+	Payload   any // marshalled as JSON
+}
+
+// Message is a published entry.
+type Message struct {
+	ID        int64           `json:"id"`
+	Topic     string          `json:"topic"`
+	Key       string          `json:"key"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+	Attempt   int64           `json:"attempt"` // 1 on the first try
+}
+
+// Add writes an entry. Call it with the queries of the unit of work that makes
+// the change.
+func Add(ctx context.Context, q db.Querier, entry Entry) error {
+	payload, err := json.Marshal(entry.Payload)
+	if err != nil {
+		return err
+	}
+	now := db.Now()
+	return q.CreateOutboxMessage(ctx, db.CreateOutboxMessageParams{
+		Topic:         entry.Topic,
+		MessageKey:    entry.Key,
+		DedupeKey:     entry.DedupeKey,
+		Payload:       string(payload),
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	})
+}
+
+// toMessage converts a stored message for the sinks.
+func toMessage(m db.OutboxMessage) Message {
+	return Message{
+		ID:        m.ID,
+		Topic:     m.Topic,
+		Key:       m.MessageKey,
+		Payload:   json.RawMessage(m.Payload),
+		CreatedAt: m.CreatedAt.Time,
+		Attempt:   m.Attempts + 1,
+	}
+}
@@ -0,0 +1,257 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"stripe-go-spike/internal/audit"
+	"stripe-go-spike/internal/db"
+)
+
+const (
+	// DefaultMaxAttempts tries a message for about a day (see Backoff)
+	DefaultMaxAttempts = 100
+	// DefaultTimeout is how long a sink has to publish a message
+	DefaultTimeout = 30 * time.Second
+	// DefaultRetention is how long published messages are kept
+	DefaultRetention = 7 * 24 * time.Hour
+
+	firstRetry = 5 * time.Second
+	maxRetry   = 15 * time.Minute
+	batchSize  = 100
+)
+
+// Backoff returns how long to wait after a message's failed attempts before the
+// next one: 5 seconds, doubling with each attempt up to 15 minutes.
+func Backoff(attempts int64) time.Duration {
+	return ExponentialBackoff(firstRetry, maxRetry, attempts)
+}
+
+// ExponentialBackoff returns how long to wait after a number of failed
+// attempts: first, doubling with each attempt up to limit. Outbound webhook
+// deliveries retry with it too, with their own bounds.
+func ExponentialBackoff(first, limit time.Duration, attempts int64) time.Duration {
+	delay := first
+	for i := int64(1); i < attempts && delay < limit; i++ {
+		delay *= 2
+	}
+	return min(delay, limit)
+}
+
+// Relay publishes outbox messages to a sink. Several relays may run against
+// the same database; each message is claimed by one of them at a time.
+//
+// A message that still fails after MaxAttempts is dead: it is no longer
+// retried, the later messages of its key go ahead without it, and an
+// outbox_message.dead audit event is logged. Requeue gives it another round.
+type Relay struct {
+	Store db.Repository
+	Sink  Sink
+	Audit *audit.Service // optional
+	// MaxAttempts is how often a message is tried (default DefaultMaxAttempts)
+	MaxAttempts int64
+	// Timeout bounds each publish (default DefaultTimeout)
+	Timeout time.Duration
+	// Retention is how long published messages are kept (default
+	// DefaultRetention; negative keeps them)
+	Retention time.Duration
+}
+
+// RelayResult counts the messages handled by one run.
+type RelayResult struct {
+	Published int `json:"published"`
+	Retrying  int `json:"retrying"`
+	Dead      int `json:"dead"`
+	Pruned    int `json:"pruned"`
+}
+
+// ErrNoSink is returned by Run for a relay without a sink, which would
+// otherwise mark every message published without handing it to anyone.
+var ErrNoSink = errors.New("outbox relay has no sink")
+
+// Run publishes every message that is due, then deletes published messages
+// past the retention. Sink errors are recorded on the message for its retry;
+// database errors abort the run.
+func (r *Relay) Run(ctx context.Context) (RelayResult, error) {
+	var result RelayResult
+	if r.Sink == nil {
+		return result, ErrNoSink
+	}
+	// Only the oldest pending message of each key is due, so keep going while
+	// messages get published or die
+	for {
+		due, err := r.Store.ListDueOutboxMessages(ctx, db.ListDueOutboxMessagesParams{
+			NextAttemptAt: db.Now(),
+			Limit:         batchSize,
+		})
+		if err != nil {
+			return result, fmt.Errorf("list due outbox messages: %w", err)
+		}
+		settled := 0
+		for _, message := range due {
+			done, err := r.publish(ctx, message)
+			if errors.Is(err, errClaimed) {
+				continue
+			}
+			if err != nil {
+				return result, err
+			}
+			switch done {
+			case published:
+				result.Published++
+				settled++
+			case dead:
+				result.Dead++
+				settled++
+			default:
+				result.Retrying++
+			}
+		}
+		if settled == 0 {
+			break
+		}
+	}
+
+	pruned, err := r.prune(ctx)
+	result.Pruned = pruned
+	return result, err
+}
+
+// errClaimed reports a message claimed by another relay.
+var errClaimed = errors.New("outbox message claimed by another relay")
+
+// outcome is what became of a message after an attempt.
+type outcome int
+
+const (
+	published outcome = iota
+	retrying
+	dead
+)
+
+// publish makes one attempt at a message and records the outcome.
+func (r *Relay) publish(ctx context.Context, message db.OutboxMessage) (outcome, error) {
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	// Hide the message from other relays while it is published; if this one
+	// dies, it is due again after the lease
+	start := time.Now()
+	claimed, err := r.Store.ClaimOutboxMessage(ctx, db.ClaimOutboxMessageParams{
+		NextAttemptAt:   db.NewTimestamp(start.Add(timeout + time.Minute)),
+		ID:              message.ID,
+		NextAttemptAt_2: message.NextAttemptAt,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return retrying, errClaimed
+	}
+	if err != nil {
+		return retrying, fmt.Errorf("claim outbox message %d: %w", message.ID, err)
+	}
+
+	publishCtx, cancel := context.WithTimeout(ctx, timeout)
+	sinkErr := r.Sink.Publish(publishCtx, toMessage(claimed))
+	cancel()
+
+	if sinkErr == nil {
+		err = r.Store.MarkOutboxMessagePublished(ctx, db.MarkOutboxMessagePublishedParams{
+			PublishedAt: db.NullTimestamp{Timestamp: db.Now(), Valid: true},
+			ID:          claimed.ID,
+		})
+		if err != nil {
+			return retrying, fmt.Errorf("record outbox message %d: %w", claimed.ID, err)
+		}
+		return published, nil
+	}
+	return r.fail(ctx, claimed, sinkErr)
+}
+
+// fail records a failed attempt: a retry after Backoff, or a dead message
+// once the attempts run out.
+func (r *Relay) fail(ctx context.Context, message db.OutboxMessage, sinkErr error) (outcome, error) {
+	now := time.Now()
+	attempts := message.Attempts + 1
+	failure := db.RecordOutboxMessageFailureParams{
+		NextAttemptAt: db.NewTimestamp(now.Add(Backoff(attempts))),
+		LastError:     sql.NullString{String: sinkErr.Error(), Valid: true},
+		ID:            message.ID,
+	}
+	if attempts >= r.maxAttempts() {
+		failure.NextAttemptAt = db.NewTimestamp(now)
+		failure.DeadAt = db.NullTimestamp{Timestamp: db.NewTimestamp(now), Valid: true}
+	}
+
+	var logged db.AuditEvent
+	err := r.Store.WithTx(ctx, func(q db.Querier) error {
+		if err := q.RecordOutboxMessageFailure(ctx, failure); err != nil {
+			return err
+		}
+		if !failure.DeadAt.Valid || r.Audit == nil {
+			return nil
+		}
+		var err error
+		logged, err = r.Audit.LogTx(ctx, q, audit.OutboxMessageDead.New(
+			"Outbox message "+strconv.FormatInt(message.ID, 10)+" ("+message.Topic+") is dead",
+			audit.OutboxMessagePayload{
+				MessageID: message.ID,
+				Topic:     message.Topic,
+				Key:       message.MessageKey,
+				Attempts:  attempts,
+				Error:     sinkErr.Error(),
+			},
+		).WithRefs(message.MessageKey, ""))
+		return err
+	})
+	if err != nil {
+		return retrying, fmt.Errorf("record outbox message %d: %w", message.ID, err)
+	}
+	if logged.ID != 0 {
+		r.Audit.Publish(logged)
+	}
+	if failure.DeadAt.Valid {
+		return dead, nil
+	}
+	return retrying, nil
+}
+
+// Requeue gives a dead message a fresh set of attempts, due straight away. It
+// reports whether the message was dead. A requeued message publishes after
+// the later messages of its key that went ahead without it.
+func Requeue(ctx context.Context, q db.Querier, id int64) (bool, error) {
+	n, err := q.RequeueOutboxMessage(ctx, db.RequeueOutboxMessageParams{
+		NextAttemptAt: db.Now(),
+		ID:            id,
+	})
+	return n > 0, err
+}
+
+func (r *Relay) maxAttempts() int64 {
+	if r.MaxAttempts <= 0 {
+		return DefaultMaxAttempts
+	}
+	return r.MaxAttempts
+}
+
+// prune deletes published messages past the retention and returns how many.
+func (r *Relay) prune(ctx context.Context) (int, error) {
+	retention := r.Retention
+	if retention == 0 {
+		retention = DefaultRetention
+	}
+	if retention < 0 {
+		return 0, nil
+	}
+	n, err := r.Store.DeletePublishedOutboxMessages(ctx, db.NullTimestamp{
+		Timestamp: db.NewTimestamp(time.Now().Add(-retention)),
+		Valid:     true,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("prune outbox messages: %w", err)
+	}
+	return int(n), nil
+}
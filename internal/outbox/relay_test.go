@@ -0,0 +1,219 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"stripe-go-spike/internal/audit"
+	"stripe-go-spike/internal/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, 5*time.Second, Backoff(1))
+	assert.Equal(t, 10*time.Second, Backoff(2))
+	assert.Equal(t, 15*time.Minute, Backoff(30))
+}
+
+func TestRelay(t *testing.T) {
+	database, err := db.NewTestConnection()
+	require.NoError(t, err)
+	defer database.Close()
+	queries := db.New(database)
+	ctx := context.Background()
+
+	add := func(topic, key, dedupeKey string) {
+		t.Helper()
+		require.NoError(t, Add(ctx, queries, Entry{Topic: topic, Key: key, DedupeKey: dedupeKey, Payload: map[string]string{"change": dedupeKey}}))
+	}
+	add("transaction.completed", "txn_1", "txn_1:completed")
+	add("transaction.completed", "txn_2", "txn_2:completed")
+	add("transaction.completed", "txn_1", "txn_1:completed") // seen again
+	add("transaction.refunded", "txn_1", "txn_1:refunded:re_1")
+
+	var published []Message
+	failing := map[string]bool{"txn_2": true}
+	sink := SinkFunc(func(ctx context.Context, m Message) error {
+		if failing[m.Key] {
+			return errors.New("broker unavailable")
+		}
+		published = append(published, m)
+		return nil
+	})
+	relay := &Relay{Store: db.NewStore(database, queries), Sink: sink, Retention: -1}
+
+	result, err := relay.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, RelayResult{Published: 2, Retrying: 1}, result)
+	require.Len(t, published, 2)
+	assert.Equal(t, "transaction.completed", published[0].Topic)
+	assert.Equal(t, "transaction.refunded", published[1].Topic, "a key's messages publish in order")
+	assert.JSONEq(t, `{"change": "txn_1:completed"}`, string(published[0].Payload))
+	assert.Equal(t, int64(1), published[0].Attempt)
+
+	// The failed message waits for its retry
+	var failedID int64
+	require.NoError(t, database.QueryRow("SELECT id FROM outbox_messages WHERE message_key = 'txn_2'").Scan(&failedID))
+	failed, err := queries.GetOutboxMessage(ctx, failedID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), failed.Attempts)
+	assert.Equal(t, "broker unavailable", failed.LastError.String)
+	assert.False(t, failed.PublishedAt.Valid)
+	assert.True(t, failed.NextAttemptAt.After(time.Now()))
+	result, err = relay.Run(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, result)
+
+	// Later messages of its key wait for it
+	add("transaction.refunded", "txn_2", "txn_2:refunded:re_2")
+	_, err = database.Exec("UPDATE outbox_messages SET next_attempt_at = ? WHERE id = ?", db.Now(), failed.ID)
+	require.NoError(t, err)
+	result, err = relay.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, RelayResult{Retrying: 1}, result)
+	assert.Len(t, published, 2)
+
+	// Until it is published: at least once, however long it takes
+	failing["txn_2"] = false
+	_, err = database.Exec("UPDATE outbox_messages SET next_attempt_at = ? WHERE id = ?", db.Now(), failed.ID)
+	require.NoError(t, err)
+	result, err = relay.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, RelayResult{Published: 2}, result)
+	require.Len(t, published, 4)
+	assert.Equal(t, failed.ID, published[2].ID)
+	assert.Equal(t, int64(3), published[2].Attempt)
+	assert.Equal(t, "transaction.refunded", published[3].Topic)
+
+	// Published messages are pruned after the retention
+	time.Sleep(5 * time.Millisecond)
+	relay.Retention = time.Millisecond
+	result, err = relay.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, RelayResult{Pruned: 4}, result)
+}
+
+func TestRelaySkipsClaimedMessages(t *testing.T) {
+	database, err := db.NewTestConnection()
+	require.NoError(t, err)
+	defer database.Close()
+	queries := db.New(database)
+	ctx := context.Background()
+	require.NoError(t, Add(ctx, queries, Entry{Topic: "transaction.completed", Key: "txn_1", DedupeKey: "txn_1:completed"}))
+
+	// Another relay claims the message between listing and publishing
+	store := db.NewStore(database, queries)
+	due, err := store.ListDueOutboxMessages(ctx, db.ListDueOutboxMessagesParams{NextAttemptAt: db.Now(), Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	_, err = store.ClaimOutboxMessage(ctx, db.ClaimOutboxMessageParams{
+		NextAttemptAt:   db.NewTimestamp(time.Now().Add(time.Minute)),
+		ID:              due[0].ID,
+		NextAttemptAt_2: due[0].NextAttemptAt,
+	})
+	require.NoError(t, err)
+
+	relay := &Relay{Store: store, Sink: SinkFunc(func(context.Context, Message) error {
+		t.Error("published a claimed message")
+		return nil
+	})}
+	_, err = relay.publish(ctx, due[0])
+	assert.ErrorIs(t, err, errClaimed)
+}
+
+func TestRelayWithoutSink(t *testing.T) {
+	database, err := db.NewTestConnection()
+	require.NoError(t, err)
+	defer database.Close()
+	queries := db.New(database)
+	ctx := context.Background()
+	require.NoError(t, Add(ctx, queries, Entry{Topic: "transaction.completed", Key: "txn_1", DedupeKey: "txn_1:completed"}))
+
+	_, err = (&Relay{Store: db.NewStore(database, queries)}).Run(ctx)
+	assert.ErrorIs(t, err, ErrNoSink)
+	due, err := queries.ListDueOutboxMessages(ctx, db.ListDueOutboxMessagesParams{NextAttemptAt: db.Now(), Limit: 10})
+	require.NoError(t, err)
+	assert.Len(t, due, 1, "nothing is marked published")
+}
+
+func TestRelayDeadLetters(t *testing.T) {
+	database, err := db.NewTestConnection()
+	require.NoError(t, err)
+	defer database.Close()
+	queries := db.New(database)
+	ctx := context.Background()
+	add := func(topic, dedupeKey string) {
+		t.Helper()
+		require.NoError(t, Add(ctx, queries, Entry{Topic: topic, Key: "txn_1", DedupeKey: dedupeKey}))
+	}
+	add("transaction.completed", "txn_1:completed")
+	add("transaction.refunded", "txn_1:refunded:re_1")
+
+	// The completion can't be published, however often it is tried
+	var published []string
+	sink := SinkFunc(func(ctx context.Context, m Message) error {
+		if m.Topic == "transaction.completed" {
+			return errors.New("malformed payload")
+		}
+		published = append(published, m.Topic)
+		return nil
+	})
+	auditService := audit.NewService(queries, audit.WithStrictTypes())
+	store := db.NewStore(database, queries)
+	relay := &Relay{Store: store, Sink: sink, Audit: auditService, MaxAttempts: 2, Retention: -1}
+	retry := func() {
+		t.Helper()
+		_, err := database.Exec("UPDATE outbox_messages SET next_attempt_at = ? WHERE published_at IS NULL AND dead_at IS NULL", db.Now())
+		require.NoError(t, err)
+	}
+
+	result, err := relay.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, RelayResult{Retrying: 1}, result)
+	assert.Empty(t, published)
+
+	// Its last attempt sets it aside, and the refund goes ahead without it
+	retry()
+	result, err = relay.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, RelayResult{Published: 1, Dead: 1}, result)
+	assert.Equal(t, []string{"transaction.refunded"}, published)
+
+	var deadID int64
+	require.NoError(t, database.QueryRow("SELECT id FROM outbox_messages WHERE topic = 'transaction.completed'").Scan(&deadID))
+	message, err := queries.GetOutboxMessage(ctx, deadID)
+	require.NoError(t, err)
+	assert.True(t, message.DeadAt.Valid)
+	assert.False(t, message.PublishedAt.Valid)
+	assert.Equal(t, int64(2), message.Attempts)
+	assert.Equal(t, "malformed payload", message.LastError.String)
+	events, err := queries.GetAuditEventsByEventType(ctx, db.GetAuditEventsByEventTypeParams{
+		EventType: audit.OutboxMessageDead.Definition().EventType, Limit: 10})
+	require.NoError(t, err)
+	assert.Len(t, events, 1)
+
+	// Dead messages aren't retried until requeued
+	retry()
+	result, err = relay.Run(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, result)
+
+	ok, err := Requeue(ctx, queries, deadID)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = Requeue(ctx, queries, deadID)
+	require.NoError(t, err)
+	assert.False(t, ok, "only dead messages are requeued")
+	relay.Sink = SinkFunc(func(ctx context.Context, m Message) error {
+		published = append(published, m.Topic)
+		return nil
+	})
+	result, err = relay.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, RelayResult{Published: 1}, result)
+	assert.Equal(t, []string{"transaction.refunded", "transaction.completed"}, published)
+}
@@ -0,0 +1,224 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Sink publishes messages. Publish returns nil only once the message is safely
+// handed over; any error has the relay retry it later.
+type Sink interface {
+	Publish(ctx context.Context, m Message) error
+}
+
+// SinkFunc adapts a function to a Sink.
+type SinkFunc func(ctx context.Context, m Message) error
+
+// Publish calls f.
+func (f SinkFunc) Publish(ctx context.Context, m Message) error {
+	return f(ctx, m)
+}
+
+// Fanout publishes each message to every sink. When any of them fails, the
+// message is retried for all of them, so the others see it again.
+func Fanout(sinks ...Sink) Sink {
+	return SinkFunc(func(ctx context.Context, m Message) error {
+		var errs []error
+		for _, sink := range sinks {
+			if err := sink.Publish(ctx, m); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	})
+}
+
+// AllTopics subscribes a Mux handler to every topic.
+const AllTopics = "*"
+
+// Mux is an in-process sink: it passes each message to the handlers of its
+// topic, in the order they were registered, stopping at the first error.
+// Messages without handlers are published to nobody.
+type Mux struct {
+	mu       sync.RWMutex
+	handlers map[string][]SinkFunc
+}
+
+// NewMux returns a Mux without handlers.
+func NewMux() *Mux {
+	return &Mux{handlers: map[string][]SinkFunc{}}
+}
+
+// Handle registers a handler for a topic, or for every topic with AllTopics.
+func (m *Mux) Handle(topic string, handler SinkFunc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handlers[topic] = append(m.handlers[topic], handler)
+}
+
+// Publish runs the message's handlers.
+func (m *Mux) Publish(ctx context.Context, msg Message) error {
+	m.mu.RLock()
+	handlers := append(append([]SinkFunc(nil), m.handlers[msg.Topic]...), m.handlers[AllTopics]...)
+	m.mu.RUnlock()
+	for _, handler := range handlers {
+		if err := handler(ctx, msg); err != nil {
+			return fmt.Errorf("%s handler: %w", msg.Topic, err)
+		}
+	}
+	return nil
+}
+
+// Headers of HTTP sink requests, also used for Kafka record headers.
+const (
+	HeaderMessageID = "X-Outbox-Id"
+	HeaderTopic     = "X-Outbox-Topic"
+	HeaderKey       = "X-Outbox-Key"
+)
+
+// HTTPSink posts each message as JSON to a URL. A 2xx response is a success.
+type HTTPSink struct {
+	URL    string
+	Client *http.Client // default: http.DefaultClient; the relay bounds each request
+	Header http.Header  // extra request headers, e.g. Authorization
+}
+
+// Publish posts the message.
+func (s *HTTPSink) Publish(ctx context.Context, m Message) error {
+	body, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	header := s.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Set(HeaderMessageID, strconv.FormatInt(m.ID, 10))
+	header.Set(HeaderTopic, m.Topic)
+	header.Set(HeaderKey, m.Key)
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	_, err = Post(ctx, client, s.URL, header, body)
+	return err
+}
+
+// Post sends body as a JSON POST with the given headers and returns the
+// response status, with an error for anything but a 2xx that quotes the start
+// of the response. The outbound webhook dispatcher sends with it too.
+func Post(ctx context.Context, client *http.Client, url string, header http.Header, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Keep the start of an error response for the failure
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg := "HTTP " + resp.Status
+		if s := strings.TrimSpace(string(snippet)); s != "" {
+			msg += ": " + s
+		}
+		return resp.StatusCode, errors.New(msg)
+	}
+	return resp.StatusCode, nil
+}
+
+// NATSPublisher is the part of a NATS connection the NATS sink uses; *nats.Conn
+// satisfies it.
+type NATSPublisher interface {
+	Publish(subject string, data []byte) error
+}
+
+// natsFlusher is implemented by *nats.Conn.
+type natsFlusher interface {
+	FlushWithContext(ctx context.Context) error
+}
+
+// NATSSink publishes each message as JSON to the subject SubjectPrefix+topic,
+// e.g. "payments.transaction.completed". NATS buffers publishes, so when the
+// connection can flush (as *nats.Conn can), Publish waits for the server to
+// have the message.
+type NATSSink struct {
+	Conn          NATSPublisher
+	SubjectPrefix string
+}
+
+// Publish sends the message.
+func (s *NATSSink) Publish(ctx context.Context, m Message) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err := s.Conn.Publish(s.SubjectPrefix+m.Topic, data); err != nil {
+		return err
+	}
+	if f, ok := s.Conn.(natsFlusher); ok {
+		return f.FlushWithContext(ctx)
+	}
+	return nil
+}
+
+// KafkaRecord is a record for a Kafka topic.
+type KafkaRecord struct {
+	Topic   string
+	Key     []byte
+	Value   []byte
+	Headers map[string]string
+}
+
+// KafkaProducer sends a record and returns once the brokers have acknowledged
+// it. Client libraries are adapted with a small wrapper around their synchronous
+// producer, e.g. kafka-go's Writer.WriteMessages with RequiredAcks set to all.
+type KafkaProducer interface {
+	Produce(ctx context.Context, record KafkaRecord) error
+}
+
+// KafkaSink sends each message as a JSON record keyed by the message key, so a
+// key's messages land on one partition in order.
+type KafkaSink struct {
+	Producer KafkaProducer
+	// Topic receives every message; when empty each message goes to the Kafka
+	// topic named after its own topic
+	Topic string
+}
+
+// Publish sends the message.
+func (s *KafkaSink) Publish(ctx context.Context, m Message) error {
+	value, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	topic := s.Topic
+	if topic == "" {
+		topic = m.Topic
+	}
+	return s.Producer.Produce(ctx, KafkaRecord{
+		Topic: topic,
+		Key:   []byte(m.Key),
+		Value: value,
+		Headers: map[string]string{
+			HeaderMessageID: strconv.FormatInt(m.ID, 10),
+			HeaderTopic:     m.Topic,
+		},
+	})
+}
@@ -0,0 +1,150 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testMessage = Message{
+	ID:        7,
+	Topic:     "transaction.completed",
+	Key:       "txn_1",
+	Payload:   json.RawMessage(`{"id":"evt_1"}`),
+	CreatedAt: time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC),
+	Attempt:   1,
+}
+
+func TestMux(t *testing.T) {
+	mux := NewMux()
+	var calls []string
+	mux.Handle("transaction.completed", func(ctx context.Context, m Message) error {
+		calls = append(calls, "fulfillment")
+		return nil
+	})
+	mux.Handle(AllTopics, func(ctx context.Context, m Message) error {
+		calls = append(calls, "log")
+		return nil
+	})
+
+	require.NoError(t, mux.Publish(context.Background(), testMessage))
+	assert.Equal(t, []string{"fulfillment", "log"}, calls)
+	calls = nil
+	require.NoError(t, mux.Publish(context.Background(), Message{Topic: "transaction.failed"}))
+	assert.Equal(t, []string{"log"}, calls)
+
+	mux.Handle("transaction.failed", func(ctx context.Context, m Message) error {
+		return errors.New("mail server down")
+	})
+	assert.ErrorContains(t, mux.Publish(context.Background(), Message{Topic: "transaction.failed"}), "mail server down")
+}
+
+func TestFanout(t *testing.T) {
+	var got []string
+	ok := SinkFunc(func(ctx context.Context, m Message) error {
+		got = append(got, m.Key)
+		return nil
+	})
+	broken := SinkFunc(func(ctx context.Context, m Message) error { return errors.New("down") })
+
+	assert.NoError(t, Fanout(ok, ok).Publish(context.Background(), testMessage))
+	assert.Len(t, got, 2)
+	assert.Error(t, Fanout(broken, ok).Publish(context.Background(), testMessage))
+	assert.Len(t, got, 3, "the healthy sink still gets the message")
+}
+
+func TestHTTPSink(t *testing.T) {
+	var req *http.Request
+	var body []byte
+	status := http.StatusAccepted
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte("queue full"))
+	}))
+	defer server.Close()
+
+	sink := &HTTPSink{URL: server.URL, Header: http.Header{"Authorization": {"Bearer token"}}}
+	require.NoError(t, sink.Publish(context.Background(), testMessage))
+	assert.Equal(t, "7", req.Header.Get(HeaderMessageID))
+	assert.Equal(t, "transaction.completed", req.Header.Get(HeaderTopic))
+	assert.Equal(t, "txn_1", req.Header.Get(HeaderKey))
+	assert.Equal(t, "Bearer token", req.Header.Get("Authorization"))
+	var sent Message
+	require.NoError(t, json.Unmarshal(body, &sent))
+	assert.Equal(t, testMessage, sent)
+
+	status = http.StatusServiceUnavailable
+	assert.ErrorContains(t, sink.Publish(context.Background(), testMessage), "503 Service Unavailable: queue full")
+}
+
+// natsStandIn records publishes like a NATS connection, and flushes like
+// *nats.Conn.
+type natsStandIn struct {
+	subjects []string
+	data     [][]byte
+	flushes  int
+	flushErr error
+}
+
+func (n *natsStandIn) Publish(subject string, data []byte) error {
+	n.subjects = append(n.subjects, subject)
+	n.data = append(n.data, data)
+	return nil
+}
+
+func (n *natsStandIn) FlushWithContext(ctx context.Context) error {
+	n.flushes++
+	return n.flushErr
+}
+
+func TestNATSSink(t *testing.T) {
+	conn := &natsStandIn{}
+	sink := &NATSSink{Conn: conn, SubjectPrefix: "payments."}
+	require.NoError(t, sink.Publish(context.Background(), testMessage))
+	assert.Equal(t, []string{"payments.transaction.completed"}, conn.subjects)
+	assert.Equal(t, 1, conn.flushes, "waits for the server")
+	var sent Message
+	require.NoError(t, json.Unmarshal(conn.data[0], &sent))
+	assert.Equal(t, testMessage, sent)
+
+	conn.flushErr = errors.New("nats: timeout")
+	assert.Error(t, sink.Publish(context.Background(), testMessage))
+}
+
+// kafkaStandIn records records like a synchronous Kafka producer.
+type kafkaStandIn struct {
+	records []KafkaRecord
+	err     error
+}
+
+func (k *kafkaStandIn) Produce(ctx context.Context, record KafkaRecord) error {
+	if k.err != nil {
+		return k.err
+	}
+	k.records = append(k.records, record)
+	return nil
+}
+
+func TestKafkaSink(t *testing.T) {
+	producer := &kafkaStandIn{}
+	require.NoError(t, (&KafkaSink{Producer: producer}).Publish(context.Background(), testMessage))
+	require.NoError(t, (&KafkaSink{Producer: producer, Topic: "payments"}).Publish(context.Background(), testMessage))
+	require.Len(t, producer.records, 2)
+	assert.Equal(t, "transaction.completed", producer.records[0].Topic)
+	assert.Equal(t, "payments", producer.records[1].Topic)
+	assert.Equal(t, []byte("txn_1"), producer.records[0].Key, "keyed for per-key ordering")
+	assert.Equal(t, map[string]string{HeaderMessageID: "7", HeaderTopic: "transaction.completed"}, producer.records[0].Headers)
+
+	producer.err = errors.New("kafka: not enough replicas")
+	assert.Error(t, (&KafkaSink{Producer: producer}).Publish(context.Background(), testMessage))
+}
@@ -0,0 +1,204 @@
+package payments
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/stripe/stripe-go/v82"
+)
+
+// ErrCheckoutOption is returned for invalid checkout options and for per-request
+// overrides of options that are not overridable.
+var ErrCheckoutOption = errors.New("invalid checkout option")
+
+// URL template placeholders. {CHECKOUT_SESSION_ID} is filled in by Stripe.
+const (
+	BaseURLPlaceholder   = "{BASE_URL}"
+	SessionIDPlaceholder = "{CHECKOUT_SESSION_ID}"
+)
+
+// Default URL templates, matching the routes the frontend handles.
+const (
+	DefaultBaseURL    = "http://localhost:8060"
+	DefaultSuccessURL = BaseURLPlaceholder + "/app?success=true&session_id=" + SessionIDPlaceholder
+	DefaultCancelURL  = BaseURLPlaceholder + "/app?canceled=true"
+)
+
+// Names of the checkout options, as used in Config.Overridable and in the
+// options of a checkout request.
+const (
+	OptionPaymentMethodTypes       = "payment_method_types"
+	OptionLocale                   = "locale"
+	OptionBillingAddressCollection = "billing_address_collection"
+	OptionPhoneNumberCollection    = "phone_number_collection"
+	OptionCustomText               = "custom_text"
+)
+
+var optionNames = []string{OptionPaymentMethodTypes, OptionLocale, OptionBillingAddressCollection,
+	OptionPhoneNumberCollection, OptionCustomText}
+
+// paymentMethodTypes are the Checkout payment method types accepted in options.
+var paymentMethodTypes = []string{
+	"acss_debit", "affirm", "afterpay_clearpay", "alipay", "amazon_pay", "au_becs_debit",
+	"bacs_debit", "bancontact", "blik", "boleto", "card", "cashapp", "eps", "fpx", "giropay",
+	"grabpay", "ideal", "klarna", "konbini", "link", "mobilepay", "multibanco", "oxxo", "p24",
+	"paynow", "paypal", "pix", "promptpay", "revolut_pay", "sepa_debit", "sofort", "swish",
+	"twint", "us_bank_account", "wechat_pay", "zip",
+}
+
+// checkoutLocales are the locales Checkout is translated into.
+var checkoutLocales = []string{
+	"auto", "bg", "cs", "da", "de", "el", "en", "en-GB", "es", "es-419", "et", "fi", "fil",
+	"fr", "fr-CA", "hr", "hu", "id", "it", "ja", "ko", "lt", "lv", "ms", "mt", "nb", "nl",
+	"pl", "pt", "pt-BR", "ro", "ru", "sk", "sl", "sv", "th", "tr", "vi", "zh", "zh-HK", "zh-TW",
+}
+
+// customTextLimit is the longest custom text Stripe accepts.
+const customTextLimit = 1200
+
+// CustomText is shown on the Checkout page. Empty messages are not shown.
+type CustomText struct {
+	Submit      string `json:"submit,omitempty"`       // next to the pay button
+	AfterSubmit string `json:"after_submit,omitempty"` // below the pay button
+}
+
+// CheckoutOptions customize the Stripe Checkout page. Zero fields leave Stripe's
+// defaults in place; in overrides they leave the configured value.
+type CheckoutOptions struct {
+	// PaymentMethodTypes restricts the payment methods offered; empty lets Stripe
+	// pick from the ones enabled in the dashboard
+	PaymentMethodTypes []string `json:"payment_method_types,omitempty"`
+	Locale             string   `json:"locale,omitempty"`
+	// BillingAddressCollection is auto or required
+	BillingAddressCollection string     `json:"billing_address_collection,omitempty"`
+	PhoneNumberCollection    *bool      `json:"phone_number_collection,omitempty"`
+	CustomText               CustomText `json:"custom_text"`
+}
+
+// set returns the names of the options that are set.
+func (o CheckoutOptions) set() []string {
+	var names []string
+	if len(o.PaymentMethodTypes) > 0 {
+		names = append(names, OptionPaymentMethodTypes)
+	}
+	if o.Locale != "" {
+		names = append(names, OptionLocale)
+	}
+	if o.BillingAddressCollection != "" {
+		names = append(names, OptionBillingAddressCollection)
+	}
+	if o.PhoneNumberCollection != nil {
+		names = append(names, OptionPhoneNumberCollection)
+	}
+	if o.CustomText != (CustomText{}) {
+		names = append(names, OptionCustomText)
+	}
+	return names
+}
+
+// Key identifies the options for reusing checkout sessions: "" when none is
+// set, their JSON otherwise.
+func (o CheckoutOptions) Key() string {
+	if len(o.set()) == 0 {
+		return ""
+	}
+	b, err := json.Marshal(o)
+	if err != nil {
+		panic(err) // strings, a slice and a bool always marshal
+	}
+	return string(b)
+}
+
+// Validate checks every option that is set.
+func (o CheckoutOptions) Validate() error {
+	for _, t := range o.PaymentMethodTypes {
+		if !slices.Contains(paymentMethodTypes, t) {
+			return fmt.Errorf("%w: unknown payment method type %q", ErrCheckoutOption, t)
+		}
+	}
+	if o.Locale != "" && !slices.Contains(checkoutLocales, o.Locale) {
+		return fmt.Errorf("%w: unsupported locale %q", ErrCheckoutOption, o.Locale)
+	}
+	switch o.BillingAddressCollection {
+	case "", "auto", "required":
+	default:
+		return fmt.Errorf("%w: billing address collection must be auto or required, not %q", ErrCheckoutOption, o.BillingAddressCollection)
+	}
+	for _, text := range []string{o.CustomText.Submit, o.CustomText.AfterSubmit} {
+		if len(text) > customTextLimit {
+			return fmt.Errorf("%w: custom text is longer than %d characters", ErrCheckoutOption, customTextLimit)
+		}
+	}
+	return nil
+}
+
+// merge returns o with the options set in overrides replaced.
+func (o CheckoutOptions) merge(overrides CheckoutOptions) CheckoutOptions {
+	if len(overrides.PaymentMethodTypes) > 0 {
+		o.PaymentMethodTypes = overrides.PaymentMethodTypes
+	}
+	if overrides.Locale != "" {
+		o.Locale = overrides.Locale
+	}
+	if overrides.BillingAddressCollection != "" {
+		o.BillingAddressCollection = overrides.BillingAddressCollection
+	}
+	if overrides.PhoneNumberCollection != nil {
+		o.PhoneNumberCollection = overrides.PhoneNumberCollection
+	}
+	if overrides.CustomText != (CustomText{}) {
+		o.CustomText = overrides.CustomText
+	}
+	return o
+}
+
+// apply sets the options on Stripe session parameters.
+func (o CheckoutOptions) apply(params *stripe.CheckoutSessionParams) {
+	if len(o.PaymentMethodTypes) > 0 {
+		params.PaymentMethodTypes = stripe.StringSlice(o.PaymentMethodTypes)
+	}
+	if o.Locale != "" {
+		params.Locale = stripe.String(o.Locale)
+	}
+	if o.BillingAddressCollection != "" {
+		params.BillingAddressCollection = stripe.String(o.BillingAddressCollection)
+	}
+	if o.PhoneNumberCollection != nil {
+		params.PhoneNumberCollection = &stripe.CheckoutSessionPhoneNumberCollectionParams{
+			Enabled: stripe.Bool(*o.PhoneNumberCollection),
+		}
+	}
+	if o.CustomText != (CustomText{}) {
+		params.CustomText = &stripe.CheckoutSessionCustomTextParams{}
+		if o.CustomText.Submit != "" {
+			params.CustomText.Submit = &stripe.CheckoutSessionCustomTextSubmitParams{
+				Message: stripe.String(o.CustomText.Submit),
+			}
+		}
+		if o.CustomText.AfterSubmit != "" {
+			params.CustomText.AfterSubmit = &stripe.CheckoutSessionCustomTextAfterSubmitParams{
+				Message: stripe.String(o.CustomText.AfterSubmit),
+			}
+		}
+	}
+}
+
+// expandURL fills the base URL into a URL template and checks that the result is
+// an absolute http(s) URL.
+func expandURL(template, baseURL string) (string, error) {
+	raw := strings.ReplaceAll(template, BaseURLPlaceholder, strings.TrimRight(baseURL, "/"))
+	// The session ID placeholder isn't valid in every URL position; check the
+	// URL with a stand-in
+	u, err := url.Parse(strings.ReplaceAll(raw, SessionIDPlaceholder, "cs_test"))
+	if err != nil {
+		return "", fmt.Errorf("%w: URL %q: %v", ErrCheckoutOption, template, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("%w: URL %q is not an absolute http(s) URL", ErrCheckoutOption, raw)
+	}
+	return raw, nil
+}
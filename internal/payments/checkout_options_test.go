@@ -0,0 +1,71 @@
+package payments
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stripe/stripe-go/v82"
+)
+
+func TestConfigValidate(t *testing.T) {
+	assert.NoError(t, Config{}.Validate())
+	assert.NoError(t, Config{
+		BaseURL:     "https://shop.example.com/",
+		SuccessURL:  "{BASE_URL}/thanks/{CHECKOUT_SESSION_ID}",
+		Checkout:    CheckoutOptions{PaymentMethodTypes: []string{"card", "link"}, Locale: "fr-CA", BillingAddressCollection: "required"},
+		Overridable: []string{OptionLocale},
+	}.Validate())
+
+	for name, cfg := range map[string]Config{
+		"relative URL":     {SuccessURL: "/app?success=true"},
+		"bad base URL":     {BaseURL: "shop.example.com"},
+		"payment method":   {Checkout: CheckoutOptions{PaymentMethodTypes: []string{"cheque"}}},
+		"locale":           {Checkout: CheckoutOptions{Locale: "xx"}},
+		"billing address":  {Checkout: CheckoutOptions{BillingAddressCollection: "always"}},
+		"overridable name": {Overridable: []string{"mode"}},
+	} {
+		assert.ErrorIs(t, cfg.Validate(), ErrCheckoutOption, name)
+	}
+}
+
+func TestCheckoutOptionsOverrides(t *testing.T) {
+	yes := true
+	s := NewService(Config{
+		Checkout:    CheckoutOptions{Locale: "en", BillingAddressCollection: "required"},
+		Overridable: []string{OptionLocale, OptionPhoneNumberCollection},
+	})
+
+	options, err := s.CheckoutOptions(CheckoutOptions{Locale: "de", PhoneNumberCollection: &yes})
+	require.NoError(t, err)
+	assert.Equal(t, CheckoutOptions{Locale: "de", BillingAddressCollection: "required", PhoneNumberCollection: &yes}, options)
+
+	_, err = s.CheckoutOptions(CheckoutOptions{BillingAddressCollection: "auto"})
+	assert.ErrorIs(t, err, ErrCheckoutOption, "not overridable")
+	_, err = s.CheckoutOptions(CheckoutOptions{Locale: "klingon"})
+	assert.ErrorIs(t, err, ErrCheckoutOption, "overrides are validated")
+
+	params := &stripe.CheckoutSessionParams{}
+	options.CustomText = CustomText{Submit: "Ships in 2 days"}
+	options.apply(params)
+	assert.Equal(t, "de", *params.Locale)
+	assert.Equal(t, "required", *params.BillingAddressCollection)
+	assert.True(t, *params.PhoneNumberCollection.Enabled)
+	assert.Equal(t, "Ships in 2 days", *params.CustomText.Submit.Message)
+	assert.Nil(t, params.CustomText.AfterSubmit)
+	assert.Nil(t, params.PaymentMethodTypes)
+}
+
+func TestCheckoutOptionsKey(t *testing.T) {
+	assert.Empty(t, CheckoutOptions{}.Key())
+	de := CheckoutOptions{Locale: "de"}
+	assert.Equal(t, de.Key(), CheckoutOptions{Locale: "de"}.Key())
+	assert.NotEqual(t, de.Key(), CheckoutOptions{Locale: "fr"}.Key())
+	assert.NotEmpty(t, CheckoutOptions{CustomText: CustomText{Submit: "Thanks"}}.Key())
+}
+
+func TestExpandURL(t *testing.T) {
+	u, err := expandURL(DefaultSuccessURL, "https://shop.example.com/")
+	require.NoError(t, err)
+	assert.Equal(t, "https://shop.example.com/app?success=true&session_id={CHECKOUT_SESSION_ID}", u)
+}
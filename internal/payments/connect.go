@@ -0,0 +1,160 @@
+package payments
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stripe/stripe-go/v82"
+)
+
+// How a vendor's share reaches their connected account. Both are destination
+// charges: the platform is the seller of record and pays the Stripe fee.
+const (
+	// FeeModeApplicationFee transfers the whole charge and takes the platform's
+	// share back as an application fee
+	FeeModeApplicationFee = "application_fee"
+	// FeeModeTransferAmount transfers only the vendor's share. Needed with
+	// automatic tax, as the tax isn't known until the customer pays and the
+	// platform has to keep it.
+	FeeModeTransferAmount = "transfer_amount"
+)
+
+// Default onboarding URL templates, matching the routes the frontend handles.
+const (
+	DefaultConnectReturnURL  = BaseURLPlaceholder + "/app?onboarding=complete"
+	DefaultConnectRefreshURL = BaseURLPlaceholder + "/app?onboarding=refresh"
+)
+
+// OnboardingLinkTTL is how long Stripe keeps account links valid.
+const OnboardingLinkTTL = 5 * time.Minute
+
+func (c Config) feeMode() (string, error) {
+	switch c.ConnectFeeMode {
+	case "":
+		if c.AutomaticTax {
+			return FeeModeTransferAmount, nil
+		}
+		return FeeModeApplicationFee, nil
+	case FeeModeApplicationFee:
+		if c.AutomaticTax {
+			return "", fmt.Errorf("connect fee mode %s can't be used with automatic tax", FeeModeApplicationFee)
+		}
+		return c.ConnectFeeMode, nil
+	case FeeModeTransferAmount:
+		return c.ConnectFeeMode, nil
+	}
+	return "", fmt.Errorf("unknown connect fee mode %q", c.ConnectFeeMode)
+}
+
+func (c Config) connectReturnURL() (string, error) {
+	if c.ConnectReturnURL == "" {
+		return expandURL(DefaultConnectReturnURL, c.baseURL())
+	}
+	return expandURL(c.ConnectReturnURL, c.baseURL())
+}
+
+func (c Config) connectRefreshURL() (string, error) {
+	if c.ConnectRefreshURL == "" {
+		return expandURL(DefaultConnectRefreshURL, c.baseURL())
+	}
+	return expandURL(c.ConnectRefreshURL, c.baseURL())
+}
+
+// FeeMode returns how vendor checkouts split the charge, FeeModeApplicationFee
+// or FeeModeTransferAmount.
+func (s *Service) FeeMode() string {
+	mode, err := s.cfg.feeMode()
+	if err != nil {
+		return FeeModeApplicationFee // rejected by Config.Validate
+	}
+	return mode
+}
+
+// ConnectedAccountParams describe a vendor's Express account. Stripe collects
+// the rest during onboarding.
+type ConnectedAccountParams struct {
+	Name    string
+	Email   string
+	Country string
+}
+
+// CreateConnectedAccount creates an Express connected account able to take card
+// payments and receive transfers, and returns its ID. In mock mode it returns a
+// made-up ID.
+func (s *Service) CreateConnectedAccount(ctx context.Context, p ConnectedAccountParams) (string, error) {
+	if s.cfg.SecretKey == "" {
+		return "acct_mock_" + uuid.New().String(), nil
+	}
+	params := &stripe.AccountCreateParams{
+		Type:    stripe.String(string(stripe.AccountTypeExpress)),
+		Country: stripe.String(p.Country),
+		BusinessProfile: &stripe.AccountCreateBusinessProfileParams{
+			Name: stripe.String(p.Name),
+		},
+		Capabilities: &stripe.AccountCreateCapabilitiesParams{
+			CardPayments: &stripe.AccountCreateCapabilitiesCardPaymentsParams{Requested: stripe.Bool(true)},
+			Transfers:    &stripe.AccountCreateCapabilitiesTransfersParams{Requested: stripe.Bool(true)},
+		},
+	}
+	if p.Email != "" {
+		params.Email = stripe.String(p.Email)
+	}
+	a, err := s.client().V1Accounts.Create(ctx, params)
+	if err != nil {
+		return "", fmt.Errorf("failed to create Stripe connected account: %w", err)
+	}
+	return a.ID, nil
+}
+
+// OnboardingLink is a single-use link to Stripe's hosted onboarding.
+type OnboardingLink struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// CreateOnboardingLink creates an onboarding link for a connected account. In
+// mock mode the link goes straight to the return URL.
+func (s *Service) CreateOnboardingLink(ctx context.Context, accountID string) (OnboardingLink, error) {
+	returnURL, err := s.cfg.connectReturnURL()
+	if err != nil {
+		return OnboardingLink{}, err
+	}
+	refreshURL, err := s.cfg.connectRefreshURL()
+	if err != nil {
+		return OnboardingLink{}, err
+	}
+	if s.cfg.SecretKey == "" {
+		return OnboardingLink{URL: returnURL, ExpiresAt: time.Now().Add(OnboardingLinkTTL)}, nil
+	}
+	link, err := s.client().V1AccountLinks.Create(ctx, &stripe.AccountLinkCreateParams{
+		Account:    stripe.String(accountID),
+		ReturnURL:  stripe.String(returnURL),
+		RefreshURL: stripe.String(refreshURL),
+		Type:       stripe.String("account_onboarding"),
+	})
+	if err != nil {
+		return OnboardingLink{}, fmt.Errorf("failed to create Stripe onboarding link for %s: %w", accountID, err)
+	}
+	return OnboardingLink{URL: link.URL, ExpiresAt: time.Unix(link.ExpiresAt, 0)}, nil
+}
+
+// AccountStatus is what a connected account can do, from account.updated
+// events.
+type AccountStatus struct {
+	ID               string `json:"id"`
+	ChargesEnabled   bool   `json:"charges_enabled"`
+	PayoutsEnabled   bool   `json:"payouts_enabled"`
+	DetailsSubmitted bool   `json:"details_submitted"`
+}
+
+// accountStatus reads an account payload.
+func accountStatus(object map[string]interface{}) *AccountStatus {
+	status := &AccountStatus{}
+	status.ID, _ = object["id"].(string)
+	status.ChargesEnabled, _ = object["charges_enabled"].(bool)
+	status.PayoutsEnabled, _ = object["payouts_enabled"].(bool)
+	status.DetailsSubmitted, _ = object["details_submitted"].(bool)
+	return status
+}
@@ -0,0 +1,19 @@
+package payments
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnectFeeMode(t *testing.T) {
+	assert.Equal(t, FeeModeApplicationFee, NewService(Config{}).FeeMode())
+	assert.Equal(t, FeeModeTransferAmount, NewService(Config{AutomaticTax: true}).FeeMode())
+	assert.Equal(t, FeeModeTransferAmount, NewService(Config{ConnectFeeMode: FeeModeTransferAmount}).FeeMode())
+
+	assert.NoError(t, Config{ConnectFeeMode: FeeModeTransferAmount, AutomaticTax: true}.Validate())
+	assert.Error(t, Config{ConnectFeeMode: FeeModeApplicationFee, AutomaticTax: true}.Validate(),
+		"the platform has to keep tax it doesn't know yet")
+	assert.Error(t, Config{ConnectFeeMode: "direct"}.Validate())
+	assert.ErrorIs(t, Config{ConnectReturnURL: "/app"}.Validate(), ErrCheckoutOption)
+}
@@ -0,0 +1,108 @@
+package payments
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stripe/stripe-go/v82"
+
+	"stripe-go-spike/internal/money"
+)
+
+// CouponParams describe a coupon to mirror at Stripe. Product restrictions and
+// per-user limits are enforced locally only: line items don't reference Stripe
+// products, and promotion codes can't limit redemptions per customer.
+type CouponParams struct {
+	Code           string
+	PercentOff     int64
+	AmountOff      money.Amount
+	ExpiresAt      time.Time // zero for no expiry
+	MaxRedemptions int64     // zero for no limit
+}
+
+// StripeCoupon identifies the Stripe objects behind a local coupon.
+type StripeCoupon struct {
+	CouponID        string
+	PromotionCodeID string
+}
+
+// CreateCoupon creates a Stripe coupon and the promotion code customers enter.
+// In mock mode it returns made-up IDs.
+func (s *Service) CreateCoupon(ctx context.Context, p CouponParams) (StripeCoupon, error) {
+	if s.cfg.SecretKey == "" {
+		mockID := uuid.New().String()
+		return StripeCoupon{CouponID: "coupon_mock_" + mockID, PromotionCodeID: "promo_mock_" + mockID}, nil
+	}
+
+	// Coupons last forever; the promotion code carries the expiry and limits so
+	// they can be changed by replacing it
+	params := &stripe.CouponCreateParams{
+		Name:     stripe.String(p.Code),
+		Duration: stripe.String(string(stripe.CouponDurationOnce)),
+	}
+	if p.PercentOff > 0 {
+		params.PercentOff = stripe.Float64(float64(p.PercentOff))
+	} else {
+		params.AmountOff = stripe.Int64(p.AmountOff.Minor)
+		params.Currency = stripe.String(strings.ToLower(p.AmountOff.Currency))
+	}
+	c, err := s.client().V1Coupons.Create(ctx, params)
+	if err != nil {
+		return StripeCoupon{}, fmt.Errorf("failed to create Stripe coupon: %w", err)
+	}
+	promotionCodeID, err := s.createPromotionCode(ctx, c.ID, p)
+	if err != nil {
+		return StripeCoupon{}, err
+	}
+	return StripeCoupon{CouponID: c.ID, PromotionCodeID: promotionCodeID}, nil
+}
+
+// ReplacePromotionCode deactivates a coupon's promotion code and creates one with
+// the new expiry and limit, which Stripe doesn't allow to be changed. It returns
+// the new promotion code ID.
+func (s *Service) ReplacePromotionCode(ctx context.Context, sc StripeCoupon, p CouponParams) (string, error) {
+	if s.cfg.SecretKey == "" {
+		return "promo_mock_" + uuid.New().String(), nil
+	}
+	if err := s.DeactivatePromotionCode(ctx, sc.PromotionCodeID); err != nil {
+		return "", err
+	}
+	return s.createPromotionCode(ctx, sc.CouponID, p)
+}
+
+// DeactivatePromotionCode stops customers from entering a promotion code.
+func (s *Service) DeactivatePromotionCode(ctx context.Context, id string) error {
+	if s.cfg.SecretKey == "" || id == "" {
+		return nil
+	}
+	_, err := s.client().V1PromotionCodes.Update(ctx, id, &stripe.PromotionCodeUpdateParams{Active: stripe.Bool(false)})
+	if err != nil {
+		return fmt.Errorf("failed to deactivate Stripe promotion code %s: %w", id, err)
+	}
+	return nil
+}
+
+func (s *Service) createPromotionCode(ctx context.Context, couponID string, p CouponParams) (string, error) {
+	params := &stripe.PromotionCodeCreateParams{
+		Coupon: stripe.String(couponID),
+		Code:   stripe.String(p.Code),
+	}
+	if !p.ExpiresAt.IsZero() {
+		params.ExpiresAt = stripe.Int64(p.ExpiresAt.Unix())
+	}
+	if p.MaxRedemptions > 0 {
+		params.MaxRedemptions = stripe.Int64(p.MaxRedemptions)
+	}
+	promo, err := s.client().V1PromotionCodes.Create(ctx, params)
+	if err != nil {
+		return "", fmt.Errorf("failed to create Stripe promotion code %s: %w", p.Code, err)
+	}
+	return promo.ID, nil
+}
+
+func (s *Service) client() *stripe.Client {
+	return stripe.NewClient(s.cfg.SecretKey)
+}
@@ -0,0 +1,207 @@
+package payments
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"sort"
+	"sync"
+	"time"
+
+	"stripe-go-spike/internal/money"
+)
+
+// FakeGateway is an in-memory Gateway for tests. It pages through its objects
+// PageSize at a time (newest first, like Stripe) and counts the pages served.
+type FakeGateway struct {
+	PageSize int
+
+	mu             sync.Mutex
+	sessions       []RemoteCheckoutSession
+	paymentIntents []RemotePaymentIntent
+	refunds        []RemoteRefund
+	fees           map[string]money.Amount
+	pages          int
+	err            error
+}
+
+// NewFakeGateway returns an empty fake serving pages of two objects.
+func NewFakeGateway() *FakeGateway {
+	return &FakeGateway{PageSize: 2}
+}
+
+// AddCheckoutSession adds or replaces a session.
+func (g *FakeGateway) AddCheckoutSession(s RemoteCheckoutSession) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.sessions = upsert(g.sessions, s, func(x RemoteCheckoutSession) string { return x.ID })
+}
+
+// AddPaymentIntent adds or replaces a payment intent.
+func (g *FakeGateway) AddPaymentIntent(pi RemotePaymentIntent) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.paymentIntents = upsert(g.paymentIntents, pi, func(x RemotePaymentIntent) string { return x.ID })
+}
+
+// AddRefund adds or replaces a refund.
+func (g *FakeGateway) AddRefund(r RemoteRefund) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.refunds = upsert(g.refunds, r, func(x RemoteRefund) string { return x.ID })
+}
+
+// SetChargeFee sets the fee ChargeFee reports for a payment intent.
+func (g *FakeGateway) SetChargeFee(paymentIntentID string, fee money.Amount) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.fees == nil {
+		g.fees = make(map[string]money.Amount)
+	}
+	g.fees[paymentIntentID] = fee
+}
+
+// FailWith makes every following call fail with err (nil to recover).
+func (g *FakeGateway) FailWith(err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.err = err
+}
+
+// Pages returns the number of pages served so far.
+func (g *FakeGateway) Pages() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.pages
+}
+
+// CheckoutSession implements Gateway. Open sessions past their ExpiresAt are
+// reported as expired, as Stripe does.
+func (g *FakeGateway) CheckoutSession(ctx context.Context, id string) (RemoteCheckoutSession, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.err != nil {
+		return RemoteCheckoutSession{}, g.err
+	}
+	i, err := g.findSession(id)
+	if err != nil {
+		return RemoteCheckoutSession{}, err
+	}
+	return g.sessions[i], nil
+}
+
+// ExpireCheckoutSession implements Gateway. Like Stripe, it only expires open
+// sessions.
+func (g *FakeGateway) ExpireCheckoutSession(ctx context.Context, id string) (RemoteCheckoutSession, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.err != nil {
+		return RemoteCheckoutSession{}, g.err
+	}
+	i, err := g.findSession(id)
+	if err != nil {
+		return RemoteCheckoutSession{}, err
+	}
+	if g.sessions[i].Status != "open" {
+		return RemoteCheckoutSession{}, fmt.Errorf("checkout session %s is %s", id, g.sessions[i].Status)
+	}
+	g.sessions[i].Status = "expired"
+	g.sessions[i].URL = ""
+	return g.sessions[i], nil
+}
+
+// ChargeFee implements Gateway. Payment intents without a fee set report zero.
+func (g *FakeGateway) ChargeFee(ctx context.Context, paymentIntentID string) (money.Amount, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.err != nil {
+		return money.Amount{}, g.err
+	}
+	return g.fees[paymentIntentID], nil
+}
+
+// findSession returns the index of a session, first expiring it if it is open
+// past its ExpiresAt. g.mu must be held.
+func (g *FakeGateway) findSession(id string) (int, error) {
+	for i, s := range g.sessions {
+		if s.ID == id {
+			if s.Status == "open" && !s.ExpiresAt.IsZero() && !time.Now().Before(s.ExpiresAt) {
+				g.sessions[i].Status = "expired"
+				g.sessions[i].URL = ""
+			}
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("checkout session %s: %w", id, ErrNotFound)
+}
+
+// CheckoutSessions implements Gateway.
+func (g *FakeGateway) CheckoutSessions(ctx context.Context, w Window) iter.Seq2[RemoteCheckoutSession, error] {
+	g.mu.Lock()
+	items := inWindow(g.sessions, w, func(x RemoteCheckoutSession) time.Time { return x.Created })
+	g.mu.Unlock()
+	return fakePages(ctx, g, items)
+}
+
+// PaymentIntents implements Gateway.
+func (g *FakeGateway) PaymentIntents(ctx context.Context, w Window) iter.Seq2[RemotePaymentIntent, error] {
+	g.mu.Lock()
+	items := inWindow(g.paymentIntents, w, func(x RemotePaymentIntent) time.Time { return x.Created })
+	g.mu.Unlock()
+	return fakePages(ctx, g, items)
+}
+
+// Refunds implements Gateway.
+func (g *FakeGateway) Refunds(ctx context.Context, w Window) iter.Seq2[RemoteRefund, error] {
+	g.mu.Lock()
+	items := inWindow(g.refunds, w, func(x RemoteRefund) time.Time { return x.Created })
+	g.mu.Unlock()
+	return fakePages(ctx, g, items)
+}
+
+func upsert[T any](items []T, item T, id func(T) string) []T {
+	for i, x := range items {
+		if id(x) == id(item) {
+			items[i] = item
+			return items
+		}
+	}
+	return append(items, item)
+}
+
+// inWindow copies the items created in w, newest first.
+func inWindow[T any](items []T, w Window, created func(T) time.Time) []T {
+	var out []T
+	for _, item := range items {
+		if w.Contains(created(item)) {
+			out = append(out, item)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return created(out[i]).After(created(out[j])) })
+	return out
+}
+
+func fakePages[T any](ctx context.Context, g *FakeGateway, items []T) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		size := max(g.PageSize, 1)
+		for start := 0; start == 0 || start < len(items); start += size {
+			g.mu.Lock()
+			g.pages++
+			err := g.err
+			g.mu.Unlock()
+			if err == nil {
+				err = ctx.Err()
+			}
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			for _, item := range items[start:min(start+size, len(items))] {
+				if !yield(item, nil) {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/stripe/stripe-go/v82"
+
+	"stripe-go-spike/internal/money"
+)
+
+// ErrNotFound is returned when Stripe has no object with the requested ID.
+var ErrNotFound = errors.New("not found at Stripe")
+
+// Window selects Stripe objects created in [From, To).
+type Window struct {
+	From time.Time
+	To   time.Time
+}
+
+// Contains reports whether t falls in the window.
+func (w Window) Contains(t time.Time) bool {
+	return !t.Before(w.From) && t.Before(w.To)
+}
+
+// RemoteCheckoutSession is the state of a checkout session at Stripe.
+type RemoteCheckoutSession struct {
+	ID              string
+	URL             string // hosted checkout page, while the session is open
+	PaymentIntentID string
+	TransactionID   string // from the session metadata
+	Status          string // open, complete or expired
+	PaymentStatus   string // paid, unpaid or no_payment_required
+	Amount          money.Amount
+	Created         time.Time
+	ExpiresAt       time.Time // when an open session expires
+}
+
+// RemotePaymentIntent is the state of a payment intent at Stripe.
+type RemotePaymentIntent struct {
+	ID               string
+	Status           string // e.g. succeeded, canceled, requires_payment_method
+	LastPaymentError string // set when the latest payment attempt failed
+	Amount           money.Amount
+	Created          time.Time
+}
+
+// RemoteRefund is the state of a refund at Stripe.
+type RemoteRefund struct {
+	ID              string
+	PaymentIntentID string
+	Status          string // pending, succeeded, failed or canceled
+	Amount          money.Amount
+	Created         time.Time
+}
+
+// Gateway reads payment objects from Stripe. The iterators page through all objects
+// created in the window and stop at the first error.
+type Gateway interface {
+	// CheckoutSession returns one session, or ErrNotFound.
+	CheckoutSession(ctx context.Context, id string) (RemoteCheckoutSession, error)
+	// ExpireCheckoutSession expires an open session so it can no longer be paid.
+	ExpireCheckoutSession(ctx context.Context, id string) (RemoteCheckoutSession, error)
+	// ChargeFee returns the Stripe fee on the latest charge of a payment intent,
+	// or zero while the charge has no balance transaction.
+	ChargeFee(ctx context.Context, paymentIntentID string) (money.Amount, error)
+	CheckoutSessions(ctx context.Context, w Window) iter.Seq2[RemoteCheckoutSession, error]
+	PaymentIntents(ctx context.Context, w Window) iter.Seq2[RemotePaymentIntent, error]
+	Refunds(ctx context.Context, w Window) iter.Seq2[RemoteRefund, error]
+}
+
+// StripeGateway is the Gateway backed by the Stripe API.
+type StripeGateway struct {
+	client   *stripe.Client
+	pageSize int64
+}
+
+// NewStripeGateway returns a gateway using the given secret key.
+func NewStripeGateway(secretKey string) *StripeGateway {
+	return &StripeGateway{client: stripe.NewClient(secretKey), pageSize: 100}
+}
+
+func (g *StripeGateway) listParams(w Window) (stripe.ListParams, *stripe.RangeQueryParams) {
+	return stripe.ListParams{Limit: stripe.Int64(g.pageSize)},
+		&stripe.RangeQueryParams{GreaterThanOrEqual: w.From.Unix(), LesserThan: w.To.Unix()}
+}
+
+// CheckoutSession implements Gateway.
+func (g *StripeGateway) CheckoutSession(ctx context.Context, id string) (RemoteCheckoutSession, error) {
+	s, err := g.client.V1CheckoutSessions.Retrieve(ctx, id, nil)
+	if err != nil {
+		return RemoteCheckoutSession{}, sessionError(id, err)
+	}
+	return remoteCheckoutSession(s), nil
+}
+
+// ExpireCheckoutSession implements Gateway.
+func (g *StripeGateway) ExpireCheckoutSession(ctx context.Context, id string) (RemoteCheckoutSession, error) {
+	s, err := g.client.V1CheckoutSessions.Expire(ctx, id, nil)
+	if err != nil {
+		return RemoteCheckoutSession{}, sessionError(id, err)
+	}
+	return remoteCheckoutSession(s), nil
+}
+
+// ChargeFee implements Gateway.
+func (g *StripeGateway) ChargeFee(ctx context.Context, paymentIntentID string) (money.Amount, error) {
+	params := &stripe.PaymentIntentRetrieveParams{}
+	params.AddExpand("latest_charge.balance_transaction")
+	pi, err := g.client.V1PaymentIntents.Retrieve(ctx, paymentIntentID, params)
+	if err != nil {
+		return money.Amount{}, err
+	}
+	if pi.LatestCharge == nil || pi.LatestCharge.BalanceTransaction == nil {
+		return money.Amount{}, nil
+	}
+	bt := pi.LatestCharge.BalanceTransaction
+	return stripeAmount(bt.Fee, bt.Currency), nil
+}
+
+// sessionError maps Stripe's 404 for a checkout session to ErrNotFound.
+func sessionError(id string, err error) error {
+	var stripeErr *stripe.Error
+	if errors.As(err, &stripeErr) && stripeErr.HTTPStatusCode == http.StatusNotFound {
+		return fmt.Errorf("checkout session %s: %w", id, ErrNotFound)
+	}
+	return err
+}
+
+// CheckoutSessions implements Gateway.
+func (g *StripeGateway) CheckoutSessions(ctx context.Context, w Window) iter.Seq2[RemoteCheckoutSession, error] {
+	list, created := g.listParams(w)
+	params := &stripe.CheckoutSessionListParams{ListParams: list, CreatedRange: created}
+	return func(yield func(RemoteCheckoutSession, error) bool) {
+		for s, err := range g.client.V1CheckoutSessions.List(ctx, params) {
+			if err != nil {
+				yield(RemoteCheckoutSession{}, err)
+				return
+			}
+			if !yield(remoteCheckoutSession(s), nil) {
+				return
+			}
+		}
+	}
+}
+
+// PaymentIntents implements Gateway.
+func (g *StripeGateway) PaymentIntents(ctx context.Context, w Window) iter.Seq2[RemotePaymentIntent, error] {
+	list, created := g.listParams(w)
+	params := &stripe.PaymentIntentListParams{ListParams: list, CreatedRange: created}
+	return func(yield func(RemotePaymentIntent, error) bool) {
+		for pi, err := range g.client.V1PaymentIntents.List(ctx, params) {
+			if err != nil {
+				yield(RemotePaymentIntent{}, err)
+				return
+			}
+			remote := RemotePaymentIntent{
+				ID:      pi.ID,
+				Status:  string(pi.Status),
+				Amount:  stripeAmount(pi.Amount, pi.Currency),
+				Created: time.Unix(pi.Created, 0),
+			}
+			if pi.LastPaymentError != nil {
+				remote.LastPaymentError = pi.LastPaymentError.Msg
+			}
+			if !yield(remote, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Refunds implements Gateway.
+func (g *StripeGateway) Refunds(ctx context.Context, w Window) iter.Seq2[RemoteRefund, error] {
+	list, created := g.listParams(w)
+	params := &stripe.RefundListParams{ListParams: list, CreatedRange: created}
+	return func(yield func(RemoteRefund, error) bool) {
+		for r, err := range g.client.V1Refunds.List(ctx, params) {
+			if err != nil {
+				yield(RemoteRefund{}, err)
+				return
+			}
+			remote := RemoteRefund{
+				ID:      r.ID,
+				Status:  string(r.Status),
+				Amount:  stripeAmount(r.Amount, r.Currency),
+				Created: time.Unix(r.Created, 0),
+			}
+			if r.PaymentIntent != nil {
+				remote.PaymentIntentID = r.PaymentIntent.ID
+			}
+			if !yield(remote, nil) {
+				return
+			}
+		}
+	}
+}
+
+func remoteCheckoutSession(s *stripe.CheckoutSession) RemoteCheckoutSession {
+	remote := RemoteCheckoutSession{
+		ID:            s.ID,
+		URL:           s.URL,
+		TransactionID: s.Metadata["transaction_id"],
+		Status:        string(s.Status),
+		PaymentStatus: string(s.PaymentStatus),
+		Amount:        stripeAmount(s.AmountTotal, s.Currency),
+		Created:       time.Unix(s.Created, 0),
+		ExpiresAt:     time.Unix(s.ExpiresAt, 0),
+	}
+	if s.PaymentIntent != nil {
+		remote.PaymentIntentID = s.PaymentIntent.ID
+	}
+	return remote
+}
+
+// stripeAmount converts a Stripe amount and lower-case currency, leaving the
+// currency as reported if it is not in the money table.
+func stripeAmount(minor int64, currency stripe.Currency) money.Amount {
+	if a, err := money.New(minor, string(currency)); err == nil {
+		return a
+	}
+	return money.Amount{Minor: minor, Currency: strings.ToUpper(string(currency))}
+}
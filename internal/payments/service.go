@@ -3,13 +3,16 @@ package payments
 import (
 	"errors"
 	"fmt"
-	"os"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/stripe/stripe-go/v82"
 	"github.com/stripe/stripe-go/v82/checkout/session"
 	"github.com/stripe/stripe-go/v82/webhook"
+
+	"stripe-go-spike/internal/money"
 )
 
 // Config holds Stripe-related configuration. Values can be empty for local spikes.
@@ -17,25 +20,185 @@ type Config struct {
 	SecretKey      string
 	PublishableKey string
 	WebhookSecret  string
+	// ConnectWebhookSecret signs the events of the Connect endpoint, which
+	// carries the connected accounts' events such as account.updated. Webhooks
+	// verified with either secret are accepted.
+	ConnectWebhookSecret string
+	// SessionTTL is how long checkout sessions stay open (default DefaultSessionTTL)
+	SessionTTL time.Duration
+
+	// BaseURL replaces {BASE_URL} in the URL templates (default DefaultBaseURL)
+	BaseURL string
+	// SuccessURL and CancelURL are where Checkout sends the customer (defaults
+	// DefaultSuccessURL and DefaultCancelURL). Stripe fills in
+	// {CHECKOUT_SESSION_ID}.
+	SuccessURL string
+	CancelURL  string
+	// Checkout are the options applied to every session.
+	Checkout CheckoutOptions
+	// Overridable names the checkout options (e.g. OptionLocale) a checkout request
+	// may override.
+	Overridable []string
+	// AutomaticTax has Stripe Tax calculate tax from the customer's address, in
+	// place of the local tax rates.
+	AutomaticTax bool
+
+	// ConnectFeeMode is how vendor checkouts split the charge (default
+	// FeeModeApplicationFee, or FeeModeTransferAmount with automatic tax)
+	ConnectFeeMode string
+	// ConnectReturnURL and ConnectRefreshURL are where Stripe sends a vendor after
+	// onboarding, and when an onboarding link has expired (defaults
+	// DefaultConnectReturnURL and DefaultConnectRefreshURL)
+	ConnectReturnURL  string
+	ConnectRefreshURL string
+}
+
+// Stripe accepts checkout session lifetimes from 30 minutes to 24 hours.
+const (
+	MinSessionTTL     = 30 * time.Minute
+	DefaultSessionTTL = 24 * time.Hour
+)
+
+// Validate checks the configuration before the service is created.
+func (c Config) Validate() error {
+	if c.SessionTTL != 0 && (c.SessionTTL < MinSessionTTL || c.SessionTTL > DefaultSessionTTL) {
+		return fmt.Errorf("checkout session TTL %s is outside %s to %s", c.SessionTTL, MinSessionTTL, DefaultSessionTTL)
+	}
+	if _, err := c.successURL(); err != nil {
+		return err
+	}
+	if _, err := c.cancelURL(); err != nil {
+		return err
+	}
+	if err := c.Checkout.Validate(); err != nil {
+		return err
+	}
+	if _, err := c.feeMode(); err != nil {
+		return err
+	}
+	if _, err := c.connectReturnURL(); err != nil {
+		return err
+	}
+	if _, err := c.connectRefreshURL(); err != nil {
+		return err
+	}
+	for _, name := range c.Overridable {
+		if !slices.Contains(optionNames, name) {
+			return fmt.Errorf("%w: unknown option %q cannot be overridable", ErrCheckoutOption, name)
+		}
+	}
+	return nil
+}
+
+func (c Config) baseURL() string {
+	if c.BaseURL == "" {
+		return DefaultBaseURL
+	}
+	return c.BaseURL
+}
+
+func (c Config) successURL() (string, error) {
+	if c.SuccessURL == "" {
+		return expandURL(DefaultSuccessURL, c.baseURL())
+	}
+	return expandURL(c.SuccessURL, c.baseURL())
+}
+
+func (c Config) cancelURL() (string, error) {
+	if c.CancelURL == "" {
+		return expandURL(DefaultCancelURL, c.baseURL())
+	}
+	return expandURL(c.CancelURL, c.baseURL())
 }
 
 // Service provides minimal payment-related operations needed for the spike.
 // In a real integration, this would wrap the official stripe-go client.
 type Service struct {
-	cfg Config
+	cfg     Config
+	gateway Gateway
+	mock    *FakeGateway // sessions created in mock mode
 }
 
 func NewService(cfg Config) *Service {
-	return &Service{cfg: cfg}
+	s := &Service{cfg: cfg}
+	if cfg.SecretKey != "" {
+		s.gateway = NewStripeGateway(cfg.SecretKey)
+	} else {
+		s.mock = NewFakeGateway()
+		s.gateway = s.mock
+	}
+	return s
+}
+
+// SessionTTL returns how long new checkout sessions stay open.
+func (s *Service) SessionTTL() time.Duration {
+	if s.cfg.SessionTTL == 0 {
+		return DefaultSessionTTL
+	}
+	return s.cfg.SessionTTL
+}
+
+// Gateway reads payment state from Stripe. In mock mode it is a FakeGateway that
+// knows the mock sessions, which stay open until they expire.
+func (s *Service) Gateway() Gateway {
+	return s.gateway
+}
+
+// MockMode reports whether the service runs without a Stripe key. The mock
+// sessions then live only in memory and are lost when the server restarts.
+func (s *Service) MockMode() bool {
+	return s.mock != nil
+}
+
+// CheckoutOptions returns the configured checkout options with overrides
+// applied. Overriding an option that is not in Config.Overridable, or an invalid
+// value, is an ErrCheckoutOption.
+func (s *Service) CheckoutOptions(overrides CheckoutOptions) (CheckoutOptions, error) {
+	for _, name := range overrides.set() {
+		if !slices.Contains(s.cfg.Overridable, name) {
+			return CheckoutOptions{}, fmt.Errorf("%w: %s cannot be overridden", ErrCheckoutOption, name)
+		}
+	}
+	if err := overrides.Validate(); err != nil {
+		return CheckoutOptions{}, err
+	}
+	return s.cfg.Checkout.merge(overrides), nil
 }
 
 // CheckoutSessionParams captures basic parameters to create a checkout session.
 type CheckoutSessionParams struct {
-	Amount        int64  `json:"amount"`
-	Currency      string `json:"currency"`
-	UserID        string `json:"user_id"`
-	ProductID     string `json:"product_id"`
-	TransactionID string `json:"transaction_id"`
+	Amount        money.Amount    `json:"amount"`
+	UserID        string          `json:"user_id"`
+	ProductID     string          `json:"product_id"`
+	TransactionID string          `json:"transaction_id"`
+	Options       CheckoutOptions `json:"options"` // overrides, see Service.CheckoutOptions
+	// PromotionCodeID applies a coupon's Stripe promotion code; Discount is what it
+	// takes off Amount, as worked out locally
+	PromotionCodeID string       `json:"promotion_code_id,omitempty"`
+	Discount        money.Amount `json:"discount"`
+	// AllowPromotionCodes lets the customer enter a promotion code on the Checkout
+	// page instead. Stripe doesn't allow both.
+	AllowPromotionCodes bool `json:"allow_promotion_codes,omitempty"`
+	// TaxCode is the product's Stripe tax code, used by automatic tax
+	TaxCode string `json:"tax_code,omitempty"`
+	// TaxRateID applies a local tax rate's Stripe tax rate; Tax is the tax it adds
+	// to the discounted amount, as worked out locally
+	TaxRateID string       `json:"tax_rate_id,omitempty"`
+	Tax       money.Amount `json:"tax"`
+	// CollectBillingAddress requires a billing address, which the webhook checks
+	// the local tax rate against
+	CollectBillingAddress bool `json:"collect_billing_address,omitempty"`
+	// Destination is the connected account of the vendor selling the product.
+	// ApplicationFee is the platform's part of the total and Transfer the
+	// vendor's; which one is sent depends on the fee mode.
+	Destination    string       `json:"destination,omitempty"`
+	ApplicationFee money.Amount `json:"application_fee"`
+	Transfer       money.Amount `json:"transfer"`
+}
+
+// AutomaticTax reports whether Stripe calculates tax at checkout.
+func (s *Service) AutomaticTax() bool {
+	return s.cfg.AutomaticTax
 }
 
 // CheckoutSession represents a simplified session response.
@@ -44,57 +207,150 @@ type CheckoutSession struct {
 	URL             string    `json:"url"`
 	PaymentIntentID string    `json:"payment_intent_id,omitempty"`
 	CreatedAt       time.Time `json:"createdAt"`
+	ExpiresAt       time.Time `json:"expiresAt"`
 }
 
 // CreateCheckoutSession creates a Stripe checkout session or returns a mock if no API key is set.
 func (s *Service) CreateCheckoutSession(p CheckoutSessionParams) (*CheckoutSession, error) {
-	if p.Amount <= 0 || p.Currency == "" {
-		return nil, errors.New("positive amount and currency are required")
+	if err := p.Amount.Validate(); err != nil {
+		return nil, err
+	}
+	if !p.Amount.IsPositive() {
+		return nil, errors.New("amount must be positive")
+	}
+	options, err := s.CheckoutOptions(p.Options)
+	if err != nil {
+		return nil, err
+	}
+	if p.AllowPromotionCodes && p.Discount.IsPositive() {
+		return nil, errors.New("a coupon can't be combined with promotion codes entered at checkout")
+	}
+	if s.cfg.AutomaticTax && p.Tax.IsPositive() {
+		return nil, errors.New("local tax can't be added when Stripe calculates tax")
+	}
+	if p.Destination != "" && p.AllowPromotionCodes {
+		// The split is worked out before checkout and wouldn't follow the discount
+		return nil, errors.New("promotion codes entered at checkout can't be used for a vendor's product")
+	}
+	total := p.Amount
+	if p.Discount.IsPositive() {
+		if total, err = p.Amount.Sub(p.Discount); err != nil {
+			return nil, err
+		}
+	}
+	if p.Tax.IsPositive() {
+		if total, err = total.Add(p.Tax); err != nil {
+			return nil, err
+		}
 	}
 
 	// If no Stripe secret key is configured, return mock response
 	if s.cfg.SecretKey == "" {
 		mockID := uuid.New().String()
-		return &CheckoutSession{
+		now := time.Now()
+		sess := &CheckoutSession{
 			ID:              "sess_mock_" + mockID,
 			URL:             fmt.Sprintf("https://checkout.stripe.com/pay/sess_mock_%s", mockID),
 			PaymentIntentID: "pi_mock_" + mockID,
-			CreatedAt:       time.Now(),
-		}, nil
+			CreatedAt:       now,
+			ExpiresAt:       now.Add(s.SessionTTL()),
+		}
+		s.mock.AddCheckoutSession(RemoteCheckoutSession{
+			ID:              sess.ID,
+			URL:             sess.URL,
+			PaymentIntentID: sess.PaymentIntentID,
+			TransactionID:   p.TransactionID,
+			Status:          "open",
+			PaymentStatus:   "unpaid",
+			Amount:          total,
+			Created:         sess.CreatedAt,
+			ExpiresAt:       sess.ExpiresAt,
+		})
+		return sess, nil
 	}
 
 	// Configure Stripe API key
 	stripe.Key = s.cfg.SecretKey
 
-	// Get the base URL from environment or use default
-	baseURL := os.Getenv("BASE_URL")
-	if baseURL == "" {
-		baseURL = "http://localhost:8060"
+	successURL, err := s.cfg.successURL()
+	if err != nil {
+		return nil, err
+	}
+	cancelURL, err := s.cfg.cancelURL()
+	if err != nil {
+		return nil, err
 	}
 
-	// Create Stripe checkout session
+	// Create Stripe checkout session. Line items are one-off prices, so the mode
+	// is always payment.
 	params := &stripe.CheckoutSessionParams{
 		Mode: stripe.String(string(stripe.CheckoutSessionModePayment)),
 		LineItems: []*stripe.CheckoutSessionLineItemParams{
 			{
 				PriceData: &stripe.CheckoutSessionLineItemPriceDataParams{
-					Currency: stripe.String(p.Currency),
+					// Stripe takes lower-case codes and amounts in the currency's minor unit
+					Currency: stripe.String(strings.ToLower(p.Amount.Currency)),
 					ProductData: &stripe.CheckoutSessionLineItemPriceDataProductDataParams{
 						Name: stripe.String(fmt.Sprintf("Product %s", p.ProductID)),
 					},
-					UnitAmount: stripe.Int64(p.Amount),
+					UnitAmount: stripe.Int64(p.Amount.Minor),
 				},
 				Quantity: stripe.Int64(1),
 			},
 		},
-		SuccessURL: stripe.String(fmt.Sprintf("%s/app?success=true&session_id={CHECKOUT_SESSION_ID}", baseURL)),
-		CancelURL:  stripe.String(fmt.Sprintf("%s/app?canceled=true", baseURL)),
+		SuccessURL: stripe.String(successURL),
+		CancelURL:  stripe.String(cancelURL),
+		ExpiresAt:  stripe.Int64(time.Now().Add(s.SessionTTL()).Unix()),
 		Metadata: map[string]string{
 			"user_id":        p.UserID,
 			"product_id":     p.ProductID,
 			"transaction_id": p.TransactionID,
 		},
 	}
+	options.apply(params)
+	if p.Discount.IsPositive() {
+		if p.PromotionCodeID == "" {
+			return nil, errors.New("coupon has no Stripe promotion code")
+		}
+		params.Discounts = []*stripe.CheckoutSessionDiscountParams{
+			{PromotionCode: stripe.String(p.PromotionCodeID)},
+		}
+	}
+	if p.AllowPromotionCodes {
+		params.AllowPromotionCodes = stripe.Bool(true)
+	}
+	// Prices are tax-exclusive either way: Stripe Tax adds tax on top, as does a
+	// local tax rate
+	item := params.LineItems[0]
+	if s.cfg.AutomaticTax {
+		params.AutomaticTax = &stripe.CheckoutSessionAutomaticTaxParams{Enabled: stripe.Bool(true)}
+		item.PriceData.TaxBehavior = stripe.String(string(stripe.PriceTaxBehaviorExclusive))
+		if p.TaxCode != "" {
+			item.PriceData.ProductData.TaxCode = stripe.String(p.TaxCode)
+		}
+	} else if p.TaxRateID != "" {
+		item.TaxRates = []*string{stripe.String(p.TaxRateID)}
+	} else if p.Tax.IsPositive() {
+		return nil, errors.New("tax rate has no Stripe tax rate")
+	}
+	if p.CollectBillingAddress {
+		params.BillingAddressCollection = stripe.String(string(stripe.CheckoutSessionBillingAddressCollectionRequired))
+	}
+	if p.Destination != "" {
+		// Destination charge: the platform is the seller of record and passes the
+		// vendor's share on
+		data := &stripe.CheckoutSessionPaymentIntentDataParams{
+			TransferData: &stripe.CheckoutSessionPaymentIntentDataTransferDataParams{
+				Destination: stripe.String(p.Destination),
+			},
+		}
+		if s.FeeMode() == FeeModeTransferAmount {
+			data.TransferData.Amount = stripe.Int64(p.Transfer.Minor)
+		} else if p.ApplicationFee.IsPositive() {
+			data.ApplicationFeeAmount = stripe.Int64(p.ApplicationFee.Minor)
+		}
+		params.PaymentIntentData = data
+	}
 
 	sess, err := session.New(params)
 	if err != nil {
@@ -105,6 +361,7 @@ func (s *Service) CreateCheckoutSession(p CheckoutSessionParams) (*CheckoutSessi
 		ID:        sess.ID,
 		URL:       sess.URL,
 		CreatedAt: time.Unix(sess.Created, 0),
+		ExpiresAt: time.Unix(sess.ExpiresAt, 0),
 	}
 
 	// Extract payment intent ID if available
@@ -117,19 +374,56 @@ func (s *Service) CreateCheckoutSession(p CheckoutSessionParams) (*CheckoutSessi
 
 // WebhookEvent represents a Stripe webhook event
 type WebhookEvent struct {
+	ID              string                 `json:"id"`
 	Type            string                 `json:"type"`
 	Data            map[string]interface{} `json:"data"`
+	ObjectID        string                 `json:"object_id,omitempty"` // ID of the event's object, e.g. re_... for refunds
 	SessionID       string                 `json:"session_id,omitempty"`
 	PaymentIntentID string                 `json:"payment_intent_id,omitempty"`
 	Status          string                 `json:"status,omitempty"`
+	// Created is when Stripe created the event; deliveries can arrive out of
+	// order
+	Created time.Time `json:"created"`
+	// Amount is the money moved by the event (amount received, refunded or
+	// disputed); zero when the payload doesn't carry one
+	Amount money.Amount `json:"amount"`
+	// Fee is the Stripe fee charged with the event. Disputes list it in the
+	// payload; for payment_intent.succeeded the webhook handler fetches it
+	Fee money.Amount `json:"fee"`
+	// Discount and PromotionCodeID are set for completed sessions paid with a
+	// promotion code
+	Discount        money.Amount `json:"discount"`
+	PromotionCodeID string       `json:"promotion_code_id,omitempty"`
+	// Tax is the tax charged on a completed session
+	Tax money.Amount `json:"tax"`
+	// BillingCountry and BillingRegion are the customer's billing address on a
+	// completed session, when Checkout collected one
+	BillingCountry string `json:"billing_country,omitempty"`
+	BillingRegion  string `json:"billing_region,omitempty"`
+	// Account is the connected account of an account.updated event
+	Account *AccountStatus `json:"account,omitempty"`
+}
+
+// webhookSecrets lists the configured signing secrets, the platform endpoint's
+// first.
+func (s *Service) webhookSecrets() []string {
+	var secrets []string
+	for _, secret := range []string{s.cfg.WebhookSecret, s.cfg.ConnectWebhookSecret} {
+		if secret != "" {
+			secrets = append(secrets, secret)
+		}
+	}
+	return secrets
 }
 
 // ProcessWebhook processes a Stripe webhook event
 func (s *Service) ProcessWebhook(payload []byte, signature string) (*WebhookEvent, error) {
 	// If no webhook secret is configured, return mock event
-	if s.cfg.WebhookSecret == "" {
+	secrets := s.webhookSecrets()
+	if len(secrets) == 0 {
 		mockID := uuid.New().String()
 		return &WebhookEvent{
+			ID:              "evt_mock_" + mockID,
 			Type:            "checkout.session.completed",
 			Data:            make(map[string]interface{}),
 			PaymentIntentID: "pi_mock_" + mockID,
@@ -138,18 +432,28 @@ func (s *Service) ProcessWebhook(payload []byte, signature string) (*WebhookEven
 	}
 
 	// Verify webhook signature with option to ignore API version mismatch
-	event, err := webhook.ConstructEventWithOptions(payload, signature, s.cfg.WebhookSecret, webhook.ConstructEventOptions{
-		IgnoreAPIVersionMismatch: true,
-	})
+	var event stripe.Event
+	var err error
+	for _, secret := range secrets {
+		event, err = webhook.ConstructEventWithOptions(payload, signature, secret, webhook.ConstructEventOptions{
+			IgnoreAPIVersionMismatch: true,
+		})
+		if err == nil {
+			break
+		}
+	}
 	if err != nil {
 		return nil, fmt.Errorf("webhook signature verification failed: %w", err)
 	}
 
 	// Extract relevant information based on event type
 	webhookEvent := &WebhookEvent{
-		Type: string(event.Type),
-		Data: event.Data.Object,
+		ID:      event.ID,
+		Type:    string(event.Type),
+		Data:    event.Data.Object,
+		Created: time.Unix(event.Created, 0).UTC(),
 	}
+	webhookEvent.ObjectID, _ = event.Data.Object["id"].(string)
 
 	switch event.Type {
 	case "checkout.session.completed":
@@ -157,6 +461,10 @@ func (s *Service) ProcessWebhook(payload []byte, signature string) (*WebhookEven
 			webhookEvent.SessionID = sessionData
 			webhookEvent.Status = "completed"
 		}
+		webhookEvent.Amount = objectAmount(event.Data.Object, "amount_total")
+		webhookEvent.Discount, webhookEvent.PromotionCodeID = sessionDiscount(event.Data.Object)
+		webhookEvent.Tax = totalDetail(event.Data.Object, "amount_tax")
+		webhookEvent.BillingCountry, webhookEvent.BillingRegion = billingAddress(event.Data.Object)
 		// Extract payment intent ID from session
 		if paymentIntentData, ok := event.Data.Object["payment_intent"].(string); ok {
 			webhookEvent.PaymentIntentID = paymentIntentData
@@ -166,6 +474,7 @@ func (s *Service) ProcessWebhook(payload []byte, signature string) (*WebhookEven
 		if paymentIntentData, ok := event.Data.Object["id"].(string); ok {
 			webhookEvent.PaymentIntentID = paymentIntentData
 		}
+		webhookEvent.Amount = objectAmount(event.Data.Object, "amount_received")
 	case "payment_intent.payment_failed":
 		webhookEvent.Status = "failed"
 		if paymentIntentData, ok := event.Data.Object["id"].(string); ok {
@@ -193,6 +502,23 @@ func (s *Service) ProcessWebhook(payload []byte, signature string) (*WebhookEven
 				webhookEvent.PaymentIntentID = paymentIntentData
 			}
 		}
+		webhookEvent.Amount = objectAmount(event.Data.Object, "amount")
+		// Disputes list the withdrawal and the dispute fee as balance transactions
+		if txns, ok := event.Data.Object["balance_transactions"].([]interface{}); ok {
+			for _, txn := range txns {
+				if txn, ok := txn.(map[string]interface{}); ok {
+					fee := objectAmount(txn, "fee")
+					if fee.Currency == "" {
+						continue
+					}
+					if webhookEvent.Fee.Currency == "" {
+						webhookEvent.Fee = fee
+					} else if sum, err := webhookEvent.Fee.Add(fee); err == nil {
+						webhookEvent.Fee = sum
+					}
+				}
+			}
+		}
 	case "refund.created":
 		webhookEvent.Status = "refunded"
 		// Extract payment intent ID from refund
@@ -206,7 +532,73 @@ func (s *Service) ProcessWebhook(payload []byte, signature string) (*WebhookEven
 			// to get the payment intent ID. For now, we'll just note that we have a charge ID.
 			_ = chargeID // Mark as used to avoid compiler error
 		}
+		webhookEvent.Amount = objectAmount(event.Data.Object, "amount")
+	case "account.updated":
+		webhookEvent.Account = accountStatus(event.Data.Object)
 	}
 
 	return webhookEvent, nil
 }
+
+// objectAmount reads an integer amount field and the object's currency from a
+// webhook payload. It returns the zero Amount if either is missing or invalid.
+func objectAmount(object map[string]interface{}, field string) money.Amount {
+	minor, ok := object[field].(float64)
+	if !ok {
+		return money.Amount{}
+	}
+	currency, _ := object["currency"].(string)
+	amount, err := money.New(int64(minor), currency)
+	if err != nil {
+		return money.Amount{}
+	}
+	return amount
+}
+
+// billingAddress reads the country and state of the customer's address from a
+// checkout session payload.
+func billingAddress(object map[string]interface{}) (string, string) {
+	details, _ := object["customer_details"].(map[string]interface{})
+	address, _ := details["address"].(map[string]interface{})
+	country, _ := address["country"].(string)
+	region, _ := address["state"].(string)
+	return country, region
+}
+
+// sessionDiscount reads the discount total and the promotion code of a checkout
+// session payload. Sessions take at most one discount.
+func sessionDiscount(object map[string]interface{}) (money.Amount, string) {
+	discount := totalDetail(object, "amount_discount")
+	discounts, _ := object["discounts"].([]interface{})
+	if len(discounts) == 0 {
+		return discount, ""
+	}
+	first, _ := discounts[0].(map[string]interface{})
+	switch promo := first["promotion_code"].(type) {
+	case string:
+		return discount, promo
+	case map[string]interface{}: // expanded
+		id, _ := promo["id"].(string)
+		return discount, id
+	}
+	return discount, ""
+}
+
+// totalDetail reads a positive amount from the total_details of a checkout
+// session payload, in the session's currency.
+func totalDetail(object map[string]interface{}, field string) money.Amount {
+	details, ok := object["total_details"].(map[string]interface{})
+	if !ok {
+		return money.Amount{}
+	}
+	minor, ok := details[field].(float64)
+	if !ok || minor <= 0 {
+		return money.Amount{}
+	}
+	currency, _ := object["currency"].(string)
+	amount, err := money.New(int64(minor), currency)
+	if err != nil {
+		return money.Amount{}
+	}
+	return amount
+}
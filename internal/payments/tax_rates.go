@@ -0,0 +1,54 @@
+package payments
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/stripe/stripe-go/v82"
+)
+
+// TaxRateParams describe a local tax rate to mirror at Stripe, so Checkout
+// shows it as a line item tax. Rates are tax-exclusive.
+type TaxRateParams struct {
+	Name    string
+	Country string
+	Region  string // ISO 3166-2 subdivision code without the country, e.g. CA
+	RateBps int64
+}
+
+// CreateTaxRate creates a Stripe tax rate and returns its ID. In mock mode it
+// returns a made-up ID.
+func (s *Service) CreateTaxRate(ctx context.Context, p TaxRateParams) (string, error) {
+	if s.cfg.SecretKey == "" {
+		return "txr_mock_" + uuid.New().String(), nil
+	}
+	params := &stripe.TaxRateCreateParams{
+		DisplayName: stripe.String(p.Name),
+		Percentage:  stripe.Float64(float64(p.RateBps) / 100),
+		Inclusive:   stripe.Bool(false),
+		Country:     stripe.String(p.Country),
+	}
+	if p.Region != "" {
+		params.State = stripe.String(p.Region)
+	}
+	r, err := s.client().V1TaxRates.Create(ctx, params)
+	if err != nil {
+		return "", fmt.Errorf("failed to create Stripe tax rate: %w", err)
+	}
+	return r.ID, nil
+}
+
+// ArchiveTaxRate stops a Stripe tax rate from being used in new sessions. Stripe
+// doesn't allow the percentage to be changed, so rates are archived and
+// replaced rather than updated.
+func (s *Service) ArchiveTaxRate(ctx context.Context, id string) error {
+	if s.cfg.SecretKey == "" || id == "" {
+		return nil
+	}
+	_, err := s.client().V1TaxRates.Update(ctx, id, &stripe.TaxRateUpdateParams{Active: stripe.Bool(false)})
+	if err != nil {
+		return fmt.Errorf("failed to archive Stripe tax rate %s: %w", id, err)
+	}
+	return nil
+}
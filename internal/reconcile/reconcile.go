@@ -0,0 +1,441 @@
+// Package reconcile compares transactions with the checkout sessions, payment
+// intents and refunds Stripe reports for a time window, and optionally repairs
+// transactions whose status drifted from Stripe's. Sweeper settles transactions
+// left pending after their checkout session expired.
+package reconcile
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"stripe-go-spike/internal/audit"
+	"stripe-go-spike/internal/db"
+	"stripe-go-spike/internal/ledger"
+	"stripe-go-spike/internal/money"
+	"stripe-go-spike/internal/outbound"
+	"stripe-go-spike/internal/payments"
+)
+
+// Mismatch kinds
+const (
+	// StatusDrift: the transaction status differs from the one Stripe implies
+	StatusDrift = "status_drift"
+	// MissingPaymentIntent: Stripe has a payment intent the transaction doesn't reference
+	MissingPaymentIntent = "missing_payment_intent"
+	// AmountMismatch: Stripe charged a different amount or currency
+	AmountMismatch = "amount_mismatch"
+	// MissingTransaction: a Stripe payment has no matching transaction
+	MissingTransaction = "missing_transaction"
+	// MissingAtStripe: a pending transaction's checkout session is unknown to Stripe
+	MissingAtStripe = "missing_at_stripe"
+	// StalePending: a transaction pending for longer than a checkout session lives
+	StalePending = "stale_pending"
+)
+
+// pageSize is the number of transactions read per query.
+const pageSize = 100
+
+// Mismatch is one difference between the database and Stripe.
+type Mismatch struct {
+	Kind            string `json:"kind"`
+	TransactionID   string `json:"transaction_id,omitempty"`
+	SessionID       string `json:"session_id,omitempty"`
+	PaymentIntentID string `json:"payment_intent_id,omitempty"`
+	LocalStatus     string `json:"local_status,omitempty"`
+	StripeStatus    string `json:"stripe_status,omitempty"` // status implied by the Stripe objects
+	Detail          string `json:"detail,omitempty"`
+	Repairable      bool   `json:"repairable"`
+	Repaired        bool   `json:"repaired"`
+	RepairError     string `json:"repair_error,omitempty"`
+}
+
+// Report is the outcome of one reconciliation run.
+type Report struct {
+	From           time.Time  `json:"from"`
+	To             time.Time  `json:"to"`
+	Sessions       int        `json:"sessions"`        // checkout sessions read from Stripe
+	PaymentIntents int        `json:"payment_intents"` // payment intents read from Stripe
+	Refunds        int        `json:"refunds"`         // refunds read from Stripe
+	Transactions   int        `json:"transactions"`    // transactions compared
+	Mismatches     []Mismatch `json:"mismatches"`
+}
+
+// Repaired returns the number of mismatches fixed during the run.
+func (r Report) Repaired() int {
+	n := 0
+	for _, m := range r.Mismatches {
+		if m.Repaired {
+			n++
+		}
+	}
+	return n
+}
+
+// Reconciler compares the database with Stripe through Gateway.
+type Reconciler struct {
+	Gateway payments.Gateway
+	Store   db.Repository
+	Audit   *audit.Service
+	// Repair moves drifted transactions forward to the status Stripe implies and
+	// fills in missing payment intents. Each fix commits with its ledger entries
+	// and a transaction.reconciled audit event.
+	Repair bool
+}
+
+// payment groups the Stripe objects of one checkout.
+type payment struct {
+	session *payments.RemoteCheckoutSession
+	intent  *payments.RemotePaymentIntent
+	refunds []payments.RemoteRefund
+}
+
+func (p *payment) sessionID() string {
+	if p.session != nil {
+		return p.session.ID
+	}
+	return ""
+}
+
+func (p *payment) paymentIntentID() string {
+	switch {
+	case p.intent != nil:
+		return p.intent.ID
+	case p.session != nil && p.session.PaymentIntentID != "":
+		return p.session.PaymentIntentID
+	case len(p.refunds) > 0:
+		return p.refunds[0].PaymentIntentID
+	}
+	return ""
+}
+
+// amount is what Stripe charged, if known.
+func (p *payment) amount() (money.Amount, bool) {
+	if p.session != nil && p.session.Amount.Currency != "" {
+		return p.session.Amount, true
+	}
+	if p.intent != nil && p.intent.Amount.Currency != "" {
+		return p.intent.Amount, true
+	}
+	return money.Amount{}, false
+}
+
+// status is the transaction status the Stripe objects imply.
+func (p *payment) status() string {
+	for _, r := range p.refunds {
+		if r.Status == "succeeded" || r.Status == "pending" {
+			return "refunded"
+		}
+	}
+	switch {
+	case p.intent != nil && p.intent.Status == "succeeded",
+		p.session != nil && p.session.PaymentStatus == "paid":
+		return "completed"
+	case p.intent != nil && p.intent.Status == "canceled",
+		p.session != nil && p.session.Status == "expired":
+		return "cancelled"
+	case p.intent != nil && p.intent.Status == "requires_payment_method" && p.intent.LastPaymentError != "":
+		return "failed"
+	}
+	return "pending"
+}
+
+// statusRank orders statuses by how far a payment has progressed. Repairs only
+// move transactions forward, so a dispute (refunded locally, completed at Stripe)
+// or a refund outside the window is not undone. The webhook status updates in
+// db/queries/transactions.sql guard their UPDATEs with the same order.
+var statusRank = map[string]int{"pending": 0, "failed": 1, "cancelled": 1, "completed": 2, "refunded": 3}
+
+// Run reconciles objects created in w. Gateway and database read errors abort
+// the run; repair failures are recorded on their mismatch.
+func (r *Reconciler) Run(ctx context.Context, w payments.Window) (Report, error) {
+	report := Report{From: w.From, To: w.To, Mismatches: []Mismatch{}}
+	err := r.run(ctx, w, &report)
+
+	payload := audit.ReconciliationPayload{
+		From:       db.NewTimestamp(w.From).String(),
+		To:         db.NewTimestamp(w.To).String(),
+		Checked:    report.Transactions,
+		Mismatches: len(report.Mismatches),
+		Repaired:   report.Repaired(),
+	}
+	if err != nil {
+		payload.Error = err.Error()
+	}
+	r.Audit.Log(ctx, audit.ReconciliationCompleted.New("Reconciliation against Stripe finished", payload))
+	return report, err
+}
+
+func (r *Reconciler) run(ctx context.Context, w payments.Window, report *Report) error {
+	found, sessions, err := r.collect(ctx, w, report)
+	if err != nil {
+		return err
+	}
+
+	seen := map[string]bool{} // transaction IDs compared
+	for _, p := range found {
+		txn, err := r.findTransaction(ctx, p)
+		if errors.Is(err, sql.ErrNoRows) {
+			report.Mismatches = append(report.Mismatches, Mismatch{
+				Kind:            MissingTransaction,
+				SessionID:       p.sessionID(),
+				PaymentIntentID: p.paymentIntentID(),
+				StripeStatus:    p.status(),
+				Detail:          "no transaction references this Stripe payment",
+			})
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		seen[txn.ID] = true
+		report.Transactions++
+		report.Mismatches = append(report.Mismatches, r.compare(ctx, txn, p)...)
+	}
+
+	// Pending transactions whose session Stripe doesn't list are stuck
+	for offset := int64(0); ; offset += pageSize {
+		txns, err := r.Store.ListTransactionsCreatedBetween(ctx, db.ListTransactionsCreatedBetweenParams{
+			CreatedAt:   db.NewTimestamp(w.From),
+			CreatedAt_2: db.NewTimestamp(w.To),
+			Limit:       pageSize,
+			Offset:      offset,
+		})
+		if err != nil {
+			return fmt.Errorf("list transactions: %w", err)
+		}
+		for _, txn := range txns {
+			if seen[txn.ID] || txn.Status != "pending" {
+				continue
+			}
+			report.Transactions++
+			if txn.StripeSessionID.Valid && !sessions[txn.StripeSessionID.String] {
+				report.Mismatches = append(report.Mismatches, Mismatch{
+					Kind:            MissingAtStripe,
+					TransactionID:   txn.ID,
+					SessionID:       txn.StripeSessionID.String,
+					PaymentIntentID: txn.StripePaymentIntentID.String,
+					LocalStatus:     txn.Status,
+					Detail:          "Stripe lists no checkout session for this pending transaction",
+				})
+			}
+		}
+		if len(txns) < pageSize {
+			return nil
+		}
+	}
+}
+
+// collect pages through the Stripe objects in w and groups them by checkout. It
+// also returns the IDs of the sessions seen.
+func (r *Reconciler) collect(ctx context.Context, w payments.Window, report *Report) ([]*payment, map[string]bool, error) {
+	var all []*payment
+	sessions := map[string]bool{}
+	byIntent := map[string]*payment{}
+
+	for s, err := range r.Gateway.CheckoutSessions(ctx, w) {
+		if err != nil {
+			return nil, nil, fmt.Errorf("list checkout sessions: %w", err)
+		}
+		report.Sessions++
+		p := &payment{session: &s}
+		all = append(all, p)
+		sessions[s.ID] = true
+		if s.PaymentIntentID != "" {
+			byIntent[s.PaymentIntentID] = p
+		}
+	}
+	for pi, err := range r.Gateway.PaymentIntents(ctx, w) {
+		if err != nil {
+			return nil, nil, fmt.Errorf("list payment intents: %w", err)
+		}
+		report.PaymentIntents++
+		p, ok := byIntent[pi.ID]
+		if !ok {
+			p = &payment{}
+			all = append(all, p)
+			byIntent[pi.ID] = p
+		}
+		p.intent = &pi
+	}
+	for refund, err := range r.Gateway.Refunds(ctx, w) {
+		if err != nil {
+			return nil, nil, fmt.Errorf("list refunds: %w", err)
+		}
+		report.Refunds++
+		p, ok := byIntent[refund.PaymentIntentID]
+		if !ok {
+			p = &payment{}
+			all = append(all, p)
+			byIntent[refund.PaymentIntentID] = p
+		}
+		p.refunds = append(p.refunds, refund)
+	}
+	return all, sessions, nil
+}
+
+// findTransaction looks a payment up by session, the transaction ID in the
+// session metadata, and payment intent, in that order.
+func (r *Reconciler) findTransaction(ctx context.Context, p *payment) (db.Transaction, error) {
+	if id := p.sessionID(); id != "" {
+		txn, err := r.Store.GetTransactionByStripeSessionID(ctx, sql.NullString{String: id, Valid: true})
+		if !errors.Is(err, sql.ErrNoRows) {
+			return txn, err
+		}
+	}
+	if p.session != nil && p.session.TransactionID != "" {
+		txn, err := r.Store.GetTransaction(ctx, p.session.TransactionID)
+		if !errors.Is(err, sql.ErrNoRows) {
+			return txn, err
+		}
+	}
+	if id := p.paymentIntentID(); id != "" {
+		return r.Store.GetTransactionByPaymentIntentID(ctx, sql.NullString{String: id, Valid: true})
+	}
+	return db.Transaction{}, sql.ErrNoRows
+}
+
+// compare returns the mismatches between txn and p, repairing them if enabled.
+func (r *Reconciler) compare(ctx context.Context, txn db.Transaction, p *payment) []Mismatch {
+	var mismatches []Mismatch
+	base := Mismatch{
+		TransactionID:   txn.ID,
+		SessionID:       txn.StripeSessionID.String,
+		PaymentIntentID: p.paymentIntentID(),
+		LocalStatus:     txn.Status,
+		StripeStatus:    p.status(),
+	}
+
+	if amount, ok := p.amount(); ok && (amount.Minor != txn.Amount || amount.Currency != txn.Currency) {
+		m := base
+		m.Kind = AmountMismatch
+		m.Detail = fmt.Sprintf("charged %s, transaction is for %s", amount, money.Amount{Minor: txn.Amount, Currency: txn.Currency})
+		mismatches = append(mismatches, m)
+	}
+
+	m := base
+	switch {
+	case base.StripeStatus != txn.Status && statusRank[base.StripeStatus] > statusRank[txn.Status]:
+		m.Kind = StatusDrift
+		m.Repairable = true
+	case base.StripeStatus != txn.Status && !(txn.Status == "refunded" && base.StripeStatus == "completed"):
+		m.Kind = StatusDrift
+		m.Detail = "transaction is ahead of Stripe; not repaired"
+	case base.PaymentIntentID != "" && !txn.StripePaymentIntentID.Valid:
+		m.Kind = MissingPaymentIntent
+		m.Repairable = true
+	default:
+		return mismatches
+	}
+	if m.Repairable && r.Repair {
+		changed, err := repair(ctx, r.Store, r.Audit, txn, p, m)
+		switch {
+		case err != nil:
+			m.RepairError = err.Error()
+		case !changed:
+			m.RepairError = "transaction changed during the run"
+		default:
+			m.Repaired = true
+		}
+	}
+	return append(mismatches, m)
+}
+
+// repair applies m to txn with its ledger entries and audit event in one unit of
+// work, and reports whether it did. A status change is also written to the
+// outbox, under the key a webhook reporting it would use, so downstream
+// consumers hear about it once. The update only applies while the stored
+// transaction still has txn's status; if a webhook or another repair changed it
+// first, nothing is written. Ledger entries already posted by webhooks are
+// skipped.
+func repair(ctx context.Context, store db.Repository, auditService *audit.Service, txn db.Transaction, p *payment, m Mismatch) (bool, error) {
+	status := txn.Status
+	if m.Kind == StatusDrift || m.Kind == StalePending {
+		status = m.StripeStatus
+	}
+	now := db.Now()
+	refundDate := txn.RefundDate
+	if status == "refunded" && !refundDate.Valid {
+		refundDate = db.NullTimestamp{Timestamp: now, Valid: true}
+	}
+	paymentIntentID := txn.StripePaymentIntentID
+	if m.PaymentIntentID != "" {
+		paymentIntentID = sql.NullString{String: m.PaymentIntentID, Valid: true}
+	}
+
+	var entries []ledger.Entry
+	if status == "completed" || status == "refunded" {
+		amount, ok := p.amount()
+		if !ok || amount.Currency != txn.Currency {
+			amount = money.Amount{Minor: txn.Amount, Currency: txn.Currency}
+		}
+		entries = append(entries, ledger.Charge(txn, amount, ""))
+		if txn.TransferAmount > 0 {
+			transfer := money.Amount{Minor: txn.TransferAmount, Currency: txn.Currency}
+			entries = append(entries, ledger.Transfer(txn, transfer, ""))
+		}
+	}
+	// The status event names the first refund, as its refund.created webhook would
+	var refundID string
+	if status == "refunded" {
+		for _, refund := range p.refunds {
+			if refund.Status == "succeeded" || refund.Status == "pending" {
+				entries = append(entries, ledger.Refund(txn, refund.Amount, refund.ID, ""))
+				if refundID == "" {
+					refundID = refund.ID
+				}
+			}
+		}
+	}
+
+	var logged db.AuditEvent
+	var changed bool
+	err := store.WithTx(ctx, func(q db.Querier) error {
+		n, err := q.RepairTransaction(ctx, db.RepairTransactionParams{
+			Status:                status,
+			StripePaymentIntentID: paymentIntentID,
+			RefundDate:            refundDate,
+			UpdatedAt:             now,
+			ID:                    txn.ID,
+			Status_2:              txn.Status,
+		})
+		if err != nil || n == 0 {
+			return err
+		}
+		changed = true
+		for _, entry := range entries {
+			if _, err := ledger.Post(ctx, q, entry); err != nil && !errors.Is(err, ledger.ErrDuplicate) {
+				return err
+			}
+		}
+		if status != txn.Status {
+			repaired, err := q.GetTransaction(ctx, txn.ID)
+			if err != nil {
+				return err
+			}
+			if err := outbound.QueueTransactionEvent(ctx, q, repaired, outbound.TransactionEventKey(txn.ID, status, refundID)); err != nil {
+				return err
+			}
+		}
+		logged, err = auditService.LogTx(ctx, q, audit.TransactionReconciled.New(
+			"Transaction repaired to match Stripe",
+			audit.TransactionReconciledPayload{
+				TransactionID:   txn.ID,
+				SessionID:       m.SessionID,
+				PaymentIntentID: m.PaymentIntentID,
+				Mismatch:        m.Kind,
+				FromStatus:      txn.Status,
+				ToStatus:        status,
+				Detail:          m.Detail,
+			},
+		).WithUser(txn.UserID).WithRefs(paymentIntentID.String, m.SessionID))
+		return err
+	})
+	if err != nil || !changed {
+		return false, err
+	}
+	auditService.Publish(logged)
+	return true, nil
+}
@@ -0,0 +1,145 @@
+package reconcile
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"stripe-go-spike/internal/audit"
+	"stripe-go-spike/internal/db"
+	"stripe-go-spike/internal/money"
+	"stripe-go-spike/internal/payments"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconcile(t *testing.T) {
+	database, err := db.NewTestConnection()
+	require.NoError(t, err)
+	defer database.Close()
+	queries := db.New(database)
+	ctx := context.Background()
+
+	now := time.Now().UTC()
+	window := payments.Window{From: now.Add(-time.Hour), To: now.Add(time.Minute)}
+	created := now.Add(-10 * time.Minute)
+
+	addTxn := func(id, status, paymentIntentID string) {
+		require.NoError(t, queries.CreateTransaction(ctx, db.CreateTransactionParams{
+			ID:                    id,
+			UserID:                "luke",
+			ProductID:             "lumaweave",
+			ProductName:           "LumaWeave Reactive Threads",
+			Amount:                4999,
+			Currency:              "USD",
+			StripeSessionID:       sql.NullString{String: "cs_" + id, Valid: true},
+			StripePaymentIntentID: sql.NullString{String: paymentIntentID, Valid: paymentIntentID != ""},
+			Status:                status,
+			CreatedAt:             db.NewTimestamp(created),
+			UpdatedAt:             db.NewTimestamp(created),
+		}))
+	}
+	usd := money.MustNew(4999, "USD")
+	gateway := payments.NewFakeGateway()
+	session := func(id, status, paymentStatus, paymentIntentID string, amount money.Amount) {
+		gateway.AddCheckoutSession(payments.RemoteCheckoutSession{ID: "cs_" + id, PaymentIntentID: paymentIntentID,
+			Status: status, PaymentStatus: paymentStatus, Amount: amount, Created: created})
+	}
+
+	// Paid at Stripe, but the webhook never arrived (and didn't set the payment intent)
+	addTxn("paid", "pending", "")
+	session("paid", "complete", "paid", "pi_paid", usd)
+	gateway.AddPaymentIntent(payments.RemotePaymentIntent{ID: "pi_paid", Status: "succeeded", Amount: usd, Created: created})
+	// Refunded at Stripe, still completed here
+	addTxn("refunded", "completed", "pi_refunded")
+	session("refunded", "complete", "paid", "pi_refunded", usd)
+	gateway.AddRefund(payments.RemoteRefund{ID: "re_1", PaymentIntentID: "pi_refunded", Status: "succeeded", Amount: money.MustNew(1000, "USD"), Created: created})
+	// In sync: an open session and a disputed payment
+	addTxn("open", "pending", "")
+	session("open", "open", "unpaid", "", usd)
+	addTxn("disputed", "refunded", "pi_disputed")
+	session("disputed", "complete", "paid", "pi_disputed", usd)
+	// Stripe charged a different amount
+	addTxn("amount", "completed", "pi_amount")
+	session("amount", "complete", "paid", "pi_amount", money.MustNew(5999, "USD"))
+	// Only on one side
+	addTxn("lost", "pending", "")
+	session("unknown", "complete", "paid", "pi_unknown", usd)
+
+	reconciler := &Reconciler{Gateway: gateway, Store: db.NewStore(database, queries), Audit: audit.NewService(queries, audit.WithStrictTypes())}
+	report, err := reconciler.Run(ctx, window)
+	require.NoError(t, err)
+	assert.Equal(t, 6, report.Sessions)
+	assert.Equal(t, 6, report.Transactions)
+	assert.Greater(t, gateway.Pages(), 3, "sessions should be read in several pages")
+
+	type key struct{ kind, txn string }
+	found := map[key]Mismatch{}
+	for _, m := range report.Mismatches {
+		found[key{m.Kind, m.TransactionID}] = m
+	}
+	assert.Len(t, found, 5)
+	assert.Equal(t, "completed", found[key{StatusDrift, "paid"}].StripeStatus)
+	assert.True(t, found[key{StatusDrift, "paid"}].Repairable)
+	assert.Equal(t, "refunded", found[key{StatusDrift, "refunded"}].StripeStatus)
+	assert.Contains(t, found[key{AmountMismatch, "amount"}].Detail, "59.99 USD")
+	assert.Equal(t, "cs_lost", found[key{MissingAtStripe, "lost"}].SessionID)
+	assert.Equal(t, "cs_unknown", found[key{MissingTransaction, ""}].SessionID)
+	assert.Zero(t, report.Repaired())
+
+	txn, err := queries.GetTransaction(ctx, "paid")
+	require.NoError(t, err)
+	assert.Equal(t, "pending", txn.Status, "a dry run changes nothing")
+
+	reconciler.Repair = true
+	report, err = reconciler.Run(ctx, window)
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Repaired())
+
+	txn, err = queries.GetTransaction(ctx, "paid")
+	require.NoError(t, err)
+	assert.Equal(t, "completed", txn.Status)
+	assert.Equal(t, "pi_paid", txn.StripePaymentIntentID.String)
+	txn, err = queries.GetTransaction(ctx, "refunded")
+	require.NoError(t, err)
+	assert.Equal(t, "refunded", txn.Status)
+	assert.True(t, txn.RefundDate.Valid)
+
+	entries, err := queries.ListJournalEntriesByTransactionID(ctx, sql.NullString{String: "refunded", Valid: true})
+	require.NoError(t, err)
+	assert.Len(t, entries, 2, "charge and refund")
+	fixes, err := queries.GetAuditEventsByEventType(ctx, db.GetAuditEventsByEventTypeParams{
+		EventType: audit.TransactionReconciled.Definition().EventType, Limit: 10})
+	require.NoError(t, err)
+	assert.Len(t, fixes, 2)
+
+	// Once repaired, only the differences that need a person remain
+	report, err = reconciler.Run(ctx, window)
+	require.NoError(t, err)
+	assert.Len(t, report.Mismatches, 3)
+	assert.Zero(t, report.Repaired())
+}
+
+func TestReconcileGatewayError(t *testing.T) {
+	database, err := db.NewTestConnection()
+	require.NoError(t, err)
+	defer database.Close()
+	queries := db.New(database)
+
+	gateway := payments.NewFakeGateway()
+	boom := errors.New("stripe unavailable")
+	gateway.FailWith(boom)
+	reconciler := &Reconciler{Gateway: gateway, Store: db.NewStore(database, queries), Audit: audit.NewService(queries, audit.WithStrictTypes())}
+	_, err = reconciler.Run(context.Background(), payments.Window{From: time.Now().Add(-time.Hour), To: time.Now()})
+	assert.ErrorIs(t, err, boom)
+
+	runs, err := queries.GetAuditEventsByEventType(context.Background(), db.GetAuditEventsByEventTypeParams{
+		EventType: audit.ReconciliationCompleted.Definition().EventType, Limit: 10})
+	require.NoError(t, err)
+	if assert.Len(t, runs, 1) {
+		assert.Contains(t, runs[0].Payload.String, "stripe unavailable")
+	}
+}
@@ -0,0 +1,43 @@
+package reconcile
+
+import (
+	"context"
+
+	"stripe-go-spike/internal/audit"
+	"stripe-go-spike/internal/db"
+	"stripe-go-spike/internal/payments"
+)
+
+// SyncSession moves txn forward to the status its checkout session implies, with
+// the ledger entries and transaction.reconciled audit event of a repair, and
+// returns the transaction as stored afterwards and whether this call changed it.
+// A transaction already at or past that status, e.g. because the webhook arrived
+// first, is returned unchanged, so syncing is safe to repeat and to run
+// concurrently.
+func SyncSession(ctx context.Context, store db.Repository, auditService *audit.Service, txn db.Transaction, session payments.RemoteCheckoutSession) (db.Transaction, bool, error) {
+	p := &payment{session: &session}
+	status := p.status()
+	if statusRank[status] <= statusRank[txn.Status] {
+		return txn, false, nil
+	}
+
+	m := Mismatch{
+		Kind:            StatusDrift,
+		TransactionID:   txn.ID,
+		SessionID:       session.ID,
+		PaymentIntentID: p.paymentIntentID(),
+		LocalStatus:     txn.Status,
+		StripeStatus:    status,
+		Detail:          "checkout session verified before its webhook arrived",
+		Repairable:      true,
+	}
+	changed, err := repair(ctx, store, auditService, txn, p, m)
+	if err != nil {
+		return txn, false, err
+	}
+	updated, err := store.GetTransaction(ctx, txn.ID)
+	if err != nil {
+		return txn, changed, err
+	}
+	return updated, changed, nil
+}
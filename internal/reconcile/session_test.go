@@ -0,0 +1,78 @@
+package reconcile
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"testing"
+	"time"
+
+	"stripe-go-spike/internal/audit"
+	"stripe-go-spike/internal/db"
+	"stripe-go-spike/internal/money"
+	"stripe-go-spike/internal/payments"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncSessionConcurrentCalls(t *testing.T) {
+	database, err := db.NewTestConnection()
+	require.NoError(t, err)
+	defer database.Close()
+	queries := db.New(database)
+	ctx := context.Background()
+
+	created := time.Now().UTC().Add(-time.Minute)
+	require.NoError(t, queries.CreateTransaction(ctx, db.CreateTransactionParams{
+		ID:              "paid",
+		UserID:          "luke",
+		ProductID:       "lumaweave",
+		ProductName:     "LumaWeave Reactive Threads",
+		Amount:          4999,
+		Currency:        "USD",
+		StripeSessionID: sql.NullString{String: "cs_paid", Valid: true},
+		Status:          "pending",
+		CreatedAt:       db.NewTimestamp(created),
+		UpdatedAt:       db.NewTimestamp(created),
+	}))
+	txn, err := queries.GetTransaction(ctx, "paid")
+	require.NoError(t, err)
+	session := payments.RemoteCheckoutSession{ID: "cs_paid", PaymentIntentID: "pi_paid", Status: "complete",
+		PaymentStatus: "paid", Amount: money.MustNew(4999, "USD"), Created: created}
+
+	store := db.NewStore(database, queries)
+	auditService := audit.NewService(queries, audit.WithStrictTypes())
+
+	// Every caller read the transaction while it was pending; only one moves it
+	const callers = 8
+	var wg sync.WaitGroup
+	changed := make(chan bool, callers)
+	for range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			updated, ok, err := SyncSession(ctx, store, auditService, txn, session)
+			assert.NoError(t, err)
+			assert.Equal(t, "completed", updated.Status)
+			changed <- ok
+		}()
+	}
+	wg.Wait()
+	close(changed)
+	n := 0
+	for ok := range changed {
+		if ok {
+			n++
+		}
+	}
+	assert.Equal(t, 1, n, "exactly one call changes the transaction")
+
+	entries, err := queries.ListJournalEntriesByTransactionID(ctx, sql.NullString{String: "paid", Valid: true})
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "the charge is posted once")
+	fixes, err := queries.GetAuditEventsByEventType(ctx, db.GetAuditEventsByEventTypeParams{
+		EventType: audit.TransactionReconciled.Definition().EventType, Limit: 10})
+	require.NoError(t, err)
+	assert.Len(t, fixes, 1, "the repair is audited once")
+}
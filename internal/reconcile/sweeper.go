@@ -0,0 +1,129 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"stripe-go-spike/internal/audit"
+	"stripe-go-spike/internal/db"
+	"stripe-go-spike/internal/payments"
+)
+
+// Sweeper settles transactions left pending after their checkout session can no
+// longer be completed, e.g. because the expiry webhook was lost. Each one is
+// checked against its session through Gateway: paid sessions complete the
+// transaction, expired or unknown ones cancel it, and sessions still open are left
+// alone. Every change commits with its ledger entries and a
+// transaction.reconciled audit event.
+type Sweeper struct {
+	Gateway payments.Gateway
+	Store   db.Repository
+	Audit   *audit.Service
+	// TTL is the age after which a pending transaction is checked
+	// (default payments.DefaultSessionTTL).
+	TTL time.Duration
+}
+
+// SweepResult counts the transactions handled by one sweep.
+type SweepResult struct {
+	Checked   int `json:"checked"`
+	Completed int `json:"completed"`
+	Cancelled int `json:"cancelled"`
+	Failed    int `json:"failed"` // changes that could not be saved
+}
+
+// Run checks every transaction that has been pending for longer than the TTL.
+// Gateway and database read errors abort the sweep.
+func (s *Sweeper) Run(ctx context.Context) (SweepResult, error) {
+	var result SweepResult
+	ttl := s.TTL
+	if ttl <= 0 {
+		ttl = payments.DefaultSessionTTL
+	}
+	cutoff := db.NewTimestamp(time.Now().Add(-ttl))
+
+	// Settled transactions drop out of the query, so only the ones left pending
+	// move the offset
+	for offset := int64(0); ; {
+		txns, err := s.Store.ListPendingTransactionsCreatedBefore(ctx, db.ListPendingTransactionsCreatedBeforeParams{
+			CreatedAt: cutoff,
+			Limit:     pageSize,
+			Offset:    offset,
+		})
+		if err != nil {
+			return result, fmt.Errorf("list pending transactions: %w", err)
+		}
+		for _, txn := range txns {
+			result.Checked++
+			settled, err := s.sweep(ctx, txn, &result)
+			if err != nil {
+				return result, err
+			}
+			if !settled {
+				offset++
+			}
+		}
+		if len(txns) < pageSize {
+			return result, nil
+		}
+	}
+}
+
+// sweep settles txn if its session is paid, expired or unknown, and reports
+// whether the transaction left the pending state.
+func (s *Sweeper) sweep(ctx context.Context, txn db.Transaction, result *SweepResult) (bool, error) {
+	m := Mismatch{
+		Kind:            StalePending,
+		TransactionID:   txn.ID,
+		SessionID:       txn.StripeSessionID.String,
+		PaymentIntentID: txn.StripePaymentIntentID.String,
+		LocalStatus:     txn.Status,
+		Repairable:      true,
+	}
+	p := &payment{}
+
+	if !txn.StripeSessionID.Valid {
+		m.StripeStatus = "cancelled"
+		m.Detail = "no checkout session was created"
+	} else {
+		session, err := s.Gateway.CheckoutSession(ctx, txn.StripeSessionID.String)
+		switch {
+		case errors.Is(err, payments.ErrNotFound):
+			m.StripeStatus = "cancelled"
+			m.Detail = "checkout session not found at Stripe"
+		case err != nil:
+			return false, fmt.Errorf("checkout session for %s: %w", txn.ID, err)
+		default:
+			p.session = &session
+			if session.PaymentIntentID != "" {
+				m.PaymentIntentID = session.PaymentIntentID
+			}
+			m.StripeStatus = p.status()
+			m.Detail = fmt.Sprintf("checkout session is %s (%s)", session.Status, session.PaymentStatus)
+		}
+	}
+	if m.StripeStatus == "pending" {
+		return false, nil
+	}
+
+	changed, err := repair(ctx, s.Store, s.Audit, txn, p, m)
+	if err != nil {
+		log.Printf("sweep: failed to settle transaction %s: %v", txn.ID, err)
+		result.Failed++
+		return false, nil
+	}
+	if !changed {
+		// Settled by a webhook meanwhile
+		return true, nil
+	}
+	switch m.StripeStatus {
+	case "completed":
+		result.Completed++
+	case "cancelled":
+		result.Cancelled++
+	}
+	return true, nil
+}
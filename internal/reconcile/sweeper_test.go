@@ -0,0 +1,140 @@
+package reconcile
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"stripe-go-spike/internal/audit"
+	"stripe-go-spike/internal/db"
+	"stripe-go-spike/internal/money"
+	"stripe-go-spike/internal/outbound"
+	"stripe-go-spike/internal/payments"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSweeper(t *testing.T) {
+	database, err := db.NewTestConnection()
+	require.NoError(t, err)
+	defer database.Close()
+	queries := db.New(database)
+	ctx := context.Background()
+
+	now := time.Now().UTC()
+	old := now.Add(-25 * time.Hour)
+	addTxn := func(id, status, sessionID string, created time.Time) {
+		require.NoError(t, queries.CreateTransaction(ctx, db.CreateTransactionParams{
+			ID:              id,
+			UserID:          "luke",
+			ProductID:       "lumaweave",
+			ProductName:     "LumaWeave Reactive Threads",
+			Amount:          4999,
+			Currency:        "USD",
+			StripeSessionID: sql.NullString{String: sessionID, Valid: sessionID != ""},
+			Status:          status,
+			CreatedAt:       db.NewTimestamp(created),
+			UpdatedAt:       db.NewTimestamp(created),
+		}))
+	}
+	usd := money.MustNew(4999, "USD")
+	gateway := payments.NewFakeGateway()
+	session := func(id, status, paymentStatus, paymentIntentID string, expiresAt time.Time) {
+		gateway.AddCheckoutSession(payments.RemoteCheckoutSession{ID: id, PaymentIntentID: paymentIntentID,
+			Status: status, PaymentStatus: paymentStatus, Amount: usd, Created: old, ExpiresAt: expiresAt})
+	}
+
+	addTxn("paid", "pending", "cs_paid", old)
+	session("cs_paid", "complete", "paid", "pi_paid", old.Add(24*time.Hour))
+	addTxn("expired", "pending", "cs_expired", old)
+	session("cs_expired", "open", "unpaid", "", old.Add(24*time.Hour)) // past ExpiresAt, so reported expired
+	addTxn("still-open", "pending", "cs_open", old)
+	session("cs_open", "open", "unpaid", "", now.Add(time.Hour))
+	addTxn("unknown", "pending", "cs_unknown", old)
+	addTxn("no-session", "pending", "", old)
+	addTxn("recent", "pending", "cs_recent", now.Add(-time.Hour))
+	addTxn("done", "completed", "cs_done", old)
+
+	sweeper := &Sweeper{Gateway: gateway, Store: db.NewStore(database, queries), Audit: audit.NewService(queries, audit.WithStrictTypes())}
+	result, err := sweeper.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, SweepResult{Checked: 5, Completed: 1, Cancelled: 3}, result)
+
+	for id, status := range map[string]string{
+		"paid": "completed", "expired": "cancelled", "unknown": "cancelled", "no-session": "cancelled",
+		"still-open": "pending", "recent": "pending", "done": "completed",
+	} {
+		txn, err := queries.GetTransaction(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, status, txn.Status, id)
+	}
+	txn, err := queries.GetTransaction(ctx, "paid")
+	require.NoError(t, err)
+	assert.Equal(t, "pi_paid", txn.StripePaymentIntentID.String)
+	entries, err := queries.ListJournalEntriesByTransactionID(ctx, sql.NullString{String: "paid", Valid: true})
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "the charge is posted")
+
+	fixes, err := queries.GetAuditEventsByEventType(ctx, db.GetAuditEventsByEventTypeParams{
+		EventType: audit.TransactionReconciled.Definition().EventType, Limit: 10})
+	require.NoError(t, err)
+	assert.Len(t, fixes, 4)
+
+	// Each settled transaction is written to the outbox once, under the key its
+	// webhook would use, so a late webhook adds nothing
+	require.NoError(t, outbound.QueueTransactionEvent(ctx, queries, txn, outbound.TransactionEventKey("paid", "completed", "evt_late")))
+	messages, err := queries.ListDueOutboxMessages(ctx, db.ListDueOutboxMessagesParams{NextAttemptAt: db.Now(), Limit: 10})
+	require.NoError(t, err)
+	topics := map[string]string{}
+	for _, m := range messages {
+		assert.Equal(t, m.MessageKey+":"+strings.TrimPrefix(m.Topic, "transaction."), m.DedupeKey)
+		topics[m.MessageKey] = m.Topic
+	}
+	assert.Equal(t, map[string]string{
+		"paid": "transaction.completed", "expired": "transaction.cancelled",
+		"unknown": "transaction.cancelled", "no-session": "transaction.cancelled",
+	}, topics)
+	assert.Len(t, messages, 4)
+
+	// Only the open session is checked again
+	result, err = sweeper.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, SweepResult{Checked: 1}, result)
+}
+
+func TestSweeperGatewayError(t *testing.T) {
+	database, err := db.NewTestConnection()
+	require.NoError(t, err)
+	defer database.Close()
+	queries := db.New(database)
+	ctx := context.Background()
+
+	created := time.Now().Add(-48 * time.Hour)
+	require.NoError(t, queries.CreateTransaction(ctx, db.CreateTransactionParams{
+		ID:              "stale",
+		UserID:          "luke",
+		ProductID:       "lumaweave",
+		ProductName:     "LumaWeave Reactive Threads",
+		Amount:          4999,
+		Currency:        "USD",
+		StripeSessionID: sql.NullString{String: "cs_stale", Valid: true},
+		Status:          "pending",
+		CreatedAt:       db.NewTimestamp(created),
+		UpdatedAt:       db.NewTimestamp(created),
+	}))
+
+	gateway := payments.NewFakeGateway()
+	boom := errors.New("stripe unavailable")
+	gateway.FailWith(boom)
+	sweeper := &Sweeper{Gateway: gateway, Store: db.NewStore(database, queries), Audit: audit.NewService(queries, audit.WithStrictTypes())}
+	_, err = sweeper.Run(ctx)
+	assert.ErrorIs(t, err, boom)
+
+	txn, err := queries.GetTransaction(ctx, "stale")
+	require.NoError(t, err)
+	assert.Equal(t, "pending", txn.Status, "an unreachable gateway doesn't cancel anything")
+}
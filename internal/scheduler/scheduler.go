@@ -0,0 +1,77 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// JobFunc is one run of a periodic job.
+type JobFunc func(ctx context.Context) error
+
+type job struct {
+	name     string
+	interval time.Duration
+	run      JobFunc
+}
+
+// Scheduler runs registered jobs at fixed intervals in background goroutines.
+// Runs of the same job never overlap; a run that outlasts its interval delays the next one.
+type Scheduler struct {
+	jobs   []job
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New creates an empty scheduler.
+func New() *Scheduler {
+	return &Scheduler{}
+}
+
+// Every registers fn to run every interval, which must be positive. Jobs must be
+// registered before Start.
+func (s *Scheduler) Every(name string, interval time.Duration, fn JobFunc) error {
+	if interval <= 0 {
+		return fmt.Errorf("scheduler: job %s: interval must be positive, got %s", name, interval)
+	}
+	s.jobs = append(s.jobs, job{name: name, interval: interval, run: fn})
+	return nil
+}
+
+// Start launches all registered jobs. The first run of each job happens after one interval.
+func (s *Scheduler) Start(ctx context.Context) {
+	ctx, s.cancel = context.WithCancel(ctx)
+	for _, j := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(ctx, j)
+	}
+}
+
+// Stop cancels running jobs and waits for them to return.
+func (s *Scheduler) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, j job) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			start := time.Now()
+			if err := j.run(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("scheduler: job %s failed after %s: %v", j.name, time.Since(start).Round(time.Millisecond), err)
+			}
+		}
+	}
+}
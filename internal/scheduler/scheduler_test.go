@@ -0,0 +1,100 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEveryRejectsNonPositiveIntervals(t *testing.T) {
+	s := New()
+	noop := func(ctx context.Context) error { return nil }
+	assert.Error(t, s.Every("zero", 0, noop))
+	assert.Error(t, s.Every("negative", -time.Second, noop))
+	assert.Empty(t, s.jobs)
+
+	assert.NoError(t, s.Every("ok", time.Second, noop))
+	assert.Len(t, s.jobs, 1)
+}
+
+func TestSchedulerRunsJobsUntilStopped(t *testing.T) {
+	s := New()
+	var runs atomic.Int32
+	ran := make(chan struct{}, 1)
+	require.NoError(t, s.Every("tick", 5*time.Millisecond, func(ctx context.Context) error {
+		runs.Add(1)
+		select {
+		case ran <- struct{}{}:
+		default:
+		}
+		return errors.New("failures are logged, not fatal")
+	}))
+
+	s.Start(context.Background())
+	for range 3 {
+		select {
+		case <-ran:
+		case <-time.After(time.Second):
+			t.Fatal("job did not run")
+		}
+	}
+	s.Stop()
+
+	stopped := runs.Load()
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, stopped, runs.Load(), "job ran after Stop")
+}
+
+func TestSchedulerDoesNotOverlapRuns(t *testing.T) {
+	s := New()
+	var running, overlaps, runs atomic.Int32
+	require.NoError(t, s.Every("slow", time.Millisecond, func(ctx context.Context) error {
+		if running.Add(1) > 1 {
+			overlaps.Add(1)
+		}
+		time.Sleep(5 * time.Millisecond)
+		running.Add(-1)
+		runs.Add(1)
+		return nil
+	}))
+
+	s.Start(context.Background())
+	require.Eventually(t, func() bool { return runs.Load() >= 3 }, time.Second, time.Millisecond)
+	s.Stop()
+	assert.Zero(t, overlaps.Load())
+}
+
+func TestStopCancelsRunningJobs(t *testing.T) {
+	s := New()
+	started := make(chan struct{}, 1)
+	require.NoError(t, s.Every("blocking", time.Millisecond, func(ctx context.Context) error {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+
+	s.Start(context.Background())
+	<-started
+	done := make(chan struct{})
+	go func() {
+		s.Stop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Stop did not cancel the running job")
+	}
+}
+
+func TestStopWithoutStart(t *testing.T) {
+	New().Stop()
+}
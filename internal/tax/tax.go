@@ -0,0 +1,118 @@
+// Package tax picks local tax rates and computes the tax on a charge, for
+// deployments that don't use Stripe automatic tax. Prices are tax-exclusive:
+// tax is added on top of the price less any discount.
+package tax
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"stripe-go-spike/internal/db"
+	"stripe-go-spike/internal/money"
+)
+
+// ErrInvalidRate is returned for tax rates that can't be saved.
+var ErrInvalidRate = errors.New("invalid tax rate")
+
+// MaxRateBps is the highest rate accepted, 100%.
+const MaxRateBps = 10000
+
+var (
+	countryPattern = regexp.MustCompile(`^[A-Z]{2}$`)
+	regionPattern  = regexp.MustCompile(`^[A-Z0-9]{1,3}$`)
+	// Stripe product tax codes, e.g. txcd_99999999
+	taxCodePattern = regexp.MustCompile(`^txcd_\d{8}$`)
+)
+
+// Rate is a tax rate definition. Region and TaxCode are empty to match any.
+type Rate struct {
+	Country string
+	Region  string
+	TaxCode string
+	Name    string
+	RateBps int64 // basis points: 1900 is 19%
+}
+
+// Normalize returns the rate with country and region in upper case.
+func (r Rate) Normalize() Rate {
+	r.Country = strings.ToUpper(strings.TrimSpace(r.Country))
+	r.Region = strings.ToUpper(strings.TrimSpace(r.Region))
+	r.TaxCode = strings.TrimSpace(r.TaxCode)
+	r.Name = strings.TrimSpace(r.Name)
+	return r
+}
+
+// Validate checks a normalized rate.
+func (r Rate) Validate() error {
+	switch {
+	case !countryPattern.MatchString(r.Country):
+		return fmt.Errorf("%w: country must be an ISO 3166-1 alpha-2 code", ErrInvalidRate)
+	case r.Region != "" && !regionPattern.MatchString(r.Region):
+		return fmt.Errorf("%w: region must be an ISO 3166-2 subdivision code such as CA", ErrInvalidRate)
+	case r.TaxCode != "" && !taxCodePattern.MatchString(r.TaxCode):
+		return fmt.Errorf("%w: tax code must be a Stripe product tax code such as txcd_99999999", ErrInvalidRate)
+	case r.Name == "" || len(r.Name) > 50:
+		return fmt.Errorf("%w: name must be 1-50 characters", ErrInvalidRate)
+	case r.RateBps < 0 || r.RateBps > MaxRateBps:
+		return fmt.Errorf("%w: rate must be between 0 and %d basis points", ErrInvalidRate, MaxRateBps)
+	}
+	return nil
+}
+
+// Percentage formats basis points as a percentage, e.g. 825 as "8.25".
+func Percentage(bps int64) string {
+	s := fmt.Sprintf("%d.%02d", bps/100, bps%100)
+	return strings.TrimSuffix(strings.TrimRight(s, "0"), ".")
+}
+
+// Select returns the most specific of rates for a region and product tax code:
+// region and tax code, then region, then tax code, then the whole country.
+func Select(rates []db.TaxRate, region, taxCode string) (db.TaxRate, bool) {
+	region = strings.ToUpper(region)
+	best, bestScore := db.TaxRate{}, -1
+	for _, r := range rates {
+		if (r.Region != "" && r.Region != region) || (r.TaxCode != "" && r.TaxCode != taxCode) {
+			continue
+		}
+		score := 0
+		if r.Region != "" {
+			score += 2
+		}
+		if r.TaxCode != "" {
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = r, score
+		}
+	}
+	return best, bestScore >= 0
+}
+
+// Lookup returns the active rate for a country, region and product tax code.
+// There is none for an empty country.
+func Lookup(ctx context.Context, q db.Querier, country, region, taxCode string) (db.TaxRate, bool, error) {
+	if country == "" {
+		return db.TaxRate{}, false, nil
+	}
+	rates, err := q.ListActiveTaxRatesByCountry(ctx, strings.ToUpper(country))
+	if err != nil {
+		return db.TaxRate{}, false, err
+	}
+	rate, ok := Select(rates, region, taxCode)
+	return rate, ok, nil
+}
+
+// InUse reports whether any local tax rate is active.
+func InUse(ctx context.Context, q db.Querier) (bool, error) {
+	n, err := q.CountActiveTaxRates(ctx)
+	return n > 0, err
+}
+
+// Compute returns the tax on a tax-exclusive amount, rounded half up to the
+// currency's minor unit as Stripe does for a single line item.
+func Compute(amount money.Amount, rateBps int64) money.Amount {
+	return money.Amount{Minor: (amount.Minor*rateBps + 5000) / 10000, Currency: amount.Currency}
+}
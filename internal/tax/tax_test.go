@@ -0,0 +1,62 @@
+package tax
+
+import (
+	"testing"
+
+	"stripe-go-spike/internal/db"
+	"stripe-go-spike/internal/money"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectPrefersTheMostSpecificRate(t *testing.T) {
+	rates := []db.TaxRate{
+		{ID: 1, Country: "US", Name: "Sales tax", RateBps: 500},
+		{ID: 2, Country: "US", TaxCode: "txcd_10000000", Name: "Digital goods", RateBps: 0},
+		{ID: 3, Country: "US", Region: "CA", Name: "CA sales tax", RateBps: 725},
+		{ID: 4, Country: "US", Region: "CA", TaxCode: "txcd_10000000", Name: "CA digital goods", RateBps: 100},
+	}
+	for _, tc := range []struct {
+		region, taxCode string
+		want            int64
+	}{
+		{"", "txcd_99999999", 1},
+		{"NY", "txcd_99999999", 1},
+		{"NY", "txcd_10000000", 2},
+		{"ca", "txcd_99999999", 3},
+		{"CA", "txcd_10000000", 4},
+	} {
+		rate, ok := Select(rates, tc.region, tc.taxCode)
+		assert.True(t, ok)
+		assert.Equal(t, tc.want, rate.ID, "%s %s", tc.region, tc.taxCode)
+	}
+
+	_, ok := Select(rates[3:], "NY", "txcd_10000000")
+	assert.False(t, ok)
+}
+
+func TestCompute(t *testing.T) {
+	assert.Equal(t, money.MustNew(950, "USD"), Compute(money.MustNew(4999, "USD"), 1900), "949.81 rounds up")
+	assert.Equal(t, money.MustNew(362, "USD"), Compute(money.MustNew(4999, "USD"), 725), "362.43 rounds down")
+	assert.Equal(t, money.MustNew(750, "JPY"), Compute(money.MustNew(7500, "JPY"), 1000))
+	assert.True(t, Compute(money.MustNew(4999, "USD"), 0).IsZero())
+}
+
+func TestRateValidate(t *testing.T) {
+	valid := Rate{Country: " de ", Name: "VAT", RateBps: 1900}.Normalize()
+	assert.Equal(t, "DE", valid.Country)
+	assert.NoError(t, valid.Validate())
+
+	for name, r := range map[string]Rate{
+		"country":  {Country: "DEU", Name: "VAT", RateBps: 1900},
+		"region":   {Country: "US", Region: "CA-1", Name: "Sales tax", RateBps: 725},
+		"tax code": {Country: "US", TaxCode: "general", Name: "Sales tax", RateBps: 725},
+		"name":     {Country: "US", RateBps: 725},
+		"rate":     {Country: "US", Name: "Sales tax", RateBps: 10001},
+	} {
+		assert.ErrorIs(t, r.Validate(), ErrInvalidRate, name)
+	}
+	assert.Equal(t, "8.25", Percentage(825))
+	assert.Equal(t, "19", Percentage(1900))
+	assert.Equal(t, "7.5", Percentage(750))
+}